@@ -0,0 +1,330 @@
+// Package client is a typed Go client of the phonebook API, so consumers
+// don't have to write the requests and decode the envelopes themselves.
+//
+//	c := client.New("https://phonebook.example.com", client.WithAPIKey(key))
+//	contacts, total, err := c.List(ctx, client.ListOptions{Take: 50})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// sessionHeader and tenantHeader match routing.SessionHeader and
+	// routing.TenantHeader
+	sessionHeader = "X-Session-Id"
+	tenantHeader  = "X-Tenant-Id"
+
+	DefaultRetries = 2
+	DefaultBackoff = 500 * time.Millisecond
+	// maxBackoff caps the exponential backoff and Retry-After
+	maxBackoff = 30 * time.Second
+)
+
+// Client calls the API of one server. It is safe for concurrent use
+// except for Login and Logout, which change the session of the client.
+type Client struct {
+	baseURL   string
+	http      *http.Client
+	userAgent string
+	tenant    string
+	retries   int
+	backoff   time.Duration
+
+	// one of the credentials is sent, a session wins over the others
+	session  string
+	apiKey   string
+	user     string
+	password string
+
+	// sleep waits between attempts, replaced by tests
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// New returns a client of the server at baseURL, such as
+// http://localhost:3030.
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		http:      &http.Client{Timeout: time.Minute},
+		userAgent: "phonebook-client",
+		retries:   DefaultRetries,
+		backoff:   DefaultBackoff,
+		sleep:     sleep,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithHTTPClient sends the requests with h, for custom transports,
+// proxies or client certificates.
+func WithHTTPClient(h *http.Client) Option {
+	return func(c *Client) { c.http = h }
+}
+
+// WithAPIKey authenticates with an API key as bearer token.
+func WithAPIKey(key string) Option {
+	return func(c *Client) { c.apiKey = key }
+}
+
+// WithSession authenticates with the session id of a login, see Login.
+func WithSession(id string) Option {
+	return func(c *Client) { c.session = id }
+}
+
+// WithBasicAuth sends user and password with every request. The API
+// accepts them on CardDAV only, use Login for the others.
+func WithBasicAuth(user, password string) Option {
+	return func(c *Client) { c.user, c.password = user, password }
+}
+
+// WithTenant acts in the named tenant, for users who may switch tenants.
+func WithTenant(id string) Option {
+	return func(c *Client) { c.tenant = id }
+}
+
+// WithUserAgent names the consumer in the server logs.
+func WithUserAgent(ua string) Option {
+	return func(c *Client) { c.userAgent = ua }
+}
+
+// WithRetries sets how often a failed request is repeated, backoff is
+// the first wait and doubles with every further attempt. Zero retries
+// turn retrying off.
+func WithRetries(retries int, backoff time.Duration) Option {
+	return func(c *Client) { c.retries, c.backoff = retries, backoff }
+}
+
+// Error is a request the server refused or failed.
+type Error struct {
+	StatusCode int
+	// Message is the error text of the server
+	Message string
+	Method  string
+	Path    string
+	// RetryAfter is set on 429 answers
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.Message)
+}
+
+// IsNotFound tells if err is a 404 answer.
+func IsNotFound(err error) bool { return statusOf(err) == http.StatusNotFound }
+
+// IsUnauthorized tells if err is a 401 answer, the login is missing or
+// expired.
+func IsUnauthorized(err error) bool { return statusOf(err) == http.StatusUnauthorized }
+
+// IsForbidden tells if err is a 403 answer, a permission is missing.
+func IsForbidden(err error) bool { return statusOf(err) == http.StatusForbidden }
+
+func statusOf(err error) int {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.StatusCode
+	}
+
+	return 0
+}
+
+// envelope is helper.Result with the data left undecoded
+type envelope struct {
+	Data    json.RawMessage
+	Message string
+	Total   int
+}
+
+// do sends a request with body encoded as JSON and decodes the answer
+// into out unless it is nil
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = b
+	}
+
+	resp, err := c.send(ctx, method, path, payload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding the answer: %w", method, path, err)
+	}
+
+	return nil
+}
+
+// send repeats the request while it fails temporarily, the caller closes
+// the body of the returned response
+func (c *Client) send(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, method, path, payload)
+		if err == nil && resp.StatusCode < 300 {
+			return resp, nil
+		}
+
+		wait := c.backoff << uint(attempt)
+		if err == nil {
+			e := readError(resp, method, path)
+			if e.RetryAfter > 0 {
+				wait = e.RetryAfter
+			}
+			err = e
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		if attempt >= c.retries || !retryable(method, err) {
+			return nil, err
+		}
+
+		if wait > maxBackoff {
+			wait = maxBackoff
+		}
+		// jitter spreads clients failing together
+		wait += time.Duration(rand.Int63n(int64(wait)/4 + 1))
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.tenant != "" {
+		req.Header.Set(tenantHeader, c.tenant)
+	}
+
+	switch {
+	case c.session != "":
+		req.Header.Set(sessionHeader, c.session)
+	case c.apiKey != "":
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	case c.user != "":
+		req.SetBasicAuth(c.user, c.password)
+	}
+
+	return c.http.Do(req)
+}
+
+// readError turns a failed answer into an *Error, the body is the plain
+// text message of the server
+func readError(resp *http.Response, method, path string) *Error {
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+
+	e := &Error{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(b)), Method: method, Path: path}
+	if e.Message == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+	}
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && s > 0 {
+		e.RetryAfter = time.Duration(s) * time.Second
+	}
+
+	return e
+}
+
+// retryable tells if a failure is worth another attempt. Rate limited
+// and unavailable requests were not processed and are repeated for any
+// method, network errors only for idempotent methods as a POST may have
+// been saved before the connection broke.
+func retryable(method string, err error) bool {
+	var e *Error
+	if !errors.As(err, &e) {
+		return method != http.MethodPost
+	}
+
+	switch e.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusBadGateway, http.StatusGatewayTimeout:
+		return method != http.MethodPost
+	}
+
+	return false
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Login starts a session for user, later requests of the client use it.
+// MustChange is true when the password has to be changed first.
+func (c *Client) Login(ctx context.Context, user, password string) (mustChange bool, err error) {
+	var res struct {
+		Data struct {
+			SessionId  string
+			MustChange bool
+		}
+	}
+	form := struct{ UserName, Password string }{user, password}
+	if err := c.do(ctx, http.MethodPost, "/auth/login", form, &res); err != nil {
+		return false, err
+	}
+
+	c.session = res.Data.SessionId
+
+	return res.Data.MustChange, nil
+}
+
+// Logout ends the session of the client.
+func (c *Client) Logout(ctx context.Context) error {
+	if err := c.do(ctx, http.MethodPost, "/auth/logout", nil, nil); err != nil {
+		return err
+	}
+	c.session = ""
+
+	return nil
+}
+
+// Session is the session id of the last Login, to be kept for
+// WithSession.
+func (c *Client) Session() string { return c.session }
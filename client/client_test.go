@@ -0,0 +1,359 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// fakeServer keeps contacts in memory the way the phonebook routes answer
+type fakeServer struct {
+	sync.Mutex
+	contacts map[bson.ObjectId]model.Phonebook
+	order    []bson.ObjectId
+	// fail answers the next requests with these codes
+	fail     []int
+	requests []*http.Request
+}
+
+func newFakeServer(t *testing.T) (*fakeServer, *Client) {
+	f := &fakeServer{contacts: map[bson.ObjectId]model.Phonebook{}}
+	ts := httptest.NewServer(f)
+	t.Cleanup(ts.Close)
+
+	c := New(ts.URL+"/", WithAPIKey("key"), WithRetries(2, time.Millisecond))
+	c.sleep = func(ctx context.Context, d time.Duration) error { return nil }
+
+	return f, c
+}
+
+func (f *fakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.Lock()
+	defer f.Unlock()
+	f.requests = append(f.requests, r)
+
+	if len(f.fail) > 0 {
+		code := f.fail[0]
+		f.fail = f.fail[1:]
+		if code == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "3")
+		}
+		w.WriteHeader(code)
+		w.Write([]byte("failing"))
+		return
+	}
+	if r.URL.Path == "/auth/login" {
+		json.NewEncoder(w).Encode(envelopeOf(map[string]interface{}{"SessionId": "s1", "MustChange": true}, 0))
+		return
+	}
+	if r.Header.Get("Authorization") != "Bearer key" && r.Header.Get(sessionHeader) != "s1" {
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte("Login required"))
+		return
+	}
+
+	id := r.URL.Path[strings.LastIndex(r.URL.Path, "/")+1:]
+	notFound := func() {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("ID not found"))
+	}
+
+	switch {
+	case r.URL.Path == "/phonebook/get":
+		q := ListOptions{}
+		json.NewDecoder(r.Body).Decode(&q)
+		data := []model.Phonebook{}
+		for i, id := range f.order {
+			if i >= q.Skip && (q.Take == 0 || len(data) < q.Take) {
+				data = append(data, f.contacts[id])
+			}
+		}
+		json.NewEncoder(w).Encode(envelopeOf(data, len(f.order)))
+	case r.URL.Path == "/phonebook/sync":
+		// the first page lists the contacts, the second a deletion
+		q := struct{ SyncToken string }{}
+		json.NewDecoder(r.Body).Decode(&q)
+		page := map[string]interface{}{"Contacts": []model.Phonebook{}, "Deleted": []bson.ObjectId{}, "More": q.SyncToken == "", "SyncToken": "page2"}
+		if q.SyncToken == "" {
+			for _, id := range f.order {
+				page["Contacts"] = append(page["Contacts"].([]model.Phonebook), f.contacts[id])
+			}
+		} else {
+			page["Deleted"], page["SyncToken"] = []bson.ObjectId{f.order[0]}, "done"
+		}
+		json.NewEncoder(w).Encode(page)
+	case strings.HasPrefix(r.URL.Path, "/phonebook/view/"):
+		c, ok := f.contacts[bson.ObjectIdHex(id)]
+		if !bson.IsObjectIdHex(id) || !ok {
+			notFound()
+			return
+		}
+		json.NewEncoder(w).Encode(envelopeOf(c, 1))
+	case r.URL.Path == "/phonebook/save" || strings.HasPrefix(r.URL.Path, "/phonebook/edit/"):
+		c := model.Phonebook{}
+		json.NewDecoder(r.Body).Decode(&c)
+		if c.FirstName == "" {
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("FirstName is required"))
+			return
+		}
+		if r.Method == http.MethodPut {
+			c.Id = bson.ObjectIdHex(id)
+		}
+		if c.Id == "" {
+			c.Id = bson.NewObjectId()
+		}
+		if _, ok := f.contacts[c.Id]; !ok {
+			f.order = append(f.order, c.Id)
+		}
+		f.contacts[c.Id] = c
+		json.NewEncoder(w).Encode(c)
+	case strings.HasPrefix(r.URL.Path, "/phonebook/delete/"):
+		c, ok := f.contacts[bson.ObjectIdHex(id)]
+		if !ok {
+			notFound()
+			return
+		}
+		delete(f.contacts, c.Id)
+		json.NewEncoder(w).Encode(c)
+	default:
+		notFound()
+	}
+}
+
+func envelopeOf(data interface{}, total int) map[string]interface{} {
+	return map[string]interface{}{"Data": data, "Message": "", "Total": total}
+}
+
+func (f *fakeServer) add(names ...string) {
+	for _, n := range names {
+		c := model.Phonebook{Id: bson.NewObjectId(), FirstName: n}
+		f.contacts[c.Id] = c
+		f.order = append(f.order, c.Id)
+	}
+}
+
+func TestListAndGet(t *testing.T) {
+	f, c := newFakeServer(t)
+	f.add("Agil", "Budi", "Citra")
+	ctx := context.Background()
+
+	page, total, err := c.List(ctx, ListOptions{Take: 2, Skip: 1})
+	if err != nil || total != 3 || len(page) != 2 || page[0].FirstName != "Budi" {
+		t.Fatalf("list = %+v, %d, %v", page, total, err)
+	}
+	if ua := f.requests[0].Header.Get("User-Agent"); ua != "phonebook-client" {
+		t.Errorf("user agent = %s", ua)
+	}
+
+	got, err := c.Get(ctx, page[1].Id.Hex())
+	if err != nil || got.FirstName != "Citra" {
+		t.Errorf("get = %+v, %v", got, err)
+	}
+
+	_, err = c.Get(ctx, bson.NewObjectId().Hex())
+	if !IsNotFound(err) || !strings.Contains(err.Error(), "ID not found") {
+		t.Errorf("missing contact: %v", err)
+	}
+
+	all, err := c.All(ctx, ListOptions{})
+	if err != nil || len(all) != 3 {
+		t.Errorf("all = %d, %v", len(all), err)
+	}
+}
+
+func TestSync(t *testing.T) {
+	f, c := newFakeServer(t)
+	f.add("Agil", "Budi")
+
+	res, err := c.Sync(context.Background(), "", Directory)
+	if err != nil || len(res.Contacts) != 2 || len(res.Deleted) != 1 || res.Deleted[0] != f.order[0].Hex() || res.SyncToken != "done" || res.Reset {
+		t.Fatalf("sync = %+v, %v", res, err)
+	}
+	if len(f.requests) != 2 || f.requests[0].Method != http.MethodPost {
+		t.Errorf("%d requests", len(f.requests))
+	}
+}
+
+func TestSave(t *testing.T) {
+	f, c := newFakeServer(t)
+	ctx := context.Background()
+
+	created, err := c.Create(ctx, &model.Phonebook{FirstName: "Agil", JobTitle: "Engineer", Company: "Acme"})
+	if err != nil || created.Id == "" {
+		t.Fatalf("create = %+v, %v", created, err)
+	}
+
+	patched, err := c.Patch(ctx, created.Id.Hex(), map[string]interface{}{"JobTitle": "Manager"})
+	if err != nil || patched.JobTitle != "Manager" || patched.Company != "Acme" {
+		t.Errorf("patch = %+v, %v", patched, err)
+	}
+	if last := f.requests[len(f.requests)-1]; last.Method != http.MethodPut || last.URL.Path != "/phonebook/edit/"+created.Id.Hex() {
+		t.Errorf("patch sent %s %s", last.Method, last.URL.Path)
+	}
+
+	var e *Error
+	if _, err := c.Update(ctx, &model.Phonebook{Id: created.Id}); err == nil {
+		t.Error("invalid update accepted")
+	} else if !errors.As(err, &e) || e.StatusCode != 500 || e.Message != "FirstName is required" {
+		t.Errorf("update error = %#v", err)
+	}
+
+	if err := c.Delete(ctx, created.Id.Hex()); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Delete(ctx, created.Id.Hex()); !IsNotFound(err) {
+		t.Errorf("second delete = %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	f, c := newFakeServer(t)
+	var waits []time.Duration
+	c.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	ctx := context.Background()
+
+	f.fail = []int{http.StatusServiceUnavailable, http.StatusTooManyRequests}
+	if _, _, err := c.List(ctx, ListOptions{}); err != nil {
+		t.Fatalf("not retried: %v", err)
+	}
+	if len(waits) != 2 || waits[1] < 3*time.Second {
+		t.Errorf("waits = %v", waits)
+	}
+
+	// a POST may have been saved before the gateway failed
+	f.fail = []int{http.StatusBadGateway}
+	if _, err := c.Create(ctx, &model.Phonebook{FirstName: "Agil"}); statusOf(err) != http.StatusBadGateway {
+		t.Errorf("create retried: %v", err)
+	}
+
+	f.fail = []int{503, 503, 503}
+	if _, _, err := c.List(ctx, ListOptions{}); statusOf(err) != 503 {
+		t.Errorf("retries not limited: %v", err)
+	}
+
+	// server errors are not temporary
+	f.fail = []int{500}
+	if _, _, err := c.List(ctx, ListOptions{}); statusOf(err) != 500 || len(f.fail) != 0 {
+		t.Errorf("500 = %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, err := c.List(cancelled, ListOptions{}); err != context.Canceled {
+		t.Errorf("cancelled = %v", err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	f, c := newFakeServer(t)
+	anonymous := New(c.baseURL, WithRetries(0, 0))
+	ctx := context.Background()
+
+	if _, _, err := anonymous.List(ctx, ListOptions{}); !IsUnauthorized(err) {
+		t.Errorf("anonymous list = %v", err)
+	}
+
+	mustChange, err := anonymous.Login(ctx, "agil", "secret")
+	if err != nil || !mustChange || anonymous.Session() != "s1" {
+		t.Fatalf("login = %v, %v", mustChange, err)
+	}
+	if _, _, err := anonymous.List(ctx, ListOptions{}); err != nil {
+		t.Errorf("list after login = %v", err)
+	}
+	if got := f.requests[len(f.requests)-1].Header.Get(sessionHeader); got != "s1" {
+		t.Errorf("session header = %q", got)
+	}
+
+	tenant := New(c.baseURL, WithSession("s1"), WithTenant("acme"))
+	tenant.List(ctx, ListOptions{})
+	if got := f.requests[len(f.requests)-1].Header.Get(tenantHeader); got != "acme" {
+		t.Errorf("tenant header = %q", got)
+	}
+}
+
+func TestImportExport(t *testing.T) {
+	f, c := newFakeServer(t)
+	f.add("Agil", "Budi")
+	ctx := context.Background()
+
+	for _, format := range []Format{JSON, VCard, CSV} {
+		buf := &bytes.Buffer{}
+		n, err := c.Export(ctx, buf, format, ListOptions{})
+		if err != nil || n != 2 {
+			t.Fatalf("%s export = %d, %v", format, n, err)
+		}
+
+		contacts, err := Decode(bytes.NewReader(buf.Bytes()), format)
+		if err != nil || len(contacts) != 2 || contacts[1].FirstName != "Budi" || contacts[1].Id != f.order[1] {
+			t.Fatalf("%s decode = %+v, %v", format, contacts, err)
+		}
+
+		res, err := c.Import(ctx, buf, format, "")
+		if err != nil || res.Saved != 2 || len(f.order) != 2 {
+			t.Errorf("%s import = %+v, %v, %d contacts", format, res, err, len(f.order))
+		}
+	}
+
+	book := bson.NewObjectId()
+	res, err := c.Import(ctx, strings.NewReader(`[{"FirstName": "Citra"}, {"LastName": "Dewi"}]`), JSON, book.Hex())
+	if err != nil || res.Saved != 1 || res.Errors[1] == nil {
+		t.Fatalf("import = %+v, %v", res, err)
+	}
+	if c := f.contacts[f.order[2]]; c.FirstName != "Citra" || c.AddressBookId != book {
+		t.Errorf("imported %+v", c)
+	}
+
+	if _, err := Decode(strings.NewReader("x"), "xml"); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
+
+func TestCSV(t *testing.T) {
+	in := model.Phonebook{Id: bson.NewObjectId(), FirstName: "Agil", LastName: "Dwi", Company: "Acme, Inc.",
+		PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "+62 812", ProneType: "Mobile"}, {PhoneNo: "021 555", ProneType: "Work", PhoneExt: "12"}},
+		Emails:      []model.EmailDetail{{EmailAddress: "agil@example.com", EmailType: "Work"}},
+		Addresses:   []model.AddressDetail{{AddressType: "Home", City: "Jakarta", Country: "Indonesia"}},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := Encode(buf, CSV, []model.Phonebook{in}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "Mobile: +62 812; Work: 021 555 ext 12") {
+		t.Errorf("csv = %s", buf)
+	}
+
+	out, err := Decode(buf, CSV)
+	if err != nil || len(out) != 1 {
+		t.Fatalf("decode = %+v, %v", out, err)
+	}
+	c := out[0]
+	if c.Id != in.Id || c.Company != in.Company || len(c.PhoneNumber) != 2 || c.PhoneNumber[1] != in.PhoneNumber[1] ||
+		c.Email != "agil@example.com" || c.Addresses[0] != in.Addresses[0] {
+		t.Errorf("round trip = %+v", c)
+	}
+
+	// spreadsheets reorder and drop columns
+	out, err = Decode(strings.NewReader("LastName,FirstName,Phones\nDewi,Citra,0812\n"), CSV)
+	if err != nil || out[0].FirstName != "Citra" || out[0].PhoneNumber[0].ProneType != "Mobile" {
+		t.Errorf("partial columns = %+v, %v", out, err)
+	}
+	if _, err := Decode(strings.NewReader("Id,FirstName\nnope,Agil\n"), CSV); err == nil {
+		t.Error("invalid id accepted")
+	}
+}
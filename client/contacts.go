@@ -0,0 +1,335 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	vcard "github.com/tmluthfiana/phonebook/modules/vcard"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Directory as ListOptions.AddressBookId lists the shared directory only.
+const Directory = model.SourceDirectory
+
+// pageSize is the page All fetches at once
+const pageSize = 200
+
+// ListOptions filter and page List, the zero value lists the first page
+// of every visible contact.
+type ListOptions struct {
+	Take int
+	Skip int
+	// AddressBookId limits the list to one address book, Directory to the
+	// shared directory
+	AddressBookId string
+}
+
+// List returns a page of the visible contacts and the total matching
+// the filter.
+func (c *Client) List(ctx context.Context, opts ListOptions) ([]model.Phonebook, int, error) {
+	res := envelope{}
+	if err := c.do(ctx, http.MethodGet, "/phonebook/get", opts, &res); err != nil {
+		return nil, 0, err
+	}
+
+	contacts := []model.Phonebook{}
+	if err := json.Unmarshal(res.Data, &contacts); err != nil {
+		return nil, 0, err
+	}
+
+	return contacts, res.Total, nil
+}
+
+// All pages through every contact matching the filter, Take and Skip of
+// opts are ignored.
+func (c *Client) All(ctx context.Context, opts ListOptions) ([]model.Phonebook, error) {
+	all := []model.Phonebook{}
+	for opts.Take, opts.Skip = pageSize, 0; ; opts.Skip += pageSize {
+		page, total, err := c.List(ctx, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, page...)
+		if len(page) < pageSize || len(all) >= total {
+			return all, nil
+		}
+	}
+}
+
+// SyncResult is what changed since a sync token, see Sync.
+type SyncResult struct {
+	// Reset tells to drop the local copy before applying Contacts, the token
+	// was given for another user, access or address book
+	Reset    bool
+	Contacts []model.Phonebook
+	// Deleted are the hex ids of the contacts to remove, ids the copy does
+	// not hold can be ignored
+	Deleted []string
+	// SyncToken is passed to the next Sync
+	SyncToken string
+}
+
+// Sync returns the contacts created, updated or deleted since token, every
+// visible contact for an empty token. It pages until the changes are
+// complete; addressBookId limits the sync like ListOptions.AddressBookId.
+func (c *Client) Sync(ctx context.Context, token, addressBookId string) (*SyncResult, error) {
+	res := &SyncResult{Contacts: []model.Phonebook{}, Deleted: []string{}}
+	for {
+		q := struct {
+			SyncToken     string
+			Take          int
+			AddressBookId string
+		}{token, pageSize, addressBookId}
+		page := struct {
+			Reset     bool
+			Contacts  []model.Phonebook
+			Deleted   []bson.ObjectId
+			More      bool
+			SyncToken string
+		}{}
+		if err := c.do(ctx, http.MethodPost, "/phonebook/sync", q, &page); err != nil {
+			return nil, err
+		}
+
+		res.Reset = res.Reset || page.Reset
+		res.Contacts = append(res.Contacts, page.Contacts...)
+		for _, id := range page.Deleted {
+			res.Deleted = append(res.Deleted, id.Hex())
+		}
+		token = page.SyncToken
+
+		if !page.More {
+			res.SyncToken = token
+			return res, nil
+		}
+	}
+}
+
+// Get returns a contact by its hex id.
+func (c *Client) Get(ctx context.Context, id string) (*model.Phonebook, error) {
+	res := envelope{}
+	if err := c.do(ctx, http.MethodGet, "/phonebook/view/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+
+	contact := &model.Phonebook{}
+	if err := json.Unmarshal(res.Data, contact); err != nil {
+		return nil, err
+	}
+
+	return contact, nil
+}
+
+// Create saves a new contact and returns it as stored. A contact with an
+// id that doesn't exist yet is created under that id.
+func (c *Client) Create(ctx context.Context, contact *model.Phonebook) (*model.Phonebook, error) {
+	saved := &model.Phonebook{}
+	if err := c.do(ctx, http.MethodPost, "/phonebook/save", contact, saved); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// Update replaces every field of the contact of the same id, see Patch to
+// change some fields only.
+func (c *Client) Update(ctx context.Context, contact *model.Phonebook) (*model.Phonebook, error) {
+	if contact.Id == "" {
+		return nil, errors.New("client: Update of a contact without id")
+	}
+
+	saved := &model.Phonebook{}
+	if err := c.do(ctx, http.MethodPut, "/phonebook/edit/"+contact.Id.Hex(), contact, saved); err != nil {
+		return nil, err
+	}
+
+	return saved, nil
+}
+
+// Patch changes the named fields of a contact and keeps the others, keys
+// are the JSON field names such as "JobTitle". The contact is read and
+// written back, so concurrent changes to other fields in between are
+// lost.
+func (c *Client) Patch(ctx context.Context, id string, fields map[string]interface{}) (*model.Phonebook, error) {
+	current, err := c.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	patched, err := ApplyPatch(current, fields)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.Update(ctx, patched)
+}
+
+// ApplyPatch returns a copy of contact with the named fields replaced,
+// the id is kept.
+func ApplyPatch(contact *model.Phonebook, fields map[string]interface{}) (*model.Phonebook, error) {
+	b, err := json.Marshal(contact)
+	if err != nil {
+		return nil, err
+	}
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for k, v := range fields {
+		doc[k] = v
+	}
+
+	if b, err = json.Marshal(doc); err != nil {
+		return nil, err
+	}
+	patched := &model.Phonebook{}
+	if err := json.Unmarshal(b, patched); err != nil {
+		return nil, fmt.Errorf("client: invalid patch: %w", err)
+	}
+	patched.Id = contact.Id
+
+	return patched, nil
+}
+
+// Delete removes a contact.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/phonebook/delete/"+url.PathEscape(id), nil, nil)
+}
+
+// Format is a file format of Import and Export.
+type Format string
+
+const (
+	// JSON is an array of contacts as the API returns them
+	JSON Format = "json"
+	// VCard is a file of vCard 3.0 cards as address book apps exchange
+	VCard Format = "vcard"
+	// CSV is a spreadsheet with a row per contact, see CSVHeader
+	CSV Format = "csv"
+)
+
+// Export writes the contacts matching the filter to w and returns how
+// many were written, see Encode.
+func (c *Client) Export(ctx context.Context, w io.Writer, format Format, opts ListOptions) (int, error) {
+	contacts, err := c.All(ctx, opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := Encode(w, format, contacts); err != nil {
+		return 0, err
+	}
+
+	return len(contacts), nil
+}
+
+// Encode writes contacts to w in format. vCards keep the id as UID, so
+// importing them again updates the same contacts.
+func Encode(w io.Writer, format Format, contacts []model.Phonebook) error {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(contacts)
+	case VCard:
+		bw := bufio.NewWriter(w)
+		for i := range contacts {
+			uid := contacts[i].Uid
+			if uid == "" {
+				uid = contacts[i].Id.Hex()
+			}
+			bw.Write(vcard.Encode(&contacts[i], uid))
+		}
+		return bw.Flush()
+	case CSV:
+		return encodeCSV(w, contacts)
+	}
+
+	return fmt.Errorf("client: unknown format %q", format)
+}
+
+// ImportResult counts the contacts of an import, Errors holds the failed
+// ones by their position in the file.
+type ImportResult struct {
+	Saved  int
+	Errors map[int]error
+}
+
+// Import saves every contact read from r, contacts with the id of an
+// existing one update it. A contact the server refuses doesn't stop the
+// import, only reading the file or a cancelled ctx do. addressBookId puts
+// contacts without an address book into that book, empty keeps them in
+// the directory.
+func (c *Client) Import(ctx context.Context, r io.Reader, format Format, addressBookId string) (*ImportResult, error) {
+	contacts, err := Decode(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	if addressBookId != "" && !bson.IsObjectIdHex(addressBookId) {
+		return nil, fmt.Errorf("client: invalid address book id %q", addressBookId)
+	}
+
+	res := &ImportResult{Errors: map[int]error{}}
+	for i := range contacts {
+		if contacts[i].AddressBookId == "" && addressBookId != "" {
+			contacts[i].AddressBookId = bson.ObjectIdHex(addressBookId)
+		}
+
+		if _, err := c.Create(ctx, &contacts[i]); err != nil {
+			if ctx.Err() != nil {
+				return res, ctx.Err()
+			}
+			res.Errors[i] = err
+			continue
+		}
+		res.Saved++
+	}
+
+	return res, nil
+}
+
+// Decode reads the contacts of a file in format. vCard UIDs in hex are
+// taken as id, others are kept as the Uid of the contact.
+func Decode(r io.Reader, format Format) ([]model.Phonebook, error) {
+	contacts := []model.Phonebook{}
+
+	switch format {
+	case JSON:
+		if err := json.NewDecoder(r).Decode(&contacts); err != nil {
+			return nil, fmt.Errorf("client: reading contacts: %w", err)
+		}
+	case VCard:
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		for n, card := range vcard.Split(data) {
+			contact, uid, err := vcard.Decode(card)
+			if err != nil {
+				return nil, fmt.Errorf("client: vCard %d: %w", n+1, err)
+			}
+			if bson.IsObjectIdHex(uid) {
+				contact.Id = bson.ObjectIdHex(uid)
+			} else {
+				contact.Uid = uid
+			}
+			contacts = append(contacts, *contact)
+		}
+	case CSV:
+		return decodeCSV(r)
+	default:
+		return nil, fmt.Errorf("client: unknown format %q", format)
+	}
+
+	return contacts, nil
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// CSVHeader are the columns of the CSV format. Phones and Emails list
+// "Type: value" entries separated by semicolons, such as
+// "Mobile: +62 812; Work: 021 555 ext 12", the address columns hold the
+// first address only.
+var CSVHeader = []string{"Id", "FirstName", "LastName", "Phones", "Emails", "JobTitle", "Company", "Department",
+	"Website", "Birthday", "Notes", "AddressType", "Street", "City", "Region", "PostalCode", "Country", "AddressBookId"}
+
+// phoneExt separates the extension from the number in the Phones column
+const phoneExt = " ext "
+
+func encodeCSV(w io.Writer, contacts []model.Phonebook) error {
+	cw := csv.NewWriter(w)
+	cw.Write(CSVHeader)
+
+	for _, c := range contacts {
+		phones := []string{}
+		for _, p := range c.PhoneNumber {
+			no := p.PhoneNo
+			if p.PhoneExt != "" {
+				no += phoneExt + p.PhoneExt
+			}
+			phones = append(phones, typed(p.ProneType, no))
+		}
+
+		emails := []string{}
+		for _, e := range c.Emails {
+			emails = append(emails, typed(e.EmailType, e.EmailAddress))
+		}
+
+		a := model.AddressDetail{}
+		if len(c.Addresses) > 0 {
+			a = c.Addresses[0]
+		}
+
+		cw.Write([]string{hexOf(c.Id), c.FirstName, c.LastName, strings.Join(phones, "; "), strings.Join(emails, "; "),
+			c.JobTitle, c.Company, c.Department, c.Website, c.Birthday, c.Notes,
+			a.AddressType, a.Street, a.City, a.Region, a.PostalCode, a.Country, hexOf(c.AddressBookId)})
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+// decodeCSV reads the columns by their header, so spreadsheets may drop or
+// reorder them. Unknown columns are ignored.
+func decodeCSV(r io.Reader) ([]model.Phonebook, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("client: reading the CSV header: %w", err)
+	}
+	col := map[string]int{}
+	for i, h := range header {
+		col[strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))] = i
+	}
+
+	contacts := []model.Phonebook{}
+	for line := 2; ; line++ {
+		row, err := cr.Read()
+		if err == io.EOF {
+			return contacts, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("client: CSV line %d: %w", line, err)
+		}
+
+		get := func(name string) string {
+			if i, ok := col[name]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		c := model.Phonebook{
+			FirstName: get("FirstName"), LastName: get("LastName"), JobTitle: get("JobTitle"), Company: get("Company"),
+			Department: get("Department"), Website: get("Website"), Birthday: get("Birthday"), Notes: get("Notes"),
+		}
+
+		for name, id := range map[string]*bson.ObjectId{"Id": &c.Id, "AddressBookId": &c.AddressBookId} {
+			if v := get(name); v != "" {
+				if !bson.IsObjectIdHex(v) {
+					return nil, fmt.Errorf("client: CSV line %d: invalid %s %q", line, name, v)
+				}
+				*id = bson.ObjectIdHex(v)
+			}
+		}
+
+		for _, p := range entries(get("Phones"), "Mobile") {
+			no, ext := p[1], ""
+			if i := strings.Index(no, phoneExt); i >= 0 {
+				no, ext = strings.TrimSpace(no[:i]), strings.TrimSpace(no[i+len(phoneExt):])
+			}
+			c.PhoneNumber = append(c.PhoneNumber, model.PhoneNumberDetail{PhoneNo: no, ProneType: p[0], PhoneExt: ext})
+		}
+		for _, e := range entries(get("Emails"), model.EmailTypeOther) {
+			c.Emails = append(c.Emails, model.EmailDetail{EmailAddress: e[1], EmailType: e[0]})
+		}
+
+		a := model.AddressDetail{Street: get("Street"), City: get("City"), Region: get("Region"), PostalCode: get("PostalCode"), Country: get("Country")}
+		if a != (model.AddressDetail{}) {
+			a.AddressType = get("AddressType")
+			if a.AddressType == "" {
+				a.AddressType = model.EmailTypeWork
+			}
+			c.Addresses = []model.AddressDetail{a}
+		}
+
+		c.NormalizeEmail()
+		contacts = append(contacts, c)
+	}
+}
+
+func typed(t, value string) string {
+	if t == "" {
+		return value
+	}
+
+	return t + ": " + value
+}
+
+// entries splits a Phones or Emails cell into type and value pairs,
+// values without a type get def
+func entries(cell, def string) [][2]string {
+	list := [][2]string{}
+	for _, e := range strings.Split(cell, ";") {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+
+		t, v := def, e
+		if i := strings.Index(e, ":"); i > 0 {
+			t, v = strings.TrimSpace(e[:i]), strings.TrimSpace(e[i+1:])
+		}
+		list = append(list, [2]string{t, v})
+	}
+
+	return list
+}
+
+func hexOf(id bson.ObjectId) string {
+	if id == "" {
+		return ""
+	}
+
+	return id.Hex()
+}
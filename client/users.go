@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// User is an account as the acl routes list it.
+type User struct {
+	Id       string
+	LoginID  string
+	FullName string
+	Email    string
+	Groups   []string
+	Tenant   string
+}
+
+// UserForm creates or updates a user, an empty Password keeps the
+// current one on update. Groups replace the current groups.
+type UserForm struct {
+	LoginID  string
+	FullName string
+	Email    string
+	Password string
+	Enable   bool
+	Groups   []string
+	Tenant   string
+}
+
+// Users returns a page of the users, a zero take lists them all.
+func (c *Client) Users(ctx context.Context, take, skip int) ([]User, error) {
+	res := envelope{}
+	page := struct{ Take, Skip int }{take, skip}
+	if err := c.do(ctx, http.MethodGet, "/acl/user/get", page, &res); err != nil {
+		return nil, err
+	}
+
+	users := []User{}
+	if err := json.Unmarshal(res.Data, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+// GetUser returns a user by id.
+func (c *Client) GetUser(ctx context.Context, id string) (*User, error) {
+	res := envelope{}
+	if err := c.do(ctx, http.MethodGet, "/acl/user/view/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+
+	user := &User{}
+	if err := json.Unmarshal(res.Data, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateUser adds a user, LoginID and Password are required.
+func (c *Client) CreateUser(ctx context.Context, form UserForm) (*User, error) {
+	user := &User{}
+	if err := c.do(ctx, http.MethodPost, "/acl/user/save", form, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// UpdateUser replaces the fields of the user with id, the LoginID can't
+// change.
+func (c *Client) UpdateUser(ctx context.Context, id string, form UserForm) (*User, error) {
+	user := &User{}
+	if err := c.do(ctx, http.MethodPut, "/acl/user/edit/"+url.PathEscape(id), form, user); err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteUser removes a user, users can't delete themselves.
+func (c *Client) DeleteUser(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/acl/user/delete/"+url.PathEscape(id), nil, nil)
+}
+
+// Token describes an API key, the key itself is only returned by
+// CreateToken.
+type Token struct {
+	Id      string
+	UserID  string
+	Name    string
+	Prefix  string
+	Scopes  []string
+	Created time.Time
+	Expired time.Time
+	Revoked time.Time
+	Active  bool
+}
+
+// TokenForm creates an API key for the logged in user. Scopes are
+// permissions such as phonebook.read the user holds, ExpiresIn is in days
+// and defaults on the server.
+type TokenForm struct {
+	Name      string
+	Scopes    []string
+	ExpiresIn int
+}
+
+// Tokens lists the API keys of userID, empty for the logged in user.
+// Keys of other users need acl.read.
+func (c *Client) Tokens(ctx context.Context, userID string) ([]Token, error) {
+	res := envelope{}
+	query := struct{ UserID string }{userID}
+	if err := c.do(ctx, http.MethodGet, "/auth/token/get", query, &res); err != nil {
+		return nil, err
+	}
+
+	tokens := []Token{}
+	if err := json.Unmarshal(res.Data, &tokens); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// CreateToken issues an API key and returns it with its description, the
+// key can't be read again later.
+func (c *Client) CreateToken(ctx context.Context, form TokenForm) (string, *Token, error) {
+	var res struct {
+		Data struct {
+			Key   string
+			Token Token
+		}
+	}
+	if err := c.do(ctx, http.MethodPost, "/auth/token/save", form, &res); err != nil {
+		return "", nil, err
+	}
+
+	return res.Data.Key, &res.Data.Token, nil
+}
+
+// RevokeToken disables an API key.
+func (c *Client) RevokeToken(ctx context.Context, id string) (*Token, error) {
+	var res struct{ Data Token }
+	if err := c.do(ctx, http.MethodPost, "/auth/token/revoke/"+url.PathEscape(id), nil, &res); err != nil {
+		return nil, err
+	}
+
+	return &res.Data, nil
+}
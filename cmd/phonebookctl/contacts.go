@@ -0,0 +1,354 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	client "github.com/tmluthfiana/phonebook/client"
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func list(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	opts := client.ListOptions{}
+	fs.StringVar(&opts.AddressBookId, "book", "", "")
+	fs.IntVar(&opts.Take, "take", 50, "")
+	fs.IntVar(&opts.Skip, "skip", 0, "")
+	if err := flags(fs, args); err != nil || fs.NArg() > 0 {
+		return errUsage
+	}
+
+	contacts, total, err := e.store.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if e.out.format == "table" && total > opts.Skip+len(contacts) {
+		defer fmt.Fprintf(e.err, "%d of %d contacts, see -skip\n", len(contacts), total)
+	}
+
+	return e.out.contacts(contacts)
+}
+
+func search(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	opts := client.ListOptions{}
+	fs.StringVar(&opts.AddressBookId, "book", "", "")
+	if err := flags(fs, args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+
+	contacts, err := e.store.All(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	found := []model.Phonebook{}
+	for _, c := range contacts {
+		if matches(&c, strings.Join(fs.Args(), " ")) {
+			found = append(found, c)
+		}
+	}
+
+	return e.out.contacts(found)
+}
+
+// matches tells if text is part of a name, email, company, department or
+// job title of c, ignoring case, or of a phone number ignoring spaces and
+// dashes
+func matches(c *model.Phonebook, text string) bool {
+	text = strings.ToLower(strings.TrimSpace(text))
+
+	fields := []string{c.FirstName + " " + c.LastName, c.LastName + " " + c.FirstName, c.Company, c.Department, c.JobTitle, c.Email}
+	for _, m := range c.Emails {
+		fields = append(fields, m.EmailAddress)
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), text) {
+			return true
+		}
+	}
+
+	if digits := phoneDigits(text); digits != "" && strings.Trim(text, "+0123456789 -().") == "" {
+		for _, p := range c.PhoneNumber {
+			if strings.Contains(phoneDigits(p.PhoneNo), digits) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// phoneDigits drops the separators of a phone number
+func phoneDigits(s string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '+' {
+			return r
+		}
+		return -1
+	}, s)
+}
+
+func show(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+
+	c, err := e.store.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	return e.out.contact(c)
+}
+
+// fields parses Field=value arguments into a patch. Keys are the JSON
+// names of model.Phonebook, values starting with [ or { are JSON. Phone
+// and Email take "[Type:]value" and may repeat, they set PhoneNumber and
+// Emails.
+func fields(args []string) (map[string]interface{}, error) {
+	patch := map[string]interface{}{}
+	phones, emails := []model.PhoneNumberDetail{}, []model.EmailDetail{}
+
+	for _, a := range args {
+		i := strings.Index(a, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("%q is not Field=value", a)
+		}
+		key, value := a[:i], a[i+1:]
+
+		switch key {
+		case "Phone":
+			t, v := typedValue(value, "Mobile")
+			phones = append(phones, model.PhoneNumberDetail{PhoneNo: v, ProneType: t})
+			patch["PhoneNumber"] = phones
+		case "Email":
+			t, v := typedValue(value, model.EmailTypeWork)
+			emails = append(emails, model.EmailDetail{EmailAddress: v, EmailType: t})
+			patch["Emails"] = emails
+			patch["Email"] = ""
+		default:
+			if strings.HasPrefix(value, "[") || strings.HasPrefix(value, "{") {
+				var v interface{}
+				if err := json.Unmarshal([]byte(value), &v); err != nil {
+					return nil, fmt.Errorf("%s: %v", key, err)
+				}
+				patch[key] = v
+			} else {
+				patch[key] = value
+			}
+		}
+	}
+
+	return patch, nil
+}
+
+// typedValue splits "Work:021 555" into type and value
+func typedValue(s, def string) (string, string) {
+	if i := strings.Index(s, ":"); i > 0 && strings.IndexFunc(s[:i], func(r rune) bool { return !unicode.IsLetter(r) }) < 0 {
+		return s[:i], strings.TrimSpace(s[i+1:])
+	}
+
+	return def, s
+}
+
+func add(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("add", flag.ContinueOnError)
+	book := fs.String("book", "", "")
+	if err := flags(fs, args); err != nil || fs.NArg() == 0 {
+		return errUsage
+	}
+
+	patch, err := fields(fs.Args())
+	if err != nil {
+		return err
+	}
+	if *book != "" {
+		patch["AddressBookId"] = *book
+	}
+
+	c, err := client.ApplyPatch(&model.Phonebook{}, patch)
+	if err != nil {
+		return err
+	}
+	if c, err = e.store.Create(ctx, c); err != nil {
+		return err
+	}
+
+	return e.out.contact(c)
+}
+
+func edit(ctx context.Context, e *env, args []string) error {
+	if len(args) < 2 {
+		return errUsage
+	}
+
+	patch, err := fields(args[1:])
+	if err != nil {
+		return err
+	}
+
+	c, err := e.store.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if c, err = client.ApplyPatch(c, patch); err != nil {
+		return err
+	}
+	if c, err = e.store.Update(ctx, c); err != nil {
+		return err
+	}
+
+	return e.out.contact(c)
+}
+
+func remove(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+
+	for _, id := range args {
+		if err := e.store.Delete(ctx, id); err != nil {
+			return fmt.Errorf("%s: %v", id, err)
+		}
+		fmt.Fprintln(e.err, "deleted", id)
+	}
+
+	return nil
+}
+
+// formatOf takes the format from the flag or else the file extension
+func formatOf(flagged, file string) (client.Format, error) {
+	if flagged == "" {
+		switch strings.ToLower(filepath.Ext(file)) {
+		case ".csv":
+			flagged = "csv"
+		case ".vcf", ".vcard":
+			flagged = "vcard"
+		default:
+			flagged = "json"
+		}
+	}
+
+	switch f := client.Format(flagged); f {
+	case client.CSV, client.VCard, client.JSON:
+		return f, nil
+	}
+
+	return "", fmt.Errorf("unknown format %q, use csv, vcard or json", flagged)
+}
+
+func importContacts(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "")
+	book := fs.String("book", "", "")
+	dryRun := fs.Bool("dry-run", false, "")
+	if err := flags(fs, args); err != nil || fs.NArg() != 1 {
+		return errUsage
+	}
+	if *book != "" && !bson.IsObjectIdHex(*book) {
+		return fmt.Errorf("invalid address book id %q", *book)
+	}
+
+	f, err := formatOf(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var r io.Reader = e.in
+	if fs.Arg(0) != "-" {
+		file, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		r = file
+	}
+
+	contacts, err := client.Decode(r, f)
+	if err != nil {
+		return err
+	}
+
+	saved, failed := 0, 0
+	for i := range contacts {
+		c := &contacts[i]
+		if c.AddressBookId == "" && *book != "" {
+			c.AddressBookId = bson.ObjectIdHex(*book)
+		}
+		if *dryRun {
+			continue
+		}
+
+		if _, err := e.store.Create(ctx, c); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Fprintf(e.err, "contact %d (%s %s): %v\n", i+1, c.FirstName, c.LastName, err)
+			failed++
+			continue
+		}
+		saved++
+	}
+
+	if *dryRun {
+		fmt.Fprintf(e.err, "read %d contacts, none saved\n", len(contacts))
+		return e.out.contacts(contacts)
+	}
+
+	fmt.Fprintf(e.err, "saved %d of %d contacts\n", saved, len(contacts))
+	if failed > 0 {
+		return fmt.Errorf("%d contacts failed", failed)
+	}
+
+	return nil
+}
+
+func exportContacts(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := fs.String("format", "", "")
+	opts := client.ListOptions{}
+	fs.StringVar(&opts.AddressBookId, "book", "", "")
+	if err := flags(fs, args); err != nil || fs.NArg() > 1 {
+		return errUsage
+	}
+
+	f, err := formatOf(*format, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	contacts, err := e.store.All(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	if fs.NArg() == 0 || fs.Arg(0) == "-" {
+		return client.Encode(e.out.w, f, contacts)
+	}
+
+	file, err := os.Create(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := client.Encode(file, f, contacts); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return errors.New("writing " + fs.Arg(0) + ": " + err.Error())
+	}
+
+	fmt.Fprintf(e.err, "exported %d contacts to %s\n", len(contacts), fs.Arg(0))
+	return nil
+}
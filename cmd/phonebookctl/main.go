@@ -0,0 +1,196 @@
+// Command phonebookctl administers the phonebook from the shell, through
+// the API or with -offline directly on the database.
+//
+//	phonebookctl -url https://phonebook.example.com -key $KEY list -book directory
+//	phonebookctl -o csv search acme > acme.csv
+//	phonebookctl import contacts.vcf
+//	phonebookctl -offline -config db.json user passwd 5f1c...
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	client "github.com/tmluthfiana/phonebook/client"
+)
+
+// command runs with the arguments after its name
+type command struct {
+	usage string
+	run   func(ctx context.Context, e *env, args []string) error
+}
+
+var commands = map[string]command{
+	"login":  {"login USER, prints a session id for PHONEBOOK_SESSION", login},
+	"list":   {"list [-book ID|directory] [-take N] [-skip N]", list},
+	"search": {"search [-book ID|directory] TEXT, matches names, phones, emails and company", search},
+	"show":   {"show ID", show},
+	"add":    {"add [-book ID] Field=value..., e.g. FirstName=Agil Phone=Work:021555 Email=agil@example.com", add},
+	"edit":   {"edit ID Field=value..., changes the named fields only", edit},
+	"delete": {"delete ID...", remove},
+	"import": {"import [-format csv|vcard|json] [-book ID] [-dry-run] FILE|-", importContacts},
+	"export": {"export [-format csv|vcard|json] [-book ID|directory] [FILE]", exportContacts},
+	"user": {"user list [-take N] [-skip N] | show ID | add -login LOGIN [-name] [-email] [-groups a,b] [-tenant] [-disabled]" +
+		" | edit ID [flags of add] | passwd ID | delete ID", user},
+	"token": {"token list [-user ID] | create -name NAME [-scopes a,b] [-days N] | revoke ID", token},
+}
+
+// env is the state every command shares
+type env struct {
+	store store
+	// api is nil with -offline
+	api *client.Client
+	out *output
+	in  io.Reader
+	err io.Writer
+}
+
+var errUsage = errors.New("usage")
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("phonebookctl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	url := fs.String("url", envOr("PHONEBOOK_URL", "http://localhost:3030"), "API address, or PHONEBOOK_URL")
+	key := fs.String("key", os.Getenv("PHONEBOOK_API_KEY"), "API key, or PHONEBOOK_API_KEY")
+	session := fs.String("session", os.Getenv("PHONEBOOK_SESSION"), "session id of login, or PHONEBOOK_SESSION")
+	userName := fs.String("user", os.Getenv("PHONEBOOK_USER"), "log in as user with PHONEBOOK_PASSWORD, or PHONEBOOK_USER")
+	tenant := fs.String("tenant", os.Getenv("PHONEBOOK_TENANT"), "tenant to act in, or PHONEBOOK_TENANT")
+	format := fs.String("o", "table", "output as table, json or csv")
+	offline := fs.Bool("offline", false, "work on the database instead of the API")
+	config := fs.String("config", "", "with -offline, JSON file of server config keys such as host and database")
+	by := fs.String("as", "phonebookctl", "with -offline, name recorded as creator and editor")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: phonebookctl [flags] command [args]\n\ncommands:")
+		names := []string{}
+		for name := range commands {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintln(stderr, "  "+commands[name].usage)
+		}
+		fmt.Fprintln(stderr, "\nflags:")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	cmd, ok := commands[fs.Arg(0)]
+	if !ok {
+		fs.Usage()
+		return 2
+	}
+
+	out, err := newOutput(stdout, *format)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	e := &env{out: out, in: stdin, err: stderr}
+	if *offline {
+		s, err := newDBStore(*config, *tenant, *by)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		e.store = s
+	} else {
+		e.api = client.New(*url, client.WithAPIKey(*key), client.WithSession(*session), client.WithTenant(*tenant),
+			client.WithUserAgent("phonebookctl"))
+		e.store = e.api
+
+		if *userName != "" && fs.Arg(0) != "login" {
+			if _, err := e.api.Login(ctx, *userName, os.Getenv("PHONEBOOK_PASSWORD")); err != nil {
+				fmt.Fprintln(stderr, err)
+				return 1
+			}
+			defer e.api.Logout(context.Background())
+		}
+	}
+
+	if err := cmd.run(ctx, e, fs.Args()[1:]); err != nil {
+		if err == errUsage {
+			fmt.Fprintln(stderr, "usage: phonebookctl "+cmd.usage)
+			return 2
+		}
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	return 0
+}
+
+func envOr(name, def string) string {
+	if v := os.Getenv(name); v != "" {
+		return v
+	}
+
+	return def
+}
+
+// flags parses the flags of a command, a parse error is a usage error
+func flags(fs *flag.FlagSet, args []string) error {
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	return nil
+}
+
+// password reads PHONEBOOK_PASSWORD, or a line of stdin for scripts
+// piping it in
+func (e *env) password(prompt string) (string, error) {
+	if p := os.Getenv("PHONEBOOK_PASSWORD"); p != "" {
+		return p, nil
+	}
+
+	fmt.Fprint(e.err, prompt)
+	line, err := bufio.NewReader(e.in).ReadString('\n')
+	if err != nil && line == "" {
+		return "", errors.New("no password given")
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func login(ctx context.Context, e *env, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	if e.api == nil {
+		return errors.New("login needs the API, not -offline")
+	}
+
+	password, err := e.password("Password: ")
+	if err != nil {
+		return err
+	}
+	mustChange, err := e.api.Login(ctx, args[0], password)
+	if err != nil {
+		return err
+	}
+	if mustChange {
+		fmt.Fprintln(e.err, "the password must be changed before the session can be used")
+	}
+
+	_, err = fmt.Fprintln(e.out.w, e.api.Session())
+	return err
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	client "github.com/tmluthfiana/phonebook/client"
+	model "github.com/tmluthfiana/phonebook/model"
+)
+
+func TestFields(t *testing.T) {
+	patch, err := fields([]string{"FirstName=Agil", "Phone=Work:021 555", "Phone=0812", "Email=agil@example.com", `Addresses=[{"City":"Jakarta"}]`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := client.ApplyPatch(&model.Phonebook{LastName: "Dwi", Email: "old@example.com"}, patch)
+	if err != nil {
+		t.Fatalf("apply = %v", err)
+	}
+	if c.FirstName != "Agil" || c.LastName != "Dwi" || len(c.PhoneNumber) != 2 || c.PhoneNumber[0].ProneType != "Work" || c.PhoneNumber[1].ProneType != "Mobile" || c.Addresses[0].City != "Jakarta" {
+		t.Errorf("contact = %+v", c)
+	}
+	if c.NormalizeEmail(); len(c.Emails) != 1 || c.Email != "agil@example.com" {
+		t.Errorf("emails = %+v, %s", c.Emails, c.Email)
+	}
+
+	if _, err := fields([]string{"FirstName"}); err == nil {
+		t.Error("argument without value accepted")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	c := &model.Phonebook{FirstName: "Agil", LastName: "Dwi", Company: "Acme",
+		PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "+62 812-3456"}}}
+
+	for text, want := range map[string]bool{"dwi agil": true, "ACME": true, "8123456": true, "812 34": true, "budi": false, "62a": false} {
+		if got := matches(c, text); got != want {
+			t.Errorf("matches(%q) = %v", text, got)
+		}
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	for _, args := range [][]string{{}, {"nope"}, {"-o", "xml", "list"}, {"show"}, {"user", "nope"}} {
+		stderr := &bytes.Buffer{}
+		if code := run(args, strings.NewReader(""), &bytes.Buffer{}, stderr); code != 2 || stderr.Len() == 0 {
+			t.Errorf("%v = %d, %q", args, code, stderr)
+		}
+	}
+
+	if f, err := formatOf("", "contacts.VCF"); f != client.VCard || err != nil {
+		t.Errorf("format = %s, %v", f, err)
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	client "github.com/tmluthfiana/phonebook/client"
+	model "github.com/tmluthfiana/phonebook/model"
+)
+
+// output writes results as a table for people, or as JSON or CSV for
+// scripts
+type output struct {
+	w      io.Writer
+	format string
+}
+
+func newOutput(w io.Writer, format string) (*output, error) {
+	switch format {
+	case "table", "json", "csv":
+		return &output{w: w, format: format}, nil
+	}
+
+	return nil, fmt.Errorf("unknown output %q, use table, json or csv", format)
+}
+
+// print writes v as JSON, or the rows under header otherwise
+func (o *output) print(v interface{}, header []string, rows [][]string) error {
+	switch o.format {
+	case "json":
+		enc := json.NewEncoder(o.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "csv":
+		cw := csv.NewWriter(o.w)
+		cw.Write(header)
+		cw.WriteAll(rows)
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.ToUpper(strings.Join(header, "\t")))
+	for _, r := range rows {
+		fmt.Fprintln(tw, strings.Join(r, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// contacts prints a short table, CSV in the format import reads
+func (o *output) contacts(list []model.Phonebook) error {
+	if o.format == "csv" {
+		return client.Encode(o.w, client.CSV, list)
+	}
+
+	rows := [][]string{}
+	for _, c := range list {
+		phone := ""
+		if len(c.PhoneNumber) > 0 {
+			phone = c.PhoneNumber[0].PhoneNo
+		}
+		rows = append(rows, []string{c.Id.Hex(), strings.TrimSpace(c.FirstName + " " + c.LastName), phone, c.PrimaryEmail(),
+			c.Company, c.JobTitle, bookOf(c)})
+	}
+
+	return o.print(list, []string{"Id", "Name", "Phone", "Email", "Company", "Title", "Book"}, rows)
+}
+
+// contact prints every field of one contact
+func (o *output) contact(c *model.Phonebook) error {
+	switch o.format {
+	case "json":
+		return o.print(c, nil, nil)
+	case "csv":
+		return o.contacts([]model.Phonebook{*c})
+	}
+
+	rows := [][]string{
+		{"Id", c.Id.Hex()},
+		{"Name", strings.TrimSpace(c.FirstName + " " + c.LastName)},
+	}
+	for _, p := range c.PhoneNumber {
+		no := p.PhoneNo
+		if p.PhoneExt != "" {
+			no += " ext " + p.PhoneExt
+		}
+		rows = append(rows, []string{"Phone", p.ProneType + ": " + no})
+	}
+	for _, e := range c.Emails {
+		rows = append(rows, []string{"Email", e.EmailType + ": " + e.EmailAddress})
+	}
+	for _, a := range c.Addresses {
+		parts := []string{}
+		for _, p := range []string{a.Street, a.City, a.Region, a.PostalCode, a.Country} {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		rows = append(rows, []string{"Address", a.AddressType + ": " + strings.Join(parts, ", ")})
+	}
+	for _, f := range [][2]string{{"Title", c.JobTitle}, {"Company", c.Company}, {"Department", c.Department},
+		{"Website", c.Website}, {"Birthday", c.Birthday}, {"Notes", c.Notes}, {"Book", bookOf(*c)}} {
+		if f[1] != "" {
+			rows = append(rows, f[:])
+		}
+	}
+	rows = append(rows, []string{"Modified", c.Modified().Format(time.RFC3339)})
+
+	return o.print(c, []string{"Field", "Value"}, rows)
+}
+
+func bookOf(c model.Phonebook) string {
+	if c.AddressBookId == "" {
+		return client.Directory
+	}
+
+	return c.AddressBookId.Hex()
+}
+
+func (o *output) users(list []client.User) error {
+	rows := [][]string{}
+	for _, u := range list {
+		rows = append(rows, []string{u.Id, u.LoginID, u.FullName, u.Email, strings.Join(u.Groups, ","), u.Tenant})
+	}
+
+	return o.print(list, []string{"Id", "LoginID", "FullName", "Email", "Groups", "Tenant"}, rows)
+}
+
+func (o *output) tokens(list []client.Token) error {
+	rows := [][]string{}
+	for _, t := range list {
+		rows = append(rows, []string{t.Id, t.UserID, t.Name, t.Prefix, strings.Join(t.Scopes, ","),
+			t.Expired.Format("2006-01-02"), fmt.Sprint(t.Active)})
+	}
+
+	return o.print(list, []string{"Id", "UserID", "Name", "Prefix", "Scopes", "Expires", "Active"}, rows)
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"time"
+
+	client "github.com/tmluthfiana/phonebook/client"
+	controllers "github.com/tmluthfiana/phonebook/controllers"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	acl "github.com/eaciit/acl/v1.0"
+	db "github.com/eaciit/dbox"
+	_ "github.com/eaciit/dbox/dbc/mongo"
+	tk "github.com/eaciit/toolkit"
+)
+
+// store is what the commands work on, the API through *client.Client or
+// the database itself with -offline
+type store interface {
+	List(ctx context.Context, opts client.ListOptions) ([]model.Phonebook, int, error)
+	All(ctx context.Context, opts client.ListOptions) ([]model.Phonebook, error)
+	Get(ctx context.Context, id string) (*model.Phonebook, error)
+	Create(ctx context.Context, c *model.Phonebook) (*model.Phonebook, error)
+	Update(ctx context.Context, c *model.Phonebook) (*model.Phonebook, error)
+	Delete(ctx context.Context, id string) error
+
+	Users(ctx context.Context, take, skip int) ([]client.User, error)
+	GetUser(ctx context.Context, id string) (*client.User, error)
+	CreateUser(ctx context.Context, form client.UserForm) (*client.User, error)
+	UpdateUser(ctx context.Context, id string, form client.UserForm) (*client.User, error)
+	DeleteUser(ctx context.Context, id string) error
+
+	Tokens(ctx context.Context, userID string) ([]client.Token, error)
+	CreateToken(ctx context.Context, form client.TokenForm) (string, *client.Token, error)
+	RevokeToken(ctx context.Context, id string) (*client.Token, error)
+}
+
+var errOffline = errors.New("not available with -offline, API keys are issued to the logged in user")
+
+// dbStore reads and writes the database of a tenant directly, for
+// administration while the server is down. Contacts are validated as
+// the API does, address book access and permissions are not checked.
+type dbStore struct {
+	scope helper.TenantScope
+	// by is recorded as CreatedBy and UpdateBy
+	by string
+}
+
+// newDBStore applies the keys of the JSON object in configFile over
+// helper.GlobalConfig, such as {"host": "db:27017", "database": "phonebook"}
+func newDBStore(configFile, tenant, by string) (*dbStore, error) {
+	if configFile != "" {
+		b, err := os.ReadFile(configFile)
+		if err != nil {
+			return nil, err
+		}
+		conf := map[string]string{}
+		if err := json.Unmarshal(b, &conf); err != nil {
+			return nil, errors.New("reading " + configFile + ": " + err.Error())
+		}
+		for k, v := range conf {
+			helper.GlobalConfig[k] = v
+		}
+	}
+	acl.SetAclDbConfig(helper.GlobalConfig)
+
+	if tenant == "" {
+		tenant = model.DefaultTenant
+	}
+	if _, err := helper.FindTenant(tenant); err != nil {
+		return nil, err
+	}
+
+	return &dbStore{scope: helper.TenantScope(tenant), by: by}, nil
+}
+
+func (s *dbStore) filter(opts client.ListOptions) ([]*db.Filter, error) {
+	switch {
+	case opts.AddressBookId == "":
+		return nil, nil
+	case opts.AddressBookId == client.Directory:
+		return []*db.Filter{db.Eq("AddressBookId", nil)}, nil
+	case bson.IsObjectIdHex(opts.AddressBookId):
+		return []*db.Filter{db.Eq("AddressBookId", bson.ObjectIdHex(opts.AddressBookId))}, nil
+	}
+
+	return nil, errors.New("Invalid AddressBookId " + opts.AddressBookId)
+}
+
+func (s *dbStore) List(ctx context.Context, opts client.ListOptions) ([]model.Phonebook, int, error) {
+	filters, err := s.filter(opts)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := []model.Phonebook{}
+	total, err := s.scope.Find(new(model.Phonebook), filters, opts.Take, opts.Skip, &data)
+
+	return data, total, err
+}
+
+func (s *dbStore) All(ctx context.Context, opts client.ListOptions) ([]model.Phonebook, error) {
+	opts.Take, opts.Skip = 0, 0
+	data, _, err := s.List(ctx, opts)
+
+	return data, err
+}
+
+func (s *dbStore) Get(ctx context.Context, id string) (*model.Phonebook, error) {
+	if !bson.IsObjectIdHex(id) {
+		return nil, helper.ErrNotFound
+	}
+
+	c := &model.Phonebook{}
+	if err := s.scope.Get(c, bson.ObjectIdHex(id)); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *dbStore) Create(ctx context.Context, c *model.Phonebook) (*model.Phonebook, error) {
+	if err := controllers.SaveContact(s.scope, c, s.by); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *dbStore) Update(ctx context.Context, c *model.Phonebook) (*model.Phonebook, error) {
+	if _, err := s.Get(ctx, c.Id.Hex()); err != nil {
+		return nil, err
+	}
+
+	return s.Create(ctx, c)
+}
+
+func (s *dbStore) Delete(ctx context.Context, id string) error {
+	c, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return controllers.DeleteContact(s.scope, c, s.by)
+}
+
+func userOf(u *acl.User) client.User {
+	return client.User{Id: u.ID, LoginID: u.LoginID, FullName: u.FullName, Email: u.Email, Groups: u.Groups, Tenant: routing.UserTenant(u)}
+}
+
+func (s *dbStore) Users(ctx context.Context, take, skip int) ([]client.User, error) {
+	crs, err := acl.Find(new(acl.User), nil, tk.M{"take": take, "skip": skip})
+	if err != nil {
+		return nil, err
+	}
+	defer crs.Close()
+
+	users := []acl.User{}
+	if err := crs.Fetch(&users, 0, false); err != nil {
+		return nil, err
+	}
+
+	list := make([]client.User, 0, len(users))
+	for i := range users {
+		list = append(list, userOf(&users[i]))
+	}
+
+	return list, nil
+}
+
+func (s *dbStore) GetUser(ctx context.Context, id string) (*client.User, error) {
+	u := new(acl.User)
+	if err := acl.FindByID(u, id); err != nil || u.ID == "" {
+		return nil, helper.ErrNotFound
+	}
+
+	user := userOf(u)
+	return &user, nil
+}
+
+func (s *dbStore) CreateUser(ctx context.Context, form client.UserForm) (*client.User, error) {
+	if form.LoginID == "" || form.Password == "" {
+		return nil, errors.New("LoginID and Password are required")
+	}
+
+	u := new(acl.User)
+	u.LoginID = form.LoginID
+
+	return s.saveUser(u, form)
+}
+
+func (s *dbStore) UpdateUser(ctx context.Context, id string, form client.UserForm) (*client.User, error) {
+	u := new(acl.User)
+	if err := acl.FindByID(u, id); err != nil || u.ID == "" {
+		return nil, helper.ErrNotFound
+	}
+
+	return s.saveUser(u, form)
+}
+
+// saveUser follows controllers.User.Save, users default to the tenant of
+// the store
+func (s *dbStore) saveUser(u *acl.User, form client.UserForm) (*client.User, error) {
+	u.FullName = form.FullName
+	u.Email = form.Email
+	u.Enable = form.Enable
+
+	if form.Tenant == "" {
+		form.Tenant = string(s.scope)
+	}
+	if _, err := helper.FindTenant(form.Tenant); err != nil {
+		return nil, err
+	}
+	routing.SetUserTenant(u, form.Tenant)
+
+	u.Groups = []string{}
+	for _, g := range form.Groups {
+		if err := u.AddToGroup(g); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := acl.Save(u); err != nil {
+		return nil, err
+	}
+	if form.Password != "" {
+		if err := acl.ChangePassword(u.ID, form.Password); err != nil {
+			return nil, err
+		}
+	}
+
+	user := userOf(u)
+	return &user, nil
+}
+
+func (s *dbStore) DeleteUser(ctx context.Context, id string) error {
+	u := new(acl.User)
+	if err := acl.FindByID(u, id); err != nil || u.ID == "" {
+		return helper.ErrNotFound
+	}
+
+	return acl.Delete(u)
+}
+
+func tokenOf(t *acl.Token) client.Token {
+	return client.Token{Id: t.ID, UserID: t.UserID, Name: t.Data1, Prefix: t.Data3, Scopes: routing.TokenScopes(t),
+		Created: t.Created, Expired: t.Expired, Revoked: t.Claimed, Active: routing.TokenActive(t)}
+}
+
+func (s *dbStore) Tokens(ctx context.Context, userID string) ([]client.Token, error) {
+	filter := db.Eq("purpose", routing.TokenPurpose)
+	if userID != "" {
+		filter = db.And(db.Eq("userid", userID), filter)
+	}
+
+	crs, err := acl.Find(new(acl.Token), filter, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer crs.Close()
+
+	tokens := []acl.Token{}
+	if err := crs.Fetch(&tokens, 0, false); err != nil {
+		return nil, err
+	}
+
+	list := make([]client.Token, 0, len(tokens))
+	for i := range tokens {
+		list = append(list, tokenOf(&tokens[i]))
+	}
+
+	return list, nil
+}
+
+func (s *dbStore) CreateToken(ctx context.Context, form client.TokenForm) (string, *client.Token, error) {
+	return "", nil, errOffline
+}
+
+func (s *dbStore) RevokeToken(ctx context.Context, id string) (*client.Token, error) {
+	t := new(acl.Token)
+	if err := acl.FindByID(t, id); err != nil || t.ID == "" || t.Purpose != routing.TokenPurpose {
+		return nil, helper.ErrNotFound
+	}
+
+	if t.Claimed.IsZero() {
+		t.Claimed = time.Now().UTC()
+		if err := acl.Save(t); err != nil {
+			return nil, err
+		}
+	}
+
+	token := tokenOf(t)
+	return &token, nil
+}
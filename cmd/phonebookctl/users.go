@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"strings"
+
+	client "github.com/tmluthfiana/phonebook/client"
+)
+
+func user(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	args, sub := args[1:], args[0]
+
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("user list", flag.ContinueOnError)
+		take := fs.Int("take", 0, "")
+		skip := fs.Int("skip", 0, "")
+		if err := flags(fs, args); err != nil {
+			return err
+		}
+		users, err := e.store.Users(ctx, *take, *skip)
+		if err != nil {
+			return err
+		}
+		return e.out.users(users)
+	case "show":
+		if len(args) != 1 {
+			return errUsage
+		}
+		u, err := e.store.GetUser(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return e.out.users([]client.User{*u})
+	case "add", "edit":
+		return saveUser(ctx, e, sub, args)
+	case "passwd":
+		if len(args) != 1 {
+			return errUsage
+		}
+		u, err := e.store.GetUser(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		password, err := e.password("New password: ")
+		if err != nil {
+			return err
+		}
+		form := client.UserForm{FullName: u.FullName, Email: u.Email, Password: password, Enable: true, Groups: u.Groups, Tenant: u.Tenant}
+		if _, err := e.store.UpdateUser(ctx, u.Id, form); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.err, "changed the password of", u.LoginID)
+		return nil
+	case "delete":
+		if len(args) != 1 {
+			return errUsage
+		}
+		if err := e.store.DeleteUser(ctx, args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintln(e.err, "deleted", args[0])
+		return nil
+	}
+
+	return errUsage
+}
+
+// saveUser adds a user, or edits one keeping the fields without a flag.
+// The API doesn't tell if a user is disabled, so edits enable the user
+// unless -disabled is given.
+func saveUser(ctx context.Context, e *env, sub string, args []string) error {
+	fs := flag.NewFlagSet("user "+sub, flag.ContinueOnError)
+	form := client.UserForm{}
+	fs.StringVar(&form.LoginID, "login", "", "")
+	fs.StringVar(&form.FullName, "name", "", "")
+	fs.StringVar(&form.Email, "email", "", "")
+	fs.StringVar(&form.Tenant, "tenant", "", "")
+	groups := fs.String("groups", "", "")
+	disabled := fs.Bool("disabled", false, "")
+	if err := flags(fs, args); err != nil {
+		return err
+	}
+	form.Enable = !*disabled
+	if *groups != "" {
+		form.Groups = strings.Split(*groups, ",")
+	}
+
+	var saved *client.User
+	var err error
+	if sub == "add" {
+		if form.LoginID == "" || fs.NArg() != 0 {
+			return errUsage
+		}
+		if form.Password, err = e.password("Password: "); err != nil {
+			return err
+		}
+		saved, err = e.store.CreateUser(ctx, form)
+	} else {
+		if fs.NArg() != 1 {
+			return errUsage
+		}
+		current, gerr := e.store.GetUser(ctx, fs.Arg(0))
+		if gerr != nil {
+			return gerr
+		}
+
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+		if !set["name"] {
+			form.FullName = current.FullName
+		}
+		if !set["email"] {
+			form.Email = current.Email
+		}
+		if !set["tenant"] {
+			form.Tenant = current.Tenant
+		}
+		if !set["groups"] {
+			form.Groups = current.Groups
+		}
+		saved, err = e.store.UpdateUser(ctx, current.Id, form)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.out.users([]client.User{*saved})
+}
+
+func token(ctx context.Context, e *env, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	args, sub := args[1:], args[0]
+
+	switch sub {
+	case "list":
+		fs := flag.NewFlagSet("token list", flag.ContinueOnError)
+		userID := fs.String("user", "", "")
+		if err := flags(fs, args); err != nil {
+			return err
+		}
+		tokens, err := e.store.Tokens(ctx, *userID)
+		if err != nil {
+			return err
+		}
+		return e.out.tokens(tokens)
+	case "create":
+		fs := flag.NewFlagSet("token create", flag.ContinueOnError)
+		form := client.TokenForm{}
+		fs.StringVar(&form.Name, "name", "", "")
+		scopes := fs.String("scopes", "", "")
+		fs.IntVar(&form.ExpiresIn, "days", 0, "")
+		if err := flags(fs, args); err != nil || form.Name == "" {
+			return errUsage
+		}
+		if *scopes != "" {
+			form.Scopes = strings.Split(*scopes, ",")
+		}
+
+		key, t, err := e.store.CreateToken(ctx, form)
+		if err != nil {
+			return err
+		}
+		// the key goes to stdout alone so scripts can capture it
+		fmt.Fprintf(e.err, "created %s (%s), expires %s; the key is shown only once\n", t.Name, t.Id, t.Expired.Format("2006-01-02"))
+		_, err = fmt.Fprintln(e.out.w, key)
+		return err
+	case "revoke":
+		if len(args) != 1 {
+			return errUsage
+		}
+		t, err := e.store.RevokeToken(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		return e.out.tokens([]client.Token{*t})
+	}
+
+	return errUsage
+}
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	acl "github.com/eaciit/acl/v1.0"
+	db "github.com/eaciit/dbox"
+)
+
+type AddressBook struct {
+	*routing.BaseController
+}
+
+// addressBookInfo is a book with the access of the request user.
+type addressBookInfo struct {
+	model.AddressBook
+	Access string
+}
+
+// Docs describes the address book routes for the API reference.
+func (a *AddressBook) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":    {Summary: "List the address books of the user, or view one by id", Response: []addressBookInfo{}, View: addressBookInfo{}, Result: true},
+		"Save":   {Summary: "Create or rename an own address book", Request: bookForm{}, Response: model.AddressBook{}},
+		"Delete": {Summary: "Delete an own address book", Response: model.AddressBook{}},
+		"Share": {Summary: "Share an own address book with a user or group", Description: "An empty Access takes the share back.",
+			Request: model.Share{}, Response: model.AddressBook{}},
+	}
+}
+
+// bookForm is the payload of Save.
+type bookForm struct {
+	Name string
+}
+
+func (a *AddressBook) Get(r *routing.WeContent) interface{} {
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	if _, err := r.VarsGet("id"); err == nil {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+
+		book, ok := access.books[id]
+		if !ok {
+			return r.NotFound(helper.ErrNotFound)
+		}
+		return r.JSON(helper.NewResult().SetData(access.info(book)).SetTotal(1))
+	}
+
+	data := make([]addressBookInfo, 0, len(access.bookIds))
+	for _, id := range access.bookIds {
+		data = append(data, access.info(access.books[id.(bson.ObjectId)]))
+	}
+
+	return r.JSON(helper.NewResult().SetData(data).SetTotal(len(data)))
+}
+
+func (a *AddressBook) Save(r *routing.WeContent) interface{} {
+	frm := bookForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if frm.Name == "" {
+		return r.BadRequest(errors.New("Name is required"))
+	}
+
+	// shares are only changed through Share
+	book := model.AddressBook{OwnerId: r.User.ID}
+	if _, err := r.VarsGet("id"); err == nil {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		if err := tenantScope(r).Get(&book, id); err != nil || book.OwnerId != r.User.ID {
+			return r.NotFound(helper.ErrNotFound)
+		}
+	}
+
+	book.Name = frm.Name
+	if err := tenantScope(r).Save(&book); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(book)
+}
+
+func (a *AddressBook) Delete(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	book := model.AddressBook{}
+	if err := tenantScope(r).Get(&book, id); err != nil || book.OwnerId != r.User.ID {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	if book.Personal {
+		return r.BadRequest(errors.New("The personal address book cannot be deleted"))
+	}
+
+	if linked, err := hasLinkedRecords(tenantScope(r), new(model.Phonebook), "AddressBookId", id); err != nil {
+		return r.ServerError(err)
+	} else if linked {
+		return r.BadRequest(errors.New("Address book still has contacts"))
+	}
+
+	if err := tenantScope(r).Delete(&book); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(book)
+}
+
+// Share gives a user or group access to one of the user's books, an empty
+// Access takes it back.
+func (a *AddressBook) Share(r *routing.WeContent) interface{} {
+	share := model.Share{}
+	if e := r.Parse(&share); e != nil {
+		return r.ServerError(e)
+	}
+
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	book := model.AddressBook{}
+	if err := tenantScope(r).Get(&book, id); err != nil || book.OwnerId != r.User.ID {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	if err := checkShare(share); err != nil {
+		return r.BadRequest(err)
+	}
+
+	book.Shares = book.Shares.Set(share)
+	if err := tenantScope(r).Save(&book); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(book)
+}
+
+// Share gives a user or group access to a single contact of one of the
+// user's books, an empty Access takes it back.
+func (p *Phonebook) Share(r *routing.WeContent) interface{} {
+	share := model.Share{}
+	if e := r.Parse(&share); e != nil {
+		return r.ServerError(e)
+	}
+
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	contact := model.Phonebook{}
+	if err := tenantScope(r).Get(&contact, id); err != nil || !access.canRead(&contact) {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	if contact.AddressBookId == "" {
+		return r.BadRequest(errors.New("Contacts of the directory are shared already"))
+	}
+
+	if book := access.books[contact.AddressBookId]; book.OwnerId != r.User.ID {
+		return r.Forbidden(errors.New("Only the owner of the address book can share its contacts"))
+	}
+
+	if err := checkShare(share); err != nil {
+		return r.BadRequest(err)
+	}
+
+	old := contact
+	contact.Shares = contact.Shares.Set(share)
+	contact.UpdateBy = r.UserName()
+	if err := tenantScope(r).Save(&contact); err != nil {
+		return r.ServerError(err)
+	}
+
+	// a share taken back hides the contact, syncing clients of the user or
+	// group learn it from the tombstone
+	if len(contact.Shares) < len(old.Shares) {
+		if err := tenantScope(r).Save(model.NewTombstone(&old, r.UserName())); err != nil {
+			return r.ServerError(err)
+		}
+	}
+
+	return r.JSON(contact)
+}
+
+// checkShare makes sure a share names an existing user or group and a
+// known access.
+func checkShare(share model.Share) error {
+	if (share.UserId == "") == (share.GroupId == "") {
+		return errors.New("Either UserId or GroupId is required")
+	}
+
+	if share.Access != model.AccessNone && share.Access != model.AccessRead && share.Access != model.AccessWrite {
+		return errors.New("Access must be read, write or empty")
+	}
+
+	if share.UserId != "" {
+		user := new(acl.User)
+		if err := acl.FindByID(user, share.UserId); err != nil || user.ID != share.UserId {
+			return errors.New("User " + share.UserId + " not found")
+		}
+	} else {
+		group := new(acl.Group)
+		if err := acl.FindByID(group, share.GroupId); err != nil || group.ID != share.GroupId {
+			return errors.New("Group " + share.GroupId + " not found")
+		}
+	}
+
+	return nil
+}
+
+// bookAccess tells which contacts of the tenant the request user can see
+// and change: the directory by the phonebook permissions, address books
+// and single contacts by ownership and shares.
+type bookAccess struct {
+	userId  string
+	groups  []string
+	read    bool
+	write   bool
+	books   map[bson.ObjectId]model.AddressBook
+	bookIds []interface{}
+}
+
+// loadBookAccess reads the books visible to the request user, the personal
+// book is created at login.
+func loadBookAccess(r *routing.WeContent) (*bookAccess, error) {
+	a := &bookAccess{
+		userId: r.User.ID,
+		groups: r.User.Groups,
+		read:   r.Can("phonebook.read"),
+		write:  r.Can("phonebook.write"),
+		books:  map[bson.ObjectId]model.AddressBook{},
+	}
+
+	data := make([]model.AddressBook, 0)
+	if _, err := tenantScope(r).Find(new(model.AddressBook), []*db.Filter{a.shared("OwnerId")}, 0, 0, &data); err != nil {
+		return nil, err
+	}
+
+	for _, book := range data {
+		a.add(book)
+	}
+
+	return a, nil
+}
+
+func (a *bookAccess) add(book model.AddressBook) {
+	a.books[book.Id] = book
+	a.bookIds = append(a.bookIds, book.Id)
+}
+
+// shared matches records owned by the user through owner or shared with the user or
+// one of the groups.
+func (a *bookAccess) shared(owner string) *db.Filter {
+	groups := make([]interface{}, 0, len(a.groups))
+	for _, g := range a.groups {
+		groups = append(groups, g)
+	}
+
+	filters := []*db.Filter{db.Eq("Shares.UserId", a.userId)}
+	if owner != "" {
+		filters = append(filters, db.Eq(owner, a.userId))
+	}
+	if len(groups) > 0 {
+		filters = append(filters, db.In("Shares.GroupId", groups...))
+	}
+
+	return db.Or(filters...)
+}
+
+// filter matches the contacts the user can see.
+func (a *bookAccess) filter() *db.Filter {
+	filters := []*db.Filter{db.In("AddressBookId", a.bookIds...), a.shared("")}
+	if a.read {
+		filters = append(filters, db.Eq("AddressBookId", nil))
+	}
+
+	return db.Or(filters...)
+}
+
+// canWriteTo reports whether the user may add contacts to the book, the
+// directory when bookId is empty.
+func (a *bookAccess) canWriteTo(bookId bson.ObjectId) bool {
+	if bookId == "" {
+		return a.write
+	}
+
+	book, ok := a.books[bookId]
+	return ok && book.Access(a.userId, a.groups) == model.AccessWrite
+}
+
+// canRead reports whether the user sees c.
+func (a *bookAccess) canRead(c *model.Phonebook) bool {
+	return a.contact(c) != model.AccessNone
+}
+
+// canWrite reports whether the user may change or delete c.
+func (a *bookAccess) canWrite(c *model.Phonebook) bool {
+	return a.contact(c) == model.AccessWrite
+}
+
+// contact is the access of the user to c.
+func (a *bookAccess) contact(c *model.Phonebook) string {
+	return a.source(c).Access
+}
+
+// source tells where c comes from for the user.
+func (a *bookAccess) source(c *model.Phonebook) model.ContactSource {
+	if c.AddressBookId == "" {
+		access := model.AccessNone
+		if a.write {
+			access = model.AccessWrite
+		} else if a.read {
+			access = model.AccessRead
+		}
+		return model.ContactSource{Type: model.SourceDirectory, Access: access}
+	}
+
+	src := model.ContactSource{Type: model.SourceContact, AddressBookId: c.AddressBookId}
+	if book, ok := a.books[c.AddressBookId]; ok {
+		src.Type = model.SourceBook
+		if book.OwnerId == a.userId {
+			src.Type = model.SourceOwn
+		}
+		src.AddressBookName = book.Name
+		src.Access = book.Access(a.userId, a.groups)
+	}
+	src.Access = model.MaxAccess(src.Access, c.Shares.Access(a.userId, a.groups))
+
+	return src
+}
+
+func (a *bookAccess) info(book model.AddressBook) addressBookInfo {
+	return addressBookInfo{AddressBook: book, Access: book.Access(a.userId, a.groups)}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"net/http"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+type Auth struct {
+	*routing.BaseController
+}
+
+// userInfo is what the API tells about an acl user, never the password hash.
+type userInfo struct {
+	Id       string
+	LoginID  string
+	FullName string
+	Email    string
+	Groups   []string
+	Tenant   string
+}
+
+// Docs describes the session routes for the API reference.
+func (a *Auth) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Login":  {Summary: "Log in", Description: "The session id is also set as cookie.", Request: loginForm{}, Response: loginInfo{}, Result: true},
+		"Logout": {Summary: "End the session", Result: true},
+		"Me":     {Summary: "The logged in user", Response: userInfo{}, Result: true},
+	}
+}
+
+// loginForm is the payload of Login.
+type loginForm struct {
+	UserName string
+	Password string
+}
+
+// loginInfo answers a successful Login.
+type loginInfo struct {
+	SessionId  string
+	MustChange int
+	User       userInfo
+}
+
+func newUserInfo(u *acl.User) userInfo {
+	return userInfo{
+		Id:       u.ID,
+		LoginID:  u.LoginID,
+		FullName: u.FullName,
+		Email:    u.Email,
+		Groups:   u.Groups,
+		Tenant:   routing.UserTenant(u),
+	}
+}
+
+func (a *Auth) Login(r *routing.WeContent) interface{} {
+	frm := loginForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if frm.UserName == "" || frm.Password == "" {
+		return r.BadRequest(errors.New("User Name and Password are required"))
+	}
+
+	sessionId, mustChange, err := acl.Login(frm.UserName, frm.Password, false)
+	if err != nil {
+		return r.Unauthorized(err)
+	}
+
+	user, err := routing.FindSessionUser(sessionId)
+	if err != nil {
+		return r.Unauthorized(err)
+	}
+
+	if _, err := helper.TenantScope(userTenant(user)).PersonalBook(user.ID); err != nil {
+		return r.ServerError(err)
+	}
+
+	http.SetCookie(r.Writer, &http.Cookie{
+		Name:     routing.SessionCookie,
+		Value:    sessionId,
+		Path:     "/",
+		HttpOnly: true,
+	})
+
+	return r.JSON(helper.NewResult().SetData(loginInfo{sessionId, mustChange, newUserInfo(user)}))
+}
+
+func (a *Auth) Logout(r *routing.WeContent) interface{} {
+	if err := acl.Logout(r.SessionID); err != nil {
+		return r.ServerError(err)
+	}
+
+	http.SetCookie(r.Writer, &http.Cookie{
+		Name:   routing.SessionCookie,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	return r.JSON(helper.NewResult().SetMessage("Logged out"))
+}
+
+func (a *Auth) Me(r *routing.WeContent) interface{} {
+	return r.JSON(helper.NewResult().SetData(newUserInfo(r.User)))
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	carddav "github.com/tmluthfiana/phonebook/modules/carddav"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+// CardDavPrefix is where the address book is mounted.
+const CardDavPrefix = "/carddav"
+
+// cardDavPermissions are needed by the CardDAV methods on top of the
+// phonebook.read of the route.
+var cardDavPermissions = map[string]string{
+	"PUT":    "phonebook.write",
+	"DELETE": "phonebook.delete",
+}
+
+type CardDav struct {
+	*routing.BaseController
+}
+
+func (c *CardDav) Serve(r *routing.WeContent) interface{} {
+	if p, ok := cardDavPermissions[r.Req.Method]; ok && !r.Can(p) {
+		return r.Forbidden(errors.New("Permission " + p + " required"))
+	}
+
+	h := &carddav.Handler{
+		Prefix:   CardDavPrefix,
+		Name:     "Phonebook",
+		Backend:  cardDavBackend{user: r.UserName(), scope: tenantScope(r)},
+		ReadOnly: !r.Can("phonebook.write"),
+	}
+	h.ServeHTTP(r.Writer, r.Req)
+
+	return nil
+}
+
+// cardDavBackend stores the cards in the shared directory with the same
+// checks as the REST API, address books are not served.
+type cardDavBackend struct {
+	user  string
+	scope helper.TenantScope
+}
+
+func (b cardDavBackend) List() ([]model.Phonebook, error) {
+	data := make([]model.Phonebook, 0)
+	_, err := b.scope.Find(new(model.Phonebook), []*db.Filter{inDirectory()}, 0, 0, &data)
+
+	return data, err
+}
+
+func (b cardDavBackend) Get(name string) (*model.Phonebook, error) {
+	filter := db.Eq("Uid", name)
+	if bson.IsObjectIdHex(name) {
+		filter = db.Or(filter, db.Eq("_id", bson.ObjectIdHex(name)))
+	}
+
+	data := make([]model.Phonebook, 0)
+	if _, err := b.scope.Find(new(model.Phonebook), []*db.Filter{inDirectory(), filter}, 0, 0, &data); err != nil {
+		return nil, err
+	}
+
+	for i := range data {
+		if data[i].CardName() == name {
+			return &data[i], nil
+		}
+	}
+
+	return nil, carddav.ErrNotFound
+}
+
+func (b cardDavBackend) Put(name string, c *model.Phonebook, existing *model.Phonebook) error {
+	if existing != nil {
+		c.Id = existing.Id
+		c.KeepServerFields(existing)
+		// vCards carry no directory links
+		c.OrganizationId = existing.OrganizationId
+		c.DepartmentId = existing.DepartmentId
+		c.LocationId = existing.LocationId
+		c.ManagerId = existing.ManagerId
+		c.Status = existing.Status
+		c.UpdateBy = b.user
+	} else {
+		c.Uid = name
+		c.CreatedBy = b.user
+	}
+
+	if err := validateContact(b.scope, c); err != nil {
+		return &carddav.InvalidError{Err: err}
+	}
+
+	return b.scope.Save(c)
+}
+
+func (b cardDavBackend) Delete(c *model.Phonebook) error {
+	return deleteContact(b.scope, c, b.user)
+}
+
+func (b cardDavBackend) Deleted(since time.Time) ([]model.Tombstone, error) {
+	data := make([]model.Tombstone, 0)
+	_, err := b.scope.Find(new(model.Tombstone), []*db.Filter{inDirectory(), db.Gt("DeletedDate", since)}, 0, 0, &data)
+
+	return data, err
+}
@@ -0,0 +1,358 @@
+package controllers
+
+import (
+	"errors"
+	"time"
+
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	phonebookv1 "github.com/tmluthfiana/phonebook/proto/phonebook/v1"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+const (
+	// listPageSize is how many contacts List reads from the database at once
+	listPageSize = 200
+	// watchBuffer is how many events a Watch may lag behind before it is ended
+	watchBuffer = 256
+)
+
+// ContactService serves the phonebook.v1.Contacts gRPC service, see
+// proto/phonebook/v1/contacts.proto. Its methods are routed like REST
+// controllers, so they share the middlewares and the contact rules.
+type ContactService struct {
+	*routing.BaseController
+}
+
+func (s *ContactService) Get(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	req := &phonebookv1.IdRequest{}
+	if err := call.ReceiveOne(req); err != nil {
+		return call.Fail(err)
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	m := &model.Phonebook{}
+	if !bson.IsObjectIdHex(req.Id) || tenantScope(r).Get(m, bson.ObjectIdHex(req.Id)) != nil || !access.canRead(m) {
+		return call.Fail(contactStatus(helper.ErrNotFound))
+	}
+
+	call.Send(contactMessage(m))
+
+	return nil
+}
+
+func (s *ContactService) List(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	req := &phonebookv1.ListContactsRequest{}
+	if err := call.ReceiveOne(req); err != nil {
+		return call.Fail(err)
+	}
+
+	book, err := bookFilter(req.AddressBookId)
+	if err != nil {
+		return call.Fail(grpc.Errorf(grpc.InvalidArgument, "%v", err))
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	// pages continue after the last id, contacts created meanwhile come last
+	sent, last := 0, bson.ObjectId("")
+	for {
+		filters := []*db.Filter{access.filter()}
+		if book != nil {
+			filters = append(filters, book)
+		}
+		if last != "" {
+			filters = append(filters, db.Gt("_id", last))
+		}
+
+		data := make([]model.Phonebook, 0)
+		if _, err := tenantScope(r).FindSorted(new(model.Phonebook), filters, []string{"_id"}, listPageSize, 0, &data); err != nil {
+			return call.Fail(contactStatus(err))
+		}
+
+		for i := range data {
+			if req.Limit > 0 && sent == int(req.Limit) {
+				return nil
+			}
+			if err := call.Send(contactMessage(&data[i])); err != nil {
+				return nil
+			}
+			sent++
+			last = data[i].Id
+		}
+
+		if len(data) < listPageSize {
+			return nil
+		}
+	}
+}
+
+func (s *ContactService) Create(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	m, err := receiveContact(call)
+	if err != nil {
+		return call.Fail(err)
+	}
+	// ids are given by the server
+	m.Id = ""
+
+	return saveContactMessage(r, call, m)
+}
+
+func (s *ContactService) Update(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	m, err := receiveContact(call)
+	if err != nil {
+		return call.Fail(err)
+	}
+	if m.Id == "" {
+		return call.Fail(grpc.Errorf(grpc.InvalidArgument, "contact.id is required"))
+	}
+
+	return saveContactMessage(r, call, m)
+}
+
+func (s *ContactService) Delete(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	req := &phonebookv1.IdRequest{}
+	if err := call.ReceiveOne(req); err != nil {
+		return call.Fail(err)
+	}
+	if !bson.IsObjectIdHex(req.Id) {
+		return call.Fail(contactStatus(helper.ErrNotFound))
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	m, err := removeContact(r, access, bson.ObjectIdHex(req.Id))
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	call.Send(contactMessage(m))
+
+	return nil
+}
+
+// Watch streams the changes of the contacts the caller could see when the
+// watch started, until the caller hangs up or the server shuts down.
+func (s *ContactService) Watch(r *routing.WeContent) interface{} {
+	call := grpc.CallFrom(r.Req.Context())
+	req := &phonebookv1.ListContactsRequest{}
+	if err := call.ReceiveOne(req); err != nil {
+		return call.Fail(err)
+	}
+
+	book := req.AddressBookId
+	if _, err := bookFilter(book); err != nil {
+		return call.Fail(grpc.Errorf(grpc.InvalidArgument, "%v", err))
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	sub := helper.ContactEvents.Subscribe(watchBuffer)
+	defer sub.Close()
+
+	types := map[string]phonebookv1.EventType{events.Created: phonebookv1.EventCreated,
+		events.Updated: phonebookv1.EventUpdated, events.Deleted: phonebookv1.EventDeleted}
+
+	for {
+		select {
+		case <-r.Req.Context().Done():
+			return nil
+		case e, ok := <-sub.C:
+			if !ok {
+				return call.Fail(grpc.Errorf(grpc.ResourceExhausted, "the watch fell behind, list the contacts again"))
+			}
+			if e.TenantId != r.TenantID || !inBook(&e.Contact, book) || !access.canRead(&e.Contact) {
+				continue
+			}
+
+			msg := &phonebookv1.ContactEvent{Type: types[e.Type], Contact: contactMessage(&e.Contact), Time: e.Time.UTC().Format(time.RFC3339Nano)}
+			if err := call.Send(msg); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+func receiveContact(call *grpc.Call) (*model.Phonebook, error) {
+	req := &phonebookv1.ContactRequest{}
+	if err := call.ReceiveOne(req); err != nil {
+		return nil, err
+	}
+	if req.Contact == nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "contact is required")
+	}
+
+	m, err := contactModel(req.Contact)
+	if err != nil {
+		return nil, grpc.Errorf(grpc.InvalidArgument, "%v", err)
+	}
+
+	return m, nil
+}
+
+// saveContactMessage stores m by the rules of Phonebook.Save, an update
+// of a contact the caller cannot see is not found.
+func saveContactMessage(r *routing.WeContent, call *grpc.Call, m *model.Phonebook) interface{} {
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	if m.Id != "" {
+		existing := &model.Phonebook{}
+		if tenantScope(r).Get(existing, m.Id) != nil || !access.canRead(existing) {
+			return call.Fail(contactStatus(helper.ErrNotFound))
+		}
+	}
+
+	if err := storeContact(r, access, m); err != nil {
+		return call.Fail(contactStatus(err))
+	}
+
+	call.Send(contactMessage(m))
+
+	return nil
+}
+
+// contactStatus is the gRPC status of an error of the contact helpers.
+func contactStatus(err error) *grpc.Status {
+	var invalid invalidContactError
+	switch {
+	case errors.As(err, &invalid):
+		return grpc.Errorf(grpc.InvalidArgument, "%v", err)
+	case err == helper.ErrNotFound:
+		return grpc.Errorf(grpc.NotFound, "%v", err)
+	case err == errContactReadOnly, err == errBookReadOnly, err == errWriteDenied, err == errDeleteDenied:
+		return grpc.Errorf(grpc.PermissionDenied, "%v", err)
+	}
+
+	return grpc.Errorf(grpc.Internal, "%v", err)
+}
+
+// bookFilter limits contacts to a book or to the shared directory for
+// "directory", it is nil for an empty id.
+func bookFilter(id string) (*db.Filter, error) {
+	switch {
+	case id == "":
+		return nil, nil
+	case id == model.SourceDirectory:
+		return inDirectory(), nil
+	case bson.IsObjectIdHex(id):
+		return db.Eq("AddressBookId", bson.ObjectIdHex(id)), nil
+	}
+
+	return nil, errors.New("Invalid AddressBookId " + id)
+}
+
+// inBook is bookFilter for a single contact.
+func inBook(c *model.Phonebook, id string) bool {
+	switch id {
+	case "":
+		return true
+	case model.SourceDirectory:
+		return c.AddressBookId == ""
+	}
+
+	return c.AddressBookId.Hex() == id
+}
+
+func contactMessage(c *model.Phonebook) *phonebookv1.Contact {
+	hex := func(id bson.ObjectId) string {
+		if id == "" {
+			return ""
+		}
+		return id.Hex()
+	}
+	stamp := func(t time.Time) string {
+		if t.IsZero() {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+
+	m := &phonebookv1.Contact{
+		Id: hex(c.Id), FirstName: c.FirstName, LastName: c.LastName,
+		JobTitle: c.JobTitle, Company: c.Company, Department: c.Department, Website: c.Website,
+		Birthday: c.Birthday, Notes: c.Notes, OrganizationId: hex(c.OrganizationId), DepartmentId: hex(c.DepartmentId),
+		LocationId: hex(c.LocationId), ManagerId: hex(c.ManagerId), AddressBookId: hex(c.AddressBookId), Status: c.Status,
+		CreatedDate: stamp(c.CreatedDate), CreatedBy: c.CreatedBy, UpdateDate: stamp(c.UpdateDate), UpdateBy: c.UpdateBy,
+	}
+	if c.Photo != nil {
+		m.PhotoUrl = c.Photo.PhotoUrl
+	}
+	for _, p := range c.PhoneNumber {
+		m.PhoneNumbers = append(m.PhoneNumbers, &phonebookv1.PhoneNumber{Number: p.PhoneNo, Type: p.ProneType, Ext: p.PhoneExt})
+	}
+	for _, e := range c.Emails {
+		m.Emails = append(m.Emails, &phonebookv1.Email{Address: e.EmailAddress, Type: e.EmailType})
+	}
+	for _, a := range c.Addresses {
+		m.Addresses = append(m.Addresses, &phonebookv1.Address{Type: a.AddressType, Street: a.Street, City: a.City,
+			Region: a.Region, PostalCode: a.PostalCode, Country: a.Country})
+	}
+
+	return m
+}
+
+// contactModel reads the fields clients may set, the server fields are
+// kept by storeContact.
+func contactModel(m *phonebookv1.Contact) (*model.Phonebook, error) {
+	c := &model.Phonebook{
+		FirstName: m.FirstName, LastName: m.LastName, JobTitle: m.JobTitle, Company: m.Company,
+		Department: m.Department, Website: m.Website, Birthday: m.Birthday, Notes: m.Notes, Status: m.Status,
+		PhoneNumber: []model.PhoneNumberDetail{}, Emails: []model.EmailDetail{}, Addresses: []model.AddressDetail{},
+	}
+
+	for name, id := range map[string]struct {
+		value string
+		field *bson.ObjectId
+	}{
+		"id": {m.Id, &c.Id}, "organization_id": {m.OrganizationId, &c.OrganizationId}, "department_id": {m.DepartmentId, &c.DepartmentId},
+		"location_id": {m.LocationId, &c.LocationId}, "manager_id": {m.ManagerId, &c.ManagerId}, "address_book_id": {m.AddressBookId, &c.AddressBookId},
+	} {
+		if id.value == "" {
+			continue
+		}
+		if !bson.IsObjectIdHex(id.value) {
+			return nil, errors.New("Invalid " + name + " " + id.value)
+		}
+		*id.field = bson.ObjectIdHex(id.value)
+	}
+
+	for _, p := range m.PhoneNumbers {
+		c.PhoneNumber = append(c.PhoneNumber, model.PhoneNumberDetail{PhoneNo: p.Number, ProneType: p.Type, PhoneExt: p.Ext})
+	}
+	for _, e := range m.Emails {
+		c.Emails = append(c.Emails, model.EmailDetail{EmailAddress: e.Address, EmailType: e.Type})
+	}
+	for _, a := range m.Addresses {
+		c.Addresses = append(c.Addresses, model.AddressDetail{AddressType: a.Type, Street: a.Street, City: a.City,
+			Region: a.Region, PostalCode: a.PostalCode, Country: a.Country})
+	}
+
+	return c, nil
+}
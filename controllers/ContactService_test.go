@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestContactMessage(t *testing.T) {
+	c := &model.Phonebook{Id: bson.NewObjectId(), FirstName: "Tias", LastName: "Faluthi", ManagerId: bson.NewObjectId(),
+		PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "0812", ProneType: "Mobile"}},
+		Emails:      []model.EmailDetail{{EmailAddress: "tias@example.com", EmailType: "Work"}},
+		CreatedDate: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Photo: &model.PhotoDetail{PhotoUrl: "/phonebook/photo/view/x"}}
+
+	m := contactMessage(c)
+	if m.Id != c.Id.Hex() || m.AddressBookId != "" || m.CreatedDate != "2024-01-02T03:04:05Z" || m.UpdateDate != "" || m.PhotoUrl == "" {
+		t.Errorf("message = %+v", m)
+	}
+
+	back, err := contactModel(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if back.Id != c.Id || back.ManagerId != c.ManagerId || back.LastName != "Faluthi" || back.PhoneNumber[0] != c.PhoneNumber[0] || back.Emails[0] != c.Emails[0] {
+		t.Errorf("model = %+v", back)
+	}
+
+	m.LocationId = "nope"
+	if _, err := contactModel(m); err == nil {
+		t.Error("invalid location_id accepted")
+	}
+}
+
+func TestContactStatus(t *testing.T) {
+	for err, want := range map[error]grpc.Code{
+		helper.ErrNotFound:                      grpc.NotFound,
+		errBookReadOnly:                         grpc.PermissionDenied,
+		errWriteDenied:                          grpc.PermissionDenied,
+		errDeleteDenied:                         grpc.PermissionDenied,
+		invalidContactError{errors.New("Last")}: grpc.InvalidArgument,
+		errors.New("db down"):                   grpc.Internal,
+	} {
+		if s := contactStatus(err); s.Code != want || s.Message != err.Error() {
+			t.Errorf("%v = %+v", err, s)
+		}
+	}
+
+	book := bson.NewObjectId()
+	c := &model.Phonebook{AddressBookId: book}
+	if !inBook(c, "") || !inBook(c, book.Hex()) || inBook(c, model.SourceDirectory) || !inBook(&model.Phonebook{}, model.SourceDirectory) {
+		t.Error("inBook")
+	}
+	if _, err := bookFilter("nope"); err == nil {
+		t.Error("invalid book accepted")
+	}
+}
@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	db "github.com/eaciit/dbox"
+)
+
+// maxManagerDepth bounds the walk up the reporting line when checking for cycles.
+const maxManagerDepth = 100
+
+type Directory struct {
+	*routing.BaseController
+}
+
+// Docs describes the directory browsing routes for the API reference.
+func (d *Directory) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"ByDepartment": {Summary: "Directory contacts of a department", Request: pageForm{}, Response: []model.Phonebook{}, Result: true},
+		"ByLocation":   {Summary: "Directory contacts of a location", Request: pageForm{}, Response: []model.Phonebook{}, Result: true},
+		"OrgChart":     {Summary: "Reporting tree below a directory contact", Response: model.OrgChartNode{}, Result: true},
+	}
+}
+
+// inDirectory matches the contacts of the shared directory, contacts of
+// address books are left out of browsing and the org chart.
+func inDirectory() *db.Filter {
+	return db.Eq("AddressBookId", nil)
+}
+
+func (d *Directory) ByDepartment(r *routing.WeContent) interface{} {
+	return d.browse(r, "DepartmentId", new(model.Department))
+}
+
+func (d *Directory) ByLocation(r *routing.WeContent) interface{} {
+	return d.browse(r, "LocationId", new(model.Location))
+}
+
+func (d *Directory) browse(r *routing.WeContent, field string, parent model.TenantRecord) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	if err := tenantScope(r).Get(parent, id); err != nil {
+		return r.NotFound(err)
+	}
+
+	data := make([]model.Phonebook, 0)
+	total, err := tenantScope(r).Find(new(model.Phonebook), []*db.Filter{inDirectory(), db.Eq(field, id)}, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(helper.NewResult().SetData(data).SetTotal(total))
+}
+
+func (d *Directory) OrgChart(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	root := model.Phonebook{}
+	if err := tenantScope(r).Get(&root, id); err != nil || root.AddressBookId != "" {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	reports := make([]model.Phonebook, 0)
+	_, err = tenantScope(r).Find(new(model.Phonebook), []*db.Filter{inDirectory(), db.Ne("ManagerId", nil)}, 0, 0, &reports)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(helper.NewResult().SetData(model.BuildOrgChart(root, reports)))
+}
+
+// checkManager makes sure the manager of contact is in the directory of the tenant and that the
+// reporting line above it does not lead back to contact.
+func checkManager(scope helper.TenantScope, contact *model.Phonebook) error {
+	if contact.ManagerId == "" {
+		return nil
+	}
+
+	if contact.ManagerId == contact.Id {
+		return errors.New("Contact cannot be their own manager")
+	}
+
+	next := contact.ManagerId
+	for i := 0; next != "" && i < maxManagerDepth; i++ {
+		manager := model.Phonebook{}
+		if err := scope.Get(&manager, next); err != nil || manager.AddressBookId != "" {
+			if next == contact.ManagerId {
+				return errors.New("Manager not found")
+			}
+			return nil
+		}
+
+		if contact.Id != "" && manager.ManagerId == contact.Id {
+			return errors.New("Manager would create a reporting cycle")
+		}
+
+		next = manager.ManagerId
+	}
+
+	return nil
+}
+
+// checkDirectoryLinks makes sure the organization, department and location
+// a contact points at exist in its tenant.
+func checkDirectoryLinks(scope helper.TenantScope, contact *model.Phonebook) error {
+	if contact.OrganizationId != "" {
+		if err := scope.Get(new(model.Organization), contact.OrganizationId); err != nil {
+			return errors.New("Organization not found")
+		}
+	}
+
+	if contact.DepartmentId != "" {
+		if err := scope.Get(new(model.Department), contact.DepartmentId); err != nil {
+			return errors.New("Department not found")
+		}
+	}
+
+	if contact.LocationId != "" {
+		if err := scope.Get(new(model.Location), contact.LocationId); err != nil {
+			return errors.New("Location not found")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,651 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"time"
+
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	graphql "github.com/tmluthfiana/phonebook/modules/graphql"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+const (
+	// maxGraphQLTake bounds a page of contacts
+	maxGraphQLTake = 500
+	// maxGraphQLDepth bounds the nesting of manager and reports
+	maxGraphQLDepth = 10
+	// maxGraphQLReports bounds the reports of a contact
+	maxGraphQLReports = 100
+	// maxGraphQLComplexity bounds the fields of a query, a page of contacts
+	// counting take times its selection and reports 100 times
+	maxGraphQLComplexity = 25000
+)
+
+type GraphQL struct {
+	*routing.BaseController
+}
+
+// Docs describes the GraphQL routes for the API reference.
+func (g *GraphQL) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Query": {Summary: "Run a GraphQL query or mutation on the contacts",
+			Description: "The schema is served by /graphql/schema. Errors of single fields answer 200 and are listed in errors.",
+			Request:     graphql.Request{}, Response: graphql.Response{}},
+		"Schema": {Summary: "The GraphQL schema in the schema definition language", ResponseType: "text/plain"},
+	}
+}
+
+func (g *GraphQL) Query(r *routing.WeContent) interface{} {
+	req := graphql.Request{}
+	if err := json.NewDecoder(io.LimitReader(r.Req.Body, 1048576)).Decode(&req); err != nil {
+		return r.BadRequest(errors.New("Invalid GraphQL request: " + err.Error()))
+	}
+	if req.Query == "" {
+		return r.BadRequest(errors.New("query is required"))
+	}
+
+	ctx := context.WithValue(r.Req.Context(), graphQLKey{}, &graphQLState{r: r, records: map[string]interface{}{}})
+	return r.JSON(graphql.Execute(ctx, contactSchema, req))
+}
+
+func (g *GraphQL) Schema(r *routing.WeContent) interface{} {
+	return r.Data("text/plain; charset=utf-8", []byte(contactSchema.SDL()))
+}
+
+type graphQLKey struct{}
+
+// graphQLState is what the resolvers of a request share
+type graphQLState struct {
+	r      *routing.WeContent
+	access *bookAccess
+	// records caches the organizations, departments, locations and
+	// managers many contacts point at, by table and id
+	records map[string]interface{}
+}
+
+func stateOf(ctx context.Context) *graphQLState {
+	return ctx.Value(graphQLKey{}).(*graphQLState)
+}
+
+func (s *graphQLState) bookAccess() (*bookAccess, error) {
+	if s.access == nil {
+		access, err := loadBookAccess(s.r)
+		if err != nil {
+			return nil, err
+		}
+		s.access = access
+	}
+
+	return s.access, nil
+}
+
+// record loads the record id of the tenant into m, nil when there is none
+func (s *graphQLState) record(m model.TenantRecord, id bson.ObjectId) interface{} {
+	if id == "" {
+		return nil
+	}
+
+	key := m.TableName() + "/" + id.Hex()
+	if v, ok := s.records[key]; ok {
+		return v
+	}
+
+	var v interface{}
+	if err := tenantScope(s.r).Get(m, id); err == nil {
+		v = m
+	}
+	s.records[key] = v
+
+	return v
+}
+
+// directoryRecord is record for the organization routes, which need
+// directory.read
+func (s *graphQLState) directoryRecord(m model.TenantRecord, id bson.ObjectId) (interface{}, error) {
+	if id == "" {
+		return nil, nil
+	}
+	if !s.r.Can("directory.read") {
+		return nil, errors.New("Permission directory.read required")
+	}
+
+	return s.record(m, id), nil
+}
+
+// contact is the contact id when the user can see it
+func (s *graphQLState) contact(id bson.ObjectId) (*model.Phonebook, error) {
+	access, err := s.bookAccess()
+	if err != nil {
+		return nil, err
+	}
+
+	c, _ := s.record(new(model.Phonebook), id).(*model.Phonebook)
+	if c == nil || !access.canRead(c) {
+		return nil, nil
+	}
+
+	return c, nil
+}
+
+var contactSchema = newContactSchema()
+
+// contactSortFields are the fields contacts sort by, "-lastName" sorts
+// descending
+var contactSortFields = map[string]string{
+	"firstName":   "FirstName",
+	"lastName":    "LastName",
+	"company":     "Company",
+	"department":  "Department",
+	"jobTitle":    "JobTitle",
+	"createdDate": "CreatedDate",
+	"updateDate":  "UpdateDate",
+}
+
+func newContactSchema() *graphql.Schema {
+	timeType := &graphql.Scalar{Name: "Time", Description: "RFC 3339 date and time",
+		Serialize: func(v interface{}) (interface{}, error) {
+			t, ok := v.(time.Time)
+			if !ok {
+				return nil, errors.New("Time cannot represent a value that is not a time")
+			}
+			if t.IsZero() {
+				return nil, nil
+			}
+			return t.Format(time.RFC3339), nil
+		},
+		Parse: func(v interface{}) (interface{}, error) {
+			s, _ := v.(string)
+			return time.Parse(time.RFC3339, s)
+		}}
+	str, id := graphql.String, graphql.ID
+	nonNull, list := graphql.NonNullOf, graphql.ListOf
+
+	phoneType := &graphql.Object{Name: "PhoneNumber", Fields: graphql.Fields{
+		"number": {Type: nonNull(str), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.PhoneNumberDetail).PhoneNo, nil
+		}},
+		"type": {Type: str, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.PhoneNumberDetail).ProneType, nil
+		}},
+		"ext": {Type: str, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.PhoneNumberDetail).PhoneExt, nil
+		}},
+	}}
+	emailType := &graphql.Object{Name: "Email", Fields: graphql.Fields{
+		"address": {Type: nonNull(str), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.EmailDetail).EmailAddress, nil
+		}},
+		"type": {Type: str, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.EmailDetail).EmailType, nil
+		}},
+	}}
+	addressType := &graphql.Object{Name: "Address", Fields: graphql.Fields{
+		"type": {Type: str, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source.(model.AddressDetail).AddressType, nil
+		}},
+		"street":     {Type: str},
+		"city":       {Type: str},
+		"region":     {Type: str},
+		"postalCode": {Type: str},
+		"country":    {Type: str},
+	}}
+
+	organizationType := &graphql.Object{Name: "Organization", Fields: graphql.Fields{
+		"id":      {Type: nonNull(id)},
+		"name":    {Type: str},
+		"website": {Type: str},
+	}}
+	departmentType := &graphql.Object{Name: "Department", Fields: graphql.Fields{
+		"id":   {Type: nonNull(id)},
+		"name": {Type: str},
+		"code": {Type: str},
+		"organization": {Type: organizationType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return stateOf(p.Context).directoryRecord(new(model.Organization), p.Source.(*model.Department).OrganizationId)
+		}},
+	}}
+	departmentType.Fields["parent"] = &graphql.Field{Type: departmentType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		return stateOf(p.Context).directoryRecord(new(model.Department), p.Source.(*model.Department).ParentId)
+	}}
+	locationType := &graphql.Object{Name: "Location", Fields: graphql.Fields{
+		"id":       {Type: nonNull(id)},
+		"name":     {Type: str},
+		"timeZone": {Type: str},
+		"address":  {Type: addressType},
+		"organization": {Type: organizationType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return stateOf(p.Context).directoryRecord(new(model.Organization), p.Source.(*model.Location).OrganizationId)
+		}},
+	}}
+
+	addressBookType := &graphql.Object{Name: "AddressBook", Fields: graphql.Fields{
+		"id":       {Type: nonNull(id)},
+		"name":     {Type: str},
+		"ownerId":  {Type: str},
+		"personal": {Type: graphql.Boolean},
+		"access":   {Type: str, Description: "read or write, what the user may do with the contacts of the book"},
+	}}
+	sourceType := &graphql.Object{Name: "ContactSource", Fields: graphql.Fields{
+		"type":            {Type: nonNull(str), Description: "directory, own, shared-book or shared-contact"},
+		"addressBookId":   {Type: id},
+		"addressBookName": {Type: str},
+		"access":          {Type: str},
+	}}
+	shareType := &graphql.Object{Name: "Share", Fields: graphql.Fields{
+		"userId":  {Type: str},
+		"groupId": {Type: str},
+		"access":  {Type: nonNull(str)},
+	}}
+	historyType := &graphql.Object{Name: "History", Description: "When and by whom the contact was created and last changed", Fields: graphql.Fields{
+		"createdDate": {Type: timeType},
+		"createdBy":   {Type: str},
+		"updateDate":  {Type: timeType},
+		"updateBy":    {Type: str},
+		"lastAction":  {Type: str, Description: "insert or update"},
+	}}
+
+	contact := func(p graphql.ResolveParams) *model.Phonebook {
+		return p.Source.(*model.Phonebook)
+	}
+	contactType := &graphql.Object{Name: "Contact", Fields: graphql.Fields{
+		"id":         {Type: nonNull(id)},
+		"firstName":  {Type: str},
+		"lastName":   {Type: str},
+		"jobTitle":   {Type: str},
+		"company":    {Type: str},
+		"department": {Type: str, Description: "the department as free text, see orgUnit"},
+		"website":    {Type: str},
+		"birthday":   {Type: str, Description: "YYYY-MM-DD"},
+		"notes":      {Type: str},
+		"email":      {Type: str, Description: "the primary address of emails"},
+		"status":     {Type: str},
+		"photoUrl": {Type: str, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			if photo := contact(p).Photo; photo != nil {
+				return photo.PhotoUrl, nil
+			}
+			return nil, nil
+		}},
+		"phoneNumbers": {Type: nonNull(list(nonNull(phoneType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return append([]model.PhoneNumberDetail{}, contact(p).PhoneNumber...), nil
+		}},
+		"emails": {Type: nonNull(list(nonNull(emailType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return append([]model.EmailDetail{}, contact(p).Emails...), nil
+		}},
+		"addresses": {Type: nonNull(list(nonNull(addressType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return append([]model.AddressDetail{}, contact(p).Addresses...), nil
+		}},
+		"organization": {Type: organizationType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return stateOf(p.Context).directoryRecord(new(model.Organization), contact(p).OrganizationId)
+		}},
+		"orgUnit": {Type: departmentType, Description: "the department of the organization", Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return stateOf(p.Context).directoryRecord(new(model.Department), contact(p).DepartmentId)
+		}},
+		"location": {Type: locationType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return stateOf(p.Context).directoryRecord(new(model.Location), contact(p).LocationId)
+		}},
+		"addressBook": {Type: addressBookType, Description: "null for contacts of the directory and of books not shared with the user",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				access, err := stateOf(p.Context).bookAccess()
+				if err != nil {
+					return nil, err
+				}
+				if book, ok := access.books[contact(p).AddressBookId]; ok {
+					return access.info(book), nil
+				}
+				return nil, nil
+			}},
+		"source": {Type: nonNull(sourceType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			access, err := stateOf(p.Context).bookAccess()
+			if err != nil {
+				return nil, err
+			}
+			return access.source(contact(p)), nil
+		}},
+		"shares": {Type: nonNull(list(nonNull(shareType))), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return append(model.Shares{}, contact(p).Shares...), nil
+		}},
+		"history": {Type: nonNull(historyType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source, nil
+		}},
+	}}
+	contactType.Fields["manager"] = &graphql.Field{Type: contactType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+		if id := contact(p).ManagerId; id != "" {
+			return stateOf(p.Context).contact(id)
+		}
+		return nil, nil
+	}}
+	contactType.Fields["reports"] = &graphql.Field{Type: nonNull(list(nonNull(contactType))), Description: "the contacts managed by the contact, at most 100",
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			contacts, _, err := findContacts(p.Context, []*db.Filter{db.Eq("ManagerId", contact(p).Id)}, nil, maxGraphQLReports, 0)
+			return contacts, err
+		},
+		Size: func(map[string]interface{}) int { return maxGraphQLReports }}
+
+	pageType := &graphql.Object{Name: "ContactPage", Fields: graphql.Fields{
+		"total": {Type: nonNull(graphql.Int), Description: "the count of all matching contacts"},
+		"items": {Type: nonNull(list(nonNull(contactType)))},
+	}}
+
+	filterType := &graphql.InputObject{Name: "ContactFilter", Fields: graphql.Args{
+		"addressBookId":  {Type: id, Description: "a book, or directory for the shared directory"},
+		"search":         {Type: str, Description: "part of the name, company, email or phone number, ignoring case"},
+		"company":        {Type: str},
+		"organizationId": {Type: id},
+		"departmentId":   {Type: id},
+		"locationId":     {Type: id},
+		"managerId":      {Type: id},
+	}}
+
+	phoneInput := &graphql.InputObject{Name: "PhoneNumberInput", Fields: graphql.Args{
+		"number": {Type: nonNull(str)},
+		"type":   {Type: str},
+		"ext":    {Type: str},
+	}}
+	emailInput := &graphql.InputObject{Name: "EmailInput", Fields: graphql.Args{
+		"address": {Type: nonNull(str)},
+		"type":    {Type: str},
+	}}
+	addressInput := &graphql.InputObject{Name: "AddressInput", Fields: graphql.Args{
+		"type":       {Type: str},
+		"street":     {Type: str},
+		"city":       {Type: str},
+		"region":     {Type: str},
+		"postalCode": {Type: str},
+		"country":    {Type: str},
+	}}
+	contactInput := &graphql.InputObject{Name: "ContactInput", Description: "The fields to set, updates keep the fields left out",
+		Fields: graphql.Args{
+			"phoneNumbers": {Type: list(nonNull(phoneInput))},
+			"emails":       {Type: list(nonNull(emailInput))},
+			"addresses":    {Type: list(nonNull(addressInput))},
+		}}
+	for name := range contactTextFields {
+		contactInput.Fields[name] = &graphql.Arg{Type: str}
+	}
+	for name := range contactIdFields {
+		contactInput.Fields[name] = &graphql.Arg{Type: id}
+	}
+
+	query := &graphql.Object{Name: "Query", Fields: graphql.Fields{
+		"contacts": {Type: nonNull(pageType), Description: "the contacts visible to the user",
+			Args: graphql.Args{
+				"filter": {Type: filterType},
+				"sort": {Type: list(nonNull(str)), Description: "fields to sort by, a leading - sorts descending: " +
+					"firstName, lastName, company, department, jobTitle, createdDate or updateDate"},
+				"take": {Type: graphql.Int, Default: 50, Description: "at most 500"},
+				"skip": {Type: graphql.Int, Default: 0},
+			},
+			Resolve: contactsQuery,
+			Size: func(args map[string]interface{}) int {
+				take, _ := args["take"].(int)
+				return take
+			}},
+		"contact": {Type: contactType, Args: graphql.Args{"id": {Type: nonNull(id)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				v := p.Args["id"].(string)
+				if !bson.IsObjectIdHex(v) {
+					return nil, nil
+				}
+				return stateOf(p.Context).contact(bson.ObjectIdHex(v))
+			}},
+		"addressBooks": {Type: nonNull(list(nonNull(addressBookType))), Description: "the books of the user and the books shared with the user",
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				access, err := stateOf(p.Context).bookAccess()
+				if err != nil {
+					return nil, err
+				}
+				books := []addressBookInfo{}
+				for _, id := range access.bookIds {
+					books = append(books, access.info(access.books[id.(bson.ObjectId)]))
+				}
+				return books, nil
+			}},
+	}}
+
+	mutation := &graphql.Object{Name: "Mutation", Fields: graphql.Fields{
+		"createContact": {Type: nonNull(contactType), Args: graphql.Args{"input": {Type: nonNull(contactInput)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				c := &model.Phonebook{}
+				if err := applyContactInput(c, p.Args["input"].(map[string]interface{})); err != nil {
+					return nil, err
+				}
+				return c, saveGraphQLContact(p.Context, c)
+			}},
+		"updateContact": {Type: nonNull(contactType), Args: graphql.Args{"id": {Type: nonNull(id)}, "input": {Type: nonNull(contactInput)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				v := p.Args["id"].(string)
+				if !bson.IsObjectIdHex(v) {
+					return nil, helper.ErrNotFound
+				}
+				stored, err := stateOf(p.Context).contact(bson.ObjectIdHex(v))
+				if err != nil {
+					return nil, err
+				}
+				if stored == nil {
+					return nil, helper.ErrNotFound
+				}
+
+				c := *stored
+				if err := applyContactInput(&c, p.Args["input"].(map[string]interface{})); err != nil {
+					return nil, err
+				}
+				return &c, saveGraphQLContact(p.Context, &c)
+			}},
+		"deleteContact": {Type: nonNull(contactType), Description: "deletes the contact and answers it as it was",
+			Args: graphql.Args{"id": {Type: nonNull(id)}},
+			Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				v := p.Args["id"].(string)
+				if !bson.IsObjectIdHex(v) {
+					return nil, helper.ErrNotFound
+				}
+				s := stateOf(p.Context)
+				access, err := s.bookAccess()
+				if err != nil {
+					return nil, err
+				}
+				c, err := removeContact(s.r, access, bson.ObjectIdHex(v))
+				if err == nil {
+					delete(s.records, c.TableName()+"/"+v)
+				}
+				return c, err
+			}},
+	}}
+
+	schema, err := graphql.NewSchema(query, mutation)
+	if err != nil {
+		panic(err)
+	}
+	schema.MaxDepth = maxGraphQLDepth
+	schema.MaxComplexity = maxGraphQLComplexity
+
+	return schema
+}
+
+func contactsQuery(p graphql.ResolveParams) (interface{}, error) {
+	f := struct {
+		AddressBookId  string
+		Search         string
+		Company        string
+		OrganizationId string
+		DepartmentId   string
+		LocationId     string
+		ManagerId      string
+	}{}
+	if err := graphql.Decode(p.Args["filter"], &f); err != nil {
+		return nil, err
+	}
+
+	filters := []*db.Filter{}
+	if f.AddressBookId == model.SourceDirectory {
+		filters = append(filters, inDirectory())
+	} else if f.AddressBookId != "" {
+		if !bson.IsObjectIdHex(f.AddressBookId) {
+			return nil, errors.New("Invalid AddressBookId " + f.AddressBookId)
+		}
+		filters = append(filters, db.Eq("AddressBookId", bson.ObjectIdHex(f.AddressBookId)))
+	}
+	if f.Search != "" {
+		filters = append(filters, db.Or(db.Contains("FirstName", f.Search), db.Contains("LastName", f.Search),
+			db.Contains("Company", f.Search), db.Contains("Emails.EmailAddress", f.Search), db.Contains("PhoneNumber.PhoneNo", f.Search)))
+	}
+	if f.Company != "" {
+		filters = append(filters, db.Eq("Company", f.Company))
+	}
+	for field, v := range map[string]string{"OrganizationId": f.OrganizationId, "DepartmentId": f.DepartmentId,
+		"LocationId": f.LocationId, "ManagerId": f.ManagerId} {
+		if v == "" {
+			continue
+		}
+		if !bson.IsObjectIdHex(v) {
+			return nil, errors.New("Invalid " + field + " " + v)
+		}
+		filters = append(filters, db.Eq(field, bson.ObjectIdHex(v)))
+	}
+
+	order := []string{}
+	sort, _ := p.Args["sort"].([]interface{})
+	for _, s := range sort {
+		name, desc := s.(string), ""
+		if len(name) > 0 && name[0] == '-' {
+			name, desc = name[1:], "-"
+		}
+		field, ok := contactSortFields[name]
+		if !ok {
+			return nil, errors.New("Cannot sort by " + s.(string))
+		}
+		order = append(order, desc+field)
+	}
+
+	take, skip := p.Args["take"].(int), p.Args["skip"].(int)
+	if take < 1 || take > maxGraphQLTake || skip < 0 {
+		return nil, errors.New("take must be 1 to 500 and skip not negative")
+	}
+
+	items, total, err := findContacts(p.Context, filters, order, take, skip)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"total": total, "items": items}, nil
+}
+
+// findContacts is the page of contacts matching filters the user can see
+func findContacts(ctx context.Context, filters []*db.Filter, order []string, take, skip int) ([]*model.Phonebook, int, error) {
+	s := stateOf(ctx)
+	access, err := s.bookAccess()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	data := make([]model.Phonebook, 0)
+	total, err := tenantScope(s.r).FindSorted(new(model.Phonebook), append([]*db.Filter{access.filter()}, filters...), order, take, skip, &data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	contacts := make([]*model.Phonebook, 0, len(data))
+	for i := range data {
+		contacts = append(contacts, &data[i])
+	}
+
+	return contacts, total, nil
+}
+
+// saveGraphQLContact stores a contact by the rules of Phonebook.Save
+func saveGraphQLContact(ctx context.Context, c *model.Phonebook) error {
+	s := stateOf(ctx)
+	access, err := s.bookAccess()
+	if err != nil {
+		return err
+	}
+
+	if err := storeContact(s.r, access, c); err != nil {
+		return err
+	}
+	delete(s.records, c.TableName()+"/"+c.Id.Hex())
+
+	return nil
+}
+
+// contactTextFields and contactIdFields map the fields of ContactInput to
+// the contact
+var (
+	contactTextFields = map[string]func(c *model.Phonebook) *string{
+		"firstName":  func(c *model.Phonebook) *string { return &c.FirstName },
+		"lastName":   func(c *model.Phonebook) *string { return &c.LastName },
+		"jobTitle":   func(c *model.Phonebook) *string { return &c.JobTitle },
+		"company":    func(c *model.Phonebook) *string { return &c.Company },
+		"department": func(c *model.Phonebook) *string { return &c.Department },
+		"website":    func(c *model.Phonebook) *string { return &c.Website },
+		"birthday":   func(c *model.Phonebook) *string { return &c.Birthday },
+		"notes":      func(c *model.Phonebook) *string { return &c.Notes },
+		"status":     func(c *model.Phonebook) *string { return &c.Status },
+	}
+	contactIdFields = map[string]func(c *model.Phonebook) *bson.ObjectId{
+		"organizationId": func(c *model.Phonebook) *bson.ObjectId { return &c.OrganizationId },
+		"departmentId":   func(c *model.Phonebook) *bson.ObjectId { return &c.DepartmentId },
+		"locationId":     func(c *model.Phonebook) *bson.ObjectId { return &c.LocationId },
+		"managerId":      func(c *model.Phonebook) *bson.ObjectId { return &c.ManagerId },
+		"addressBookId":  func(c *model.Phonebook) *bson.ObjectId { return &c.AddressBookId },
+	}
+)
+
+// applyContactInput sets the fields given in a ContactInput, null clears
+// a field
+func applyContactInput(c *model.Phonebook, in map[string]interface{}) error {
+	text := func(m map[string]interface{}, key string) string {
+		s, _ := m[key].(string)
+		return s
+	}
+
+	for key, v := range in {
+		if field, ok := contactTextFields[key]; ok {
+			*field(c) = text(in, key)
+			continue
+		}
+		if field, ok := contactIdFields[key]; ok {
+			id := text(in, key)
+			if id != "" && !bson.IsObjectIdHex(id) {
+				return errors.New("Invalid " + key + " " + id)
+			}
+			*field(c) = ""
+			if id != "" {
+				*field(c) = bson.ObjectIdHex(id)
+			}
+			continue
+		}
+
+		items, _ := v.([]interface{})
+		switch key {
+		case "phoneNumbers":
+			c.PhoneNumber = []model.PhoneNumberDetail{}
+			for _, item := range items {
+				m := item.(map[string]interface{})
+				c.PhoneNumber = append(c.PhoneNumber, model.PhoneNumberDetail{PhoneNo: text(m, "number"), ProneType: text(m, "type"), PhoneExt: text(m, "ext")})
+			}
+		case "emails":
+			// the legacy address would come back through NormalizeEmail
+			c.Email = ""
+			c.Emails = []model.EmailDetail{}
+			for _, item := range items {
+				m := item.(map[string]interface{})
+				c.Emails = append(c.Emails, model.EmailDetail{EmailAddress: text(m, "address"), EmailType: text(m, "type")})
+			}
+		case "addresses":
+			c.Addresses = []model.AddressDetail{}
+			for _, item := range items {
+				m := item.(map[string]interface{})
+				c.Addresses = append(c.Addresses, model.AddressDetail{AddressType: text(m, "type"), Street: text(m, "street"), City: text(m, "city"),
+					Region: text(m, "region"), PostalCode: text(m, "postalCode"), Country: text(m, "country")})
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	graphql "github.com/tmluthfiana/phonebook/modules/graphql"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestApplyContactInput(t *testing.T) {
+	org := bson.NewObjectId()
+	c := &model.Phonebook{FirstName: "Tias", LastName: "Faluthi", Email: "old@example.com", Company: "Eaciit", ManagerId: bson.NewObjectId()}
+
+	err := applyContactInput(c, map[string]interface{}{
+		"firstName":      "Agil",
+		"company":        nil,
+		"organizationId": org.Hex(),
+		"managerId":      nil,
+		"phoneNumbers":   []interface{}{map[string]interface{}{"number": "0812", "type": "Mobile"}},
+		"emails":         []interface{}{map[string]interface{}{"address": "agil@example.com", "type": "Work"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.FirstName != "Agil" || c.LastName != "Faluthi" || c.Company != "" || c.OrganizationId != org || c.ManagerId != "" {
+		t.Errorf("contact = %+v", c)
+	}
+	if len(c.PhoneNumber) != 1 || c.PhoneNumber[0].PhoneNo != "0812" || c.PhoneNumber[0].ProneType != "Mobile" {
+		t.Errorf("phones = %+v", c.PhoneNumber)
+	}
+	if c.NormalizeEmail(); len(c.Emails) != 1 || c.Email != "agil@example.com" {
+		t.Errorf("emails = %+v, %s", c.Emails, c.Email)
+	}
+
+	if err := applyContactInput(c, map[string]interface{}{"locationId": "nope"}); err == nil {
+		t.Error("invalid locationId accepted")
+	}
+}
+
+func TestContactSchema(t *testing.T) {
+	// invalid documents fail before a resolver needs the request
+	for query, want := range map[string]string{
+		`{ contacts { items { id phones } } }`:                     "Contact has no field phones",
+		`{ contacts(filter: {name: "x"}) { total } }`:              "ContactFilter has no field name",
+		`mutation { createContact(input: {firstName: 1}) { id } }`: "String cannot represent 1",
+		`mutation { deleteContact { id } }`:                        "argument id of type ID! is required",
+		`{ contact(id: "x") { manager { manager { manager { manager { manager { manager { manager { manager { manager { id } } } } } } } } } } }`: "deeper than 10",
+		`{ contacts(take: 500) { items { id reports { id } } } }`:                                                                                 "more than 25000 fields",
+		`{ a: contacts(take: 100) { items { reports { reports { id } } } } b: contacts { total } }`:                                               "more than 25000 fields",
+	} {
+		res := graphql.Execute(context.Background(), contactSchema, graphql.Request{Query: query})
+		if len(res.Errors) != 1 || !strings.Contains(res.Errors[0].Message, want) || res.Data != nil {
+			t.Errorf("%s: %+v", query, res.Errors)
+		}
+	}
+}
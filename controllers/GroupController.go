@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	acl "github.com/eaciit/acl/v1.0"
+	db "github.com/eaciit/dbox"
+	tk "github.com/eaciit/toolkit"
+)
+
+type Group struct {
+	*routing.BaseController
+}
+
+// Docs describes the group routes for the API reference.
+func (g *Group) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":    {Summary: "List groups, or view one by id", Request: pageForm{}, Response: []acl.Group{}, View: acl.Group{}, Result: true},
+		"Save":   {Summary: "Create or update a group", Request: groupForm{}, Response: acl.Group{}},
+		"Delete": {Summary: "Delete a group", Response: acl.Group{}},
+		"Grant":  {Summary: "Grant access to the group", Request: grantForm{}, Response: acl.Group{}},
+		"Revoke": {Summary: "Revoke access from the group", Request: grantForm{}, Response: acl.Group{}},
+	}
+}
+
+// groupForm is the payload of Save.
+type groupForm struct {
+	Title  string
+	Enable bool
+	Owner  string
+}
+
+func (g *Group) Get(r *routing.WeContent) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if v, err := r.VarsGet("id"); err == nil {
+		group := new(acl.Group)
+		if err := acl.FindByID(group, v); err != nil {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(helper.NewResult().SetData(group).SetTotal(1))
+	}
+
+	crs, err := acl.Find(new(acl.Group), nil, tk.M{"take": frm.Take, "skip": frm.Skip})
+	if err != nil {
+		return r.ServerError(err)
+	}
+	defer crs.Close()
+
+	data := make([]acl.Group, 0)
+	if err := crs.Fetch(&data, 0, false); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(helper.NewResult().SetData(data).SetTotal(crs.Count()))
+}
+
+func (g *Group) Save(r *routing.WeContent) interface{} {
+	frm := groupForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if frm.Title == "" {
+		return r.BadRequest(errors.New("Title is required"))
+	}
+
+	// grants are only changed through Grant and Revoke
+	group := new(acl.Group)
+	if v, err := r.VarsGet("id"); err == nil {
+		if err := acl.FindByID(group, v); err != nil {
+			return r.NotFound(errors.New("ID not found"))
+		}
+	}
+
+	group.Title = frm.Title
+	group.Enable = frm.Enable
+	group.Owner = frm.Owner
+
+	if err := acl.Save(group); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(group)
+}
+
+func (g *Group) Delete(r *routing.WeContent) interface{} {
+	v, _ := r.VarsGet("id")
+
+	group := new(acl.Group)
+	if err := acl.FindByID(group, v); err != nil {
+		return r.NotFound(errors.New("ID not found"))
+	}
+
+	crs, err := acl.Find(new(acl.User), db.Eq("groups", group.ID), tk.M{"take": 1})
+	if err != nil {
+		return r.ServerError(err)
+	}
+	defer crs.Close()
+
+	if crs.Count() > 0 {
+		return r.BadRequest(errors.New("Group still has members"))
+	}
+
+	if err := acl.Delete(group); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(group)
+}
+
+// grantForm names the access id and the verbs (read, write, delete or any
+// acl access name) to grant or revoke.
+type grantForm struct {
+	AccessID string
+	Access   []string
+}
+
+func (f *grantForm) access() ([]acl.AccessTypeEnum, error) {
+	if f.AccessID == "" || len(f.Access) == 0 {
+		return nil, errors.New("AccessID and Access are required")
+	}
+
+	access := []acl.AccessTypeEnum{}
+	for _, verb := range f.Access {
+		_, a, err := routing.ParsePermission(f.AccessID + "." + verb)
+		if err != nil {
+			return nil, err
+		}
+		access = append(access, a)
+	}
+
+	return access, nil
+}
+
+func (g *Group) Grant(r *routing.WeContent) interface{} {
+	return g.changeGrant(r, true)
+}
+
+func (g *Group) Revoke(r *routing.WeContent) interface{} {
+	return g.changeGrant(r, false)
+}
+
+func (g *Group) changeGrant(r *routing.WeContent, grant bool) interface{} {
+	frm := grantForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	access, err := frm.access()
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	v, _ := r.VarsGet("id")
+	group := new(acl.Group)
+	if err := acl.FindByID(group, v); err != nil {
+		return r.NotFound(errors.New("ID not found"))
+	}
+
+	if grant {
+		group.Grant(frm.AccessID, access...)
+	} else if hasGrant(group.Grants, frm.AccessID) {
+		group.Revoke(frm.AccessID, access...)
+	}
+
+	if err := acl.Save(group); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(group)
+}
+
+// hasGrant guards acl Revoke, which does not expect unknown access ids.
+func hasGrant(grants []acl.AccessGrant, accessId string) bool {
+	for _, g := range grants {
+		if g.AccessID == accessId {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,58 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	ldapsync "github.com/tmluthfiana/phonebook/modules/ldapsync"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+)
+
+type LdapSync struct {
+	*routing.BaseController
+}
+
+// Docs describes the LDAP sync routes for the API reference.
+func (l *LdapSync) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Run":    {Summary: "Sync the directory from LDAP now", Request: ldapSyncForm{}, Response: ldapsync.Diff{}, Result: true},
+		"Status": {Summary: "The outcome of the last sync", Response: ldapsync.Diff{}, Result: true},
+	}
+}
+
+// ldapSyncForm is the payload of Run, a dry run reports without saving.
+type ldapSyncForm struct {
+	DryRun bool
+}
+
+func (l *LdapSync) Run(r *routing.WeContent) interface{} {
+	frm := ldapSyncForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if helper.LdapSync == nil || r.TenantID != helper.GlobalConfig["ldaptenant"] {
+		return r.NotFound(errors.New("LDAP sync is not configured"))
+	}
+
+	diff, err := helper.LdapSync.Run(frm.DryRun)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(helper.NewResult().SetData(diff))
+}
+
+func (l *LdapSync) Status(r *routing.WeContent) interface{} {
+	if helper.LdapSync == nil || r.TenantID != helper.GlobalConfig["ldaptenant"] {
+		return r.NotFound(errors.New("LDAP sync is not configured"))
+	}
+
+	res := helper.NewResult()
+
+	diff, err := helper.LdapSync.Last()
+	if err != nil {
+		res.SetMessage(err.Error())
+	}
+
+	return r.JSON(res.SetData(diff))
+}
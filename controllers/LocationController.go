@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+type Location struct {
+	*routing.BaseController
+}
+
+// Docs describes the location routes for the API reference.
+func (l *Location) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":    {Summary: "List locations, or view one by id", Request: orgPageForm{}, Response: []model.Location{}, View: model.Location{}, Result: true},
+		"Save":   {Summary: "Create or update a location", Request: model.Location{}, Response: model.Location{}},
+		"Delete": {Summary: "Delete a location", Response: model.Location{}},
+	}
+}
+
+func (l *Location) Get(r *routing.WeContent) interface{} {
+	frm := orgPageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	var dbFilter []*db.Filter
+
+	_, err := r.VarsGet("id")
+	isView := err == nil
+	if isView {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		dbFilter = append(dbFilter, db.Eq("_id", id))
+	}
+
+	if bson.IsObjectIdHex(frm.OrganizationId) {
+		dbFilter = append(dbFilter, db.Eq("OrganizationId", bson.ObjectIdHex(frm.OrganizationId)))
+	}
+
+	data := make([]model.Location, 0)
+	total, err := tenantScope(r).Find(new(model.Location), dbFilter, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	res := helper.NewResult()
+
+	if isView {
+		if len(data) == 0 {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(res.SetData(data[0]).SetTotal(total))
+	}
+
+	return r.JSON(res.SetData(data).SetTotal(total))
+}
+
+func (l *Location) Save(r *routing.WeContent) interface{} {
+	model := model.Location{}
+	if e := r.Parse(&model); e != nil {
+		return r.ServerError(e)
+	}
+
+	if _, err := r.VarsGet("id"); err == nil {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		model.Id = id
+	}
+
+	if model.Name == "" {
+		return r.ServerError(errors.New("Name is required"))
+	}
+
+	if err := tenantScope(r).Save(&model); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(model)
+}
+
+func (l *Location) Delete(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	if linked, err := hasLinkedRecords(tenantScope(r), new(model.Phonebook), "LocationId", id); err != nil {
+		return r.ServerError(err)
+	} else if linked {
+		return r.ServerError(errors.New("Location still has contacts"))
+	}
+
+	model := model.Location{
+		Id: id,
+	}
+	if err := tenantScope(r).Delete(&model); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(model)
+}
@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"bytes"
+	metrics "github.com/tmluthfiana/phonebook/modules/metrics"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+)
+
+type Metrics struct {
+	*routing.BaseController
+}
+
+// Docs describes the metrics route for the API reference.
+func (m *Metrics) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get": {Summary: "Prometheus metrics", ResponseType: metrics.ContentType},
+	}
+}
+
+// Get serves the metrics in the Prometheus text format.
+func (m *Metrics) Get(r *routing.WeContent) interface{} {
+	buf := &bytes.Buffer{}
+	if err := metrics.Default.Write(buf); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.Data(metrics.ContentType, buf.Bytes())
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	db "github.com/eaciit/dbox"
+)
+
+type Organization struct {
+	*routing.BaseController
+}
+
+// Docs describes the organization routes for the API reference.
+func (o *Organization) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":    {Summary: "List organizations, or view one by id", Request: pageForm{}, Response: []model.Organization{}, View: model.Organization{}, Result: true},
+		"Save":   {Summary: "Create or update a organization", Request: model.Organization{}, Response: model.Organization{}},
+		"Delete": {Summary: "Delete a organization", Response: model.Organization{}},
+	}
+}
+
+func (o *Organization) Get(r *routing.WeContent) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	var dbFilter []*db.Filter
+
+	_, err := r.VarsGet("id")
+	isView := err == nil
+	if isView {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		dbFilter = append(dbFilter, db.Eq("_id", id))
+	}
+
+	data := make([]model.Organization, 0)
+	total, err := tenantScope(r).Find(new(model.Organization), dbFilter, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	res := helper.NewResult()
+
+	if isView {
+		if len(data) == 0 {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(res.SetData(data[0]).SetTotal(total))
+	}
+
+	return r.JSON(res.SetData(data).SetTotal(total))
+}
+
+func (o *Organization) Save(r *routing.WeContent) interface{} {
+	model := model.Organization{}
+	if e := r.Parse(&model); e != nil {
+		return r.ServerError(e)
+	}
+
+	if _, err := r.VarsGet("id"); err == nil {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		model.Id = id
+	}
+
+	if model.Name == "" {
+		return r.ServerError(errors.New("Name is required"))
+	}
+
+	if err := tenantScope(r).Save(&model); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(model)
+}
+
+func (o *Organization) Delete(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	for _, m := range []model.TenantRecord{new(model.Department), new(model.Location), new(model.Phonebook)} {
+		if linked, err := hasLinkedRecords(tenantScope(r), m, "OrganizationId", id); err != nil {
+			return r.ServerError(err)
+		} else if linked {
+			return r.ServerError(errors.New("Organization is still in use"))
+		}
+	}
+
+	model := model.Organization{
+		Id: id,
+	}
+	if err := tenantScope(r).Delete(&model); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(model)
+}
@@ -2,15 +2,15 @@ package controllers
 
 import (
 	"errors"
-	"fmt"
 	helper "github.com/tmluthfiana/phonebook/helper"
 	model "github.com/tmluthfiana/phonebook/model"
 	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"strings"
+	"time"
 
 	"gopkg.in/mgo.v2/bson"
 
 	db "github.com/eaciit/dbox"
-	"github.com/eaciit/orm"
 	tk "github.com/eaciit/toolkit"
 )
 
@@ -18,13 +18,48 @@ type Phonebook struct {
 	*routing.BaseController
 }
 
+// contactInfo is a listed contact with where it comes from.
+type contactInfo struct {
+	model.Phonebook
+	Source model.ContactSource
+}
+
+// Docs describes the contact routes for the API reference.
+func (p *Phonebook) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get": {Summary: "List the visible contacts, or view one by id", Request: contactQuery{},
+			Response: []contactInfo{}, View: contactInfo{}, Result: true},
+		"Save": {Summary: "Create or update a contact", Description: "Directory contacts need phonebook.write, address book contacts write access to the book. Moving a contact needs write access to both books.",
+			Request: model.Phonebook{}, Response: model.Phonebook{}},
+		"Delete": {Summary: "Delete a contact", Description: "Directory contacts need phonebook.delete, address book contacts write access to the contact and its book.",
+			Response: model.Phonebook{}},
+		"Share": {Summary: "Share a contact of an own address book", Description: "An empty Access takes the share back.",
+			Request: model.Share{}, Response: model.Phonebook{}},
+		"UploadPhoto": {Summary: "Upload the photo of a contact", Description: "A multipart form with the image in the photo field.",
+			RequestType: "multipart/form-data", Response: model.Phonebook{}},
+		"Photo":       {Summary: "Download the photo of a contact", Query: []string{"size", "v"}, ResponseType: "image/*"},
+		"DeletePhoto": {Summary: "Delete the photo of a contact", Response: model.Phonebook{}},
+		"Sync": {Summary: "List the contacts changed since a sync token",
+			Description: "Without SyncToken the visible contacts are listed. Page with the returned SyncToken while More is set, keep the last one for the next sync.",
+			Request:     syncQuery{}, Response: syncResult{}},
+		"Events": {Summary: "Stream the contact changes as server-sent events",
+			Description: "Events are contact.created, contact.updated and contact.deleted with the contact as data. group (or book) is an address book id or directory, contacts have no tags. Reconnects resume after Last-Event-ID (or lastEventId), reset asks the client to reload the contacts.",
+			Query:       []string{"group", "book", "department", "lastEventId"}, ResponseType: "text/event-stream"},
+	}
+}
+
+// contactQuery is the payload of Get.
+type contactQuery struct {
+	Id   string
+	Take int
+	Skip int
+	Sort []tk.M
+	// AddressBookId limits the list to one book, "directory" to the shared directory
+	AddressBookId string
+}
+
 func (p *Phonebook) Get(r *routing.WeContent) interface{} {
-	frm := struct {
-		Id   string
-		Take int
-		Skip int
-		Sort []tk.M
-	}{}
+	frm := contactQuery{}
 	if e := r.Parse(&frm); e != nil {
 		return r.ServerError(e)
 	}
@@ -34,66 +69,50 @@ func (p *Phonebook) Get(r *routing.WeContent) interface{} {
 		frm.Id = v
 	}
 
-	qry := tk.M{
-		"limit": frm.Take,
-		"skip":  frm.Skip,
-	}
-
-	var dbFilter []*db.Filter
-
-	if frm.Id != "" {
-		dbFilter = append(dbFilter, db.Eq("_id", bson.ObjectIdHex(v)))
-	}
-
-	if len(dbFilter) > 0 {
-		qry.Set("where", db.And(dbFilter...))
-	}
-
-	conn, err := helper.ConnectToDB()
-	defer conn.Close()
+	access, err := loadBookAccess(r)
 	if err != nil {
 		return r.ServerError(err)
 	}
 
-	ctx := orm.New(conn)
-	crs, err := ctx.Find(new(model.Phonebook), qry)
-	defer crs.Close()
-	if err != nil {
-		return r.ServerError(err)
+	dbFilter := []*db.Filter{access.filter()}
+
+	if frm.AddressBookId == model.SourceDirectory {
+		dbFilter = append(dbFilter, inDirectory())
+	} else if bson.IsObjectIdHex(frm.AddressBookId) {
+		dbFilter = append(dbFilter, db.Eq("AddressBookId", bson.ObjectIdHex(frm.AddressBookId)))
+	} else if frm.AddressBookId != "" {
+		return r.BadRequest(errors.New("Invalid AddressBookId " + frm.AddressBookId))
 	}
 
-	data := make([]model.Phonebook, 0)
-	err = crs.Fetch(&data, 0, false)
-	if err != nil {
-		return r.ServerError(err)
+	if frm.Id != "" {
+		if !bson.IsObjectIdHex(frm.Id) {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		dbFilter = append(dbFilter, db.Eq("_id", bson.ObjectIdHex(frm.Id)))
 	}
 
-	qTotal := ctx.Connection.NewQuery()
-	qTotal.Where(dbFilter...)
-	crs, err = qTotal.Aggr(db.AggrSum, 1, "Count").From(new(model.Phonebook).TableName()).Group("").Cursor(nil)
-	defer crs.Close()
+	data := make([]model.Phonebook, 0)
+	total, err := tenantScope(r).Find(new(model.Phonebook), dbFilter, frm.Take, frm.Skip, &data)
 	if err != nil {
 		return r.ServerError(err)
 	}
 
-	total := 0
-	tkm := tk.M{}
-	crs.Fetch(&tkm, 1, false)
-	if tkm != nil {
-		total = tkm.GetInt("Count")
+	contacts := make([]contactInfo, 0, len(data))
+	for i := range data {
+		contacts = append(contacts, contactInfo{Phonebook: data[i], Source: access.source(&data[i])})
 	}
 
 	res := helper.NewResult()
 
 	if frm.Id != "" {
-		if len(data) > 0 {
-			return r.JSON(res.SetData(data[0]).SetTotal(total))
+		if len(contacts) > 0 {
+			return r.JSON(res.SetData(contacts[0]).SetTotal(total))
 		} else {
 			return r.NotFound(errors.New("ID not found"))
 		}
 	}
 
-	return r.JSON(res.SetData(data).SetTotal(total))
+	return r.JSON(res.SetData(contacts).SetTotal(total))
 }
 
 func (p *Phonebook) Save(r *routing.WeContent) interface{} {
@@ -102,53 +121,236 @@ func (p *Phonebook) Save(r *routing.WeContent) interface{} {
 		return r.ServerError(e)
 	}
 
-	fmt.Println(fmt.Sprintf("xxxx %+v", model))
-
-	v, err := r.VarsGet("id")
-	if err == nil {
+	if v, err := r.VarsGet("id"); err == nil {
+		if !bson.IsObjectIdHex(v) {
+			return r.NotFound(helper.ErrNotFound)
+		}
 		model.Id = bson.ObjectIdHex(v)
 	}
 
-	if model.LastName == "" {
-		return r.ServerError(errors.New("Last Name is required"))
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
 	}
 
-	if model.FirstName == "" {
-		return r.ServerError(errors.New("First Name is required"))
+	if err := storeContact(r, access, &model); err != nil {
+		return contactFailure(r, err)
 	}
 
-	if len(model.PhoneNumber) == 0 {
-		return r.ServerError(errors.New("Phone Number Required"))
+	return r.JSON(model)
+}
+
+func (c *Phonebook) Delete(r *routing.WeContent) interface{} {
+	v, _ := r.VarsGet("id")
+	if !bson.IsObjectIdHex(v) {
+		return r.NotFound(helper.ErrNotFound)
 	}
 
-	conn, err := helper.ConnectToDB()
-	defer conn.Close()
+	access, err := loadBookAccess(r)
 	if err != nil {
 		return r.ServerError(err)
 	}
 
-	tk.Printfn("model %+v", model)
-
-	err = helper.SaveRecord(&model)
+	model, err := removeContact(r, access, bson.ObjectIdHex(v))
 	if err != nil {
-		return r.ServerError(err)
+		return contactFailure(r, err)
 	}
 
 	return r.JSON(model)
 }
 
-func (c *Phonebook) Delete(r *routing.WeContent) interface{} {
-	v, err := r.VarsGet("id")
-	if err == nil {
-		tk.Printfn("r %+v", v)
+// The refusals of storeContact and removeContact
+var (
+	errContactReadOnly = errors.New("Contact is read-only")
+	errBookReadOnly    = errors.New("Address book is read-only")
+	errWriteDenied     = errors.New("Permission phonebook.write required")
+	errDeleteDenied    = errors.New("Permission phonebook.delete required")
+)
+
+// invalidContactError is a contact storeContact refused to save, REST
+// answers it like any other error.
+type invalidContactError struct {
+	error
+}
+
+// contactFailure answers an error of storeContact or removeContact.
+func contactFailure(r *routing.WeContent, err error) interface{} {
+	switch err {
+	case helper.ErrNotFound:
+		return r.NotFound(err)
+	case errContactReadOnly, errBookReadOnly, errWriteDenied, errDeleteDenied:
+		return r.Forbidden(err)
 	}
 
-	model := model.Phonebook{
-		Id: bson.ObjectIdHex(v),
+	return r.ServerError(err)
+}
+
+// storeContact creates or updates m when the request user may write m and
+// its address book, the REST and GraphQL APIs share it. The directory needs
+// phonebook.write, address books their own access.
+func storeContact(r *routing.WeContent, access *bookAccess, m *model.Phonebook) error {
+	existing := model.Phonebook{}
+	if m.Id != "" {
+		if err := tenantScope(r).Get(&existing, m.Id); err == nil {
+			if !access.canWrite(&existing) {
+				return errContactReadOnly
+			}
+			m.KeepServerFields(&existing)
+		}
 	}
-	if err := helper.DeleteRecord(&model); err != nil {
-		return r.ServerError(err)
+
+	directory := m.AddressBookId == "" || (existing.Id != "" && existing.AddressBookId == "")
+	if directory && !r.Can("phonebook.write") {
+		return errWriteDenied
 	}
 
-	return r.JSON(model)
+	if (existing.Id == "" || m.AddressBookId != existing.AddressBookId) && !access.canWriteTo(m.AddressBookId) {
+		return errBookReadOnly
+	}
+
+	// a contact leaves its book only for those who may write the book, not
+	// for those it is shared with
+	if existing.Id != "" && m.AddressBookId != existing.AddressBookId && !access.canWriteTo(existing.AddressBookId) {
+		return errBookReadOnly
+	}
+
+	if existing.Id == "" {
+		m.CreatedBy = r.UserName()
+	} else {
+		m.UpdateBy = r.UserName()
+	}
+
+	if err := validateContact(tenantScope(r), m); err != nil {
+		return invalidContactError{err}
+	}
+
+	if err := tenantScope(r).Save(m); err != nil {
+		return err
+	}
+
+	return leaveBook(tenantScope(r), &existing, m, r.UserName())
+}
+
+// removeContact deletes the contact id when the request user may write it
+// and its book, the directory needs phonebook.delete. Contacts the user
+// cannot see are not found.
+func removeContact(r *routing.WeContent, access *bookAccess, id bson.ObjectId) (*model.Phonebook, error) {
+	m := &model.Phonebook{}
+
+	// the stored contact names the tombstone of CardDAV cards
+	if err := tenantScope(r).Get(m, id); err != nil || !access.canRead(m) {
+		return nil, helper.ErrNotFound
+	}
+
+	if m.AddressBookId == "" && !r.Can("phonebook.delete") {
+		return nil, errDeleteDenied
+	}
+
+	if !access.canWrite(m) {
+		return nil, errContactReadOnly
+	}
+
+	// like a move, deleting takes the contact out of its book
+	if !access.canWriteTo(m.AddressBookId) {
+		return nil, errBookReadOnly
+	}
+
+	if err := deleteContact(tenantScope(r), m, r.UserName()); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// validateContact checks a contact before it is saved, whichever API it
+// came from.
+func validateContact(scope helper.TenantScope, m *model.Phonebook) error {
+	if m.LastName == "" {
+		return errors.New("Last Name is required")
+	}
+
+	if m.FirstName == "" {
+		return errors.New("First Name is required")
+	}
+
+	if len(m.PhoneNumber) == 0 {
+		return errors.New("Phone Number Required")
+	}
+
+	m.NormalizeEmail()
+	for _, e := range m.Emails {
+		if !strings.Contains(e.EmailAddress, "@") {
+			return errors.New("Invalid Email Address " + e.EmailAddress)
+		}
+	}
+
+	if m.Birthday != "" {
+		if _, err := time.Parse("2006-01-02", m.Birthday); err != nil {
+			return errors.New("Birthday must be formatted as YYYY-MM-DD")
+		}
+	}
+
+	if err := checkDirectoryLinks(scope, m); err != nil {
+		return err
+	}
+
+	return checkManager(scope, m)
+}
+
+// deleteContact removes a contact with its photos and leaves a tombstone
+// for syncing clients.
+func deleteContact(scope helper.TenantScope, m *model.Phonebook, by string) error {
+	if err := scope.Delete(m); err != nil {
+		return err
+	}
+
+	if err := deletePhotos(m.Id.Hex()); err != nil {
+		return err
+	}
+
+	// detach the direct reports so they do not point at a missing manager,
+	// stamped as updated so syncing clients pick the change up
+	if err := scope.Update(m, db.Eq("ManagerId", m.Id), tk.M{"ManagerId": nil, "UpdateDate": time.Now(), "UpdateBy": by}); err != nil {
+		return err
+	}
+
+	return scope.Save(model.NewTombstone(m, by))
+}
+
+// leaveBook leaves a tombstone of existing when m moved it to another book,
+// so the clients syncing the old book drop it.
+func leaveBook(scope helper.TenantScope, existing, m *model.Phonebook, by string) error {
+	if existing.Id == "" || existing.AddressBookId == m.AddressBookId {
+		return nil
+	}
+
+	return scope.Save(model.NewTombstone(existing, by))
+}
+
+// SaveContact validates and stores a contact like Save does, for tools
+// working on the database without a request such as phonebookctl -offline.
+// Address book access is not checked.
+func SaveContact(scope helper.TenantScope, m *model.Phonebook, by string) error {
+	existing := model.Phonebook{}
+	if m.Id != "" && scope.Get(&existing, m.Id) == nil {
+		m.KeepServerFields(&existing)
+		m.UpdateBy = by
+	} else {
+		m.CreatedBy = by
+	}
+
+	if err := validateContact(scope, m); err != nil {
+		return err
+	}
+
+	if err := scope.Save(m); err != nil {
+		return err
+	}
+
+	return leaveBook(scope, &existing, m, by)
+}
+
+// DeleteContact removes a contact like Delete does, see SaveContact.
+func DeleteContact(scope helper.TenantScope, m *model.Phonebook, by string) error {
+	return deleteContact(scope, m, by)
 }
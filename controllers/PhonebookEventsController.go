@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	sse "github.com/tmluthfiana/phonebook/modules/sse"
+	"net/url"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+const (
+	// eventBuffer is how many events a stream may fall behind before it is
+	// ended, the client reconnects and resumes from the event log
+	eventBuffer = 256
+	// eventReplayMax is the most events a resumption replays, clients
+	// further behind reload the contacts
+	eventReplayMax  = 10000
+	eventReplayPage = 500
+	eventHeartbeat  = 30 * time.Second
+)
+
+// The events of /phonebook/events besides the contact changes: ready starts
+// a fresh stream, reset asks the client to reload the contacts. Both carry
+// the id of the newest logged event to resume from.
+const (
+	eventReady = "ready"
+	eventReset = "reset"
+)
+
+// eventFilter narrows an event stream to the contacts a client shows.
+type eventFilter struct {
+	access *bookAccess
+	tenant string
+	// book is an address book id or "directory", empty for all books, the
+	// address book being the group of a contact
+	book       string
+	department bson.ObjectId
+}
+
+func (f *eventFilter) match(e *events.Event) bool {
+	c := &e.Contact
+
+	return e.TenantId == f.tenant && inBook(c, f.book) &&
+		(f.department == "" || c.DepartmentId == f.department) && f.access.canRead(c)
+}
+
+// parseEventFilter reads the group (or book) and department of the query,
+// contacts have no tags so a tag is refused rather than ignored.
+func parseEventFilter(q url.Values, tenant string) (*eventFilter, error) {
+	filter := &eventFilter{tenant: tenant, book: q.Get("group")}
+	if filter.book == "" {
+		filter.book = q.Get("book")
+	}
+	if _, err := bookFilter(filter.book); err != nil {
+		return nil, err
+	}
+	if q.Has("tag") {
+		return nil, errors.New("Contacts have no tags, filter by group or department")
+	}
+	if v := q.Get("department"); v != "" {
+		if !bson.IsObjectIdHex(v) {
+			return nil, errors.New("Invalid department " + v)
+		}
+		filter.department = bson.ObjectIdHex(v)
+	}
+
+	return filter, nil
+}
+
+func (p *Phonebook) Events(r *routing.WeContent) interface{} {
+	filter, err := parseEventFilter(r.Req.URL.Query(), r.TenantID)
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+	filter.access = access
+
+	// subscribe before reading the log, so no change falls between the two
+	sub := helper.ContactEvents.Subscribe(eventBuffer)
+	defer sub.Close()
+
+	stream, err := sse.Start(r.Writer)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	replayed, err := replayEvents(tenantScope(r), sse.LastEventID(r.Req), filter, stream)
+	if err != nil {
+		r.Log().Warn("Event stream not resumed", "error", err)
+		return nil
+	}
+
+	heartbeat := time.NewTicker(eventHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Req.Context().Done():
+			return nil
+		case <-helper.EventStreamsClosed:
+			return nil
+		case <-heartbeat.C:
+			if err := stream.Comment("keep-alive"); err != nil {
+				return nil
+			}
+		case e, ok := <-sub.C:
+			// a stream that fell behind ends, the client resumes from the log
+			if !ok {
+				return nil
+			}
+			if replayed[e.Id] || !filter.match(&e) {
+				continue
+			}
+			if err := sendEvent(stream, e); err != nil {
+				return nil
+			}
+		}
+	}
+}
+
+// replayEvents sends the logged events after the client's last event id and
+// returns their ids. Streams without an id start with ready, clients the log
+// cannot catch up get reset.
+func replayEvents(scope helper.TenantScope, last string, filter *eventFilter, stream *sse.Stream) (map[string]bool, error) {
+	replayed := map[string]bool{}
+
+	if last == "" {
+		return replayed, sendStart(scope, stream, eventReady)
+	}
+	if !bson.IsObjectIdHex(last) || bson.ObjectIdHex(last).Time().Before(time.Now().Add(-helper.EventRetention())) {
+		return replayed, sendStart(scope, stream, eventReset)
+	}
+
+	after := bson.ObjectIdHex(last)
+	for {
+		data := make([]model.ContactEvent, 0)
+		total, err := scope.FindSorted(new(model.ContactEvent), []*db.Filter{db.Gt("_id", after)}, []string{"_id"}, eventReplayPage, 0, &data)
+		if err != nil {
+			return nil, err
+		}
+		if len(replayed) == 0 && total > eventReplayMax {
+			return replayed, sendStart(scope, stream, eventReset)
+		}
+
+		for _, rec := range data {
+			e := events.Event{Id: rec.Id.Hex(), Type: rec.Type, TenantId: rec.TenantId, Contact: rec.Contact, Time: rec.Time}
+			replayed[e.Id] = true
+			after = rec.Id
+			if !filter.match(&e) {
+				continue
+			}
+			if err := sendEvent(stream, e); err != nil {
+				return nil, err
+			}
+		}
+
+		if len(data) < eventReplayPage {
+			return replayed, nil
+		}
+	}
+}
+
+// sendStart sends ready or reset with the id of the newest logged event.
+func sendStart(scope helper.TenantScope, stream *sse.Stream, typ string) error {
+	newest := make([]model.ContactEvent, 0)
+	if _, err := scope.FindSorted(new(model.ContactEvent), nil, []string{"-_id"}, 1, 0, &newest); err != nil {
+		return err
+	}
+
+	id := ""
+	if len(newest) > 0 {
+		id = newest[0].Id.Hex()
+	}
+
+	return stream.Send(sse.Event{Id: id, Event: typ, Data: []byte("{}")})
+}
+
+func sendEvent(stream *sse.Stream, e events.Event) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	return stream.Send(sse.Event{Id: e.Id, Event: e.Type, Data: data})
+}
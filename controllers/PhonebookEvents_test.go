@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"net/url"
+	"testing"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestEventFilter(t *testing.T) {
+	dept, own, other := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	access := &bookAccess{userId: "tias", read: true, books: map[bson.ObjectId]model.AddressBook{own: {Id: own, OwnerId: "tias"}}}
+
+	directory := events.Event{TenantId: "acme", Contact: model.Phonebook{DepartmentId: dept}}
+	mine := events.Event{TenantId: "acme", Contact: model.Phonebook{AddressBookId: own}}
+	hidden := events.Event{TenantId: "acme", Contact: model.Phonebook{AddressBookId: other}}
+	elsewhere := events.Event{TenantId: "other", Contact: model.Phonebook{DepartmentId: dept}}
+
+	for _, c := range []struct {
+		filter eventFilter
+		want   []bool
+	}{
+		{eventFilter{}, []bool{true, true, false, false}},
+		{eventFilter{book: model.SourceDirectory}, []bool{true, false, false, false}},
+		{eventFilter{book: own.Hex()}, []bool{false, true, false, false}},
+		{eventFilter{department: dept}, []bool{true, false, false, false}},
+	} {
+		c.filter.access, c.filter.tenant = access, "acme"
+		for i, e := range []events.Event{directory, mine, hidden, elsewhere} {
+			if got := c.filter.match(&e); got != c.want[i] {
+				t.Errorf("%+v matched event %d: %v", c.filter, i, got)
+			}
+		}
+	}
+}
+
+func TestParseEventFilter(t *testing.T) {
+	book := bson.NewObjectId()
+
+	for query, want := range map[string]string{
+		"group=" + book.Hex():                book.Hex(),
+		"book=directory":                     model.SourceDirectory,
+		"group=directory&book=" + book.Hex(): model.SourceDirectory,
+		"":                                   "",
+	} {
+		q, _ := url.ParseQuery(query)
+		f, err := parseEventFilter(q, "acme")
+		if err != nil || f.book != want || f.tenant != "acme" {
+			t.Errorf("%q: %+v, %v", query, f, err)
+		}
+	}
+
+	for _, query := range []string{"group=nope", "tag=vip", "department=x"} {
+		q, _ := url.ParseQuery(query)
+		if _, err := parseEventFilter(q, "acme"); err == nil {
+			t.Errorf("%q accepted", query)
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	photo "github.com/tmluthfiana/phonebook/modules/photo"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func (p *Phonebook) UploadPhoto(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	contact := model.Phonebook{}
+	if status := writableContact(r, &contact, id); status != nil {
+		return status
+	}
+
+	// leave room for the multipart framing around the image itself
+	r.Req.Body = http.MaxBytesReader(r.Writer, r.Req.Body, photo.MaxSize+1<<20)
+	file, _, err := r.Req.FormFile("photo")
+	if err != nil {
+		return r.BadRequest(errors.New("Photo is required"))
+	}
+	defer file.Close()
+
+	data, err := ioutil.ReadAll(io.LimitReader(file, photo.MaxSize+1))
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	files, err := photo.Process(id.Hex(), data)
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	store := helper.NewPhotoStore()
+	for _, f := range files {
+		if err := store.Put(f); err != nil {
+			return r.ServerError(err)
+		}
+	}
+
+	version := photo.Version(data)
+	contact.Photo = &model.PhotoDetail{
+		PhotoUrl:    "/phonebook/photo/view/" + id.Hex() + "?v=" + version,
+		ContentType: files[0].ContentType,
+		Version:     version,
+		UploadDate:  files[0].UploadDate,
+	}
+	contact.UpdateBy = r.UserName()
+
+	if err := tenantScope(r).Save(&contact); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(contact)
+}
+
+func (p *Phonebook) Photo(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	contact := model.Phonebook{}
+	if err := tenantScope(r).Get(&contact, id); err != nil || !access.canRead(&contact) {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	size := r.Req.URL.Query().Get("size")
+	if size == "" {
+		size = photo.Original
+	} else if !validPhotoSize(size) {
+		return r.BadRequest(errors.New("Invalid photo size " + size))
+	}
+
+	f, err := helper.NewPhotoStore().Get(photo.Name(id.Hex(), size))
+	if err == photo.ErrNotFound {
+		return r.NotFound(err)
+	} else if err != nil {
+		return r.ServerError(err)
+	}
+
+	etag := `"` + photo.Version(f.Data) + `"`
+	h := r.Writer.Header()
+	// photos of address book contacts must not end up in shared caches
+	if contact.AddressBookId == "" {
+		h.Set("Cache-Control", "public, max-age=86400")
+	} else {
+		h.Set("Cache-Control", "private, max-age=86400")
+	}
+	h.Set("ETag", etag)
+	h.Set("Last-Modified", f.UploadDate.UTC().Format(http.TimeFormat))
+
+	if r.Req.Header.Get("If-None-Match") == etag {
+		r.Writer.WriteHeader(http.StatusNotModified)
+		return []byte{}
+	}
+
+	return r.Data(f.ContentType, f.Data)
+}
+
+func (p *Phonebook) DeletePhoto(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	contact := model.Phonebook{}
+	if status := writableContact(r, &contact, id); status != nil {
+		return status
+	}
+
+	if err := deletePhotos(id.Hex()); err != nil {
+		return r.ServerError(err)
+	}
+
+	contact.Photo = nil
+	contact.UpdateBy = r.UserName()
+	if err := tenantScope(r).Save(&contact); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(contact)
+}
+
+// writableContact loads the contact with id when the user may change it,
+// otherwise it returns the error response.
+func writableContact(r *routing.WeContent, contact *model.Phonebook, id bson.ObjectId) interface{} {
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	if err := tenantScope(r).Get(contact, id); err != nil || !access.canRead(contact) {
+		return r.NotFound(helper.ErrNotFound)
+	}
+
+	if !access.canWrite(contact) {
+		return r.Forbidden(errors.New("Contact is read-only"))
+	}
+
+	return nil
+}
+
+func deletePhotos(contactId string) error {
+	store := helper.NewPhotoStore()
+	for _, name := range photo.Names(contactId) {
+		if err := store.Delete(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func validPhotoSize(size string) bool {
+	for _, s := range photo.Sizes {
+		if strconv.Itoa(s) == size {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+)
+
+const (
+	syncTake    = 500
+	syncMaxTake = 1000
+	// syncLag keeps a sync that far behind the clock, so contacts stamped
+	// just before it but stored just after are picked up by the next sync
+	syncLag = 5 * time.Second
+)
+
+// syncQuery is the payload of Sync.
+type syncQuery struct {
+	// SyncToken is the token of the previous answer, empty for a first sync
+	SyncToken string
+	// Take is the page size, 500 by default and at most 1000
+	Take int
+	// AddressBookId limits the sync to one book, "directory" to the shared directory
+	AddressBookId string
+}
+
+// syncResult is the answer of Sync.
+type syncResult struct {
+	// Reset tells the client to drop its copy, the token was given for
+	// another user, access or book and the sync started over
+	Reset bool
+	// Contacts were created or updated since the token
+	Contacts []contactInfo
+	// Deleted are the ids of contacts the user could see before they were
+	// deleted, moved to another book or unshared since the token, they may
+	// include contacts the client never had
+	Deleted []bson.ObjectId
+	// More asks for the next page with SyncToken before the copy is complete
+	More      bool
+	SyncToken string
+}
+
+// syncToken is where a client is in the contact changes. A sync covers the
+// changes after Since up to Until, paging by contact id after After.
+type syncToken struct {
+	// Since and Until are unix milliseconds, Since is 0 before the first
+	// complete sync and Until 0 between syncs
+	Since int64         `json:"s"`
+	Until int64         `json:"u,omitempty"`
+	After bson.ObjectId `json:"a,omitempty"`
+	// Scope fingerprints the user, access and book the token was given for
+	Scope string `json:"k"`
+}
+
+func (t syncToken) String() string {
+	b, _ := json.Marshal(t)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func parseSyncToken(s string) (syncToken, error) {
+	t := syncToken{}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || json.Unmarshal(b, &t) != nil || t.Since < 0 || t.Until < 0 || t.Scope == "" {
+		return t, errors.New("Invalid SyncToken")
+	}
+
+	return t, nil
+}
+
+func millis(ms int64) time.Time {
+	return time.Unix(0, ms*int64(time.Millisecond))
+}
+
+// syncScope fingerprints what the user can see of book, a token is only
+// good while it stays the same.
+func syncScope(a *bookAccess, book string) string {
+	books := make([]string, 0, len(a.books))
+	for id, b := range a.books {
+		books = append(books, id.Hex()+"="+b.Access(a.userId, a.groups))
+	}
+	sort.Strings(books)
+
+	sum := sha256.Sum256([]byte(strings.Join(append([]string{a.userId, book, strconv.FormatBool(a.read)}, books...), "\n")))
+
+	return hex.EncodeToString(sum[:8])
+}
+
+// syncStore reads what Sync needs, tenantSyncStore in the database. Every
+// read is limited to what access can see of book.
+type syncStore interface {
+	// changed pages the contacts by id after after, with since set only those
+	// created or updated after since up to until
+	changed(access *bookAccess, book string, since, until time.Time, after bson.ObjectId, take int) ([]model.Phonebook, error)
+	// tombstones are those stamped after since up to until
+	tombstones(access *bookAccess, book string, since, until time.Time) ([]model.Tombstone, error)
+	// visible are the contacts of ids
+	visible(access *bookAccess, book string, ids []interface{}) ([]model.Phonebook, error)
+}
+
+type tenantSyncStore struct {
+	scope helper.TenantScope
+}
+
+// filter matches what access can see of book, and with since set the
+// records stamped by one of fields in the window
+func (s tenantSyncStore) filter(access *bookAccess, book string, since, until time.Time, fields ...string) []*db.Filter {
+	filter := []*db.Filter{access.filter()}
+	if f, _ := bookFilter(book); f != nil {
+		filter = append(filter, f)
+	}
+
+	if !since.IsZero() {
+		window := []*db.Filter{}
+		for _, field := range fields {
+			window = append(window, db.And(db.Gt(field, since), db.Lte(field, until)))
+		}
+		filter = append(filter, db.Or(window...))
+	}
+
+	return filter
+}
+
+func (s tenantSyncStore) changed(access *bookAccess, book string, since, until time.Time, after bson.ObjectId, take int) ([]model.Phonebook, error) {
+	filter := s.filter(access, book, since, until, "CreatedDate", "UpdateDate")
+	if after != "" {
+		filter = append(filter, db.Gt("_id", after))
+	}
+
+	data := make([]model.Phonebook, 0)
+	_, err := s.scope.FindSorted(new(model.Phonebook), filter, []string{"_id"}, take, 0, &data)
+
+	return data, err
+}
+
+func (s tenantSyncStore) tombstones(access *bookAccess, book string, since, until time.Time) ([]model.Tombstone, error) {
+	data := make([]model.Tombstone, 0)
+	_, err := s.scope.Find(new(model.Tombstone), s.filter(access, book, since, until, "DeletedDate"), 0, 0, &data)
+
+	return data, err
+}
+
+func (s tenantSyncStore) visible(access *bookAccess, book string, ids []interface{}) ([]model.Phonebook, error) {
+	data := make([]model.Phonebook, 0)
+	if len(ids) == 0 {
+		return data, nil
+	}
+
+	filter := append(s.filter(access, book, time.Time{}, time.Time{}), db.In("_id", ids...))
+	_, err := s.scope.Find(new(model.Phonebook), filter, 0, 0, &data)
+
+	return data, err
+}
+
+// invalidSyncError is a query syncContacts refused, REST answers it with
+// 400.
+type invalidSyncError struct {
+	error
+}
+
+func (p *Phonebook) Sync(r *routing.WeContent) interface{} {
+	frm := syncQuery{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	access, err := loadBookAccess(r)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	res, err := syncContacts(tenantSyncStore{tenantScope(r)}, access, frm, time.Now())
+	var invalid invalidSyncError
+	switch {
+	case errors.As(err, &invalid):
+		return r.BadRequest(invalid.error)
+	case err != nil:
+		return r.ServerError(err)
+	}
+
+	return r.JSON(res)
+}
+
+// syncContacts answers a sync of access at now. Contacts and tombstones
+// outside what access sees of the book are never read, a tombstone only
+// counts when its contact was visible before the change that left it and is
+// not visible any more.
+func syncContacts(store syncStore, access *bookAccess, frm syncQuery, now time.Time) (*syncResult, error) {
+	take := frm.Take
+	if take <= 0 {
+		take = syncTake
+	}
+	take = min(take, syncMaxTake)
+
+	if _, err := bookFilter(frm.AddressBookId); err != nil {
+		return nil, invalidSyncError{err}
+	}
+
+	res := &syncResult{Contacts: []contactInfo{}, Deleted: []bson.ObjectId{}}
+	token := syncToken{Scope: syncScope(access, frm.AddressBookId)}
+	if frm.SyncToken != "" {
+		given, err := parseSyncToken(frm.SyncToken)
+		if err != nil {
+			return nil, invalidSyncError{err}
+		}
+		if given.Scope == token.Scope {
+			token = given
+		} else {
+			res.Reset = true
+		}
+	}
+	if token.Until == 0 {
+		token.Until = now.Add(-syncLag).UnixNano() / int64(time.Millisecond)
+	}
+
+	// a first sync takes the visible contacts as they are, later syncs the
+	// visible contacts changed in the window
+	var since time.Time
+	if token.Since != 0 {
+		since = millis(token.Since)
+	}
+	until := millis(token.Until)
+
+	data, err := store.changed(access, frm.AddressBookId, since, until, token.After, take+1)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > take {
+		data = data[:take]
+		res.More = true
+		token.After = data[take-1].Id
+	}
+
+	for i := range data {
+		c := &data[i]
+		res.Contacts = append(res.Contacts, contactInfo{Phonebook: *c, Source: access.source(c)})
+	}
+
+	if !res.More {
+		if !since.IsZero() {
+			if res.Deleted, err = syncDeleted(store, access, frm.AddressBookId, since, until); err != nil {
+				return nil, err
+			}
+		}
+
+		token = syncToken{Since: token.Until, Scope: token.Scope}
+	}
+
+	res.SyncToken = token.String()
+
+	return res, nil
+}
+
+// syncDeleted are the contacts of the tombstones in the window that access
+// cannot see any more, a contact moved to another visible book or still
+// shared another way stays.
+func syncDeleted(store syncStore, access *bookAccess, book string, since, until time.Time) ([]bson.ObjectId, error) {
+	deleted, err := store.tombstones(access, book, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]interface{}, 0, len(deleted))
+	for _, t := range deleted {
+		ids = append(ids, t.ContactId)
+	}
+
+	visible, err := store.visible(access, book, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[bson.ObjectId]bool{}
+	for _, c := range visible {
+		seen[c.Id] = true
+	}
+
+	res := []bson.ObjectId{}
+	for _, t := range deleted {
+		if !seen[t.ContactId] {
+			seen[t.ContactId] = true
+			res = append(res, t.ContactId)
+		}
+	}
+
+	return res, nil
+}
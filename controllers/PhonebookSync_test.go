@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestSyncToken(t *testing.T) {
+	token := syncToken{Since: 1700000000000, Until: 1700000005000, After: bson.NewObjectId(), Scope: "0123456789abcdef"}
+
+	back, err := parseSyncToken(token.String())
+	if err != nil || back != token {
+		t.Errorf("parsed %+v, %v", back, err)
+	}
+
+	for _, s := range []string{"nope!", syncToken{Since: 1}.String(), syncToken{Since: -1, Scope: "x"}.String()} {
+		if _, err := parseSyncToken(s); err == nil {
+			t.Errorf("%q accepted", s)
+		}
+	}
+}
+
+func TestSyncScope(t *testing.T) {
+	book := bson.NewObjectId()
+	access := &bookAccess{userId: "tias", read: true, books: map[bson.ObjectId]model.AddressBook{book: {Id: book, OwnerId: "tias"}}}
+	scope := syncScope(access, "")
+
+	if syncScope(access, "") != scope {
+		t.Error("scope not stable")
+	}
+	if syncScope(access, model.SourceDirectory) == scope {
+		t.Error("book not in the scope")
+	}
+
+	other := *access
+	other.userId = "budi"
+	if syncScope(&other, "") == scope {
+		t.Error("user not in the scope")
+	}
+
+	other = *access
+	other.read = false
+	if syncScope(&other, "") == scope {
+		t.Error("directory access not in the scope")
+	}
+
+	other = *access
+	other.books = map[bson.ObjectId]model.AddressBook{book: {Id: book, OwnerId: "budi"}}
+	if syncScope(&other, "") == scope {
+		t.Error("book access not in the scope")
+	}
+}
+
+// memorySyncStore is a syncStore over slices, reading through the access
+// checks of the contact handlers.
+type memorySyncStore struct {
+	contacts []model.Phonebook
+	deleted  []model.Tombstone
+}
+
+func inWindow(t, since, until time.Time) bool {
+	return t.After(since) && !t.After(until)
+}
+
+func (s *memorySyncStore) changed(access *bookAccess, book string, since, until time.Time, after bson.ObjectId, take int) ([]model.Phonebook, error) {
+	res := []model.Phonebook{}
+	for _, c := range s.contacts {
+		if !access.canRead(&c) || !inBook(&c, book) || (after != "" && c.Id <= after) {
+			continue
+		}
+		if !since.IsZero() && !inWindow(c.CreatedDate, since, until) && !inWindow(c.UpdateDate, since, until) {
+			continue
+		}
+		if len(res) < take {
+			res = append(res, c)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memorySyncStore) tombstones(access *bookAccess, book string, since, until time.Time) ([]model.Tombstone, error) {
+	res := []model.Tombstone{}
+	for _, t := range s.deleted {
+		was := &model.Phonebook{AddressBookId: t.AddressBookId, Shares: t.Shares}
+		if access.canRead(was) && inBook(was, book) && inWindow(t.DeletedDate, since, until) {
+			res = append(res, t)
+		}
+	}
+
+	return res, nil
+}
+
+func (s *memorySyncStore) visible(access *bookAccess, book string, ids []interface{}) ([]model.Phonebook, error) {
+	res := []model.Phonebook{}
+	for _, c := range s.contacts {
+		for _, id := range ids {
+			if c.Id == id && access.canRead(&c) && inBook(&c, book) {
+				res = append(res, c)
+			}
+		}
+	}
+
+	return res, nil
+}
+
+// syncIds are the ids of the contacts of res
+func syncIds(res *syncResult) map[bson.ObjectId]bool {
+	ids := map[bson.ObjectId]bool{}
+	for _, c := range res.Contacts {
+		ids[c.Id] = true
+	}
+
+	return ids
+}
+
+func TestSyncContacts(t *testing.T) {
+	own, other, private := bson.NewObjectId(), bson.NewObjectId(), bson.NewObjectId()
+	access := &bookAccess{userId: "tias", read: true, books: map[bson.ObjectId]model.AddressBook{
+		own:   {Id: own, OwnerId: "tias"},
+		other: {Id: other, OwnerId: "tias"},
+	}}
+	start := time.Now().Add(-time.Hour)
+
+	// contact ids are made in order, so the pages come in this order
+	contact := func(book bson.ObjectId, shares ...model.Share) model.Phonebook {
+		return model.Phonebook{Id: bson.NewObjectId(), AddressBookId: book, Shares: shares, CreatedDate: start, UpdateDate: start}
+	}
+	listed := contact("")
+	kept := contact(own)
+	moved := contact(own)
+	hidden := contact(private)
+	shared := contact(private, model.Share{UserId: "tias", Access: model.AccessRead})
+	store := &memorySyncStore{contacts: []model.Phonebook{listed, kept, moved, hidden, shared}}
+
+	first, err := syncContacts(store, access, syncQuery{}, start.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := syncIds(first); len(ids) != 4 || ids[hidden.Id] || first.More || first.Reset || len(first.Deleted) != 0 {
+		t.Fatalf("first sync %v, %+v", ids, first)
+	}
+
+	// in the next window the private contacts change, one is deleted, a
+	// contact moves to the private book and the share is taken back
+	changed := start.Add(2 * time.Minute)
+	tombstone := func(c model.Phonebook) model.Tombstone {
+		t := *model.NewTombstone(&c, "budi")
+		t.DeletedDate = changed
+		return t
+	}
+	gone := contact(private)
+	store.deleted = []model.Tombstone{tombstone(moved), tombstone(shared), tombstone(gone)}
+	store.contacts[0].UpdateDate = changed
+	store.contacts[2].AddressBookId, store.contacts[2].UpdateDate = private, changed
+	store.contacts[3].UpdateDate = changed
+	store.contacts[4].Shares, store.contacts[4].UpdateDate = nil, changed
+	store.contacts = append(store.contacts, contact(private))
+	store.contacts[5].CreatedDate = changed
+
+	next, err := syncContacts(store, access, syncQuery{SyncToken: first.SyncToken}, changed.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := syncIds(next); len(ids) != 1 || !ids[listed.Id] {
+		t.Errorf("changed %v", ids)
+	}
+	deleted := map[bson.ObjectId]bool{}
+	for _, id := range next.Deleted {
+		deleted[id] = true
+	}
+	if len(deleted) != 2 || !deleted[moved.Id] || !deleted[shared.Id] {
+		t.Errorf("deleted %v, want the moved and unshared contacts only", next.Deleted)
+	}
+
+	// a contact moved between books the user sees is changed, not deleted
+	moveAt := changed.Add(2 * time.Minute)
+	store.deleted = []model.Tombstone{tombstone(store.contacts[1])}
+	store.deleted[0].DeletedDate = moveAt
+	store.contacts[1].AddressBookId, store.contacts[1].UpdateDate = other, moveAt
+
+	again, err := syncContacts(store, access, syncQuery{SyncToken: next.SyncToken}, moveAt.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := syncIds(again); len(ids) != 1 || !ids[kept.Id] || len(again.Deleted) != 0 {
+		t.Errorf("move %v, deleted %v", ids, again.Deleted)
+	}
+}
+
+func TestSyncContactsPages(t *testing.T) {
+	book := bson.NewObjectId()
+	access := &bookAccess{userId: "tias", books: map[bson.ObjectId]model.AddressBook{book: {Id: book, OwnerId: "tias"}}}
+	store := &memorySyncStore{}
+	for i := 0; i < 5; i++ {
+		store.contacts = append(store.contacts, model.Phonebook{Id: bson.NewObjectId(), AddressBookId: book})
+	}
+	// the directory is not readable without phonebook.read
+	store.contacts = append(store.contacts, model.Phonebook{Id: bson.NewObjectId()})
+
+	seen := map[bson.ObjectId]bool{}
+	frm := syncQuery{Take: 2}
+	for pages := 1; ; pages++ {
+		res, err := syncContacts(store, access, frm, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for id := range syncIds(res) {
+			seen[id] = true
+		}
+		if !res.More {
+			if pages != 3 || len(seen) != 5 {
+				t.Errorf("%d pages, %d contacts", pages, len(seen))
+			}
+			break
+		}
+		frm.SyncToken = res.SyncToken
+	}
+
+	for _, frm := range []syncQuery{{AddressBookId: "nope"}, {SyncToken: "nope!"}} {
+		var invalid invalidSyncError
+		if _, err := syncContacts(store, access, frm, time.Now()); !errors.As(err, &invalid) {
+			t.Errorf("%+v: %v", frm, err)
+		}
+	}
+}
@@ -3,157 +3,185 @@ package controllers
 import (
 	"bytes"
 	"encoding/json"
-	"fmt"
+	"errors"
 	helper "github.com/tmluthfiana/phonebook/helper"
 	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
 	"net/http"
+	"net/http/httptest"
 	"testing"
+
+	acl "github.com/eaciit/acl/v1.0"
+	_ "github.com/eaciit/dbox/dbc/mongo"
 )
 
-func TestPhonebookGet(t *testing.T) {
-	payload := struct {
-		Take int
-		Skip int
-	}{
-		Take: 10,
-		Skip: 0,
-	}
+// The phonebook tests serve the phonebook routes in process on the
+// database of helper.GlobalConfig. The session lookup and the acl checks
+// are stubbed, so no login or running server is needed.
 
-	out, err := json.Marshal(&payload)
-	if err != nil {
-		panic(err)
-	}
+const testSession = "phonebook-test"
 
-	payloadBuff := bytes.NewBufferString(string(out))
+var (
+	testURL       string
+	testDBChecked bool
+	testDBErr     error
+)
 
-	cli := http.Client{}
-	req, err := http.NewRequest(http.MethodGet, "http://localhost:3030/phonebook/get", payloadBuff)
-	req.Header["Content-type"] = []string{"application/json"}
-	if err != nil {
-		t.Error("Failed Create Connection")
+// testServer starts the router once, the routes and middlewares are those
+// of main.go
+func testServer() string {
+	if testURL != "" {
+		return testURL
 	}
 
-	resp, err := cli.Do(req)
-	if err != nil {
-		t.Error("Failed Call Connection")
+	routing.FindSessionUser = func(sessionId string) (*acl.User, error) {
+		if sessionId != testSession {
+			return nil, errors.New("Session is not active")
+		}
+		return &acl.User{ID: "admin", LoginID: "admin", FullName: "Administrator", Enable: true}, nil
 	}
-
-	response := helper.Result{}
-	decoder := json.NewDecoder(resp.Body)
-	defer resp.Body.Close()
-	err = decoder.Decode(&response)
-	t.Log(response)
-	if err != nil {
-		t.Error("Failed to decode")
+	routing.HasAccess = func(*acl.User, string, acl.AccessTypeEnum) bool {
+		return true
 	}
+
+	rt := routing.NewRouting("phonebook/controllers", []interface{}{&Phonebook{new(routing.BaseController)}})
+	rt.Use(rt.SessionAuth, routing.Tenants(routing.TenantConfig{
+		Default: model.DefaultTenant,
+		Find:    func(string) error { return nil },
+	}), rt.Authorize)
+
+	rt.Get("/phonebook/get", "Phonebook.Get").Require("phonebook.read")
+	rt.Get("/phonebook/view/{id}", "Phonebook.Get").Require("phonebook.read")
+	rt.Post("/phonebook/save", "Phonebook.Save").Require("phonebook.read")
+	rt.Put("/phonebook/edit/{id}", "Phonebook.Save").Require("phonebook.read")
+	rt.Delete("/phonebook/delete/{id}", "Phonebook.Delete").Require("phonebook.read")
+
+	testURL = httptest.NewServer(rt.Routing()).URL
+
+	return testURL
 }
 
-func TestPhonebookView(t *testing.T) {
-	Id := "5d7a50e24db82327ee59c456"
-	payload := struct {
-		Take int
-		Skip int
-	}{
-		Take: 10,
-		Skip: 0,
+// testLogin returns the stubbed session of the test user, failing the test
+// when the database is not reachable
+func testLogin(t *testing.T) string {
+	if !testDBChecked {
+		// the dbox driver waits for the database forever, check it with a timeout first
+		sess, err := helper.ConnectToMgo()
+		if err == nil {
+			sess.Close()
+		}
+		testDBErr, testDBChecked = err, true
 	}
-
-	out, err := json.Marshal(&payload)
-	if err != nil {
-		panic(err)
+	if testDBErr != nil {
+		t.Fatalf("The phonebook tests need the database of helper.GlobalConfig: %v", testDBErr)
 	}
 
-	payloadBuff := bytes.NewBufferString(string(out))
+	return testSession
+}
 
-	cli := http.Client{}
-	req, err := http.NewRequest(http.MethodGet, "http://localhost:3030/phonebook/view/"+Id, payloadBuff)
-	req.Header["Content-type"] = []string{"application/json"}
+// testCall sends payload as JSON within session, decodes the answer into out
+// and returns the status
+func testCall(t *testing.T, session, method, path string, payload, out interface{}) int {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		t.Error("Failed Create Connection")
+		t.Fatal(err)
 	}
 
-	resp, err := cli.Do(req)
+	req, err := http.NewRequest(method, testServer()+path, bytes.NewReader(body))
 	if err != nil {
-		t.Error("Failed Call Connection")
+		t.Fatal("Failed Create Connection")
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if session != "" {
+		req.Header.Set("X-Session-Id", session)
 	}
 
-	response := helper.Result{}
-	decoder := json.NewDecoder(resp.Body)
-	defer resp.Body.Close()
-	err = decoder.Decode(&response)
-	t.Log(response)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		t.Error("Failed to decode")
+		t.Fatal("Failed Call Connection")
 	}
-}
-
-func TestPhonebookSave(t *testing.T) {
+	defer resp.Body.Close()
 
-	Phone := model.PhoneNumberDetail{
-		PhoneNo:   "081317595876",
-		ProneType: "Mobile",
-		PhoneExt:  "",
+	if resp.StatusCode == http.StatusOK && out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			t.Fatal("Failed to decode")
+		}
 	}
 
-	PhoneDetail := []model.PhoneNumberDetail{}
-	PhoneDetail = append(PhoneDetail, Phone)
+	return resp.StatusCode
+}
 
+// testContact saves a new directory contact and deletes it when the test ends
+func testContact(t *testing.T, session string) model.Phonebook {
 	payload := model.Phonebook{
 		FirstName:   "Tias",
 		LastName:    "Faluthi",
-		PhoneNumber: PhoneDetail,
+		PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "081317595876", ProneType: "Mobile"}},
 		Email:       "triasluth@gmail.com",
 	}
 
-	// fmt.Println(fmt.Sprintf("payload TES %+v", payload))
-
-	out, err := json.Marshal(&payload)
-	if err != nil {
-		panic(err)
+	response := model.Phonebook{}
+	if status := testCall(t, session, http.MethodPost, "/phonebook/save", payload, &response); status != http.StatusOK {
+		t.Fatalf("Failed to Save: %d", status)
+	}
+	if response.Id == "" {
+		t.Fatal("Failed to Save")
 	}
+	t.Cleanup(func() {
+		testCall(t, session, http.MethodDelete, "/phonebook/delete/"+response.Id.Hex(), nil, nil)
+	})
 
-	fmt.Println("OUT >> ", string(out))
+	return response
+}
 
-	payloadBuff := bytes.NewBufferString(string(out))
+func TestPhonebookGet(t *testing.T) {
+	session := testLogin(t)
 
-	cli := http.Client{}
-	req, err := http.NewRequest(http.MethodPost, "http://localhost:3030/phonebook/save", payloadBuff)
-	req.Header["Content-type"] = []string{"application/json"}
-	if err != nil {
-		t.Error("Failed Create Connection")
+	payload := struct {
+		Take int
+		Skip int
+	}{
+		Take: 10,
+		Skip: 0,
 	}
 
-	resp, err := cli.Do(req)
-	if err != nil {
-		t.Error("Failed Call Connection")
+	response := helper.Result{}
+	if status := testCall(t, session, http.MethodGet, "/phonebook/get", payload, &response); status != http.StatusOK {
+		t.Errorf("status = %d", status)
 	}
-
-	response := model.Phonebook{}
-	decoder := json.NewDecoder(resp.Body)
-	defer resp.Body.Close()
-	err = decoder.Decode(&response)
 	t.Log(response)
-	if err != nil {
-		t.Error("Failed to decode")
+}
+
+func TestPhonebookView(t *testing.T) {
+	session := testLogin(t)
+	contact := testContact(t, session)
+
+	response := struct{ Data model.Phonebook }{}
+	if status := testCall(t, session, http.MethodGet, "/phonebook/view/"+contact.Id.Hex(), nil, &response); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
 	}
+	if response.Data.Id != contact.Id {
+		t.Errorf("viewed %+v", response.Data)
+	}
+}
 
-	if response.Id == "" {
-		t.Error("Failed to Save")
+func TestPhonebookSave(t *testing.T) {
+	session := testLogin(t)
+	contact := testContact(t, session)
+
+	if contact.FirstName != "Tias" || len(contact.PhoneNumber) != 1 {
+		t.Errorf("saved %+v", contact)
 	}
 }
 
 func TestPhonebookEdit(t *testing.T) {
-	// for edit current phone number
-	// Phone := model.PhoneNumberDetail{
-	// 	PhoneNo:   "08223009617",
-	// 	ProneType: "Mobile",
-	// 	PhoneExt:  "",
-	// }
-	//PhoneDetail := []model.PhoneNumberDetail{}
-	// PhoneDetail = append(PhoneDetail, Phone)
+	session := testLogin(t)
+	contact := testContact(t, session)
 
 	// for add new phone number
-	PhoneDetail := []model.PhoneNumberDetail{
+	contact.FirstName = "Agil"
+	contact.LastName = "D"
+	contact.PhoneNumber = []model.PhoneNumberDetail{
 		{
 			PhoneNo:   "08223009617",
 			ProneType: "Mobile",
@@ -166,70 +194,42 @@ func TestPhonebookEdit(t *testing.T) {
 		},
 	}
 
-	payload := model.Phonebook{
-		FirstName:   "Agil",
-		LastName:    "D",
-		PhoneNumber: PhoneDetail,
-	}
-
-	out, err := json.Marshal(&payload)
-	if err != nil {
-		panic(err)
-	}
-	payloadBuff := bytes.NewBufferString(string(out))
-
-	cli := http.Client{}
-	req, err := http.NewRequest(http.MethodPut, "http://localhost:3030/phonebook/edit/5d7a50e24db82327ee59c456", payloadBuff)
-	req.Header["Content-type"] = []string{"application/json"}
-	if err != nil {
-		t.Error("Failed Create Connection")
-	}
-
-	resp, err := cli.Do(req)
-	if err != nil {
-		t.Error("Failed Call Connection")
-	}
-
 	response := model.Phonebook{}
-	decoder := json.NewDecoder(resp.Body)
-	defer resp.Body.Close()
-	err = decoder.Decode(&response)
-	t.Log(response)
-	if err != nil {
-		t.Error("Failed to decode")
+	if status := testCall(t, session, http.MethodPut, "/phonebook/edit/"+contact.Id.Hex(), contact, &response); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if response.Id != contact.Id || response.FirstName != "Agil" || len(response.PhoneNumber) != 2 {
+		t.Errorf("edited %+v", response)
 	}
 
-	if response.Id == "" {
-		t.Error("Failed to Save")
+	if status := testCall(t, session, http.MethodPut, "/phonebook/edit/not-an-id", contact, nil); status != http.StatusNotFound {
+		t.Errorf("invalid id status = %d", status)
 	}
 }
 
 func TestPhonebookDelete(t *testing.T) {
-	Payload := `{}`
-	payloadBuff := bytes.NewBufferString(Payload)
+	session := testLogin(t)
+	contact := testContact(t, session)
 
-	cli := http.Client{}
-	req, err := http.NewRequest(http.MethodPost, "http://localhost:3030/phonebook/delete/5d7ad69b7505711d7a832849", payloadBuff)
-	req.Header["Content-type"] = []string{"application/json"}
-	if err != nil {
-		t.Error("Failed Create Connection")
+	response := model.Phonebook{}
+	if status := testCall(t, session, http.MethodDelete, "/phonebook/delete/"+contact.Id.Hex(), nil, &response); status != http.StatusOK {
+		t.Fatalf("status = %d", status)
+	}
+	if response.Id != contact.Id {
+		t.Error("Failed to Delete")
 	}
 
-	resp, err := cli.Do(req)
-	if err != nil {
-		t.Error("Failed Call Connection")
+	if status := testCall(t, session, http.MethodDelete, "/phonebook/delete/"+contact.Id.Hex(), nil, nil); status != http.StatusNotFound {
+		t.Errorf("second delete status = %d", status)
 	}
+}
 
-	response := model.Phonebook{}
-	decoder := json.NewDecoder(resp.Body)
-	defer resp.Body.Close()
-	err = decoder.Decode(&response)
-	t.Log(response)
-	if err != nil {
-		t.Error("Failed to decode")
+func TestPhonebookUnauthorized(t *testing.T) {
+	if status := testCall(t, "", http.MethodGet, "/phonebook/get", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("status without session = %d", status)
 	}
 
-	if response.Id == "" {
-		t.Error("Failed to Delete")
+	if status := testCall(t, "expired", http.MethodGet, "/phonebook/get", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("status with an inactive session = %d", status)
 	}
 }
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"regexp"
+	"strings"
+
+	db "github.com/eaciit/dbox"
+)
+
+// tenantSlug keeps tenant ids usable as subdomain
+var tenantSlug = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type Tenant struct {
+	*routing.BaseController
+}
+
+// Docs describes the tenant routes for the API reference.
+func (t *Tenant) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":  {Summary: "List tenants, or view one by id", Request: pageForm{}, Response: []model.Tenant{}, View: model.Tenant{}, Result: true},
+		"Save": {Summary: "Create or update a tenant", Description: "New tenant ids are lowercase letters, digits and dashes.", Request: model.Tenant{}, Response: model.Tenant{}},
+	}
+}
+
+func (t *Tenant) Get(r *routing.WeContent) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	var dbFilter []*db.Filter
+
+	v, err := r.VarsGet("id")
+	isView := err == nil
+	if isView {
+		dbFilter = append(dbFilter, db.Eq("_id", v))
+	}
+
+	data := make([]model.Tenant, 0)
+	total, err := helper.FindRecords(new(model.Tenant), dbFilter, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	res := helper.NewResult()
+
+	if isView {
+		if len(data) == 0 {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(res.SetData(data[0]).SetTotal(total))
+	}
+
+	return r.JSON(res.SetData(data).SetTotal(total))
+}
+
+func (t *Tenant) Save(r *routing.WeContent) interface{} {
+	model := model.Tenant{}
+	if e := r.Parse(&model); e != nil {
+		return r.ServerError(e)
+	}
+
+	existing := model
+	if v, err := r.VarsGet("id"); err == nil {
+		if err := helper.GetRecord(&existing, v); err != nil || existing.Id != v {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		model.Id = v
+		model.CreatedDate = existing.CreatedDate
+	} else {
+		model.Id = strings.ToLower(strings.TrimSpace(model.Id))
+		if !tenantSlug.MatchString(model.Id) {
+			return r.BadRequest(errors.New("ID must be lowercase letters, digits and dashes"))
+		}
+		if err := helper.GetRecord(&existing, model.Id); err == nil && existing.Id == model.Id {
+			return r.BadRequest(errors.New("Tenant " + model.Id + " already exists"))
+		}
+	}
+
+	if model.Name == "" {
+		return r.BadRequest(errors.New("Name is required"))
+	}
+
+	if model.Id == r.TenantID && !model.Enable {
+		return r.BadRequest(errors.New("The tenant of the request cannot be disabled"))
+	}
+
+	if err := helper.SaveRecord(&model); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(model)
+}
@@ -0,0 +1,192 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	"strconv"
+	"time"
+
+	acl "github.com/eaciit/acl/v1.0"
+	db "github.com/eaciit/dbox"
+)
+
+const (
+	// defaultTokenDays is the validity of API keys created without ExpiresIn.
+	defaultTokenDays = 90
+	// maxTokenDays is the longest validity an API key can be given.
+	maxTokenDays = 365
+)
+
+type Token struct {
+	*routing.BaseController
+}
+
+// tokenInfo is what the API tells about an API key, the key itself is only
+// returned by Save.
+type tokenInfo struct {
+	Id      string
+	UserID  string
+	Name    string
+	Prefix  string
+	Scopes  []string
+	Created time.Time
+	Expired time.Time
+	Revoked time.Time
+	Active  bool
+}
+
+// Docs describes the API key routes for the API reference.
+func (t *Token) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get": {Summary: "List API keys", Description: "Keys of other users of the tenant need acl.read.", Request: tokenQuery{}, Response: []tokenInfo{}, Result: true},
+		"Save": {Summary: "Create an API key", Description: "The key is only returned here. Scopes must be permissions of the user. Needs a session, API keys cannot create keys.",
+			Request: tokenForm{}, Response: tokenKey{}, Result: true},
+		"Revoke": {Summary: "Revoke an API key", Response: tokenInfo{}, Result: true},
+	}
+}
+
+// tokenForm is the payload of Save, ExpiresIn is in days, 90 by default and
+// at most 365.
+type tokenForm struct {
+	Name      string
+	Scopes    []string
+	ExpiresIn int
+}
+
+// tokenKey answers Save with the key, shown only once.
+type tokenKey struct {
+	Key   string
+	Token tokenInfo
+}
+
+// tokenQuery selects the user whose keys Get lists.
+type tokenQuery struct {
+	UserID string
+}
+
+func newTokenInfo(t *acl.Token) tokenInfo {
+	return tokenInfo{
+		Id:      t.ID,
+		UserID:  t.UserID,
+		Name:    t.Data1,
+		Prefix:  t.Data3,
+		Scopes:  routing.TokenScopes(t),
+		Created: t.Created,
+		Expired: t.Expired,
+		Revoked: t.Claimed,
+		Active:  routing.TokenActive(t),
+	}
+}
+
+// Get lists the API keys of the caller, acl.read holders may ask for the
+// keys of another user of the tenant with UserID.
+func (t *Token) Get(r *routing.WeContent) interface{} {
+	frm := tokenQuery{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if frm.UserID == "" {
+		frm.UserID = r.User.ID
+	} else if frm.UserID != r.User.ID {
+		if !r.Can("acl.read") {
+			return r.Forbidden(errors.New("Permission acl.read required"))
+		}
+		if _, err := findUser(r, frm.UserID); err != nil {
+			return r.NotFound(err)
+		}
+	}
+
+	crs, err := acl.Find(new(acl.Token), db.And(db.Eq("userid", frm.UserID), db.Eq("purpose", routing.TokenPurpose)), nil)
+	if err != nil {
+		return r.ServerError(err)
+	}
+	defer crs.Close()
+
+	tokens := make([]acl.Token, 0)
+	if err := crs.Fetch(&tokens, 0, false); err != nil {
+		return r.ServerError(err)
+	}
+
+	data := make([]tokenInfo, 0, len(tokens))
+	for i := range tokens {
+		data = append(data, newTokenInfo(&tokens[i]))
+	}
+
+	return r.JSON(helper.NewResult().SetData(data).SetTotal(len(data)))
+}
+
+// Save creates an API key for the caller. Scopes are permissions such as
+// phonebook.read and can't exceed what the caller may do, ExpiresIn is in
+// days. Keys are only created from a session, a key cannot renew itself.
+func (t *Token) Save(r *routing.WeContent) interface{} {
+	frm := tokenForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if r.Token != nil {
+		return r.Forbidden(errors.New("API keys are created from a session, not with an API key"))
+	}
+
+	if frm.Name == "" {
+		return r.BadRequest(errors.New("Name is required"))
+	}
+
+	for _, s := range frm.Scopes {
+		if !r.Can(s) {
+			return r.Forbidden(errors.New("Scope " + s + " exceeds your permissions"))
+		}
+	}
+
+	if frm.ExpiresIn <= 0 {
+		frm.ExpiresIn = defaultTokenDays
+	}
+	if frm.ExpiresIn > maxTokenDays {
+		return r.BadRequest(errors.New("ExpiresIn is at most " + strconv.Itoa(maxTokenDays) + " days"))
+	}
+
+	key, token, err := routing.NewToken(r.User.ID, frm.Name, frm.Scopes, time.Duration(frm.ExpiresIn)*24*time.Hour)
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	if err := acl.Save(token); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(helper.NewResult().SetData(tokenKey{key, newTokenInfo(token)}))
+}
+
+// Revoke disables an API key of the caller, or of any user of the tenant
+// for acl.write holders.
+func (t *Token) Revoke(r *routing.WeContent) interface{} {
+	v, err := r.VarsGet("id")
+	if err != nil {
+		return r.BadRequest(err)
+	}
+
+	token := new(acl.Token)
+	if err := acl.FindByID(token, v); err != nil || token.ID == "" || token.Purpose != routing.TokenPurpose {
+		return r.NotFound(errors.New("ID not found"))
+	}
+
+	if token.UserID != r.User.ID {
+		if !r.Can("acl.write") {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		if _, err := findUser(r, token.UserID); err != nil {
+			return r.NotFound(err)
+		}
+	}
+
+	if token.Claimed.IsZero() {
+		token.Claimed = time.Now().UTC()
+		if err := acl.Save(token); err != nil {
+			return r.ServerError(err)
+		}
+	}
+
+	return r.JSON(helper.NewResult().SetData(newTokenInfo(token)))
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	acl "github.com/eaciit/acl/v1.0"
+	db "github.com/eaciit/dbox"
+	tk "github.com/eaciit/toolkit"
+)
+
+type User struct {
+	*routing.BaseController
+}
+
+// Docs describes the user routes for the API reference.
+func (u *User) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":    {Summary: "List users, or view one by id", Request: pageForm{}, Response: []userInfo{}, View: userInfo{}, Result: true},
+		"Save":   {Summary: "Create or update a user", Request: userForm{}, Response: userInfo{}},
+		"Delete": {Summary: "Delete a user", Response: userInfo{}},
+	}
+}
+
+// userForm is the payload of Save, an empty Password keeps the current one.
+type userForm struct {
+	LoginID  string
+	FullName string
+	Email    string
+	Password string
+	Enable   bool
+	Groups   []string
+	Tenant   string
+}
+
+// userTenant is the tenant of user, users without one belong to the default
+// tenant.
+func userTenant(user *acl.User) string {
+	if tenant := routing.UserTenant(user); tenant != "" {
+		return tenant
+	}
+
+	return model.DefaultTenant
+}
+
+// usersFilter matches the users the request manages: those of its tenant,
+// or those of every tenant with TenantSwitch.
+func usersFilter(r *routing.WeContent) *db.Filter {
+	if r.Can(routing.TenantSwitch) {
+		return nil
+	}
+
+	filter := db.Eq("loginconf.tenant", r.TenantID)
+	if r.TenantID == model.DefaultTenant {
+		filter = db.Or(filter, db.Eq("loginconf.tenant", nil))
+	}
+
+	return filter
+}
+
+// findUser loads the user id, users the request does not manage are not
+// found.
+func findUser(r *routing.WeContent, id string) (*acl.User, error) {
+	user := new(acl.User)
+	if err := acl.FindByID(user, id); err != nil || user.ID == "" {
+		return nil, helper.ErrNotFound
+	}
+
+	if userTenant(user) != r.TenantID && !r.Can(routing.TenantSwitch) {
+		return nil, helper.ErrNotFound
+	}
+
+	return user, nil
+}
+
+// checkGroups refuses groups granting tenant access to callers without
+// TenantSwitch, groups are shared by all tenants and such a group would let
+// the user act in any of them.
+func checkGroups(r *routing.WeContent, groups []string) error {
+	if r.Can(routing.TenantSwitch) {
+		return nil
+	}
+
+	for _, id := range groups {
+		group := new(acl.Group)
+		if err := acl.FindByID(group, id); err != nil {
+			return err
+		}
+		if hasGrant(group.Grants, "tenant") {
+			return errors.New("Permission " + routing.TenantSwitch + " required to add users to group " + id)
+		}
+	}
+
+	return nil
+}
+
+func (u *User) Get(r *routing.WeContent) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	if v, err := r.VarsGet("id"); err == nil {
+		user, err := findUser(r, v)
+		if err != nil {
+			return r.NotFound(err)
+		}
+		return r.JSON(helper.NewResult().SetData(newUserInfo(user)).SetTotal(1))
+	}
+
+	crs, err := acl.Find(new(acl.User), usersFilter(r), tk.M{"take": frm.Take, "skip": frm.Skip})
+	if err != nil {
+		return r.ServerError(err)
+	}
+	defer crs.Close()
+
+	users := make([]acl.User, 0)
+	if err := crs.Fetch(&users, 0, false); err != nil {
+		return r.ServerError(err)
+	}
+
+	data := make([]userInfo, 0, len(users))
+	for i := range users {
+		data = append(data, newUserInfo(&users[i]))
+	}
+
+	return r.JSON(helper.NewResult().SetData(data).SetTotal(crs.Count()))
+}
+
+func (u *User) Save(r *routing.WeContent) interface{} {
+	frm := userForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	user := new(acl.User)
+	if v, err := r.VarsGet("id"); err == nil {
+		if user, err = findUser(r, v); err != nil {
+			return r.NotFound(err)
+		}
+	} else {
+		if frm.LoginID == "" || frm.Password == "" {
+			return r.BadRequest(errors.New("LoginID and Password are required"))
+		}
+		user.LoginID = frm.LoginID
+	}
+
+	user.FullName = frm.FullName
+	user.Email = frm.Email
+	user.Enable = frm.Enable
+	// users are created in the tenant of the request, other tenants need the switch permission
+	if frm.Tenant == "" {
+		frm.Tenant = r.TenantID
+	}
+	if frm.Tenant != r.TenantID && !r.Can(routing.TenantSwitch) {
+		return r.Forbidden(errors.New("Permission " + routing.TenantSwitch + " required"))
+	}
+	if _, err := helper.FindTenant(frm.Tenant); err != nil {
+		return r.BadRequest(err)
+	}
+	routing.SetUserTenant(user, frm.Tenant)
+
+	added := []string{}
+	for _, g := range frm.Groups {
+		if !tk.HasMember(user.Groups, g) {
+			added = append(added, g)
+		}
+	}
+	if err := checkGroups(r, added); err != nil {
+		return r.Forbidden(err)
+	}
+
+	user.Groups = []string{}
+	for _, g := range frm.Groups {
+		if err := user.AddToGroup(g); err != nil {
+			return r.BadRequest(err)
+		}
+	}
+
+	if err := acl.Save(user); err != nil {
+		return r.ServerError(err)
+	}
+
+	if frm.Password != "" {
+		if err := acl.ChangePassword(user.ID, frm.Password); err != nil {
+			return r.ServerError(err)
+		}
+	}
+
+	return r.JSON(newUserInfo(user))
+}
+
+func (u *User) Delete(r *routing.WeContent) interface{} {
+	v, _ := r.VarsGet("id")
+
+	user, err := findUser(r, v)
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	if user.ID == r.User.ID {
+		return r.BadRequest(errors.New("Users cannot delete themselves"))
+	}
+
+	if err := acl.Delete(user); err != nil {
+		return r.ServerError(err)
+	}
+
+	return r.JSON(newUserInfo(user))
+}
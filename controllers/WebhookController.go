@@ -0,0 +1,302 @@
+package controllers
+
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	webhook "github.com/tmluthfiana/phonebook/modules/webhook"
+	"net/url"
+	"time"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+	tk "github.com/eaciit/toolkit"
+)
+
+type Webhook struct {
+	*routing.BaseController
+}
+
+// Docs describes the webhook routes for the API reference.
+func (w *Webhook) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get": {Summary: "List webhooks, or view one by id", Description: "Secrets are not shown.",
+			Request: pageForm{}, Response: []model.Webhook{}, View: model.Webhook{}, Result: true},
+		"Save": {Summary: "Create or update a webhook",
+			Description: "The signing secret is only answered when the webhook is created or RotateSecret is set. Empty Events subscribe to all events.",
+			Request:     webhookForm{}, Response: model.Webhook{}},
+		"Delete": {Summary: "Delete a webhook and its deliveries", Response: model.Webhook{}},
+		"Deliveries": {Summary: "List the deliveries of the webhooks, newest first, or view one by id",
+			Request: deliveryForm{}, Response: []model.WebhookDelivery{}, View: model.WebhookDelivery{}, Result: true},
+		"Replay":       {Summary: "Send a delivery again", Response: model.WebhookDelivery{}},
+		"ReplayFailed": {Summary: "Send the failed deliveries of a webhook again", Response: replayResult{}},
+	}
+}
+
+// webhookForm is the payload of Save.
+type webhookForm struct {
+	model.Webhook
+	// RotateSecret replaces the secret of an existing webhook
+	RotateSecret bool
+}
+
+// deliveryForm pages the deliveries, optionally of one webhook or status.
+type deliveryForm struct {
+	pageForm
+	WebhookId string
+	Status    string
+}
+
+// replayResult is the answer of ReplayFailed.
+type replayResult struct {
+	Replayed int
+}
+
+func (w *Webhook) Get(r *routing.WeContent) interface{} {
+	frm := pageForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	var dbFilter []*db.Filter
+
+	_, err := r.VarsGet("id")
+	isView := err == nil
+	if isView {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		dbFilter = append(dbFilter, db.Eq("_id", id))
+	}
+
+	data := make([]model.Webhook, 0)
+	total, err := tenantScope(r).Find(new(model.Webhook), dbFilter, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+	for i := range data {
+		data[i].Secret = ""
+	}
+
+	res := helper.NewResult()
+
+	if isView {
+		if len(data) == 0 {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(res.SetData(data[0]).SetTotal(total))
+	}
+
+	return r.JSON(res.SetData(data).SetTotal(total))
+}
+
+func (w *Webhook) Save(r *routing.WeContent) interface{} {
+	frm := webhookForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+	hook := frm.Webhook
+	newSecret := frm.RotateSecret
+
+	if _, err := r.VarsGet("id"); err == nil {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+
+		existing := model.Webhook{}
+		if err := tenantScope(r).Get(&existing, id); err != nil {
+			return r.NotFound(err)
+		}
+		hook.Id = id
+		hook.Secret = existing.Secret
+		hook.CreatedDate = existing.CreatedDate
+		hook.CreatedBy = existing.CreatedBy
+		hook.UpdateBy = r.UserName()
+	} else {
+		hook.Id = ""
+		hook.CreatedBy = r.UserName()
+		newSecret = true
+	}
+
+	if err := validateWebhook(&hook); err != nil {
+		return r.BadRequest(err)
+	}
+
+	if newSecret {
+		secret, err := webhook.NewSecret()
+		if err != nil {
+			return r.ServerError(err)
+		}
+		hook.Secret = secret
+	}
+
+	if err := tenantScope(r).Save(&hook); err != nil {
+		return r.ServerError(err)
+	}
+
+	if !newSecret {
+		hook.Secret = ""
+	}
+
+	return r.JSON(hook)
+}
+
+// validateWebhook checks the fields a client sets on a webhook.
+func validateWebhook(h *model.Webhook) error {
+	if h.Name == "" {
+		return errors.New("Name is required")
+	}
+
+	u, err := url.Parse(h.Url)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("Url must be an absolute http or https URL")
+	}
+
+	if err := webhook.CheckURL(h.Url); err != nil {
+		return err
+	}
+
+	for _, e := range h.Events {
+		known := false
+		for _, t := range webhook.EventTypes {
+			known = known || e == t
+		}
+		if !known {
+			return errors.New("Unknown event " + e)
+		}
+	}
+
+	return nil
+}
+
+func (w *Webhook) Delete(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	hook := model.Webhook{}
+	if err := tenantScope(r).Get(&hook, id); err != nil {
+		return r.NotFound(err)
+	}
+
+	if err := tenantScope(r).Delete(&hook); err != nil {
+		return r.ServerError(err)
+	}
+
+	if err := helper.DeleteRecords(new(model.WebhookDelivery), db.And(db.Eq("WebhookId", id), tenantScope(r).Filter())); err != nil {
+		return r.ServerError(err)
+	}
+
+	hook.Secret = ""
+
+	return r.JSON(hook)
+}
+
+func (w *Webhook) Deliveries(r *routing.WeContent) interface{} {
+	frm := deliveryForm{}
+	if e := r.Parse(&frm); e != nil {
+		return r.ServerError(e)
+	}
+
+	var dbFilter []*db.Filter
+
+	_, err := r.VarsGet("id")
+	isView := err == nil
+	if isView {
+		id, err := objectIdVar(r, "id")
+		if err != nil {
+			return r.NotFound(err)
+		}
+		dbFilter = append(dbFilter, db.Eq("_id", id))
+	}
+
+	if frm.WebhookId != "" {
+		if !bson.IsObjectIdHex(frm.WebhookId) {
+			return r.BadRequest(errors.New("Invalid WebhookId " + frm.WebhookId))
+		}
+		dbFilter = append(dbFilter, db.Eq("WebhookId", bson.ObjectIdHex(frm.WebhookId)))
+	}
+
+	if frm.Status != "" {
+		dbFilter = append(dbFilter, db.Eq("Status", frm.Status))
+	}
+
+	data := make([]model.WebhookDelivery, 0)
+	total, err := tenantScope(r).FindSorted(new(model.WebhookDelivery), dbFilter, []string{"-CreatedDate"}, frm.Take, frm.Skip, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	res := helper.NewResult()
+
+	if isView {
+		if len(data) == 0 {
+			return r.NotFound(errors.New("ID not found"))
+		}
+		return r.JSON(res.SetData(data[0]).SetTotal(total))
+	}
+
+	return r.JSON(res.SetData(data).SetTotal(total))
+}
+
+func (w *Webhook) Replay(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	del := model.WebhookDelivery{}
+	if err := tenantScope(r).Get(&del, id); err != nil {
+		return r.NotFound(err)
+	}
+
+	del.Status = model.DeliveryPending
+	del.Tries = 0
+	del.NextAttempt = time.Now()
+	if err := tenantScope(r).Save(&del); err != nil {
+		return r.ServerError(err)
+	}
+	wakeWebhooks()
+
+	return r.JSON(del)
+}
+
+func (w *Webhook) ReplayFailed(r *routing.WeContent) interface{} {
+	id, err := objectIdVar(r, "id")
+	if err != nil {
+		return r.NotFound(err)
+	}
+
+	if err := tenantScope(r).Get(new(model.Webhook), id); err != nil {
+		return r.NotFound(err)
+	}
+
+	failed := db.And(db.Eq("WebhookId", id), db.Eq("Status", model.DeliveryFailed))
+	data := make([]tk.M, 0)
+	total, err := tenantScope(r).Find(new(model.WebhookDelivery), []*db.Filter{failed}, 1, 0, &data)
+	if err != nil {
+		return r.ServerError(err)
+	}
+
+	if total > 0 {
+		retry := tk.M{"Status": model.DeliveryPending, "Tries": 0, "NextAttempt": time.Now()}
+		if err := tenantScope(r).Update(new(model.WebhookDelivery), failed, retry); err != nil {
+			return r.ServerError(err)
+		}
+		wakeWebhooks()
+	}
+
+	return r.JSON(replayResult{Replayed: total})
+}
+
+// wakeWebhooks sends replayed deliveries without waiting for the next round.
+func wakeWebhooks() {
+	if helper.Webhooks != nil {
+		helper.Webhooks.Wake()
+	}
+}
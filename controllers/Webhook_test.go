@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"testing"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+)
+
+func TestValidateWebhook(t *testing.T) {
+	ok := model.Webhook{Name: "CRM", Url: "https://203.0.113.10/hooks/phonebook", Events: []string{events.Created, events.Deleted}}
+	if err := validateWebhook(&ok); err != nil {
+		t.Error(err)
+	}
+
+	for name, change := range map[string]func(h *model.Webhook){
+		"no name":       func(h *model.Webhook) { h.Name = "" },
+		"relative url":  func(h *model.Webhook) { h.Url = "/hooks" },
+		"other scheme":  func(h *model.Webhook) { h.Url = "ftp://crm.example.com" },
+		"unknown event": func(h *model.Webhook) { h.Events = []string{"contact.viewed"} },
+		"loopback":      func(h *model.Webhook) { h.Url = "http://127.0.0.1:8080/hooks" },
+		"localhost":     func(h *model.Webhook) { h.Url = "http://localhost/hooks" },
+		"private":       func(h *model.Webhook) { h.Url = "https://10.1.2.3/hooks" },
+		"metadata":      func(h *model.Webhook) { h.Url = "http://169.254.169.254/latest/meta-data/" },
+		"ipv6 loopback": func(h *model.Webhook) { h.Url = "http://[::1]/hooks" },
+	} {
+		h := ok
+		change(&h)
+		if err := validateWebhook(&h); err == nil {
+			t.Errorf("%s accepted", name)
+		}
+	}
+}
@@ -1,5 +1,59 @@
 package controllers
 
+import (
+	"errors"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+
+	db "github.com/eaciit/dbox"
+	tk "github.com/eaciit/toolkit"
+)
+
 type BaseController struct {
 	DBCon string
 }
+
+// pageForm is the common paging payload of the list endpoints.
+type pageForm struct {
+	Take int
+	Skip int
+}
+
+// orgPageForm pages the departments or locations, optionally of one organization.
+type orgPageForm struct {
+	pageForm
+	OrganizationId string
+}
+
+// objectIdVar reads a path variable holding a Mongo object id.
+func objectIdVar(r *routing.WeContent, k string) (bson.ObjectId, error) {
+	v, err := r.VarsGet(k)
+	if err != nil {
+		return "", err
+	}
+
+	if !bson.IsObjectIdHex(v) {
+		return "", errors.New("Invalid " + k + " " + v)
+	}
+
+	return bson.ObjectIdHex(v), nil
+}
+
+// tenantScope limits record access to the tenant of the request.
+func tenantScope(r *routing.WeContent) helper.TenantScope {
+	return helper.TenantScope(r.TenantID)
+}
+
+// hasLinkedRecords reports whether any record of m in the tenant points at id through field.
+func hasLinkedRecords(scope helper.TenantScope, m model.TenantRecord, field string, id bson.ObjectId) (bool, error) {
+	data := make([]tk.M, 0)
+	total, err := scope.Find(m, []*db.Filter{db.Eq(field, id)}, 1, 0, &data)
+	if err != nil {
+		return false, err
+	}
+
+	return total > 0, nil
+}
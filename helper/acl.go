@@ -0,0 +1,53 @@
+package helper
+
+import (
+	"strings"
+
+	acl "github.com/eaciit/acl/v1.0"
+	tk "github.com/eaciit/toolkit"
+)
+
+// AccessIDs are the acl access ids the route permissions are declared on
+var AccessIDs = []string{"phonebook", "directory", "acl", "tenant", "metrics", "webhook"}
+
+// SeedAcl create the admin and reader groups and, on an empty user
+// collection, the admin user with GlobalConfig adminpassword
+func SeedAcl() error {
+	admin := new(acl.Group)
+	if err := acl.FindByID(admin, "admin"); err != nil || admin.ID == "" {
+		admin = &acl.Group{ID: "admin", Title: "Administrators", Enable: true}
+		for _, id := range AccessIDs {
+			admin.Grant(id, acl.AccessCreate, acl.AccessRead, acl.AccessUpdate, acl.AccessDelete)
+		}
+		if err := acl.Save(admin); err != nil {
+			return err
+		}
+	}
+
+	reader := new(acl.Group)
+	if err := acl.FindByID(reader, "reader"); err != nil || reader.ID == "" {
+		reader = &acl.Group{ID: "reader", Title: "Readers", Enable: true}
+		reader.Grant("phonebook", acl.AccessRead)
+		reader.Grant("directory", acl.AccessRead)
+		if err := acl.Save(reader); err != nil {
+			return err
+		}
+	}
+
+	crs, err := acl.Find(new(acl.User), nil, tk.M{"take": 1})
+	if err != nil {
+		return err
+	}
+	defer crs.Close()
+
+	if crs.Count() > 0 || strings.TrimSpace(GlobalConfig["adminpassword"]) == "" {
+		return nil
+	}
+
+	user := &acl.User{LoginID: "admin", FullName: "Administrator", Enable: true, Groups: []string{"admin"}, MustChange: 1}
+	if err := acl.Save(user); err != nil {
+		return err
+	}
+
+	return acl.ChangePassword(user.ID, GlobalConfig["adminpassword"])
+}
@@ -1,7 +1,14 @@
 package helper
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
 
 	db "github.com/eaciit/dbox"
 	"github.com/eaciit/orm"
@@ -9,11 +16,83 @@ import (
 )
 
 var GlobalConfig map[string]string = map[string]string{
-	"host":      "localhost:27017",
-	"database":  "phonebook",
-	"username":  "",
-	"password":  "",
-	"mechanism": "DEFAULT",
+	// address is where the HTTP server listens, the timeouts are in seconds; shutdowntimeout bounds
+	// the drain of in-flight requests on SIGINT or SIGTERM
+	"address":           ":3030",
+	"readtimeout":       "30",
+	"readheadertimeout": "10",
+	"writetimeout":      "60",
+	"idletimeout":       "120",
+	"maxheaderbytes":    "1048576",
+	"shutdowntimeout":   "30",
+	// tls* turn address into HTTPS, the files are reloaded within tlsreload seconds of a change.
+	// tlsciphers is a comma separated list of crypto/tls suite names for TLS 1.2, tlsclientauth is
+	// none, request or require and checks client certificates against tlsclientca. redirectaddress
+	// listens for plain HTTP and redirects it to HTTPS, e.g. ":80"
+	"tlscert":         "",
+	"tlskey":          "",
+	"tlsminversion":   "1.2",
+	"tlsciphers":      "",
+	"tlsclientca":     "",
+	"tlsclientauth":   "none",
+	"tlsreload":       "10",
+	"redirectaddress": "",
+	"host":            "localhost:27017",
+	"database":        "phonebook",
+	"username":        "",
+	"password":        "",
+	"mechanism":       "DEFAULT",
+	// photostore is either gridfs or disk, the latter keeps photos below photodir
+	"photostore": "gridfs",
+	"photodir":   "photos",
+	// adminpassword is given to the admin user created on an empty acl user collection
+	"adminpassword": "",
+	// ldap* configure the directory sync, ldapinterval is in minutes and 0 disables the schedule
+	"ldapaddress":  "",
+	"ldapbinddn":   "",
+	"ldappassword": "",
+	"ldapbasedn":   "",
+	"ldapfilter":   "(&(objectClass=person)(!(objectClass=computer)))",
+	"ldappagesize": "500",
+	"ldapinterval": "60",
+	"ldaptenant":   "default",
+	// ldapmaxdeactivate is the largest percentage of the synced contacts one sync may deactivate
+	"ldapmaxdeactivate": "20",
+	// ldapserver* configure the read-only LDAP listener for desk phones, an empty address disables it
+	"ldapserveraddress":   "",
+	"ldapserverbasedn":    "ou=phonebook,dc=example,dc=com",
+	"ldapserverbinddn":    "",
+	"ldapserverpassword":  "",
+	"ldapserveranonymous": "false",
+	"ldapserversizelimit": "500",
+	"ldapservertenant":    "default",
+	// grpcaddress serves the phonebook.v1.Contacts gRPC service with reflection, e.g. ":9090",
+	// an empty address disables it; it uses TLS like the HTTP server when tlscert is set
+	"grpcaddress":        "",
+	"grpcmaxmessagesize": "4194304",
+	// webhook* tune the delivery of contact changes: a delivery fails after webhookmaxattempts tries,
+	// each waits webhooktimeout seconds for the receiver; delivered deliveries are kept
+	// webhookretention days, 0 keeps them forever
+	"webhookmaxattempts": "8",
+	"webhooktimeout":     "10",
+	"webhookretention":   "30",
+	// eventretention is how many days /phonebook/events can resume from, older events are deleted
+	"eventretention": "7",
+	// ratelimit is the default number of requests per minute a client (API key, user or
+	// address) may send to a route, ratelimitburst how many at once, 0 for ratelimit
+	"ratelimit":      "600",
+	"ratelimitburst": "0",
+	// ratelimitaddress is how many requests per minute one address may send to all routes
+	// together, counted before authentication, 0 disables it
+	"ratelimitaddress": "1200",
+	// trustproxy takes client addresses from X-Forwarded-For, only set it behind a proxy
+	"trustproxy": "false",
+	// readytimeout is how many seconds /readyz waits for the database
+	"readytimeout": "2",
+	// loglevel is debug, info, warn or error; logs are JSON lines on stderr
+	"loglevel": "info",
+	// tenantdomain resolves subdomains to tenants, acme.<tenantdomain> is the tenant acme
+	"tenantdomain": "",
 }
 
 func ConnectToDB() (db.IConnection, error) {
@@ -36,15 +115,129 @@ func ConnectToDB() (db.IConnection, error) {
 		return nil, e
 	}
 
-	return c, nil
+	return pool.track(c)
+}
+
+// pool keeps the open dbox connections so CloseDB can wait for them on shutdown
+var pool = &connPool{conns: map[*trackedConn]bool{}}
+
+// ErrDBClosed is returned by ConnectToDB once CloseDB was called
+var ErrDBClosed = errors.New("database connections are closed")
+
+type connPool struct {
+	lock   sync.Mutex
+	conns  map[*trackedConn]bool
+	closed bool
+}
+
+// trackedConn leaves the pool when it is closed
+type trackedConn struct {
+	db.IConnection
+	once sync.Once
 }
 
-func SaveRecord(m orm.IModel) error {
+func (c *trackedConn) Close() {
+	c.once.Do(func() {
+		c.IConnection.Close()
+
+		pool.lock.Lock()
+		defer pool.lock.Unlock()
+		delete(pool.conns, c)
+	})
+}
+
+func (p *connPool) track(c db.IConnection) (db.IConnection, error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.closed {
+		c.Close()
+		return nil, ErrDBClosed
+	}
+
+	t := &trackedConn{IConnection: c}
+	p.conns[t] = true
+
+	return t, nil
+}
+
+func (p *connPool) open() []*trackedConn {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	conns := []*trackedConn{}
+	for c := range p.conns {
+		conns = append(conns, c)
+	}
+
+	return conns
+}
+
+// CloseDB refuse new connections and wait for the open ones to be closed by their callers, those
+// still open when ctx is done are closed
+func CloseDB(ctx context.Context) error {
+	pool.lock.Lock()
+	pool.closed = true
+	pool.lock.Unlock()
+
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+	for len(pool.open()) > 0 {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			conns := pool.open()
+			for _, c := range conns {
+				c.Close()
+			}
+			return fmt.Errorf("closed %d database connections still in use: %w", len(conns), ctx.Err())
+		}
+	}
+
+	return nil
+}
+
+// ConnectToMgo dial a raw mgo session for the features dbox does not cover, such as GridFS
+func ConnectToMgo() (*mgo.Session, error) {
+	return dialMgo(10 * time.Second)
+}
+
+func dialMgo(timeout time.Duration) (*mgo.Session, error) {
+	info := &mgo.DialInfo{
+		Addrs:    strings.Split(strings.TrimSpace(GlobalConfig["host"]), "~"),
+		Database: GlobalConfig["database"],
+		Timeout:  timeout,
+	}
+
+	// same authentication rules as the dbox mongo driver
+	if GlobalConfig["username"] != "" {
+		info.Username = GlobalConfig["username"]
+		info.Password = DecryptAes128(GlobalConfig["password"], AES128KEY)
+		if GlobalConfig["mechanism"] == "DEFAULT" {
+			info.Source = "admin"
+		} else {
+			info.Mechanism = GlobalConfig["mechanism"]
+		}
+	}
+
+	sess, err := mgo.DialWithInfo(info)
+	if err != nil {
+		return nil, err
+	}
+	sess.SetMode(mgo.Monotonic, true)
+
+	return sess, nil
+}
+
+func SaveRecord(m orm.IModel) (err error) {
+	defer observeDB("save", m.TableName(), time.Now(), &err)
+
 	conn, err := ConnectToDB()
-	defer conn.Close()
 	if err != nil {
 		return err
 	}
+	defer conn.Close()
 
 	ctx := orm.New(conn)
 	err = ctx.Save(m)
@@ -54,12 +247,14 @@ func SaveRecord(m orm.IModel) error {
 	return nil
 }
 
-func DeleteRecord(m orm.IModel) error {
+func DeleteRecord(m orm.IModel) (err error) {
+	defer observeDB("delete", m.TableName(), time.Now(), &err)
+
 	conn, err := ConnectToDB()
-	defer conn.Close()
 	if err != nil {
-		return nil
+		return err
 	}
+	defer conn.Close()
 
 	ctx := orm.New(conn)
 	err = ctx.Delete(m)
@@ -70,15 +265,114 @@ func DeleteRecord(m orm.IModel) error {
 	return nil
 }
 
-//GetDataFromDB Get Data from query (pipe)
+// UpdateRecords set the given fields on every record of tablename matching where
+func UpdateRecords(tablename string, where *db.Filter, data tk.M) (err error) {
+	defer observeDB("update", tablename, time.Now(), &err)
+
+	conn, err := ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	q := conn.NewQuery().From(tablename).Where(where).Update()
+	defer q.Close()
+
+	return q.Exec(tk.M{"data": data})
+}
+
+// DeleteRecords remove every record of m's table matching where
+func DeleteRecords(m orm.IModel, where *db.Filter) (err error) {
+	defer observeDB("delete", m.TableName(), time.Now(), &err)
+
+	conn, err := ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return orm.New(conn).DeleteMany(m, where)
+}
+
+// FindRecords fetch a page of records matching the filters into result and return the total matching count
+func FindRecords(m orm.IModel, filters []*db.Filter, take, skip int, result interface{}) (total int, err error) {
+	return FindSortedRecords(m, filters, nil, take, skip, result)
+}
+
+// FindSortedRecords is FindRecords in the order of the given fields, a field prefixed with - sorts descending
+func FindSortedRecords(m orm.IModel, filters []*db.Filter, order []string, take, skip int, result interface{}) (total int, err error) {
+	defer observeDB("find", m.TableName(), time.Now(), &err)
+
+	conn, err := ConnectToDB()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	qry := tk.M{
+		"limit": take,
+		"skip":  skip,
+	}
+
+	if len(filters) > 0 {
+		qry.Set("where", db.And(filters...))
+	}
+	if len(order) > 0 {
+		qry.Set("order", order)
+	}
+
+	ctx := orm.New(conn)
+	crs, err := ctx.Find(m, qry)
+	if err != nil {
+		return 0, err
+	}
+	defer crs.Close()
+
+	err = crs.Fetch(result, 0, false)
+	if err != nil {
+		return 0, err
+	}
+
+	qTotal := ctx.Connection.NewQuery()
+	qTotal.Where(filters...)
+	crsTotal, err := qTotal.Aggr(db.AggrSum, 1, "Count").From(m.TableName()).Group("").Cursor(nil)
+	if err != nil {
+		return 0, err
+	}
+	defer crsTotal.Close()
+
+	tkm := tk.M{}
+	crsTotal.Fetch(&tkm, 1, false)
+	if tkm != nil {
+		total = tkm.GetInt("Count")
+	}
+
+	return total, nil
+}
+
+// GetRecord load a single record by its id into m
+func GetRecord(m orm.IModel, id interface{}) (err error) {
+	defer observeDB("get", m.TableName(), time.Now(), &err)
+
+	conn, err := ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx := orm.New(conn)
+	return ctx.GetById(m, id)
+}
+
+// GetDataFromDB Get Data from query (pipe)
 func GetDataFromDB(pipe []tk.M, tablename string) ([]tk.M, error) {
 	result := []tk.M{}
 
 	conn, err := ConnectToDB()
-	defer conn.Close()
 	if err != nil {
 		return result, err
 	}
+	defer conn.Close()
 	query := conn.NewQuery()
 
 	if len(pipe) != 0 {
@@ -0,0 +1,64 @@
+package helper
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	db "github.com/eaciit/dbox"
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+// EventStreamsClosed is closed when the server shuts down, so the event
+// streams end instead of holding up the drain of requests
+var EventStreamsClosed = make(chan struct{})
+
+var closeEventStreams sync.Once
+
+// CloseEventStreams ends the event streams, see EventStreamsClosed
+func CloseEventStreams() {
+	closeEventStreams.Do(func() { close(EventStreamsClosed) })
+}
+
+// EventRetention is how long the event log keeps events, GlobalConfig eventretention in days
+func EventRetention() time.Duration {
+	days, err := strconv.Atoi(GlobalConfig["eventretention"])
+	if err != nil || days <= 0 {
+		days = 7
+	}
+
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// logEvent stores e in the event log and returns its id
+func logEvent(e events.Event) (string, error) {
+	rec := &model.ContactEvent{TenantId: e.TenantId, Type: e.Type, Contact: e.Contact, Time: e.Time}
+	if err := SaveRecord(rec); err != nil {
+		return "", err
+	}
+
+	return rec.Id.Hex(), nil
+}
+
+// StartEventLog deletes the events older than EventRetention every hour until stop is closed
+func StartEventLog(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(time.Hour)
+		defer ticker.Stop()
+
+		for {
+			before := time.Now().Add(-EventRetention())
+			if err := DeleteRecords(new(model.ContactEvent), db.Lt("Time", before)); err != nil {
+				logger.Error("Event log not pruned", "error", err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
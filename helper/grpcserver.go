@@ -0,0 +1,27 @@
+package helper
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strconv"
+
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+	phonebookv1 "github.com/tmluthfiana/phonebook/proto/phonebook/v1"
+)
+
+// NewGrpcServer creates the gRPC listener from GlobalConfig, h routes the
+// methods of phonebook.v1.Contacts and tlsConfig is the one of the HTTP
+// server, nil for plaintext HTTP/2
+func NewGrpcServer(h http.Handler, tlsConfig *tls.Config) *grpc.Server {
+	maxSize, _ := strconv.Atoi(GlobalConfig["grpcmaxmessagesize"])
+
+	s := &grpc.Server{
+		Address:        GlobalConfig["grpcaddress"],
+		TLS:            tlsConfig,
+		Handler:        h,
+		MaxMessageSize: maxSize,
+	}
+	s.Register(phonebookv1.File)
+
+	return s
+}
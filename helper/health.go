@@ -0,0 +1,88 @@
+package helper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"runtime/debug"
+	"time"
+
+	health "github.com/tmluthfiana/phonebook/modules/health"
+)
+
+// Version, Commit and BuildDate are set when building, e.g.
+// go build -ldflags "-X github.com/tmluthfiana/phonebook/helper.Version=1.4.0"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo describe the running binary, the commit falls back to the one recorded by the go tool
+func BuildInfo() health.Build {
+	build := health.Build{Version: Version, Commit: Commit, BuildDate: BuildDate}
+
+	if info, ok := debug.ReadBuildInfo(); ok {
+		build.GoVersion = info.GoVersion
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" && build.Commit == "" {
+				build.Commit = s.Value
+			}
+			if s.Key == "vcs.time" && build.BuildDate == "" {
+				build.BuildDate = s.Value
+			}
+		}
+	}
+
+	return build
+}
+
+// PingDB check that MongoDB answers before ctx is done
+func PingDB(ctx context.Context) (err error) {
+	defer observeDB("ping", "", time.Now(), &err)
+
+	timeout := 10 * time.Second
+	if deadline, ok := ctx.Deadline(); ok {
+		timeout = time.Until(deadline)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		sess, err := dialMgo(timeout)
+		if err != nil {
+			done <- err
+			return
+		}
+		defer sess.Close()
+
+		done <- sess.Ping()
+	}()
+
+	select {
+	case err = <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// HealthChecks are the dependencies checked by the readiness probe, MongoDB is critical and the
+// photo directory is not
+func HealthChecks() []health.Check {
+	checks := []health.Check{{Name: "mongodb", Critical: true, Run: PingDB}}
+
+	if GlobalConfig["photostore"] == "disk" {
+		checks = append(checks, health.Check{Name: "photostore", Run: func(ctx context.Context) error {
+			info, err := os.Stat(GlobalConfig["photodir"])
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return errors.New(GlobalConfig["photodir"] + " is not a directory")
+			}
+			return nil
+		}})
+	}
+
+	return checks
+}
@@ -0,0 +1,45 @@
+package helper
+
+import (
+	"crypto/subtle"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	ldapserver "github.com/tmluthfiana/phonebook/modules/ldapserver"
+
+	db "github.com/eaciit/dbox"
+)
+
+// NewLdapServer create the read-only LDAP listener from GlobalConfig, ldapserverpassword is encrypted like password
+func NewLdapServer() *ldapserver.Server {
+	sizeLimit, _ := strconv.Atoi(GlobalConfig["ldapserversizelimit"])
+	bindDN := GlobalConfig["ldapserverbinddn"]
+	bindPassword := DecryptAes128(GlobalConfig["ldapserverpassword"], AES128KEY)
+
+	return &ldapserver.Server{
+		Address:        GlobalConfig["ldapserveraddress"],
+		BaseDN:         GlobalConfig["ldapserverbasedn"],
+		Directory:      ContactDirectory{Tenant: TenantScope(GlobalConfig["ldapservertenant"])},
+		AllowAnonymous: GlobalConfig["ldapserveranonymous"] == "true",
+		SizeLimit:      sizeLimit,
+		IdleTimeout:    5 * time.Minute,
+		Authenticate: func(dn, password string) bool {
+			return bindDN != "" && strings.EqualFold(dn, bindDN) &&
+				subtle.ConstantTimeCompare([]byte(password), []byte(bindPassword)) == 1
+		},
+	}
+}
+
+// ContactDirectory publish the directory contacts of a tenant that are not deactivated
+type ContactDirectory struct {
+	Tenant TenantScope
+}
+
+func (d ContactDirectory) Contacts() ([]model.Phonebook, error) {
+	data := make([]model.Phonebook, 0)
+	_, err := d.Tenant.Find(new(model.Phonebook), []*db.Filter{db.Eq("AddressBookId", nil), db.Ne("Status", model.StatusInactive)}, 0, 0, &data)
+
+	return data, err
+}
@@ -0,0 +1,55 @@
+package helper
+
+import (
+	"strconv"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	ldapsync "github.com/tmluthfiana/phonebook/modules/ldapsync"
+
+	db "github.com/eaciit/dbox"
+)
+
+// LdapSync is the directory sync job, created on start when ldapaddress is configured
+var LdapSync *ldapsync.Job
+
+// NewLdapSync create the sync job from GlobalConfig
+func NewLdapSync() *ldapsync.Job {
+	minutes, _ := strconv.Atoi(GlobalConfig["ldapinterval"])
+
+	return ldapsync.NewJob(LdapSyncConfig, ContactStore{Tenant: TenantScope(GlobalConfig["ldaptenant"])}, time.Duration(minutes)*time.Minute)
+}
+
+// LdapSyncConfig read the sync settings from GlobalConfig, ldappassword is encrypted like password
+func LdapSyncConfig() ldapsync.Config {
+	pageSize, _ := strconv.Atoi(GlobalConfig["ldappagesize"])
+	maxDeactivate, _ := strconv.ParseFloat(GlobalConfig["ldapmaxdeactivate"], 64)
+
+	return ldapsync.Config{
+		Address:       GlobalConfig["ldapaddress"],
+		BindDN:        GlobalConfig["ldapbinddn"],
+		BindPassword:  DecryptAes128(GlobalConfig["ldappassword"], AES128KEY),
+		BaseDN:        GlobalConfig["ldapbasedn"],
+		Filter:        GlobalConfig["ldapfilter"],
+		Attributes:    ldapsync.DefaultAttributes,
+		PageSize:      pageSize,
+		Timeout:       30 * time.Second,
+		MaxDeactivate: maxDeactivate / 100,
+	}
+}
+
+// ContactStore give the directory sync access to the Phonebook collection of a tenant
+type ContactStore struct {
+	Tenant TenantScope
+}
+
+func (s ContactStore) FindBySource(source string) ([]model.Phonebook, error) {
+	data := make([]model.Phonebook, 0)
+	_, err := s.Tenant.Find(new(model.Phonebook), []*db.Filter{db.Eq("ExternalSource", source)}, 0, 0, &data)
+
+	return data, err
+}
+
+func (s ContactStore) Save(m *model.Phonebook) error {
+	return s.Tenant.Save(m)
+}
@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"sync"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+	metrics "github.com/tmluthfiana/phonebook/modules/metrics"
+
+	mgo "gopkg.in/mgo.v2"
+
+	db "github.com/eaciit/dbox"
+)
+
+// contactTotalsTTL keeps scrapes from counting the contacts every time
+const contactTotalsTTL = 30 * time.Second
+
+var (
+	dbDuration = metrics.Default.NewHistogramVec("db_operation_duration_seconds",
+		"Time taken by database operations including the connection, by operation and collection.", nil, "operation", "collection")
+	dbErrors = metrics.Default.NewCounterVec("db_errors_total",
+		"Failed database operations, by operation and collection.", "operation", "collection")
+)
+
+func init() {
+	// mgo only counts its sockets when asked to
+	mgo.SetStats(true)
+}
+
+// observeDB record the duration and failure of a database operation, deferred with its start time
+func observeDB(operation, collection string, start time.Time, err *error) {
+	dbDuration.Observe(time.Since(start).Seconds(), operation, collection)
+	if *err != nil {
+		dbErrors.Inc(operation, collection)
+	}
+}
+
+// RegisterMetrics add the connection pool and contact gauges to reg
+func RegisterMetrics(reg *metrics.Registry) {
+	reg.NewGaugeFunc("db_pool_clusters", "Open mgo clusters, one per database connection.", func() []metrics.Sample {
+		return []metrics.Sample{{Value: float64(mgo.GetStats().Clusters)}}
+	})
+	reg.NewGaugeFunc("db_pool_sockets", "Sockets of the mgo pools, by state.", func() []metrics.Sample {
+		stats := mgo.GetStats()
+		return []metrics.Sample{
+			{Labels: []string{"alive"}, Value: float64(stats.SocketsAlive)},
+			{Labels: []string{"in_use"}, Value: float64(stats.SocketsInUse)},
+		}
+	}, "state")
+	reg.NewGaugeFunc("phonebook_contacts", "Contacts by tenant and book, directory or addressbook.", contactTotals, "tenant", "book")
+}
+
+var contactTotalsCache struct {
+	lock    sync.Mutex
+	at      time.Time
+	samples []metrics.Sample
+}
+
+// contactTotals count the contacts of every tenant, the last counts are kept on failure
+func contactTotals() []metrics.Sample {
+	c := &contactTotalsCache
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if time.Since(c.at) < contactTotalsTTL {
+		return c.samples
+	}
+	c.at = time.Now()
+
+	tenants := make([]model.Tenant, 0)
+	if _, err := FindRecords(new(model.Tenant), nil, 0, 0, &tenants); err != nil {
+		logger.Error("Count contacts failed", "error", err)
+		return c.samples
+	}
+
+	books := map[string]*db.Filter{
+		"directory":   db.Eq("AddressBookId", nil),
+		"addressbook": db.Ne("AddressBookId", nil),
+	}
+
+	samples := []metrics.Sample{}
+	for _, tenant := range tenants {
+		for _, book := range []string{"directory", "addressbook"} {
+			data := make([]model.Phonebook, 0)
+			total, err := TenantScope(tenant.Id).Find(new(model.Phonebook), []*db.Filter{books[book]}, 1, 0, &data)
+			if err != nil {
+				logger.Error("Count contacts failed", "tenant", tenant.Id, "error", err)
+				return c.samples
+			}
+			samples = append(samples, metrics.Sample{Labels: []string{tenant.Id, book}, Value: float64(total)})
+		}
+	}
+	c.samples = samples
+
+	return samples
+}
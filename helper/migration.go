@@ -0,0 +1,52 @@
+package helper
+
+import (
+	model "github.com/tmluthfiana/phonebook/model"
+
+	db "github.com/eaciit/dbox"
+	"github.com/eaciit/orm"
+	tk "github.com/eaciit/toolkit"
+)
+
+// MigratePhonebookEmail moves the legacy single Email of stored contacts into the Emails list
+func MigratePhonebookEmail() (int, error) {
+	conn, err := ConnectToDB()
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	ctx := orm.New(conn)
+	crs, err := ctx.Find(new(model.Phonebook), tk.M{"where": db.Ne("Email", "")})
+	if err != nil {
+		return 0, err
+	}
+	defer crs.Close()
+
+	data := make([]model.Phonebook, 0)
+	if err := crs.Fetch(&data, 0, false); err != nil {
+		return 0, err
+	}
+
+	migrated := 0
+	for i := range data {
+		m := &data[i]
+		if m.HasEmail(m.Email) {
+			continue
+		}
+
+		m.NormalizeEmail()
+
+		// save through the query directly so PreSave does not touch LastAction and UpdateDate
+		q := conn.NewQuery().From(m.TableName()).Save()
+		err = q.Exec(tk.M{"data": m})
+		q.Close()
+		if err != nil {
+			return migrated, err
+		}
+
+		migrated++
+	}
+
+	return migrated, nil
+}
@@ -0,0 +1,14 @@
+package helper
+
+import (
+	photo "github.com/tmluthfiana/phonebook/modules/photo"
+)
+
+// NewPhotoStore return the photo store selected by GlobalConfig
+func NewPhotoStore() photo.Store {
+	if GlobalConfig["photostore"] == "disk" {
+		return photo.NewDiskStore(GlobalConfig["photodir"])
+	}
+
+	return photo.NewGridFSStore(ConnectToMgo, GlobalConfig["database"])
+}
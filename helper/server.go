@@ -0,0 +1,50 @@
+package helper
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	server "github.com/tmluthfiana/phonebook/modules/server"
+)
+
+// ServerConfig read the HTTP server settings from GlobalConfig, timeouts are in seconds. HTTPS is
+// served when tlscert is set
+func ServerConfig() (server.Config, error) {
+	seconds := func(key string) time.Duration {
+		n, _ := strconv.Atoi(GlobalConfig[key])
+		return time.Duration(n) * time.Second
+	}
+	maxHeaderBytes, _ := strconv.Atoi(GlobalConfig["maxheaderbytes"])
+
+	cfg := server.Config{
+		Addr:              GlobalConfig["address"],
+		ReadTimeout:       seconds("readtimeout"),
+		ReadHeaderTimeout: seconds("readheadertimeout"),
+		WriteTimeout:      seconds("writetimeout"),
+		IdleTimeout:       seconds("idletimeout"),
+		MaxHeaderBytes:    maxHeaderBytes,
+		ShutdownTimeout:   seconds("shutdowntimeout"),
+	}
+
+	if GlobalConfig["tlscert"] == "" {
+		return cfg, nil
+	}
+
+	tls, err := server.NewTLSConfig(server.TLSConfig{
+		CertFile:       GlobalConfig["tlscert"],
+		KeyFile:        GlobalConfig["tlskey"],
+		MinVersion:     GlobalConfig["tlsminversion"],
+		CipherSuites:   strings.Split(GlobalConfig["tlsciphers"], ","),
+		ClientCAFile:   GlobalConfig["tlsclientca"],
+		ClientAuth:     GlobalConfig["tlsclientauth"],
+		ReloadInterval: seconds("tlsreload"),
+	})
+	if err != nil {
+		return cfg, err
+	}
+	cfg.TLS = tls
+	cfg.RedirectAddr = GlobalConfig["redirectaddress"]
+
+	return cfg, nil
+}
@@ -0,0 +1,206 @@
+package helper
+
+import (
+	"crypto/sha256"
+	"errors"
+	"reflect"
+	"time"
+
+	mgo "gopkg.in/mgo.v2"
+	"gopkg.in/mgo.v2/bson"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+
+	db "github.com/eaciit/dbox"
+	"github.com/eaciit/orm"
+	tk "github.com/eaciit/toolkit"
+)
+
+// tenantTables are the collections of the model.TenantRecord models
+var tenantTables = []string{
+	new(model.Phonebook).TableName(),
+	new(model.Tombstone).TableName(),
+	new(model.ContactEvent).TableName(),
+	new(model.Organization).TableName(),
+	new(model.Department).TableName(),
+	new(model.Location).TableName(),
+	new(model.AddressBook).TableName(),
+	new(model.Webhook).TableName(),
+	new(model.WebhookDelivery).TableName(),
+}
+
+var ErrNotFound = errors.New("ID not found")
+
+// ContactEvents announces the contacts saved or deleted through a
+// TenantScope, whichever API changed them.
+var ContactEvents = events.NewBroker()
+
+// TenantScope reads and writes the records of one tenant, records of other
+// tenants look like they do not exist
+type TenantScope string
+
+func (t TenantScope) Filter() *db.Filter {
+	return db.Eq("TenantId", string(t))
+}
+
+// Find is FindRecords limited to the tenant
+func (t TenantScope) Find(m model.TenantRecord, filters []*db.Filter, take, skip int, result interface{}) (int, error) {
+	return FindRecords(m, append([]*db.Filter{t.Filter()}, filters...), take, skip, result)
+}
+
+// FindSorted is FindSortedRecords limited to the tenant
+func (t TenantScope) FindSorted(m model.TenantRecord, filters []*db.Filter, order []string, take, skip int, result interface{}) (int, error) {
+	return FindSortedRecords(m, append([]*db.Filter{t.Filter()}, filters...), order, take, skip, result)
+}
+
+// Get is GetRecord failing with ErrNotFound for records of other tenants
+func (t TenantScope) Get(m model.TenantRecord, id interface{}) error {
+	if err := GetRecord(m, id); err != nil {
+		return ErrNotFound
+	}
+
+	if m.GetTenantId() != string(t) {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// Save stores m in the tenant, refusing to overwrite a record of another tenant with the same id
+func (t TenantScope) Save(m model.TenantRecord) error {
+	if id := m.RecordID(); !reflect.ValueOf(id).IsZero() {
+		stored := reflect.New(reflect.TypeOf(m).Elem()).Interface().(model.TenantRecord)
+		if err := GetRecord(stored, id); err == nil && stored.GetTenantId() != "" && stored.GetTenantId() != string(t) {
+			return ErrNotFound
+		}
+	}
+
+	m.SetTenantId(string(t))
+
+	if err := SaveRecord(m); err != nil {
+		return err
+	}
+
+	if c, ok := m.(*model.Phonebook); ok {
+		typ := events.Updated
+		if c.LastAction == "insert" {
+			typ = events.Created
+		}
+		t.publish(typ, c)
+	}
+
+	return nil
+}
+
+// Delete removes m when it belongs to the tenant
+func (t TenantScope) Delete(m model.TenantRecord) (err error) {
+	defer observeDB("delete", m.TableName(), time.Now(), &err)
+
+	conn, err := ConnectToDB()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err = orm.New(conn).DeleteMany(m, db.And(db.Eq("_id", m.RecordID()), t.Filter())); err != nil {
+		return err
+	}
+
+	if c, ok := m.(*model.Phonebook); ok {
+		t.publish(events.Deleted, c)
+	}
+
+	return nil
+}
+
+// PersonalBook returns the personal address book of owner, creating it on
+// first use. The upsert matches the tenant, owner and Personal flag and
+// inserts a book with an id derived from them, so concurrent first calls
+// create one book.
+func (t TenantScope) PersonalBook(owner string) (book *model.AddressBook, err error) {
+	book = new(model.AddressBook)
+	defer observeDB("upsert", book.TableName(), time.Now(), &err)
+
+	sess, err := ConnectToMgo()
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
+	sum := sha256.Sum256([]byte(string(t) + "\n" + owner))
+	change := mgo.Change{
+		Update: bson.M{"$setOnInsert": bson.M{
+			"_id":         bson.ObjectId(sum[:12]),
+			"Name":        "Personal",
+			"Shares":      model.Shares{},
+			"CreatedDate": time.Now(),
+			"UpdateDate":  time.Time{},
+		}},
+		Upsert:    true,
+		ReturnNew: true,
+	}
+	query := sess.DB(GlobalConfig["database"]).C(book.TableName()).Find(bson.M{"TenantId": string(t), "OwnerId": owner, "Personal": true})
+
+	// the loser of a concurrent first call fails on the id, the retry finds the book
+	if _, err = query.Apply(change, book); mgo.IsDup(err) {
+		_, err = query.Apply(change, book)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+// Update is UpdateRecords limited to the tenant
+func (t TenantScope) Update(m model.TenantRecord, where *db.Filter, data tk.M) error {
+	return UpdateRecords(m.TableName(), db.And(where, t.Filter()), data)
+}
+
+// publish logs the change for the event streams and announces it, changes
+// the log missed are still announced
+func (t TenantScope) publish(typ string, c *model.Phonebook) {
+	e := events.Event{Type: typ, TenantId: string(t), Contact: *c, Time: time.Now()}
+	if id, err := logEvent(e); err != nil {
+		logger.Error("Contact event not logged", "event", typ, "contact", c.Id.Hex(), "error", err)
+	} else {
+		e.Id = id
+	}
+
+	ContactEvents.Publish(e)
+}
+
+// FindTenant load an enabled tenant
+func FindTenant(id string) (*model.Tenant, error) {
+	tenant := new(model.Tenant)
+	if err := GetRecord(tenant, id); err != nil || tenant.Id != id {
+		return nil, errors.New("Tenant " + id + " not found")
+	}
+
+	if !tenant.Enable {
+		return nil, errors.New("Tenant " + id + " is disabled")
+	}
+
+	return tenant, nil
+}
+
+// SeedTenant create the default tenant and move the records stored before tenants existed into it
+func SeedTenant() error {
+	tenant := new(model.Tenant)
+	if err := GetRecord(tenant, model.DefaultTenant); err != nil || tenant.Id == "" {
+		tenant = &model.Tenant{Id: model.DefaultTenant, Name: "Default", Enable: true}
+		if err := SaveRecord(tenant); err != nil {
+			return err
+		}
+	}
+
+	for _, table := range tenantTables {
+		if err := UpdateRecords(table, db.Eq("TenantId", nil), tk.M{"TenantId": model.DefaultTenant}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
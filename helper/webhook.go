@@ -0,0 +1,113 @@
+package helper
+
+import (
+	"strconv"
+	"time"
+
+	db "github.com/eaciit/dbox"
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	webhook "github.com/tmluthfiana/phonebook/modules/webhook"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Webhooks delivers the ContactEvents to the webhooks of the tenants, created on start
+var Webhooks *webhook.Dispatcher
+
+// NewWebhooks creates the webhook dispatcher from GlobalConfig
+func NewWebhooks() *webhook.Dispatcher {
+	d := webhook.NewDispatcher(WebhookStore{})
+
+	if n, err := strconv.Atoi(GlobalConfig["webhookmaxattempts"]); err == nil && n > 0 {
+		d.MaxAttempts = n
+	}
+	if n, err := strconv.Atoi(GlobalConfig["webhooktimeout"]); err == nil && n > 0 {
+		d.Client.Timeout = time.Duration(n) * time.Second
+	}
+	if n, err := strconv.Atoi(GlobalConfig["webhookretention"]); err == nil && n > 0 {
+		d.Retention = time.Duration(n) * 24 * time.Hour
+	}
+
+	return d
+}
+
+// WebhookStore keeps the webhooks and their deliveries in the database
+type WebhookStore struct{}
+
+func (WebhookStore) Hooks(tenant string) ([]model.Webhook, error) {
+	hooks := []model.Webhook{}
+	if _, err := TenantScope(tenant).Find(new(model.Webhook), []*db.Filter{db.Eq("Enable", true)}, 0, 0, &hooks); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+func (WebhookStore) Hook(tenant string, id bson.ObjectId) (*model.Webhook, error) {
+	hook := new(model.Webhook)
+	if err := TenantScope(tenant).Get(hook, id); err != nil {
+		return nil, err
+	}
+
+	return hook, nil
+}
+
+func (WebhookStore) Due(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	due := []model.WebhookDelivery{}
+	filters := []*db.Filter{db.Eq("Status", model.DeliveryPending), db.Lte("NextAttempt", now)}
+	if _, err := FindSortedRecords(new(model.WebhookDelivery), filters, []string{"NextAttempt"}, limit, 0, &due); err != nil {
+		return nil, err
+	}
+
+	return due, nil
+}
+
+func (WebhookStore) SaveDelivery(d *model.WebhookDelivery) error {
+	return TenantScope(d.TenantId).Save(d)
+}
+
+func (WebhookStore) Prune(before time.Time) error {
+	return DeleteRecords(new(model.WebhookDelivery), db.And(db.Eq("Status", model.DeliveryDelivered), db.Lt("CreatedDate", before)))
+}
+
+// webhookCursor names the EventCursor of the webhook queue
+const webhookCursor = "webhooks"
+
+func (WebhookStore) Events(after bson.ObjectId, before time.Time, limit int) ([]events.Event, error) {
+	filters := []*db.Filter{db.Lt("_id", bson.NewObjectIdWithTime(before))}
+	if after != "" {
+		filters = append(filters, db.Gt("_id", after))
+	}
+
+	data := []model.ContactEvent{}
+	if _, err := FindSortedRecords(new(model.ContactEvent), filters, []string{"_id"}, limit, 0, &data); err != nil {
+		return nil, err
+	}
+
+	logged := make([]events.Event, 0, len(data))
+	for _, rec := range data {
+		logged = append(logged, events.Event{Id: rec.Id.Hex(), Type: rec.Type, TenantId: rec.TenantId, Contact: rec.Contact, Time: rec.Time})
+	}
+
+	return logged, nil
+}
+
+func (s WebhookStore) Cursor() (bson.ObjectId, error) {
+	cursors := []model.EventCursor{}
+	if _, err := FindRecords(new(model.EventCursor), []*db.Filter{db.Eq("_id", webhookCursor)}, 1, 0, &cursors); err != nil {
+		return "", err
+	}
+	if len(cursors) > 0 {
+		return cursors[0].EventId, nil
+	}
+
+	// the first start queues the events logged from now on
+	cursor := bson.NewObjectIdWithTime(time.Now())
+
+	return cursor, s.SaveCursor(cursor)
+}
+
+func (WebhookStore) SaveCursor(id bson.ObjectId) error {
+	return SaveRecord(&model.EventCursor{Id: webhookCursor, EventId: id})
+}
@@ -1,21 +1,225 @@
 package main
 
 import (
+	"context"
+	helper "github.com/tmluthfiana/phonebook/helper"
+	model "github.com/tmluthfiana/phonebook/model"
+	health "github.com/tmluthfiana/phonebook/modules/health"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+	metrics "github.com/tmluthfiana/phonebook/modules/metrics"
+	openapi "github.com/tmluthfiana/phonebook/modules/openapi"
 	routing "github.com/tmluthfiana/phonebook/modules/routing"
+	server "github.com/tmluthfiana/phonebook/modules/server"
 	w "github.com/tmluthfiana/phonebook/webext"
-	"net/http"
+	"os"
+	"strconv"
+	"time"
 
+	acl "github.com/eaciit/acl/v1.0"
 	_ "github.com/eaciit/dbox/dbc/mongo"
 )
 
 func main() {
+	logger.SetDefault(logger.New(os.Stderr, logger.ParseLevel(helper.GlobalConfig["loglevel"])))
+	logger.Info("Starting phonebook", "version", helper.Version)
+
+	if n, err := helper.MigratePhonebookEmail(); err != nil {
+		logger.Error("Migrate Email failed", "error", err)
+	} else if n > 0 {
+		logger.Info("Migrated Email", "contacts", n)
+	}
+
+	acl.SetAclDbConfig(helper.GlobalConfig)
+	if err := helper.SeedAcl(); err != nil {
+		logger.Error("Seed acl failed", "error", err)
+	}
+
+	if err := helper.SeedTenant(); err != nil {
+		logger.Error("Seed tenant failed", "error", err)
+	}
+
+	stopJobs := make(chan struct{})
+	if helper.GlobalConfig["ldapaddress"] != "" {
+		helper.LdapSync = helper.NewLdapSync()
+		helper.LdapSync.Start(stopJobs)
+	}
+
+	helper.Webhooks = helper.NewWebhooks()
+	helper.Webhooks.Start(stopJobs)
+	helper.StartEventLog(stopJobs)
+
+	ldapServer := helper.NewLdapServer()
+	if helper.GlobalConfig["ldapserveraddress"] != "" {
+		go func() {
+			if err := ldapServer.ListenAndServe(); err != nil {
+				logger.Error("LDAP server failed", "error", err)
+			}
+		}()
+	}
+
+	tenants := routing.Tenants(routing.TenantConfig{
+		Domain:  helper.GlobalConfig["tenantdomain"],
+		Default: model.DefaultTenant,
+		Find: func(id string) error {
+			_, err := helper.FindTenant(id)
+			return err
+		},
+	})
+
+	requests, _ := strconv.Atoi(helper.GlobalConfig["ratelimit"])
+	burst, _ := strconv.Atoi(helper.GlobalConfig["ratelimitburst"])
+	limiter := routing.NewRateLimiter(routing.Limit{Requests: requests, Per: time.Minute, Burst: burst})
+	limiter.TrustProxy = helper.GlobalConfig["trustproxy"] == "true"
+	perAddress, _ := strconv.Atoi(helper.GlobalConfig["ratelimitaddress"])
+	limiter.Address = routing.Limit{Requests: perAddress, Per: time.Minute}
+
+	accessLog := routing.AccessLog(logger.Default)
+
+	helper.RegisterMetrics(metrics.Default)
+	requestMetrics := routing.Metrics(routing.NewRouteMetrics(metrics.Default))
+
+	// the gRPC methods pass the middlewares of the REST routes, the controller checks the address books
+	grpcRoutes := routing.NewRouting("phonebook/controllers", w.RegisterClass())
+	grpcRoutes.Use(accessLog, requestMetrics, limiter.ThrottleAddress, grpcRoutes.SessionAuth, limiter.Throttle, tenants, grpcRoutes.Authorize)
+	for _, method := range []string{"Get", "List", "Create", "Update", "Delete", "Watch"} {
+		grpcRoutes.Post("/phonebook.v1.Contacts/"+method, "ContactService."+method).Require("phonebook.read")
+	}
+
 	routing := routing.NewRouting("phonebook/controllers", w.RegisterClass())
+	routing.Use(accessLog, requestMetrics, limiter.ThrottleAddress, routing.SessionAuth, limiter.Throttle, tenants, routing.Authorize)
+
+	timeout, _ := strconv.Atoi(helper.GlobalConfig["readytimeout"])
+	probes := health.NewProbes(helper.BuildInfo(), helper.HealthChecks()...)
+	probes.Timeout = time.Duration(timeout) * time.Second
+	routing.Handle("/healthz", probes.Live)
+	routing.Handle("/readyz", probes.Ready)
+
+	routing.Get("/metrics", "Metrics.Get").Require("metrics.read")
+
+	routing.Post("/auth/login", "Auth.Login").Public().RateLimit(10, time.Minute)
+	routing.Post("/auth/logout", "Auth.Logout")
+	routing.Get("/auth/me", "Auth.Me")
+	routing.Get("/auth/token/get", "Token.Get")
+	routing.Post("/auth/token/save", "Token.Save")
+	routing.Post("/auth/token/revoke/{id}", "Token.Revoke")
+
+	routing.Get("/phonebook/get", "Phonebook.Get").Require("phonebook.read")
+	routing.Get("/phonebook/view/{id}", "Phonebook.Get").Require("phonebook.read")
+	// address books are checked by the controller on top of these
+	routing.Post("/phonebook/save", "Phonebook.Save").Require("phonebook.read")
+	routing.Put("/phonebook/edit/{id}", "Phonebook.Save").Require("phonebook.read")
+	routing.Delete("/phonebook/delete/{id}", "Phonebook.Delete").Require("phonebook.read")
+	routing.Post("/phonebook/share/{id}", "Phonebook.Share").Require("phonebook.read")
+	routing.Post("/phonebook/photo/upload/{id}", "Phonebook.UploadPhoto").Require("phonebook.read")
+	routing.Get("/phonebook/photo/view/{id}", "Phonebook.Photo").Require("phonebook.read")
+	routing.Delete("/phonebook/photo/delete/{id}", "Phonebook.DeletePhoto").Require("phonebook.read")
+	routing.Get("/phonebook/events", "Phonebook.Events").Require("phonebook.read")
+	routing.Post("/phonebook/sync", "Phonebook.Sync").Require("phonebook.read")
+
+	routing.Get("/addressbook/get", "AddressBook.Get").Require("phonebook.read")
+	routing.Get("/addressbook/view/{id}", "AddressBook.Get").Require("phonebook.read")
+	routing.Post("/addressbook/save", "AddressBook.Save").Require("phonebook.read")
+	routing.Put("/addressbook/edit/{id}", "AddressBook.Save").Require("phonebook.read")
+	routing.Delete("/addressbook/delete/{id}", "AddressBook.Delete").Require("phonebook.read")
+	routing.Post("/addressbook/share/{id}", "AddressBook.Share").Require("phonebook.read")
+
+	routing.Get("/organization/get", "Organization.Get").Require("directory.read")
+	routing.Get("/organization/view/{id}", "Organization.Get").Require("directory.read")
+	routing.Post("/organization/save", "Organization.Save").Require("directory.write")
+	routing.Put("/organization/edit/{id}", "Organization.Save").Require("directory.write")
+	routing.Delete("/organization/delete/{id}", "Organization.Delete").Require("directory.delete")
+
+	routing.Get("/department/get", "Department.Get").Require("directory.read")
+	routing.Get("/department/view/{id}", "Department.Get").Require("directory.read")
+	routing.Post("/department/save", "Department.Save").Require("directory.write")
+	routing.Put("/department/edit/{id}", "Department.Save").Require("directory.write")
+	routing.Delete("/department/delete/{id}", "Department.Delete").Require("directory.delete")
+
+	routing.Get("/location/get", "Location.Get").Require("directory.read")
+	routing.Get("/location/view/{id}", "Location.Get").Require("directory.read")
+	routing.Post("/location/save", "Location.Save").Require("directory.write")
+	routing.Put("/location/edit/{id}", "Location.Save").Require("directory.write")
+	routing.Delete("/location/delete/{id}", "Location.Delete").Require("directory.delete")
+
+	routing.Get("/directory/department/{id}", "Directory.ByDepartment").Require("phonebook.read")
+	routing.Get("/directory/location/{id}", "Directory.ByLocation").Require("phonebook.read")
+	routing.Get("/directory/orgchart/{id}", "Directory.OrgChart").Require("phonebook.read")
+
+	routing.Get("/acl/group/get", "Group.Get").Require("acl.read")
+	routing.Get("/acl/group/view/{id}", "Group.Get").Require("acl.read")
+	routing.Post("/acl/group/save", "Group.Save").Require("tenant.write")
+	routing.Put("/acl/group/edit/{id}", "Group.Save").Require("tenant.write")
+	routing.Delete("/acl/group/delete/{id}", "Group.Delete").Require("tenant.write")
+	routing.Post("/acl/group/grant/{id}", "Group.Grant").Require("tenant.write")
+	routing.Post("/acl/group/revoke/{id}", "Group.Revoke").Require("tenant.write")
+
+	routing.Get("/acl/user/get", "User.Get").Require("acl.read")
+	routing.Get("/acl/user/view/{id}", "User.Get").Require("acl.read")
+	routing.Post("/acl/user/save", "User.Save").Require("acl.write")
+	routing.Put("/acl/user/edit/{id}", "User.Save").Require("acl.write")
+	routing.Delete("/acl/user/delete/{id}", "User.Delete").Require("acl.delete")
+
+	routing.Get("/tenant/get", "Tenant.Get").Require("tenant.read")
+	routing.Get("/tenant/view/{id}", "Tenant.Get").Require("tenant.read")
+	routing.Post("/tenant/save", "Tenant.Save").Require("tenant.write")
+	routing.Put("/tenant/edit/{id}", "Tenant.Save").Require("tenant.write")
+
+	routing.Get("/webhook/get", "Webhook.Get").Require("webhook.read")
+	routing.Get("/webhook/view/{id}", "Webhook.Get").Require("webhook.read")
+	routing.Post("/webhook/save", "Webhook.Save").Require("webhook.write")
+	routing.Put("/webhook/edit/{id}", "Webhook.Save").Require("webhook.write")
+	routing.Delete("/webhook/delete/{id}", "Webhook.Delete").Require("webhook.delete")
+	routing.Post("/webhook/replay/{id}", "Webhook.ReplayFailed").Require("webhook.write")
+	routing.Get("/webhook/delivery/get", "Webhook.Deliveries").Require("webhook.read")
+	routing.Get("/webhook/delivery/view/{id}", "Webhook.Deliveries").Require("webhook.read")
+	routing.Post("/webhook/delivery/replay/{id}", "Webhook.Replay").Require("webhook.write")
+
+	routing.Post("/ldap/sync", "LdapSync.Run").Require("phonebook.write").RateLimit(2, time.Minute)
+	routing.Get("/ldap/sync/status", "LdapSync.Status").Require("phonebook.read")
+
+	// the resolvers check the address books like the contact routes
+	routing.Post("/graphql", "GraphQL.Query").Require("phonebook.read")
+	routing.Get("/graphql/schema", "GraphQL.Schema").Require("phonebook.read")
+
+	routing.Mount("/.well-known/carddav", "CardDav.Serve").Public()
+	routing.Mount("/carddav", "CardDav.Serve").BasicAuth("phonebook").Require("phonebook.read")
+
+	// the reference is generated from the routes above, register new routes before it
+	spec := openapi.Generate(openapi.Info{Title: "Phonebook API", Version: helper.Version}, routing.Routes(), helper.Result{})
+	routing.Handle("/openapi.json", spec.ServeHTTP)
+	routing.Handle("/docs", openapi.DocsHandler("Phonebook API", "/openapi.json").ServeHTTP)
+
+	cfg, err := helper.ServerConfig()
+	if err != nil {
+		logger.Error("HTTP server config failed", "error", err)
+		os.Exit(1)
+	}
+
+	srv := server.New(cfg, routing.Routing())
+	// event streams never finish on their own, end them as the drain starts
+	srv.HTTP.RegisterOnShutdown(helper.CloseEventStreams)
+	// closers run last to first: the database goes once the jobs and listeners are stopped
+	srv.OnShutdown("database", helper.CloseDB)
+	srv.OnShutdown("ldap sync and webhooks", func(ctx context.Context) error {
+		close(stopJobs)
+		return nil
+	})
+	srv.OnShutdown("ldap server", func(ctx context.Context) error {
+		return ldapServer.Close()
+	})
 
-	routing.Get("/phonebook/get", "Phonebook.Get")
-	routing.Get("/phonebook/view/{id}", "Phonebook.Get")
-	routing.Post("/phonebook/save", "Phonebook.Save")
-	routing.Put("/phonebook/edit/{id}", "Phonebook.Save")
-	routing.Delete("/phonebook/delete/{id}", "Phonebook.Delete")
+	grpcServer := helper.NewGrpcServer(grpcRoutes.Routing(), cfg.TLS)
+	if helper.GlobalConfig["grpcaddress"] != "" {
+		go func() {
+			if err := grpcServer.ListenAndServe(); err != nil {
+				logger.Error("gRPC server failed", "error", err)
+			}
+		}()
+	}
+	srv.OnShutdown("grpc server", grpcServer.Shutdown)
 
-	http.ListenAndServe(":3030", routing.Routing())
+	if err := srv.ListenAndServe(); err != nil {
+		logger.Error("HTTP server failed", "error", err)
+		os.Exit(1)
+	}
 }
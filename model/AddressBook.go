@@ -0,0 +1,142 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const (
+	AccessNone  = ""
+	AccessRead  = "read"
+	AccessWrite = "write"
+)
+
+// MaxAccess is the wider of two access levels.
+func MaxAccess(a, b string) string {
+	if a == AccessWrite || b == AccessWrite {
+		return AccessWrite
+	}
+
+	if a == AccessRead || b == AccessRead {
+		return AccessRead
+	}
+
+	return AccessNone
+}
+
+// Share gives a user or an acl group read or write access to an address
+// book or a single contact.
+type Share struct {
+	UserId  string `bson:"UserId,omitempty" json:"UserId,omitempty"`
+	GroupId string `bson:"GroupId,omitempty" json:"GroupId,omitempty"`
+	Access  string `bson:"Access" json:"Access"`
+}
+
+type Shares []Share
+
+// Access is the widest access the shares give the user or one of groups.
+func (s Shares) Access(userId string, groups []string) string {
+	access := AccessNone
+	for _, share := range s {
+		if share.UserId != "" && share.UserId == userId {
+			access = MaxAccess(access, share.Access)
+		}
+
+		for _, g := range groups {
+			if share.GroupId != "" && share.GroupId == g {
+				access = MaxAccess(access, share.Access)
+			}
+		}
+	}
+
+	return access
+}
+
+// Set replaces the share of the same user or group, AccessNone removes it.
+func (s Shares) Set(share Share) Shares {
+	res := Shares{}
+	for _, old := range s {
+		if old.UserId != share.UserId || old.GroupId != share.GroupId {
+			res = append(res, old)
+		}
+	}
+
+	if share.Access != AccessNone {
+		res = append(res, share)
+	}
+
+	return res
+}
+
+// AddressBook holds contacts outside the shared directory. Every user gets
+// a Personal book, further books can be created and shared.
+type AddressBook struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	Name          string        `bson:"Name" json:"Name"`
+	OwnerId       string        `bson:"OwnerId" json:"OwnerId"`
+	Personal      bool          `bson:"Personal" json:"Personal"`
+	Shares        Shares        `bson:"Shares" json:"Shares"`
+	CreatedDate   time.Time
+	UpdateDate    time.Time
+}
+
+func (e *AddressBook) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+// Access is what the user with groups may do with the book, its owner
+// may write.
+func (e *AddressBook) Access(userId string, groups []string) string {
+	if e.OwnerId == userId {
+		return AccessWrite
+	}
+
+	return e.Shares.Access(userId, groups)
+}
+
+func (e *AddressBook) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *AddressBook) TableName() string {
+	return "AddressBook"
+}
+
+func (e *AddressBook) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *AddressBook) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+const (
+	// SourceDirectory is the shared directory of the tenant
+	SourceDirectory = "directory"
+	// SourceOwn are the books of the user
+	SourceOwn = "own"
+	// SourceBook are books other users share
+	SourceBook = "shared-book"
+	// SourceContact are single contacts other users share
+	SourceContact = "shared-contact"
+)
+
+// ContactSource tells where a listed contact comes from and what the
+// user may do with it.
+type ContactSource struct {
+	Type            string        `json:"Type"`
+	AddressBookId   bson.ObjectId `json:"AddressBookId,omitempty"`
+	AddressBookName string        `json:"AddressBookName,omitempty"`
+	Access          string        `json:"Access"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "testing"
+
+func TestSharesAccess(t *testing.T) {
+	shares := Shares{
+		{UserId: "tias", Access: AccessRead},
+		{GroupId: "sales", Access: AccessWrite},
+	}
+
+	cases := []struct {
+		user   string
+		groups []string
+		access string
+	}{
+		{"tias", nil, AccessRead},
+		{"tias", []string{"sales"}, AccessWrite},
+		{"bob", []string{"reader"}, AccessNone},
+		{"", []string{""}, AccessNone},
+	}
+
+	for _, c := range cases {
+		if got := shares.Access(c.user, c.groups); got != c.access {
+			t.Errorf("%s in %v: expected %q, got %q", c.user, c.groups, c.access, got)
+		}
+	}
+}
+
+func TestSharesSet(t *testing.T) {
+	shares := Shares{}.Set(Share{UserId: "tias", Access: AccessRead})
+	shares = shares.Set(Share{UserId: "tias", Access: AccessWrite})
+	shares = shares.Set(Share{GroupId: "sales", Access: AccessRead})
+	if len(shares) != 2 || shares.Access("tias", nil) != AccessWrite {
+		t.Fatalf("expected the share of tias to be replaced, got %+v", shares)
+	}
+
+	shares = shares.Set(Share{UserId: "tias", Access: AccessNone})
+	if len(shares) != 1 || shares[0].GroupId != "sales" {
+		t.Errorf("expected only the sales share, got %+v", shares)
+	}
+}
+
+func TestAddressBookAccess(t *testing.T) {
+	book := AddressBook{OwnerId: "tias", Shares: Shares{{GroupId: "sales", Access: AccessRead}}}
+	if book.Access("tias", nil) != AccessWrite {
+		t.Error("the owner should write")
+	}
+	if book.Access("bob", []string{"sales"}) != AccessRead {
+		t.Error("sales should read")
+	}
+	if book.Access("bob", nil) != AccessNone {
+		t.Error("bob should not see the book")
+	}
+}
@@ -0,0 +1,45 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// ContactEvent logs a change of a contact so event streams can resume
+// where a client left off. Ids grow with time, streams page by them.
+type ContactEvent struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	// Type is contact.created, contact.updated or contact.deleted
+	Type string `bson:"Type" json:"Type"`
+	// Contact is the contact as saved, or as it was before the deletion
+	Contact Phonebook `bson:"Contact" json:"Contact"`
+	Time    time.Time `bson:"Time" json:"Time"`
+}
+
+func (e *ContactEvent) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+	}
+
+	return nil
+}
+
+func (e *ContactEvent) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *ContactEvent) TableName() string {
+	return "PhonebookEvent"
+}
+
+func (e *ContactEvent) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *ContactEvent) SetTenantId(id string) {
+	e.TenantId = id
+}
@@ -0,0 +1,159 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+type Organization struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	Name          string        `bson:"Name" json:"Name"`
+	Website       string        `bson:"Website" json:"Website"`
+	CreatedDate   time.Time
+	UpdateDate    time.Time
+}
+
+func (e *Organization) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Organization) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Organization) TableName() string {
+	return "Organization"
+}
+
+func (e *Organization) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Organization) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+type Department struct {
+	orm.ModelBase  `bson:"-" json:"-"`
+	Id             bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId       string        `bson:"TenantId" json:"TenantId"`
+	OrganizationId bson.ObjectId `bson:"OrganizationId,omitempty" json:"OrganizationId"`
+	ParentId       bson.ObjectId `bson:"ParentId,omitempty" json:"ParentId"`
+	Name           string        `bson:"Name" json:"Name"`
+	Code           string        `bson:"Code" json:"Code"`
+	CreatedDate    time.Time
+	UpdateDate     time.Time
+}
+
+func (e *Department) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Department) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Department) TableName() string {
+	return "Department"
+}
+
+func (e *Department) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Department) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+type Location struct {
+	orm.ModelBase  `bson:"-" json:"-"`
+	Id             bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId       string        `bson:"TenantId" json:"TenantId"`
+	OrganizationId bson.ObjectId `bson:"OrganizationId,omitempty" json:"OrganizationId"`
+	Name           string        `bson:"Name" json:"Name"`
+	Address        AddressDetail `bson:"Address" json:"Address"`
+	TimeZone       string        `bson:"TimeZone" json:"TimeZone"`
+	CreatedDate    time.Time
+	UpdateDate     time.Time
+}
+
+func (e *Location) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Location) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Location) TableName() string {
+	return "Location"
+}
+
+func (e *Location) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Location) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+// OrgChartNode is a contact together with everyone reporting to them.
+type OrgChartNode struct {
+	Contact Phonebook
+	Reports []*OrgChartNode
+}
+
+// BuildOrgChart arranges contacts into the reporting tree below root. A
+// contact is visited only once, so broken data with manager cycles still
+// yields a finite tree.
+func BuildOrgChart(root Phonebook, contacts []Phonebook) *OrgChartNode {
+	reports := map[bson.ObjectId][]Phonebook{}
+	for _, c := range contacts {
+		if c.ManagerId != "" {
+			reports[c.ManagerId] = append(reports[c.ManagerId], c)
+		}
+	}
+
+	visited := map[bson.ObjectId]bool{}
+
+	var build func(c Phonebook) *OrgChartNode
+	build = func(c Phonebook) *OrgChartNode {
+		visited[c.Id] = true
+
+		node := &OrgChartNode{Contact: c, Reports: []*OrgChartNode{}}
+		for _, r := range reports[c.Id] {
+			if !visited[r.Id] {
+				node.Reports = append(node.Reports, build(r))
+			}
+		}
+
+		return node
+	}
+
+	return build(root)
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"testing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestBuildOrgChart(t *testing.T) {
+	ceo := Phonebook{Id: bson.NewObjectId(), FirstName: "Ceo"}
+	cto := Phonebook{Id: bson.NewObjectId(), FirstName: "Cto", ManagerId: ceo.Id}
+	dev := Phonebook{Id: bson.NewObjectId(), FirstName: "Dev", ManagerId: cto.Id}
+	cfo := Phonebook{Id: bson.NewObjectId(), FirstName: "Cfo", ManagerId: ceo.Id}
+
+	chart := BuildOrgChart(ceo, []Phonebook{cto, dev, cfo})
+	if len(chart.Reports) != 2 {
+		t.Fatalf("expected 2 direct reports, got %d", len(chart.Reports))
+	}
+
+	if chart.Reports[0].Contact.Id != cto.Id || len(chart.Reports[0].Reports) != 1 {
+		t.Error("Dev should report to Cto")
+	}
+}
+
+func TestBuildOrgChartCycle(t *testing.T) {
+	a := Phonebook{Id: bson.NewObjectId()}
+	b := Phonebook{Id: bson.NewObjectId(), ManagerId: a.Id}
+	a.ManagerId = b.Id
+
+	chart := BuildOrgChart(a, []Phonebook{a, b})
+	if len(chart.Reports) != 1 || len(chart.Reports[0].Reports) != 0 {
+		t.Error("cycle should stop at the root")
+	}
+}
@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 
 	"github.com/eaciit/orm"
@@ -10,16 +11,43 @@ import (
 type Phonebook struct {
 	orm.ModelBase `bson:"-" json:"-"`
 	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
 	FirstName     string        `bson:"FirstName" json:"FirstName"`
 	LastName      string        `bson:"LastName" json:"LastName"`
 	PhoneNumber   []PhoneNumberDetail
-	Email         string `bson:"Email" json:"Email"`
-	LastAction    string
-	Status        string
-	CreatedDate   time.Time
-	CreatedBy     string
-	UpdateDate    time.Time
-	UpdateBy      string
+	Emails        []EmailDetail   `bson:"Emails" json:"Emails"`
+	Addresses     []AddressDetail `bson:"Addresses" json:"Addresses"`
+	JobTitle      string          `bson:"JobTitle" json:"JobTitle"`
+	Company       string          `bson:"Company" json:"Company"`
+	Department    string          `bson:"Department" json:"Department"`
+	Website       string          `bson:"Website" json:"Website"`
+	Birthday      string          `bson:"Birthday" json:"Birthday"`
+	Notes         string          `bson:"Notes" json:"Notes"`
+	// directory links, see Organization, Department and Location
+	OrganizationId bson.ObjectId `bson:"OrganizationId,omitempty" json:"OrganizationId"`
+	DepartmentId   bson.ObjectId `bson:"DepartmentId,omitempty" json:"DepartmentId"`
+	LocationId     bson.ObjectId `bson:"LocationId,omitempty" json:"LocationId"`
+	ManagerId      bson.ObjectId `bson:"ManagerId,omitempty" json:"ManagerId"`
+	Photo          *PhotoDetail  `bson:"Photo,omitempty" json:"Photo,omitempty"`
+	// ExternalSource and ExternalId identify contacts maintained by a
+	// directory sync, e.g. "ldap" and the entry DN
+	ExternalSource string `bson:"ExternalSource,omitempty" json:"ExternalSource,omitempty"`
+	ExternalId     string `bson:"ExternalId,omitempty" json:"ExternalId,omitempty"`
+	// AddressBookId is empty for contacts of the shared directory, Shares
+	// give single contacts of an address book to further users and groups
+	AddressBookId bson.ObjectId `bson:"AddressBookId,omitempty" json:"AddressBookId"`
+	Shares        Shares        `bson:"Shares,omitempty" json:"Shares,omitempty"`
+	// Uid names contacts created over CardDAV, others use the hex Id
+	Uid string `bson:"Uid,omitempty" json:"Uid,omitempty"`
+	// Email is the legacy single address, kept in sync with the primary
+	// entry of Emails so old clients can still read and write it.
+	Email       string `bson:"Email" json:"Email"`
+	LastAction  string
+	Status      string
+	CreatedDate time.Time
+	CreatedBy   string
+	UpdateDate  time.Time
+	UpdateBy    string
 }
 
 func (e *Phonebook) PreSave() error {
@@ -32,15 +60,146 @@ func (e *Phonebook) PreSave() error {
 		e.LastAction = "update"
 	}
 
+	e.NormalizeEmail()
+
 	return nil
 }
 
+// NormalizeEmail moves the legacy Email into Emails when it is not listed
+// yet, then points Email at the primary address.
+func (e *Phonebook) NormalizeEmail() {
+	legacy := strings.TrimSpace(e.Email)
+	if legacy != "" && !e.HasEmail(legacy) {
+		e.Emails = append([]EmailDetail{{EmailAddress: legacy, EmailType: EmailTypeOther}}, e.Emails...)
+	}
+
+	e.Email = e.PrimaryEmail()
+}
+
+func (e *Phonebook) HasEmail(address string) bool {
+	for _, m := range e.Emails {
+		if strings.EqualFold(m.EmailAddress, address) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (e *Phonebook) PrimaryEmail() string {
+	if len(e.Emails) == 0 {
+		return ""
+	}
+
+	return e.Emails[0].EmailAddress
+}
+
+// KeepServerFields copies the fields clients never send from the stored
+// version of the contact, so a full update does not wipe them, and
+// resolves the legacy Email against it, see UpdateEmail.
+func (e *Phonebook) KeepServerFields(old *Phonebook) {
+	e.CreatedDate = old.CreatedDate
+	e.CreatedBy = old.CreatedBy
+	e.Photo = old.Photo
+	e.ExternalSource = old.ExternalSource
+	e.ExternalId = old.ExternalId
+	e.Uid = old.Uid
+	e.Shares = old.Shares
+	e.UpdateEmail(old)
+}
+
+// UpdateEmail applies the legacy Email of an update to Emails. An unchanged
+// Email leaves Emails as sent; a changed one becomes the primary address,
+// replacing the previous primary instead of piling up before it.
+func (e *Phonebook) UpdateEmail(old *Phonebook) {
+	legacy := strings.TrimSpace(e.Email)
+	if legacy == "" || strings.EqualFold(legacy, old.Email) {
+		e.Email = e.PrimaryEmail()
+		return
+	}
+
+	i := e.emailIndex(legacy)
+	if i < 0 {
+		if i = e.emailIndex(old.Email); i < 0 {
+			e.NormalizeEmail()
+			return
+		}
+		e.Emails[i].EmailAddress = legacy
+	}
+
+	emails := append([]EmailDetail{e.Emails[i]}, e.Emails[:i]...)
+	e.Emails = append(emails, e.Emails[i+1:]...)
+	e.Email = legacy
+}
+
+func (e *Phonebook) emailIndex(address string) int {
+	for i, m := range e.Emails {
+		if address != "" && strings.EqualFold(m.EmailAddress, address) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Modified is the time of the last insert or update.
+func (e *Phonebook) Modified() time.Time {
+	if e.UpdateDate.After(e.CreatedDate) {
+		return e.UpdateDate
+	}
+
+	return e.CreatedDate
+}
+
+// CardName is the resource name of the contact in the CardDAV address book.
+func (e *Phonebook) CardName() string {
+	if e.Uid != "" {
+		return e.Uid
+	}
+
+	return e.Id.Hex()
+}
+
 type PhoneNumberDetail struct {
 	PhoneNo   string `bson:"PhoneNo" json:"PhoneNo"`
 	ProneType string `bson:"ProneType" json:"ProneType"`
 	PhoneExt  string `bson:"PhoneExt" json:"PhoneExt"`
 }
 
+const (
+	StatusActive   = "active"
+	StatusInactive = "inactive"
+)
+
+const (
+	EmailTypeWork  = "Work"
+	EmailTypeHome  = "Home"
+	EmailTypeOther = "Other"
+)
+
+type EmailDetail struct {
+	EmailAddress string `bson:"EmailAddress" json:"EmailAddress"`
+	EmailType    string `bson:"EmailType" json:"EmailType"`
+}
+
+type AddressDetail struct {
+	AddressType string `bson:"AddressType" json:"AddressType"`
+	Street      string `bson:"Street" json:"Street"`
+	City        string `bson:"City" json:"City"`
+	Region      string `bson:"Region" json:"Region"`
+	PostalCode  string `bson:"PostalCode" json:"PostalCode"`
+	Country     string `bson:"Country" json:"Country"`
+}
+
+// PhotoDetail describes the uploaded photo of a contact, the image data
+// itself lives in the photo store.
+type PhotoDetail struct {
+	PhotoUrl    string    `bson:"PhotoUrl" json:"PhotoUrl"`
+	ContentType string    `bson:"ContentType" json:"ContentType"`
+	Version     string    `bson:"Version" json:"Version"`
+	UploadDate  time.Time `bson:"UploadDate" json:"UploadDate"`
+}
+
 func (e *Phonebook) RecordID() interface{} {
 	return e.Id
 }
@@ -48,3 +207,11 @@ func (e *Phonebook) RecordID() interface{} {
 func (m *Phonebook) TableName() string {
 	return "Phonebook"
 }
+
+func (e *Phonebook) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Phonebook) SetTenantId(id string) {
+	e.TenantId = id
+}
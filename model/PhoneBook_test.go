@@ -0,0 +1,44 @@
+package model
+
+import "testing"
+
+func TestUpdateEmail(t *testing.T) {
+	old := &Phonebook{Email: "a@example.com", Emails: []EmailDetail{{"a@example.com", EmailTypeWork}, {"b@example.com", EmailTypeHome}}}
+
+	cases := []struct {
+		name   string
+		email  string
+		emails []EmailDetail
+		want   []string
+	}{
+		{"old client changes Email", "c@example.com", old.Emails, []string{"c@example.com", "b@example.com"}},
+		{"old client picks another primary", "b@example.com", old.Emails, []string{"b@example.com", "a@example.com"}},
+		{"new client drops the primary", "a@example.com", old.Emails[1:], []string{"b@example.com"}},
+		{"new client leaves Email out", "", []EmailDetail{{"d@example.com", EmailTypeWork}}, []string{"d@example.com"}},
+		{"no Emails sent", "c@example.com", nil, []string{"c@example.com"}},
+	}
+
+	for _, c := range cases {
+		e := &Phonebook{Email: c.email, Emails: append([]EmailDetail{}, c.emails...)}
+		e.UpdateEmail(old)
+
+		got := []string{}
+		for _, m := range e.Emails {
+			got = append(got, m.EmailAddress)
+		}
+		if len(got) != len(c.want) || e.Email != c.want[0] {
+			t.Errorf("%s: Emails %v, Email %s", c.name, got, e.Email)
+			continue
+		}
+		for i := range got {
+			if got[i] != c.want[i] {
+				t.Errorf("%s: Emails %v", c.name, got)
+				break
+			}
+		}
+	}
+
+	if old.Emails[0].EmailAddress != "a@example.com" {
+		t.Error("the stored contact changed")
+	}
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+)
+
+// DefaultTenant owns the records stored before tenants existed and the
+// users given no tenant.
+const DefaultTenant = "default"
+
+// Tenant is a subsidiary with its own phonebook, Id is the slug used in
+// the X-Tenant-Id header and as subdomain.
+type Tenant struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            string `bson:"_id" json:"_id"`
+	Name          string `bson:"Name" json:"Name"`
+	Enable        bool   `bson:"Enable" json:"Enable"`
+	CreatedDate   time.Time
+	UpdateDate    time.Time
+}
+
+func (e *Tenant) PreSave() error {
+	if e.CreatedDate.IsZero() {
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Tenant) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Tenant) TableName() string {
+	return "Tenant"
+}
+
+// TenantRecord is implemented by the models owned by a tenant, they are
+// read and written through helper.TenantScope.
+type TenantRecord interface {
+	orm.IModel
+	GetTenantId() string
+	SetTenantId(id string)
+}
@@ -0,0 +1,52 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Tombstone remembers a deleted contact, or one that left a book or lost a
+// share, so syncing clients learn about the deletion. AddressBookId and
+// Shares are those the contact had, they tell who could see it.
+type Tombstone struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	ContactId     bson.ObjectId `bson:"ContactId" json:"ContactId"`
+	AddressBookId bson.ObjectId `bson:"AddressBookId,omitempty" json:"AddressBookId,omitempty"`
+	Shares        Shares        `bson:"Shares,omitempty" json:"Shares,omitempty"`
+	CardName      string        `bson:"CardName" json:"CardName"`
+	DeletedDate   time.Time
+	DeletedBy     string
+}
+
+func NewTombstone(c *Phonebook, by string) *Tombstone {
+	return &Tombstone{ContactId: c.Id, AddressBookId: c.AddressBookId, Shares: c.Shares, CardName: c.CardName(), DeletedBy: by}
+}
+
+func (e *Tombstone) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.DeletedDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Tombstone) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Tombstone) TableName() string {
+	return "PhonebookTombstone"
+}
+
+func (e *Tombstone) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Tombstone) SetTenantId(id string) {
+	e.TenantId = id
+}
@@ -0,0 +1,148 @@
+package model
+
+import (
+	"time"
+
+	"github.com/eaciit/orm"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Webhook subscribes a URL to the changes of directory contacts. The
+// fields below Events narrow the contacts, empty fields match all.
+type Webhook struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	Name          string        `bson:"Name" json:"Name"`
+	Url           string        `bson:"Url" json:"Url"`
+	// Secret signs the payloads, it is only shown when created or rotated
+	Secret string `bson:"Secret" json:"Secret,omitempty"`
+	// Events are contact.created, contact.updated or contact.deleted
+	Events         []string      `bson:"Events" json:"Events"`
+	Company        string        `bson:"Company,omitempty" json:"Company"`
+	OrganizationId bson.ObjectId `bson:"OrganizationId,omitempty" json:"OrganizationId"`
+	DepartmentId   bson.ObjectId `bson:"DepartmentId,omitempty" json:"DepartmentId"`
+	LocationId     bson.ObjectId `bson:"LocationId,omitempty" json:"LocationId"`
+	Enable         bool          `bson:"Enable" json:"Enable"`
+	CreatedDate    time.Time
+	CreatedBy      string
+	UpdateDate     time.Time
+	UpdateBy       string
+}
+
+func (e *Webhook) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *Webhook) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *Webhook) TableName() string {
+	return "Webhook"
+}
+
+func (e *Webhook) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *Webhook) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event sent to one webhook, with the log of its
+// attempts. Failed deliveries wait for a replay.
+type WebhookDelivery struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            bson.ObjectId `bson:"_id" json:"_id"`
+	TenantId      string        `bson:"TenantId" json:"TenantId"`
+	WebhookId     bson.ObjectId `bson:"WebhookId" json:"WebhookId"`
+	// EventId is the same for the deliveries of an event to several webhooks
+	EventId   string        `bson:"EventId" json:"EventId"`
+	Event     string        `bson:"Event" json:"Event"`
+	ContactId bson.ObjectId `bson:"ContactId" json:"ContactId"`
+	// Payload is the JSON body sent
+	Payload string `bson:"Payload" json:"Payload"`
+	Status  string `bson:"Status" json:"Status"`
+	// Tries counts the attempts since the delivery was created or replayed
+	Tries       int              `bson:"Tries" json:"Tries"`
+	NextAttempt time.Time        `bson:"NextAttempt" json:"NextAttempt"`
+	Attempts    []WebhookAttempt `bson:"Attempts" json:"Attempts"`
+	CreatedDate time.Time
+	UpdateDate  time.Time
+}
+
+// WebhookAttempt is the outcome of one POST of a delivery, StatusCode is 0
+// when no response came.
+type WebhookAttempt struct {
+	Date       time.Time `bson:"Date" json:"Date"`
+	StatusCode int       `bson:"StatusCode" json:"StatusCode"`
+	Error      string    `bson:"Error,omitempty" json:"Error,omitempty"`
+	// Response is the start of the response body
+	Response string `bson:"Response,omitempty" json:"Response,omitempty"`
+	// Duration is in milliseconds
+	Duration int64 `bson:"Duration" json:"Duration"`
+}
+
+func (e *WebhookDelivery) PreSave() error {
+	if e.Id == "" {
+		e.Id = bson.NewObjectId()
+		e.CreatedDate = time.Now()
+	} else {
+		e.UpdateDate = time.Now()
+	}
+
+	return nil
+}
+
+func (e *WebhookDelivery) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *WebhookDelivery) TableName() string {
+	return "WebhookDelivery"
+}
+
+func (e *WebhookDelivery) GetTenantId() string {
+	return e.TenantId
+}
+
+func (e *WebhookDelivery) SetTenantId(id string) {
+	e.TenantId = id
+}
+
+// EventCursor remembers the last logged ContactEvent a consumer of the
+// event log handled, Id names the consumer.
+type EventCursor struct {
+	orm.ModelBase `bson:"-" json:"-"`
+	Id            string        `bson:"_id" json:"_id"`
+	EventId       bson.ObjectId `bson:"EventId" json:"EventId"`
+	UpdateDate    time.Time
+}
+
+func (e *EventCursor) PreSave() error {
+	e.UpdateDate = time.Now()
+
+	return nil
+}
+
+func (e *EventCursor) RecordID() interface{} {
+	return e.Id
+}
+
+func (m *EventCursor) TableName() string {
+	return "PhonebookEventCursor"
+}
@@ -0,0 +1,478 @@
+// Package carddav serves the phonebook as a single CardDAV address book
+// (RFC 6352) with WebDAV sync (RFC 6578), so phones and desktop clients can
+// read and edit contacts natively.
+package carddav
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	vcard "github.com/tmluthfiana/phonebook/modules/vcard"
+)
+
+// maxBody limits request bodies, vCards with embedded photos included.
+const maxBody = 1 << 20
+
+const (
+	bookPath   = "/addressbook/"
+	cardSuffix = ".vcf"
+	// tokenPrefix makes the sync token the URI RFC 6578 asks for
+	tokenPrefix = "urn:phonebook:sync:"
+	// syncLag keeps the sync token that far behind the clock, so cards
+	// stamped just before it but stored just after are picked up by the
+	// next sync
+	syncLag = 5 * time.Second
+)
+
+var ErrNotFound = errors.New("Contact not found")
+
+// InvalidError is returned by Backend.Put for cards the phonebook refuses,
+// they are answered with 403.
+type InvalidError struct {
+	Err error
+}
+
+func (e *InvalidError) Error() string {
+	return e.Err.Error()
+}
+
+// Backend is the contact storage behind the address book, cards are named
+// by model.Phonebook.CardName.
+type Backend interface {
+	List() ([]model.Phonebook, error)
+	// Get returns ErrNotFound for unknown names.
+	Get(name string) (*model.Phonebook, error)
+	// Put stores c under name, existing is the stored contact or nil.
+	Put(name string, c *model.Phonebook, existing *model.Phonebook) error
+	Delete(c *model.Phonebook) error
+	// Deleted lists the contacts deleted after since.
+	Deleted(since time.Time) ([]model.Tombstone, error)
+}
+
+type Handler struct {
+	// Prefix is the path the handler is mounted at, e.g. /carddav
+	Prefix  string
+	Name    string
+	Backend Backend
+	// ReadOnly refuses PUT and DELETE and reports read privileges only.
+	ReadOnly bool
+
+	// now is the clock of the sync tokens, time.Now when nil
+	now func() time.Time
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/.well-known/carddav" {
+		http.Redirect(w, r, h.Prefix+"/", http.StatusMovedPermanently)
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, h.Prefix)
+	switch {
+	case !strings.HasPrefix(r.URL.Path, h.Prefix):
+		http.NotFound(w, r)
+	case p == "" || p == "/":
+		h.serveRoot(w, r)
+	case p+"/" == bookPath || p == bookPath:
+		h.serveBook(w, r)
+	case strings.HasPrefix(p, bookPath) && strings.HasSuffix(p, cardSuffix) && !strings.Contains(p[len(bookPath):], "/"):
+		h.serveCard(w, r, strings.TrimSuffix(p[len(bookPath):], cardSuffix))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) options(w http.ResponseWriter, allow string) {
+	w.Header().Set("DAV", "1, 3, addressbook")
+	w.Header().Set("Allow", allow)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) serveRoot(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		h.options(w, "OPTIONS, PROPFIND")
+	case "PROPFIND":
+		h.propfind(w, r, func(m *multistatus, wanted []xml.Name, all, namesOnly bool, depth int) error {
+			m.response(h.Prefix+"/", h.rootProps(), wanted, all, namesOnly)
+			if depth == 0 {
+				return nil
+			}
+
+			p, err := h.bookProps()
+			if err != nil {
+				return err
+			}
+			m.response(h.Prefix+bookPath, p, wanted, all, namesOnly)
+
+			return nil
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveBook(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "OPTIONS":
+		h.options(w, "OPTIONS, PROPFIND, REPORT, PUT, DELETE")
+	case "PROPFIND":
+		h.propfind(w, r, func(m *multistatus, wanted []xml.Name, all, namesOnly bool, depth int) error {
+			p, err := h.bookProps()
+			if err != nil {
+				return err
+			}
+			m.response(h.Prefix+bookPath, p, wanted, all, namesOnly)
+			if depth == 0 {
+				return nil
+			}
+
+			contacts, err := h.Backend.List()
+			if err != nil {
+				return err
+			}
+			for i := range contacts {
+				if visible(&contacts[i]) {
+					m.response(h.href(&contacts[i]), h.cardProps(&contacts[i]), wanted, all, namesOnly, cardName("address-data"))
+				}
+			}
+
+			return nil
+		})
+	case "REPORT":
+		h.report(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) serveCard(w http.ResponseWriter, r *http.Request, name string) {
+	c, err := h.Backend.Get(name)
+	if err == ErrNotFound || (err == nil && !visible(c)) {
+		c, err = nil, nil
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch r.Method {
+	case "OPTIONS":
+		h.options(w, "OPTIONS, PROPFIND, GET, HEAD, PUT, DELETE")
+	case "PROPFIND":
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		h.propfind(w, r, func(m *multistatus, wanted []xml.Name, all, namesOnly bool, depth int) error {
+			m.response(h.href(c), h.cardProps(c), wanted, all, namesOnly, cardName("address-data"))
+			return nil
+		})
+	case "GET", "HEAD":
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		data := vcard.Encode(c, c.CardName())
+		etag := etag(data)
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Last-Modified", c.Modified().UTC().Format(http.TimeFormat))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		w.Header().Set("Content-Type", vcard.ContentType)
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.Write(data)
+	case "PUT":
+		h.put(w, r, name, c)
+	case "DELETE":
+		if h.ReadOnly {
+			http.Error(w, "The address book is read-only", http.StatusForbidden)
+			return
+		}
+		if c == nil {
+			http.NotFound(w, r)
+			return
+		}
+		if !ifMatch(r, c) {
+			http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+			return
+		}
+
+		if err := h.Backend.Delete(c); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (h *Handler) put(w http.ResponseWriter, r *http.Request, name string, existing *model.Phonebook) {
+	if h.ReadOnly {
+		http.Error(w, "The address book is read-only", http.StatusForbidden)
+		return
+	}
+
+	if (existing != nil && r.Header.Get("If-None-Match") == "*") || (existing == nil && r.Header.Get("If-Match") != "") || !ifMatch(r, existing) {
+		http.Error(w, "Precondition failed", http.StatusPreconditionFailed)
+		return
+	}
+
+	if ct := r.Header.Get("Content-Type"); ct != "" && !strings.HasPrefix(ct, "text/vcard") && !strings.HasPrefix(ct, "text/x-vcard") {
+		writeError(w, http.StatusUnsupportedMediaType, cardName("supported-address-data"))
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	c, _, err := vcard.Decode(data)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, cardName("valid-address-data"))
+		return
+	}
+
+	if err := h.Backend.Put(name, c, existing); err != nil {
+		if _, ok := err.(*InvalidError); ok {
+			http.Error(w, err.Error(), http.StatusForbidden)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// no ETag, the stored card is normalized and differs from the request
+	if existing == nil {
+		w.Header().Set("Location", h.href(c))
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+type propsFunc func(m *multistatus, wanted []xml.Name, all, namesOnly bool, depth int) error
+
+func (h *Handler) propfind(w http.ResponseWriter, r *http.Request, fn propsFunc) {
+	body, err := readBody(r.Body)
+	if err != nil {
+		http.Error(w, "Invalid PROPFIND body", http.StatusBadRequest)
+		return
+	}
+
+	all, namesOnly := body == nil, false
+	wanted := []xml.Name{}
+	if body != nil {
+		switch {
+		case body.child(davName("prop")) != nil:
+			wanted = body.child(davName("prop")).names()
+		case body.child(davName("propname")) != nil:
+			namesOnly = true
+		default:
+			all = true
+		}
+	}
+
+	// infinity is answered like 1, every collection here is flat
+	depth := 1
+	if r.Header.Get("Depth") == "0" {
+		depth = 0
+	}
+
+	m := &multistatus{}
+	if err := fn(m, wanted, all, namesOnly, depth); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m.write(w, "")
+}
+
+func (h *Handler) rootProps() props {
+	home := href(h.Prefix + "/")
+
+	return props{
+		davName("resourcetype"):           element(davName("collection"), "") + element(davName("principal"), ""),
+		davName("displayname"):            escape(h.Name),
+		davName("current-user-principal"): home,
+		davName("principal-URL"):          home,
+		cardName("addressbook-home-set"):  home,
+	}
+}
+
+func (h *Handler) bookProps() (props, error) {
+	token, err := h.token()
+	if err != nil {
+		return nil, err
+	}
+
+	reports := ""
+	for _, r := range []xml.Name{cardName("addressbook-query"), cardName("addressbook-multiget"), davName("sync-collection")} {
+		reports += element(davName("supported-report"), element(davName("report"), element(r, "")))
+	}
+
+	privileges := element(davName("privilege"), element(davName("read"), ""))
+	if !h.ReadOnly {
+		privileges += element(davName("privilege"), element(davName("write"), ""))
+	}
+
+	return props{
+		davName("resourcetype"):                 element(davName("collection"), "") + element(cardName("addressbook"), ""),
+		davName("displayname"):                  escape(h.Name),
+		davName("current-user-principal"):       href(h.Prefix + "/"),
+		davName("sync-token"):                   escape(token),
+		xml.Name{Space: nsCS, Local: "getctag"}: escape(token),
+		davName("supported-report-set"):         reports,
+		davName("current-user-privilege-set"):   privileges,
+		cardName("supported-address-data"):      `<card:address-data-type content-type="text/vcard" version="3.0"/>`,
+		cardName("max-resource-size"):           strconv.Itoa(maxBody),
+	}, nil
+}
+
+func (h *Handler) cardProps(c *model.Phonebook) props {
+	data := vcard.Encode(c, c.CardName())
+
+	return props{
+		davName("resourcetype"):     "",
+		davName("getetag"):          escape(etag(data)),
+		davName("getcontenttype"):   escape(vcard.ContentType),
+		davName("getcontentlength"): strconv.Itoa(len(data)),
+		davName("getlastmodified"):  c.Modified().UTC().Format(http.TimeFormat),
+		cardName("address-data"):    escape(string(data)),
+	}
+}
+
+func (h *Handler) href(c *model.Phonebook) string {
+	return h.Prefix + bookPath + url.PathEscape(c.CardName()) + cardSuffix
+}
+
+// cardName reads the card name back from an href of a report.
+func (h *Handler) cardName(href string) (string, bool) {
+	if u, err := url.Parse(href); err == nil {
+		href = u.Path
+	}
+
+	p := strings.TrimPrefix(href, h.Prefix+bookPath)
+	if p == href || !strings.HasSuffix(p, cardSuffix) {
+		return "", false
+	}
+
+	return strings.TrimSuffix(p, cardSuffix), true
+}
+
+// token is the sync token of the current state, see until.
+func (h *Handler) token() (string, error) {
+	until, err := h.until()
+	if err != nil {
+		return "", err
+	}
+
+	return formatToken(until), nil
+}
+
+func formatToken(until time.Time) string {
+	return tokenPrefix + strconv.FormatInt(until.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// until is the time a sync covers the changes up to: the latest change
+// including deletions, but at most syncLag before now. A token stays the
+// same while nothing changes, and changes after it are left to the next
+// sync.
+func (h *Handler) until() (time.Time, error) {
+	contacts, err := h.Backend.List()
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	latest := time.Time{}
+	for i := range contacts {
+		if m := contacts[i].Modified(); m.After(latest) {
+			latest = m
+		}
+	}
+
+	deleted, err := h.Backend.Deleted(latest)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, t := range deleted {
+		if t.DeletedDate.After(latest) {
+			latest = t.DeletedDate
+		}
+	}
+
+	now := time.Now
+	if h.now != nil {
+		now = h.now
+	}
+	if lagged := now().Add(-syncLag); latest.After(lagged) {
+		latest = lagged
+	}
+
+	// tokens count milliseconds, round up so the token covers latest
+	if t := latest.Truncate(time.Millisecond); t.Before(latest) {
+		latest = t.Add(time.Millisecond)
+	}
+
+	return latest, nil
+}
+
+func parseToken(token string) (time.Time, bool) {
+	if !strings.HasPrefix(token, tokenPrefix) {
+		return time.Time{}, false
+	}
+
+	ms, err := strconv.ParseInt(token[len(tokenPrefix):], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, ms*int64(time.Millisecond)), true
+}
+
+// visible hides the contacts a directory sync deactivated.
+func visible(c *model.Phonebook) bool {
+	return c.Status != model.StatusInactive
+}
+
+func etag(data []byte) string {
+	sum := sha1.Sum(data)
+
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func ifMatch(r *http.Request, c *model.Phonebook) bool {
+	v := r.Header.Get("If-Match")
+	if v == "" || c == nil {
+		return true
+	}
+
+	if v == "*" {
+		return true
+	}
+
+	current := etag(vcard.Encode(c, c.CardName()))
+	for _, e := range strings.Split(v, ",") {
+		if strings.TrimSpace(e) == current {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,250 @@
+package carddav
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type memoryBackend struct {
+	contacts   []model.Phonebook
+	tombstones []model.Tombstone
+	now        time.Time
+}
+
+// tick moves the clock of the backend so every change gets its own time.
+func (m *memoryBackend) tick() time.Time {
+	m.now = m.now.Add(time.Second)
+	return m.now
+}
+
+func (m *memoryBackend) List() ([]model.Phonebook, error) {
+	return append([]model.Phonebook{}, m.contacts...), nil
+}
+
+func (m *memoryBackend) Get(name string) (*model.Phonebook, error) {
+	for i := range m.contacts {
+		if m.contacts[i].CardName() == name {
+			c := m.contacts[i]
+			return &c, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (m *memoryBackend) Put(name string, c *model.Phonebook, existing *model.Phonebook) error {
+	if c.LastName == "" {
+		return &InvalidError{Err: errors.New("Last Name is required")}
+	}
+
+	if existing == nil {
+		c.Id, c.Uid, c.CreatedDate = bson.NewObjectId(), name, m.tick()
+		m.contacts = append(m.contacts, *c)
+		return nil
+	}
+
+	c.Id, c.Uid, c.CreatedDate, c.UpdateDate = existing.Id, existing.Uid, existing.CreatedDate, m.tick()
+	for i := range m.contacts {
+		if m.contacts[i].Id == c.Id {
+			m.contacts[i] = *c
+		}
+	}
+	return nil
+}
+
+func (m *memoryBackend) Delete(c *model.Phonebook) error {
+	for i := range m.contacts {
+		if m.contacts[i].Id == c.Id {
+			m.contacts = append(m.contacts[:i], m.contacts[i+1:]...)
+			m.tombstones = append(m.tombstones, model.Tombstone{ContactId: c.Id, CardName: c.CardName(), DeletedDate: m.tick()})
+			return nil
+		}
+	}
+	return ErrNotFound
+}
+
+func (m *memoryBackend) Deleted(since time.Time) ([]model.Tombstone, error) {
+	res := []model.Tombstone{}
+	for _, t := range m.tombstones {
+		if t.DeletedDate.After(since) {
+			res = append(res, t)
+		}
+	}
+	return res, nil
+}
+
+const card = "BEGIN:VCARD\r\nVERSION:3.0\r\nUID:tias\r\nN:Faluthi;Tias;;;\r\nFN:Tias Faluthi\r\nTEL;TYPE=CELL:0812 999\r\nEND:VCARD\r\n"
+
+func do(h http.Handler, method, path, body string, headers ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+var tokenRe = regexp.MustCompile(`<d:sync-token>([^<]+)</d:sync-token>`)
+
+func TestCardLifecycle(t *testing.T) {
+	backend := &memoryBackend{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	backend.contacts = []model.Phonebook{{Id: bson.NewObjectId(), FirstName: "Agil", LastName: "Dwi", CreatedDate: backend.tick()}}
+	h := &Handler{Prefix: "/carddav", Name: "Phonebook", Backend: backend}
+
+	rec := do(h, "PROPFIND", "/carddav/addressbook/", `<d:propfind xmlns:d="DAV:"><d:prop><d:getetag/><d:resourcetype/><d:sync-token/></d:prop></d:propfind>`, "Depth", "1")
+	if rec.Code != http.StatusMultiStatus || strings.Count(rec.Body.String(), "<d:response>") != 2 || !strings.Contains(rec.Body.String(), "<card:addressbook/>") {
+		t.Fatalf("unexpected PROPFIND %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(h, "REPORT", "/carddav/addressbook/", `<d:sync-collection xmlns:d="DAV:"><d:sync-token/><d:sync-level>1</d:sync-level><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+	token := tokenRe.FindStringSubmatch(rec.Body.String())
+	if rec.Code != http.StatusMultiStatus || token == nil || strings.Count(rec.Body.String(), "<d:response>") != 1 {
+		t.Fatalf("unexpected initial sync %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = do(h, "PUT", "/carddav/addressbook/tias.vcf", card, "Content-Type", "text/vcard", "If-None-Match", "*"); rec.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = do(h, "PUT", "/carddav/addressbook/tias.vcf", card, "If-None-Match", "*"); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 creating an existing card, got %d", rec.Code)
+	}
+
+	if rec = do(h, "PUT", "/carddav/addressbook/other.vcf", strings.Replace(card, "N:Faluthi;Tias;;;", "N:;Tias;;;", 1)); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403 for an invalid card, got %d", rec.Code)
+	}
+
+	rec = do(h, "GET", "/carddav/addressbook/tias.vcf", "")
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || etag == "" || !strings.Contains(rec.Body.String(), "TEL;TYPE=VOICE,CELL:0812 999") {
+		t.Fatalf("unexpected GET %d %s", rec.Code, rec.Body.String())
+	}
+
+	if rec = do(h, "GET", "/carddav/addressbook/tias.vcf", "", "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304, got %d", rec.Code)
+	}
+
+	if rec = do(h, "PUT", "/carddav/addressbook/tias.vcf", card, "If-Match", `"stale"`); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected 412 on a stale ETag, got %d", rec.Code)
+	}
+
+	if rec = do(h, "PUT", "/carddav/addressbook/tias.vcf", strings.Replace(card, "0812 999", "0812 111", 1), "If-Match", etag); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d %s", rec.Code, rec.Body.String())
+	}
+
+	agil := "/carddav/addressbook/" + backend.contacts[0].Id.Hex() + ".vcf"
+	if rec = do(h, "DELETE", agil, ""); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204, got %d", rec.Code)
+	}
+
+	rec = do(h, "REPORT", "/carddav/addressbook/", `<d:sync-collection xmlns:d="DAV:"><d:sync-token>`+token[1]+`</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+	body := rec.Body.String()
+	if !strings.Contains(body, "<d:href>/carddav/addressbook/tias.vcf</d:href><d:propstat>") ||
+		!strings.Contains(body, "<d:href>"+agil+"</d:href><d:status>HTTP/1.1 404 Not Found</d:status>") {
+		t.Errorf("unexpected delta sync %s", body)
+	}
+
+	if rec = do(h, "REPORT", "/carddav/addressbook/", `<d:sync-collection xmlns:d="DAV:"><d:sync-token>bogus</d:sync-token></d:sync-collection>`); rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "valid-sync-token") {
+		t.Errorf("expected 403 valid-sync-token, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestReports(t *testing.T) {
+	backend := &memoryBackend{}
+	backend.contacts = []model.Phonebook{
+		{Id: bson.NewObjectId(), FirstName: "Tias", LastName: "Faluthi", Company: "Eaciit"},
+		{Id: bson.NewObjectId(), FirstName: "Agil", LastName: "Dwi"},
+	}
+	h := &Handler{Prefix: "/carddav", Backend: backend}
+
+	tias := "/carddav/addressbook/" + backend.contacts[0].Id.Hex() + ".vcf"
+	rec := do(h, "REPORT", "/carddav/addressbook/", `<card:addressbook-multiget xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+		<d:prop><d:getetag/><card:address-data/></d:prop>
+		<d:href>`+tias+`</d:href><d:href>/carddav/addressbook/missing.vcf</d:href>
+	</card:addressbook-multiget>`)
+	body := rec.Body.String()
+	if !strings.Contains(body, "FN:Tias Faluthi") || !strings.Contains(body, "missing.vcf</d:href><d:status>HTTP/1.1 404") {
+		t.Errorf("unexpected multiget %s", body)
+	}
+
+	rec = do(h, "REPORT", "/carddav/addressbook/", `<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+		<d:prop><d:getetag/></d:prop>
+		<card:filter test="anyof">
+			<card:prop-filter name="FN"><card:text-match match-type="starts-with">AGIL</card:text-match></card:prop-filter>
+			<card:prop-filter name="ORG"><card:text-match>eaciit</card:text-match></card:prop-filter>
+		</card:filter>
+	</card:addressbook-query>`)
+	if n := strings.Count(rec.Body.String(), "<d:response>"); n != 2 {
+		t.Errorf("expected both contacts, got %d in %s", n, rec.Body.String())
+	}
+
+	rec = do(h, "REPORT", "/carddav/addressbook/", `<card:addressbook-query xmlns:d="DAV:" xmlns:card="urn:ietf:params:xml:ns:carddav">
+		<card:filter><card:prop-filter name="ORG"><card:is-not-defined/></card:prop-filter></card:filter>
+	</card:addressbook-query>`)
+	if n := strings.Count(rec.Body.String(), "<d:response>"); n != 1 || !strings.Contains(rec.Body.String(), backend.contacts[1].Id.Hex()) {
+		t.Errorf("expected only Agil, got %s", rec.Body.String())
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	h := &Handler{Prefix: "/carddav", Backend: &memoryBackend{}, ReadOnly: true}
+
+	if rec := do(h, "PUT", "/carddav/addressbook/tias.vcf", card); rec.Code != http.StatusForbidden {
+		t.Errorf("expected 403, got %d", rec.Code)
+	}
+
+	if rec := do(h, "GET", "/.well-known/carddav", ""); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/carddav/" {
+		t.Errorf("expected a redirect to the root, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestSyncLag(t *testing.T) {
+	backend := &memoryBackend{now: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	agil := model.Phonebook{Id: bson.NewObjectId(), FirstName: "Agil", LastName: "Dwi", CreatedDate: backend.tick()}
+	backend.contacts = []model.Phonebook{agil}
+
+	clock := agil.CreatedDate.Add(time.Second)
+	h := &Handler{Prefix: "/carddav", Name: "Phonebook", Backend: backend, now: func() time.Time { return clock }}
+
+	sync := func(token string) (string, string) {
+		rec := do(h, "REPORT", "/carddav/addressbook/", `<d:sync-collection xmlns:d="DAV:"><d:sync-token>`+token+`</d:sync-token><d:prop><d:getetag/></d:prop></d:sync-collection>`)
+		next := tokenRe.FindStringSubmatch(rec.Body.String())
+		if rec.Code != http.StatusMultiStatus || next == nil {
+			t.Fatalf("unexpected sync %d %s", rec.Code, rec.Body.String())
+		}
+		return next[1], rec.Body.String()
+	}
+
+	token, body := sync("")
+	if !strings.Contains(body, agil.Id.Hex()) {
+		t.Fatalf("initial sync without the contact %s", body)
+	}
+
+	// a contact stamped before the latest change but stored after the sync
+	tias := model.Phonebook{Id: bson.NewObjectId(), FirstName: "Tias", LastName: "Faluthi", CreatedDate: agil.CreatedDate.Add(-time.Millisecond * 500)}
+	backend.contacts = append(backend.contacts, tias)
+
+	clock = clock.Add(time.Second)
+	if token, body = sync(token); strings.Contains(body, "<d:response>") {
+		t.Errorf("changes within the lag reported %s", body)
+	}
+
+	clock = clock.Add(10 * time.Second)
+	if token, body = sync(token); !strings.Contains(body, tias.Id.Hex()) || !strings.Contains(body, agil.Id.Hex()) {
+		t.Errorf("late contact not reported %s", body)
+	}
+
+	if _, body = sync(token); strings.Contains(body, "<d:response>") {
+		t.Errorf("unchanged book reported %s", body)
+	}
+}
@@ -0,0 +1,244 @@
+package carddav
+
+import (
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	vcard "github.com/tmluthfiana/phonebook/modules/vcard"
+)
+
+func (h *Handler) report(w http.ResponseWriter, r *http.Request) {
+	body, err := readBody(r.Body)
+	if err != nil || body == nil {
+		http.Error(w, "Invalid REPORT body", http.StatusBadRequest)
+		return
+	}
+
+	wanted := []xml.Name{davName("getetag")}
+	if p := body.child(davName("prop")); p != nil {
+		wanted = p.names()
+	}
+
+	switch body.XMLName {
+	case cardName("addressbook-multiget"):
+		h.multiget(w, body, wanted)
+	case cardName("addressbook-query"):
+		h.query(w, body, wanted)
+	case davName("sync-collection"):
+		h.syncCollection(w, body, wanted)
+	default:
+		writeError(w, http.StatusForbidden, davName("supported-report"))
+	}
+}
+
+func (h *Handler) multiget(w http.ResponseWriter, body *node, wanted []xml.Name) {
+	m := &multistatus{}
+	for _, c := range body.Children {
+		if c.XMLName != davName("href") {
+			continue
+		}
+
+		href := strings.TrimSpace(c.Text)
+		name, ok := h.cardName(href)
+		if !ok {
+			m.statusOnly(href, http.StatusNotFound)
+			continue
+		}
+
+		contact, err := h.Backend.Get(name)
+		if err == ErrNotFound || (err == nil && !visible(contact)) {
+			m.statusOnly(href, http.StatusNotFound)
+			continue
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		m.response(href, h.cardProps(contact), wanted, false, false)
+	}
+
+	m.write(w, "")
+}
+
+func (h *Handler) query(w http.ResponseWriter, body *node, wanted []xml.Name) {
+	contacts, err := h.Backend.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	limit := 0
+	if l := body.child(cardName("limit")); l != nil {
+		if n := l.child(cardName("nresults")); n != nil {
+			limit, _ = strconv.Atoi(strings.TrimSpace(n.Text))
+		}
+	}
+
+	filter := body.child(cardName("filter"))
+	m := &multistatus{}
+	sent := 0
+	for i := range contacts {
+		c := &contacts[i]
+		if !visible(c) || (filter != nil && !matchFilter(filter, vcard.Properties(vcard.Encode(c, c.CardName())))) {
+			continue
+		}
+
+		if limit > 0 && sent == limit {
+			m.statusOnly(h.Prefix+bookPath, http.StatusInsufficientStorage)
+			break
+		}
+
+		m.response(h.href(c), h.cardProps(c), wanted, false, false)
+		sent++
+	}
+
+	m.write(w, "")
+}
+
+func (h *Handler) syncCollection(w http.ResponseWriter, body *node, wanted []xml.Name) {
+	since, initial := time.Time{}, true
+	if t := body.child(davName("sync-token")); t != nil && strings.TrimSpace(t.Text) != "" {
+		var ok bool
+		if since, ok = parseToken(strings.TrimSpace(t.Text)); !ok {
+			writeError(w, http.StatusForbidden, davName("valid-sync-token"))
+			return
+		}
+		initial = false
+	}
+
+	until, err := h.until()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	contacts, err := h.Backend.List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	m := &multistatus{}
+	names := map[string]bool{}
+	for i := range contacts {
+		c := &contacts[i]
+		names[c.CardName()] = true
+
+		// changes after until are left to the next sync, its token starts there
+		if !initial && (!c.Modified().After(since) || c.Modified().After(until)) {
+			continue
+		}
+
+		switch {
+		case visible(c):
+			m.response(h.href(c), h.cardProps(c), wanted, false, false)
+		case !initial:
+			m.statusOnly(h.href(c), http.StatusNotFound)
+		}
+	}
+
+	if !initial {
+		deleted, err := h.Backend.Deleted(since)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, t := range deleted {
+			if t.DeletedDate.After(until) {
+				continue
+			}
+			if !names[t.CardName] {
+				names[t.CardName] = true
+				m.statusOnly(h.href(&model.Phonebook{Uid: t.CardName}), http.StatusNotFound)
+			}
+		}
+	}
+
+	m.write(w, formatToken(until))
+}
+
+// matchFilter evaluates a CARDDAV:filter, only prop-filters with
+// is-not-defined and text-match are understood, param-filters always match.
+func matchFilter(filter *node, props map[string][]string) bool {
+	allOf := filter.attr("test") == "allof"
+
+	for i := range filter.Children {
+		f := &filter.Children[i]
+		if f.XMLName != cardName("prop-filter") {
+			continue
+		}
+
+		ok := matchProp(f, props[strings.ToUpper(f.attr("name"))])
+		if ok && !allOf {
+			return true
+		}
+		if !ok && allOf {
+			return false
+		}
+	}
+
+	return allOf
+}
+
+func matchProp(f *node, values []string) bool {
+	if f.child(cardName("is-not-defined")) != nil {
+		return len(values) == 0
+	}
+	if len(values) == 0 {
+		return false
+	}
+
+	allOf := f.attr("test") == "allof"
+	tested := false
+	for i := range f.Children {
+		t := &f.Children[i]
+		if t.XMLName != cardName("text-match") {
+			continue
+		}
+		tested = true
+
+		ok := false
+		for _, v := range values {
+			if matchText(t, v) {
+				ok = true
+				break
+			}
+		}
+		if t.attr("negate-condition") == "yes" {
+			ok = !ok
+		}
+
+		if ok && !allOf {
+			return true
+		}
+		if !ok && allOf {
+			return false
+		}
+	}
+
+	// a bare prop-filter only asks for the property to exist
+	return !tested || allOf
+}
+
+// matchText compares case-insensitively, the i;unicode-casemap collation.
+func matchText(t *node, v string) bool {
+	want := strings.ToLower(t.Text)
+	v = strings.ToLower(v)
+
+	switch t.attr("match-type") {
+	case "equals":
+		return v == want
+	case "starts-with":
+		return strings.HasPrefix(v, want)
+	case "ends-with":
+		return strings.HasSuffix(v, want)
+	default:
+		return strings.Contains(v, want)
+	}
+}
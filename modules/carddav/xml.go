@@ -0,0 +1,192 @@
+package carddav
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+)
+
+const (
+	nsDAV     = "DAV:"
+	nsCardDAV = "urn:ietf:params:xml:ns:carddav"
+	// nsCS holds getctag, still used by older Apple clients
+	nsCS = "http://calendarserver.org/ns/"
+)
+
+var prefixes = map[string]string{nsDAV: "d", nsCardDAV: "card", nsCS: "cs"}
+
+func davName(local string) xml.Name  { return xml.Name{Space: nsDAV, Local: local} }
+func cardName(local string) xml.Name { return xml.Name{Space: nsCardDAV, Local: local} }
+
+// node is a generic request element.
+type node struct {
+	XMLName  xml.Name
+	Attrs    []xml.Attr `xml:",any,attr"`
+	Children []node     `xml:",any"`
+	Text     string     `xml:",chardata"`
+}
+
+func (n *node) child(name xml.Name) *node {
+	for i := range n.Children {
+		if n.Children[i].XMLName == name {
+			return &n.Children[i]
+		}
+	}
+
+	return nil
+}
+
+func (n *node) attr(local string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+
+	return ""
+}
+
+// names lists the element names of the children of a prop element.
+func (n *node) names() []xml.Name {
+	names := []xml.Name{}
+	for _, c := range n.Children {
+		names = append(names, c.XMLName)
+	}
+
+	return names
+}
+
+// readBody parses the request body, nil when it is empty.
+func readBody(r io.Reader) (*node, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxBody))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil, nil
+	}
+
+	n := &node{}
+	if err := xml.Unmarshal(data, n); err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+// props are the properties of a resource as raw inner XML.
+type props map[xml.Name]string
+
+// multistatus builds a 207 response.
+type multistatus struct {
+	bytes.Buffer
+}
+
+func element(name xml.Name, inner string) string {
+	prefix, ok := prefixes[name.Space]
+	if !ok {
+		if inner == "" {
+			return fmt.Sprintf(`<x:%s xmlns:x="%s"/>`, name.Local, escape(name.Space))
+		}
+		return fmt.Sprintf(`<x:%s xmlns:x="%s">%s</x:%s>`, name.Local, escape(name.Space), inner, name.Local)
+	}
+
+	if inner == "" {
+		return "<" + prefix + ":" + name.Local + "/>"
+	}
+	return "<" + prefix + ":" + name.Local + ">" + inner + "</" + prefix + ":" + name.Local + ">"
+}
+
+func escape(s string) string {
+	b := &bytes.Buffer{}
+	xml.EscapeText(b, []byte(s))
+
+	return b.String()
+}
+
+func href(h string) string {
+	return element(davName("href"), escape(h))
+}
+
+func status(code int) string {
+	return element(davName("status"), fmt.Sprintf("HTTP/1.1 %d %s", code, http.StatusText(code)))
+}
+
+// response writes the wanted properties of a resource, every property but
+// skip when all is set, and only the names when namesOnly is set.
+func (m *multistatus) response(h string, p props, wanted []xml.Name, all, namesOnly bool, skip ...xml.Name) {
+	if all || namesOnly {
+		wanted = []xml.Name{}
+		for name := range p {
+			if !contains(skip, name) || namesOnly {
+				wanted = append(wanted, name)
+			}
+		}
+		sort.Slice(wanted, func(i, j int) bool {
+			return wanted[i].Space+wanted[i].Local < wanted[j].Space+wanted[j].Local
+		})
+	}
+
+	found, missing := "", ""
+	for _, name := range wanted {
+		v, ok := p[name]
+		switch {
+		case !ok:
+			missing += element(name, "")
+		case namesOnly:
+			found += element(name, "")
+		default:
+			found += element(name, v)
+		}
+	}
+
+	m.WriteString("<d:response>" + href(h))
+	if found != "" {
+		m.WriteString("<d:propstat>" + element(davName("prop"), found) + status(http.StatusOK) + "</d:propstat>")
+	}
+	if missing != "" {
+		m.WriteString("<d:propstat>" + element(davName("prop"), missing) + status(http.StatusNotFound) + "</d:propstat>")
+	}
+	m.WriteString("</d:response>")
+}
+
+// status writes a response without properties, e.g. 404 for deleted cards.
+func (m *multistatus) statusOnly(h string, code int) {
+	m.WriteString("<d:response>" + href(h) + status(code) + "</d:response>")
+}
+
+func (m *multistatus) write(w http.ResponseWriter, syncToken string) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(http.StatusMultiStatus)
+
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, `<d:multistatus xmlns:d="DAV:" xmlns:card="`+nsCardDAV+`" xmlns:cs="`+nsCS+`">`)
+	w.Write(m.Bytes())
+	if syncToken != "" {
+		io.WriteString(w, element(davName("sync-token"), escape(syncToken)))
+	}
+	io.WriteString(w, "</d:multistatus>")
+}
+
+// writeError answers a failed precondition, e.g. DAV:valid-sync-token.
+func writeError(w http.ResponseWriter, code int, condition xml.Name) {
+	w.Header().Set("Content-Type", "application/xml; charset=utf-8")
+	w.WriteHeader(code)
+
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, `<d:error xmlns:d="DAV:" xmlns:card="`+nsCardDAV+`">`+element(condition, "")+"</d:error>")
+}
+
+func contains(names []xml.Name, name xml.Name) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,103 @@
+// Package events fans the changes of contacts out to the subscribers in the
+// process, such as the watch streams of the gRPC service.
+package events
+
+import (
+	"sync"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+)
+
+const (
+	Created = "contact.created"
+	Updated = "contact.updated"
+	Deleted = "contact.deleted"
+)
+
+type Event struct {
+	// Id identifies the event in the event log, empty when it was not logged
+	Id string
+	// Type is Created, Updated or Deleted
+	Type     string
+	TenantId string
+	// Contact is the contact as saved, or as it was before the deletion
+	Contact model.Phonebook
+	Time    time.Time
+}
+
+// Broker hands every published event to all subscribers. Publish never
+// blocks, a subscriber whose buffer is full is dropped.
+type Broker struct {
+	lock sync.Mutex
+	subs map[*Subscription]bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[*Subscription]bool{}}
+}
+
+type Subscription struct {
+	// C receives the events, it is closed by Close or when the subscriber
+	// fell behind
+	C <-chan Event
+
+	c       chan Event
+	broker  *Broker
+	dropped bool
+}
+
+// Subscribe receives the events published from now on, buffer events may
+// wait for the subscriber before it is dropped.
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	c := make(chan Event, buffer)
+	s := &Subscription{C: c, c: c, broker: b}
+
+	b.lock.Lock()
+	b.subs[s] = true
+	b.lock.Unlock()
+
+	return s
+}
+
+func (b *Broker) Publish(e Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			s.dropped = true
+			delete(b.subs, s)
+			close(s.c)
+		}
+	}
+}
+
+// Subscribers is the number of open subscriptions.
+func (b *Broker) Subscribers() int {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	return len(b.subs)
+}
+
+func (s *Subscription) Close() {
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	if s.broker.subs[s] {
+		delete(s.broker.subs, s)
+		close(s.c)
+	}
+}
+
+// Dropped reports whether the broker closed C because the buffer was full,
+// the subscriber missed events then.
+func (s *Subscription) Dropped() bool {
+	s.broker.lock.Lock()
+	defer s.broker.lock.Unlock()
+
+	return s.dropped
+}
@@ -0,0 +1,41 @@
+package events
+
+import (
+	"testing"
+
+	model "github.com/tmluthfiana/phonebook/model"
+)
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	fast, slow := b.Subscribe(10), b.Subscribe(1)
+
+	for _, name := range []string{"Ann", "Bob"} {
+		b.Publish(Event{Type: Created, Contact: model.Phonebook{FirstName: name}})
+	}
+
+	if e := <-fast.C; e.Contact.FirstName != "Ann" {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := <-fast.C; e.Contact.FirstName != "Bob" {
+		t.Errorf("second event = %+v", e)
+	}
+
+	// slow got Ann, Bob did not fit anymore
+	if e := <-slow.C; e.Contact.FirstName != "Ann" {
+		t.Errorf("slow event = %+v", e)
+	}
+	if _, ok := <-slow.C; ok || !slow.Dropped() {
+		t.Error("slow subscriber not dropped")
+	}
+	if fast.Dropped() || b.Subscribers() != 1 {
+		t.Errorf("%d subscribers", b.Subscribers())
+	}
+
+	fast.Close()
+	fast.Close()
+	if _, ok := <-fast.C; ok || b.Subscribers() != 0 {
+		t.Error("closed subscription still open")
+	}
+	b.Publish(Event{Type: Deleted})
+}
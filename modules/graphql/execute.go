@@ -0,0 +1,747 @@
+package graphql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Request is the JSON body of a GraphQL POST
+type Request struct {
+	Query         string                 `json:"query"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	OperationName string                 `json:"operationName,omitempty"`
+}
+
+// Response carries the data in the order the fields were selected. Data
+// is missing when the request failed before execution.
+type Response struct {
+	Data   interface{} `json:"data,omitempty"`
+	Errors []*Error    `json:"errors,omitempty"`
+}
+
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Path) == 0 {
+		return e.Message
+	}
+
+	path := []string{}
+	for _, p := range e.Path {
+		path = append(path, fmt.Sprint(p))
+	}
+
+	return strings.Join(path, ".") + ": " + e.Message
+}
+
+// Execute runs the operation of the request. Errors of resolvers null
+// their field and are listed with its path, the other fields still
+// resolve. Mutation fields run one after the other in document order.
+func Execute(ctx context.Context, s *Schema, req Request) *Response {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return failed(err)
+	}
+
+	op, err := operation(doc, req.OperationName)
+	if err != nil {
+		return failed(err)
+	}
+
+	root := s.Query
+	if op.Type == "mutation" {
+		root = s.Mutation
+	}
+	if root == nil {
+		return failed(fmt.Errorf("the schema has no %s type", op.Type))
+	}
+
+	e := &executor{ctx: ctx, schema: s, doc: doc}
+	if err := e.variables(op, req.Variables); err != nil {
+		return failed(err)
+	}
+	if err := e.validate(root, op.Selections, 1, map[string]bool{}); err != nil {
+		return failed(err)
+	}
+	if s.MaxComplexity > 0 && e.complexity(root, op.Selections, map[string]int{}) > s.MaxComplexity {
+		return failed(fmt.Errorf("the query would resolve more than %d fields", s.MaxComplexity))
+	}
+
+	data, ok := e.object(root, nil, op.Selections, nil)
+	if !ok {
+		return &Response{Data: json.RawMessage("null"), Errors: e.errors}
+	}
+
+	return &Response{Data: data, Errors: e.errors}
+}
+
+func failed(err error) *Response {
+	return &Response{Errors: []*Error{{Message: err.Error()}}}
+}
+
+func operation(doc *Document, name string) (*Operation, error) {
+	if name == "" {
+		if len(doc.Operations) != 1 {
+			return nil, errors.New("the document must hold one operation or operationName must be given")
+		}
+		return doc.Operations[0], nil
+	}
+
+	for _, op := range doc.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown operation %s", name)
+}
+
+type executor struct {
+	ctx    context.Context
+	schema *Schema
+	doc    *Document
+	vars   map[string]interface{}
+	// declared are the variables of the operation, given or not
+	declared map[string]bool
+	errors   []*Error
+}
+
+func (e *executor) fail(err error, path []interface{}) {
+	if ge, ok := err.(*Error); ok {
+		err = errors.New(ge.Message)
+	}
+	e.errors = append(e.errors, &Error{Message: err.Error(), Path: append([]interface{}{}, path...)})
+}
+
+// variables coerces the given variables to the types the operation
+// declares and applies their defaults
+func (e *executor) variables(op *Operation, given map[string]interface{}) error {
+	e.vars, e.declared = map[string]interface{}{}, map[string]bool{}
+	for _, def := range op.Vars {
+		if e.declared[def.Name] {
+			return fmt.Errorf("variable $%s declared twice", def.Name)
+		}
+		e.declared[def.Name] = true
+
+		t, err := e.schema.typeOf(def.Type)
+		if err != nil {
+			return fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+
+		v, ok := given[def.Name]
+		if !ok {
+			if def.Default == nil {
+				if _, nonNull := t.(*NonNull); nonNull {
+					return fmt.Errorf("variable $%s of type %s is required", def.Name, t)
+				}
+				continue
+			}
+			v = def.Default
+		}
+
+		if e.vars[def.Name], err = coerce(t, v); err != nil {
+			return fmt.Errorf("variable $%s: %v", def.Name, err)
+		}
+	}
+
+	return nil
+}
+
+// substitute replaces the variables of a literal by their values, ok is
+// false for a lone variable without value
+func (e *executor) substitute(v interface{}) (interface{}, bool, error) {
+	switch v := v.(type) {
+	case Variable:
+		if !e.declared[string(v)] {
+			return nil, false, fmt.Errorf("variable $%s is not declared", v)
+		}
+		value, ok := e.vars[string(v)]
+		return value, ok, nil
+	case []interface{}:
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			var err error
+			if list[i], _, err = e.substitute(item); err != nil {
+				return nil, false, err
+			}
+		}
+		return list, true, nil
+	case map[string]interface{}:
+		obj := map[string]interface{}{}
+		for k, item := range v {
+			value, ok, err := e.substitute(item)
+			if err != nil {
+				return nil, false, err
+			}
+			if ok {
+				obj[k] = value
+			}
+		}
+		return obj, true, nil
+	}
+
+	return v, true, nil
+}
+
+// arguments coerces the literals of a field or directive to the declared
+// arguments
+func (e *executor) arguments(defs Args, literals map[string]interface{}) (map[string]interface{}, error) {
+	for name := range literals {
+		if defs[name] == nil {
+			return nil, fmt.Errorf("unknown argument %s", name)
+		}
+	}
+
+	args := map[string]interface{}{}
+	for name, def := range defs {
+		v, ok, err := e.substitute(literals[name])
+		if err != nil {
+			return nil, err
+		}
+		if _, given := literals[name]; !given || !ok {
+			if def.Default == nil {
+				if _, nonNull := def.Type.(*NonNull); nonNull {
+					return nil, fmt.Errorf("argument %s of type %s is required", name, def.Type)
+				}
+				continue
+			}
+			v = def.Default
+		}
+
+		if args[name], err = coerce(def.Type, v); err != nil {
+			return nil, fmt.Errorf("argument %s: %v", name, err)
+		}
+	}
+
+	return args, nil
+}
+
+// coerce checks an input value against t and converts it to the Go
+// value of the type
+func coerce(t Type, v interface{}) (interface{}, error) {
+	if nn, ok := t.(*NonNull); ok {
+		if v == nil {
+			return nil, fmt.Errorf("expected %s, found null", t)
+		}
+		return coerce(nn.Of, v)
+	}
+	if v == nil {
+		return nil, nil
+	}
+
+	switch t := t.(type) {
+	case *List:
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if list[i], err = coerce(t.Of, item); err != nil {
+				return nil, fmt.Errorf("item %d: %v", i, err)
+			}
+		}
+		return list, nil
+	case *Scalar:
+		if _, enum := v.(EnumValue); enum {
+			return nil, fmt.Errorf("%s cannot represent %s", t.Name, describe(v))
+		}
+		return t.Parse(v)
+	case *Enum:
+		s, ok := v.(EnumValue)
+		if str, isString := v.(string); isString {
+			s, ok = EnumValue(str), true
+		}
+		if ok {
+			for _, value := range t.Values {
+				if value == string(s) {
+					return value, nil
+				}
+			}
+		}
+		return nil, fmt.Errorf("%s has no value %s", t.Name, describe(v))
+	case *InputObject:
+		fields, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s cannot represent %s", t.Name, describe(v))
+		}
+		for name := range fields {
+			if t.Fields[name] == nil {
+				return nil, fmt.Errorf("%s has no field %s", t.Name, name)
+			}
+		}
+		obj := map[string]interface{}{}
+		for name, def := range t.Fields {
+			value, given := fields[name]
+			if !given {
+				if def.Default == nil {
+					if _, nonNull := def.Type.(*NonNull); nonNull {
+						return nil, fmt.Errorf("field %s of %s is required", name, t.Name)
+					}
+					continue
+				}
+				value = def.Default
+			}
+			var err error
+			if obj[name], err = coerce(def.Type, value); err != nil {
+				return nil, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+		return obj, nil
+	}
+
+	return nil, fmt.Errorf("%s is no input type", t)
+}
+
+// validate checks the selections against the schema before anything
+// resolves, so a mistake doesn't leave a mutation half done
+func (e *executor) validate(t *Object, sels []Selection, depth int, spreading map[string]bool) error {
+	if e.schema.MaxDepth > 0 && depth > e.schema.MaxDepth {
+		return fmt.Errorf("the query nests deeper than %d levels", e.schema.MaxDepth)
+	}
+
+	keys := map[string]string{}
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *FieldSelection:
+			if _, err := e.included(sel.Directives); err != nil {
+				return err
+			}
+
+			key := responseKey(sel)
+			if name, ok := keys[key]; ok && name != sel.Name {
+				return fmt.Errorf("%s selects both %s and %s", key, name, sel.Name)
+			}
+			keys[key] = sel.Name
+
+			if sel.Name == "__typename" {
+				if len(sel.Args) > 0 || sel.Selections != nil {
+					return errors.New("__typename takes no arguments or selections")
+				}
+				continue
+			}
+
+			f := t.Fields[sel.Name]
+			if f == nil {
+				return fmt.Errorf("%s has no field %s", t.Name, sel.Name)
+			}
+			if _, err := e.arguments(f.Args, sel.Args); err != nil {
+				return fmt.Errorf("%s.%s: %v", t.Name, sel.Name, err)
+			}
+
+			obj, isObject := named(f.Type).(*Object)
+			switch {
+			case isObject && sel.Selections == nil:
+				return fmt.Errorf("%s.%s of type %s needs a selection of its fields", t.Name, sel.Name, f.Type)
+			case !isObject && sel.Selections != nil:
+				return fmt.Errorf("%s.%s of type %s has no fields to select", t.Name, sel.Name, f.Type)
+			case isObject:
+				if err := e.validate(obj, sel.Selections, depth+1, spreading); err != nil {
+					return err
+				}
+			}
+		case *FragmentSpread:
+			if _, err := e.included(sel.Directives); err != nil {
+				return err
+			}
+			f := e.doc.Fragments[sel.Name]
+			if f == nil {
+				return fmt.Errorf("unknown fragment %s", sel.Name)
+			}
+			if spreading[sel.Name] {
+				return fmt.Errorf("fragment %s spreads itself", sel.Name)
+			}
+			if f.On != t.Name {
+				return fmt.Errorf("fragment %s on %s cannot spread in %s", f.Name, f.On, t.Name)
+			}
+			spreading[sel.Name] = true
+			err := e.validate(t, f.Selections, depth, spreading)
+			delete(spreading, sel.Name)
+			if err != nil {
+				return err
+			}
+		case *InlineFragment:
+			if _, err := e.included(sel.Directives); err != nil {
+				return err
+			}
+			if sel.On != "" && sel.On != t.Name {
+				return fmt.Errorf("fragment on %s cannot spread in %s", sel.On, t.Name)
+			}
+			if err := e.validate(t, sel.Selections, depth, spreading); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// complexity counts the fields the selections of a valid query resolve,
+// multiplied by the Size of the fields above them. Counts stop growing past
+// MaxComplexity and fragments are counted once, so aliases and spreads cannot
+// make the count itself expensive.
+func (e *executor) complexity(t *Object, sels []Selection, fragments map[string]int) int {
+	limit := e.schema.MaxComplexity + 1
+
+	n := 0
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *FieldSelection:
+			if ok, _ := e.included(sel.Directives); !ok {
+				continue
+			}
+			n++
+
+			// __typename has no field
+			f := t.Fields[sel.Name]
+			if f == nil {
+				continue
+			}
+			obj, isObject := named(f.Type).(*Object)
+			if !isObject {
+				continue
+			}
+
+			size := 1
+			if f.Size != nil {
+				args, _ := e.arguments(f.Args, sel.Args)
+				size = max(0, min(f.Size(args), limit))
+			}
+			n += size * e.complexity(obj, sel.Selections, fragments)
+		case *FragmentSpread:
+			if ok, _ := e.included(sel.Directives); !ok {
+				continue
+			}
+			if _, ok := fragments[sel.Name]; !ok {
+				fragments[sel.Name] = e.complexity(t, e.doc.Fragments[sel.Name].Selections, fragments)
+			}
+			n += fragments[sel.Name]
+		case *InlineFragment:
+			if ok, _ := e.included(sel.Directives); ok {
+				n += e.complexity(t, sel.Selections, fragments)
+			}
+		}
+		n = min(n, limit)
+	}
+
+	return n
+}
+
+var conditionArgs = Args{"if": {Type: NonNullOf(Boolean)}}
+
+// included evaluates @skip and @include
+func (e *executor) included(directives []*Directive) (bool, error) {
+	include := true
+	for _, d := range directives {
+		if d.Name != "skip" && d.Name != "include" {
+			return false, fmt.Errorf("unknown directive @%s", d.Name)
+		}
+		args, err := e.arguments(conditionArgs, d.Args)
+		if err != nil {
+			return false, fmt.Errorf("@%s: %v", d.Name, err)
+		}
+		if args["if"].(bool) == (d.Name == "skip") {
+			include = false
+		}
+	}
+
+	return include, nil
+}
+
+func responseKey(f *FieldSelection) string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+
+	return f.Name
+}
+
+// collect flattens fragments into the fields to resolve, merging the
+// selections of fields with the same response key
+func (e *executor) collect(sels []Selection, keys *[]string, fields map[string][]*FieldSelection) {
+	for _, sel := range sels {
+		switch sel := sel.(type) {
+		case *FieldSelection:
+			if ok, _ := e.included(sel.Directives); !ok {
+				continue
+			}
+			key := responseKey(sel)
+			if _, seen := fields[key]; !seen {
+				*keys = append(*keys, key)
+			}
+			fields[key] = append(fields[key], sel)
+		case *FragmentSpread:
+			if ok, _ := e.included(sel.Directives); ok {
+				e.collect(e.doc.Fragments[sel.Name].Selections, keys, fields)
+			}
+		case *InlineFragment:
+			if ok, _ := e.included(sel.Directives); ok {
+				e.collect(sel.Selections, keys, fields)
+			}
+		}
+	}
+}
+
+// object resolves the selected fields of source. It is false when a non
+// null field is null, the object itself must then be null.
+func (e *executor) object(t *Object, source interface{}, sels []Selection, path []interface{}) (*orderedMap, bool) {
+	keys, fields := []string{}, map[string][]*FieldSelection{}
+	e.collect(sels, &keys, fields)
+
+	result := &orderedMap{values: map[string]interface{}{}}
+	for _, key := range keys {
+		if err := e.ctx.Err(); err != nil {
+			e.fail(err, path)
+			return nil, false
+		}
+
+		f := fields[key][0]
+		if f.Name == "__typename" {
+			result.set(key, t.Name)
+			continue
+		}
+
+		def := t.Fields[f.Name]
+		fieldPath := append(path[:len(path):len(path)], key)
+		value, ok := e.field(def, source, f, fields[key], fieldPath)
+		if !ok {
+			if _, nonNull := def.Type.(*NonNull); nonNull {
+				return nil, false
+			}
+			value = nil
+		}
+		result.set(key, value)
+	}
+
+	return result, true
+}
+
+func (e *executor) field(def *Field, source interface{}, f *FieldSelection, merged []*FieldSelection, path []interface{}) (interface{}, bool) {
+	args, err := e.arguments(def.Args, f.Args)
+	if err != nil {
+		e.fail(err, path)
+		return nil, false
+	}
+
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = defaultResolver(f.Name)
+	}
+
+	value, err := e.resolve(resolve, ResolveParams{Context: e.ctx, Source: source, Args: args})
+	if err != nil {
+		e.fail(err, path)
+		return nil, false
+	}
+
+	sels := []Selection{}
+	for _, m := range merged {
+		sels = append(sels, m.Selections...)
+	}
+
+	return e.complete(def.Type, value, sels, path)
+}
+
+// resolve turns a panic of a resolver into an error of its field
+func (e *executor) resolve(fn ResolveFunc, p ResolveParams) (value interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			value, err = nil, fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	return fn(p)
+}
+
+// complete converts a resolved value to the type of its field. It is
+// false when the value is null, the error is reported already.
+func (e *executor) complete(t Type, value interface{}, sels []Selection, path []interface{}) (interface{}, bool) {
+	if nn, ok := t.(*NonNull); ok {
+		v, ok := e.complete(nn.Of, value, sels, path)
+		if ok && v == nil {
+			e.fail(errors.New("null for a non null field"), path)
+			ok = false
+		}
+		return v, ok
+	}
+
+	if isNil(value) {
+		return nil, true
+	}
+
+	switch t := t.(type) {
+	case *List:
+		rv := reflect.ValueOf(value)
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fail(fmt.Errorf("expected a list, found %T", value), path)
+			return nil, false
+		}
+		list := make([]interface{}, rv.Len())
+		for i := range list {
+			v, ok := e.complete(t.Of, rv.Index(i).Interface(), sels, append(path[:len(path):len(path)], i))
+			if !ok {
+				if _, nonNull := t.Of.(*NonNull); nonNull {
+					return nil, false
+				}
+			}
+			list[i] = v
+		}
+		return list, true
+	case *Scalar:
+		v, err := t.Serialize(value)
+		if err != nil {
+			e.fail(err, path)
+			return nil, false
+		}
+		return v, true
+	case *Enum:
+		v, err := serializeString(value)
+		if err == nil {
+			for _, name := range t.Values {
+				if name == v {
+					return v, true
+				}
+			}
+			err = fmt.Errorf("%s has no value %v", t.Name, v)
+		}
+		e.fail(err, path)
+		return nil, false
+	case *Object:
+		obj, ok := e.object(t, value, sels, path)
+		if !ok {
+			return nil, false
+		}
+		return obj, true
+	}
+
+	e.fail(fmt.Errorf("%s is no output type", t), path)
+	return nil, false
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return rv.IsNil()
+	}
+
+	return false
+}
+
+// defaultResolver takes the map key of name, or the struct field whose
+// name or JSON name is name ignoring case
+func defaultResolver(name string) ResolveFunc {
+	return func(p ResolveParams) (interface{}, error) {
+		if m, ok := p.Source.(map[string]interface{}); ok {
+			return m[name], nil
+		}
+
+		v := reflect.ValueOf(p.Source)
+		for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+			if v.IsNil() {
+				return nil, nil
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return nil, fmt.Errorf("no field %s in %T", name, p.Source)
+		}
+
+		if f, ok := structField(v, name); ok {
+			return f.Interface(), nil
+		}
+
+		return nil, fmt.Errorf("no field %s in %T", name, p.Source)
+	}
+}
+
+func structField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" && !sf.Anonymous {
+			continue
+		}
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if strings.EqualFold(sf.Name, name) || (tag != "" && tag != "-" && strings.EqualFold(tag, name)) {
+			return v.Field(i), true
+		}
+	}
+
+	// fields of embedded structs come after the own fields
+	for i := 0; i < t.NumField(); i++ {
+		if sf := t.Field(i); sf.Anonymous {
+			f := v.Field(i)
+			if f.Kind() == reflect.Ptr {
+				if f.IsNil() {
+					continue
+				}
+				f = f.Elem()
+			}
+			if f.Kind() == reflect.Struct {
+				if found, ok := structField(f, name); ok {
+					return found, true
+				}
+			}
+		}
+	}
+
+	return reflect.Value{}, false
+}
+
+// orderedMap is an object of the response, in selection order
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func (m *orderedMap) set(key string, v interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = v
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	b := &bytes.Buffer{}
+	b.WriteByte('{')
+	for i, k := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		b.Write(key)
+		b.WriteByte(':')
+		value, err := json.Marshal(m.values[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(value)
+	}
+	b.WriteByte('}')
+
+	return b.Bytes(), nil
+}
+
+// Decode converts an argument, such as an input object, into out by its
+// JSON form
+func Decode(arg interface{}, out interface{}) error {
+	b, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(b, out)
+}
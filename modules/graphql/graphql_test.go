@@ -0,0 +1,215 @@
+package graphql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type person struct {
+	Name    string `json:"full_name"`
+	Age     int
+	Friends []*person
+	Mood    string
+}
+
+func testSchema(t *testing.T) (*Schema, *[]string) {
+	mood := &Enum{Name: "Mood", Values: []string{"HAPPY", "GRUMPY"}}
+	personType := &Object{Name: "Person", Fields: Fields{
+		"full_name": {Type: NonNullOf(String)},
+		"age":       {Type: Int},
+		"mood":      {Type: mood},
+		"broken": {Type: NonNullOf(String), Resolve: func(p ResolveParams) (interface{}, error) {
+			return nil, errors.New("broken")
+		}},
+	}}
+	personType.Fields["friends"] = &Field{Type: NonNullOf(ListOf(NonNullOf(personType))),
+		Args: Args{"first": {Type: Int, Default: 10}},
+		Resolve: func(p ResolveParams) (interface{}, error) {
+			friends := p.Source.(*person).Friends
+			if n := p.Args["first"].(int); n < len(friends) {
+				friends = friends[:n]
+			}
+			return friends, nil
+		},
+		Size: func(args map[string]interface{}) int { return args["first"].(int) }}
+
+	bob := &person{Name: "Bob", Age: 40, Mood: "GRUMPY"}
+	ann := &person{Name: "Ann", Age: 30, Mood: "HAPPY", Friends: []*person{bob, {Name: "Cid"}}}
+	people := map[string]*person{"ann": ann, "bob": bob}
+
+	filter := &InputObject{Name: "Filter", Fields: Args{
+		"mood":   {Type: mood},
+		"minAge": {Type: Int, Default: 0},
+	}}
+	query := &Object{Name: "Query", Fields: Fields{
+		"person": {Type: personType, Args: Args{"id": {Type: NonNullOf(ID)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				return people[p.Args["id"].(string)], nil
+			}},
+		"people": {Type: ListOf(personType), Args: Args{"filter": {Type: filter}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				f := struct {
+					Mood   string
+					MinAge int
+				}{}
+				if err := Decode(p.Args["filter"], &f); err != nil {
+					return nil, err
+				}
+				list := []*person{}
+				for _, id := range []string{"ann", "bob"} {
+					if pp := people[id]; (f.Mood == "" || pp.Mood == f.Mood) && pp.Age >= f.MinAge {
+						list = append(list, pp)
+					}
+				}
+				return list, nil
+			}},
+	}}
+
+	calls := &[]string{}
+	mutation := &Object{Name: "Mutation", Fields: Fields{
+		"rename": {Type: NonNullOf(personType), Args: Args{"id": {Type: NonNullOf(ID)}, "name": {Type: NonNullOf(String)}},
+			Resolve: func(p ResolveParams) (interface{}, error) {
+				*calls = append(*calls, p.Args["name"].(string))
+				pp := people[p.Args["id"].(string)]
+				if pp == nil {
+					return nil, errors.New("not found")
+				}
+				pp.Name = p.Args["name"].(string)
+				return pp, nil
+			}},
+	}}
+
+	s, err := NewSchema(query, mutation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.MaxDepth = 5
+	s.MaxComplexity = 1000
+
+	return s, calls
+}
+
+func run(s *Schema, query string, vars map[string]interface{}) string {
+	b, _ := json.Marshal(Execute(context.Background(), s, Request{Query: query, Variables: vars}))
+	return string(b)
+}
+
+func TestQuery(t *testing.T) {
+	s, _ := testSchema(t)
+
+	for _, c := range []struct{ query, want string }{
+		{`{ person(id: "ann") { full_name, age, mood } }`,
+			`{"data":{"person":{"full_name":"Ann","age":30,"mood":"HAPPY"}}}`},
+		{`query Q($id: ID!) { p: person(id: $id) { __typename ...F friends(first: 1) { full_name } } } fragment F on Person { age }`,
+			`{"data":{"p":{"__typename":"Person","age":30,"friends":[{"full_name":"Bob"}]}}}`},
+		{`{ person(id: "nobody") { age } }`,
+			`{"data":{"person":null}}`},
+		{`query($f: Filter) { people(filter: $f) { full_name } }`,
+			`{"data":{"people":[{"full_name":"Bob"}]}}`},
+		{`{ people(filter: {minAge: 35}) { full_name mood @skip(if: true) ... on Person @include(if: false) { age } } }`,
+			`{"data":{"people":[{"full_name":"Bob"}]}}`},
+		{`{ person(id: "ann") { age broken } }`,
+			`{"data":{"person":null},"errors":[{"message":"broken","path":["person","broken"]}]}`},
+		{`{ person(id: "ann") { friends { full_name broken } } }`,
+			`{"data":{"person":null},"errors":[{"message":"broken","path":["person","friends",0,"broken"]}]}`},
+	} {
+		if got := run(s, c.query, map[string]interface{}{"id": "ann", "f": map[string]interface{}{"mood": "GRUMPY"}}); got != c.want {
+			t.Errorf("%s\n got %s\nwant %s", c.query, got, c.want)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	s, calls := testSchema(t)
+
+	for query, want := range map[string]string{
+		`{ person(id: "ann") { nope } }`:                                                "Person has no field nope",
+		`{ person(id: "ann") }`:                                                         "needs a selection",
+		`{ person(id: "ann") { age { x } } }`:                                           "has no fields to select",
+		`{ person { age } }`:                                                            "argument id of type ID! is required",
+		`{ person(id: 1.5) { age } }`:                                                   "ID cannot represent 1.5",
+		`{ people(filter: {mood: SAD}) { age } }`:                                       "Mood has no value SAD",
+		`{ people(filter: {age: 1}) { age } }`:                                          "Filter has no field age",
+		`{ person(id: $id) { age } }`:                                                   "variable $id is not declared",
+		`query($id: ID!) { person(id: $id) { age } }`:                                   "variable $id of type ID! is required",
+		`{ person(id: "ann") { ...F } } fragment F on Person { ...F }`:                  "fragment F spreads itself",
+		`{ person(id: "ann") { friends { friends { friends { friends { age } } } } } }`: "deeper than 5",
+		`{ person(id: "ann") { friends(first: 40) { friends(first: 40) { age } } } }`:   "more than 1000 fields",
+		`{ a: person(id: "ann") { ...F } b: person(id: "bob") { ...F } } fragment F on Person { friends(first: 30) { friends(first: 30) { age } } }`: "more than 1000 fields",
+		`{ person(id: "ann") { a: age a: mood } }`:                                                      "a selects both age and mood",
+		`mutation { a: rename(id: "bob", name: "Rob") { age } b: rename(id: "bob", nam: "X") { age } }`: "unknown argument nam",
+		`{ person(id: "ann") { age } `:                                                                  "syntax error on line 1",
+		`query A { __typename } query B { __typename }`:                                                 "operationName must be given",
+	} {
+		if got := run(s, query, nil); !strings.Contains(got, want) || strings.Contains(got, `"data"`) {
+			t.Errorf("%s\n got %s\nwant %s", query, got, want)
+		}
+	}
+
+	if len(*calls) != 0 {
+		t.Errorf("invalid mutation ran %v", *calls)
+	}
+}
+
+func TestMutation(t *testing.T) {
+	s, calls := testSchema(t)
+
+	got := run(s, `mutation { a: rename(id: "bob", name: "Rob") { full_name } b: rename(id: "nobody", name: "X") { full_name } c: rename(id: "ann", name: "Annie") { full_name } }`, nil)
+	if want := `{"data":null,"errors":[{"message":"not found","path":["b"]}]}`; got != want {
+		t.Errorf("got %s\nwant %s", got, want)
+	}
+	// the null of b nulls the data, c doesn't run anymore
+	if strings.Join(*calls, ",") != "Rob,X" {
+		t.Errorf("calls = %v", *calls)
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`
+		# comment
+		query Q($a: [Int!]! = [1, 2], $b: String = "x\u00e9\n") @dir {
+			f(s: """
+			  block
+			    text
+			""", o: {k: [-1.5e2, true, null, ENUM]})
+		}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	op := doc.Operations[0]
+	if op.Name != "Q" || op.Vars[0].Type.String() != "[Int!]!" || op.Vars[1].Default != "xé\n" {
+		t.Errorf("operation = %+v", op)
+	}
+	f := op.Selections[0].(*FieldSelection)
+	if f.Args["s"] != "block\n  text" || f.Line != 4 {
+		t.Errorf("field = %+v", f)
+	}
+	if list := f.Args["o"].(map[string]interface{})["k"].([]interface{}); list[0] != -150.0 || list[1] != true || list[2] != nil || list[3] != EnumValue("ENUM") {
+		t.Errorf("list = %#v", list)
+	}
+
+	for _, src := range []string{`{ f(a: "x) }`, `{ }`, `fragment on on T { f }`, `{ f(a: 1, a: 2) }`, `{ f(a: 09x) }`, `{ f } %`} {
+		if _, err := Parse(src); err == nil {
+			t.Errorf("%s parsed", src)
+		}
+	}
+}
+
+func TestSDL(t *testing.T) {
+	s, _ := testSchema(t)
+
+	sdl := s.SDL()
+	for _, want := range []string{"  mutation: Mutation\n", "enum Mood {\n  HAPPY\n", "  friends(first: Int = 10): [Person!]!\n", "input Filter {\n  minAge: Int = 0\n"} {
+		if !strings.Contains(sdl, want) {
+			t.Errorf("SDL misses %q:\n%s", want, sdl)
+		}
+	}
+
+	if _, err := NewSchema(&Object{Name: "Query", Fields: Fields{"a": {Type: &Object{Name: "Query"}}}}, nil); err == nil {
+		t.Error("two types with the same name accepted")
+	}
+}
@@ -0,0 +1,561 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Document is a parsed query document.
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+type Operation struct {
+	// Type is query or mutation
+	Type       string
+	Name       string
+	Vars       []*VarDef
+	Selections []Selection
+}
+
+type VarDef struct {
+	Name    string
+	Type    TypeRef
+	Default interface{}
+}
+
+// TypeRef is a type as written in a variable definition, such as [ID!]!
+type TypeRef struct {
+	Name    string
+	Elem    *TypeRef
+	NonNull bool
+}
+
+func (t TypeRef) String() string {
+	s := t.Name
+	if t.Elem != nil {
+		s = "[" + t.Elem.String() + "]"
+	}
+	if t.NonNull {
+		s += "!"
+	}
+
+	return s
+}
+
+type Fragment struct {
+	Name       string
+	On         string
+	Selections []Selection
+}
+
+// Selection is a *FieldSelection, *FragmentSpread or *InlineFragment.
+type Selection interface{}
+
+type FieldSelection struct {
+	Alias      string
+	Name       string
+	Args       map[string]interface{}
+	Directives []*Directive
+	Selections []Selection
+	Line       int
+}
+
+type FragmentSpread struct {
+	Name       string
+	Directives []*Directive
+}
+
+type InlineFragment struct {
+	On         string
+	Directives []*Directive
+	Selections []Selection
+}
+
+type Directive struct {
+	Name string
+	Args map[string]interface{}
+}
+
+// Variable and EnumValue are the values of a document that are no literals
+type (
+	Variable  string
+	EnumValue string
+)
+
+// Parse reads an executable document: operations and fragments, without
+// type system definitions.
+func Parse(src string) (doc *Document, err error) {
+	p := &parser{lexer: lexer{src: strings.TrimPrefix(src, "\ufeff"), line: 1}}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(syntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc, err = nil, e
+		}
+	}()
+
+	p.next()
+	doc = &Document{Fragments: map[string]*Fragment{}}
+	for p.tok.kind != tokEOF {
+		switch {
+		case p.tok.is(tokPunct, "{"):
+			doc.Operations = append(doc.Operations, &Operation{Type: "query", Selections: p.selections()})
+		case p.tok.is(tokName, "query"), p.tok.is(tokName, "mutation"):
+			doc.Operations = append(doc.Operations, p.operation())
+		case p.tok.is(tokName, "fragment"):
+			f := p.fragment()
+			if _, dup := doc.Fragments[f.Name]; dup {
+				p.fail("fragment %s defined twice", f.Name)
+			}
+			doc.Fragments[f.Name] = f
+		default:
+			p.fail("unexpected %s", p.tok)
+		}
+	}
+
+	return doc, nil
+}
+
+type syntaxError struct {
+	msg  string
+	line int
+}
+
+func (e syntaxError) Error() string {
+	return fmt.Sprintf("syntax error on line %d: %s", e.line, e.msg)
+}
+
+type parser struct {
+	lexer
+	tok token
+}
+
+func (p *parser) fail(format string, args ...interface{}) {
+	panic(syntaxError{fmt.Sprintf(format, args...), p.tok.line})
+}
+
+func (p *parser) next() {
+	p.tok = p.lex()
+}
+
+func (p *parser) expect(kind tokenKind, value string) token {
+	t := p.tok
+	if t.kind != kind || (value != "" && t.value != value) {
+		want := value
+		if want == "" {
+			want = kind.String()
+		}
+		p.fail("expected %s, found %s", want, t)
+	}
+	p.next()
+
+	return t
+}
+
+func (p *parser) skipIf(kind tokenKind, value string) bool {
+	if p.tok.is(kind, value) {
+		p.next()
+		return true
+	}
+
+	return false
+}
+
+func (p *parser) name() string {
+	return p.expect(tokName, "").value
+}
+
+func (p *parser) operation() *Operation {
+	op := &Operation{Type: p.name()}
+	if p.tok.kind == tokName {
+		op.Name = p.name()
+	}
+
+	if p.skipIf(tokPunct, "(") {
+		for !p.skipIf(tokPunct, ")") {
+			p.expect(tokPunct, "$")
+			v := &VarDef{Name: p.name()}
+			p.expect(tokPunct, ":")
+			v.Type = p.typeRef()
+			if p.skipIf(tokPunct, "=") {
+				v.Default = p.value(true)
+			}
+			op.Vars = append(op.Vars, v)
+		}
+	}
+	p.directives()
+	op.Selections = p.selections()
+
+	return op
+}
+
+func (p *parser) typeRef() TypeRef {
+	t := TypeRef{}
+	if p.skipIf(tokPunct, "[") {
+		elem := p.typeRef()
+		t.Elem = &elem
+		p.expect(tokPunct, "]")
+	} else {
+		t.Name = p.name()
+	}
+	t.NonNull = p.skipIf(tokPunct, "!")
+
+	return t
+}
+
+func (p *parser) fragment() *Fragment {
+	p.expect(tokName, "fragment")
+	f := &Fragment{Name: p.name()}
+	if f.Name == "on" {
+		p.fail("fragment cannot be named on")
+	}
+	p.expect(tokName, "on")
+	f.On = p.name()
+	p.directives()
+	f.Selections = p.selections()
+
+	return f
+}
+
+func (p *parser) selections() []Selection {
+	p.expect(tokPunct, "{")
+	list := []Selection{}
+	for !p.skipIf(tokPunct, "}") {
+		if p.skipIf(tokPunct, "...") {
+			if p.tok.kind == tokName && p.tok.value != "on" {
+				list = append(list, &FragmentSpread{Name: p.name(), Directives: p.directives()})
+				continue
+			}
+			f := &InlineFragment{}
+			if p.skipIf(tokName, "on") {
+				f.On = p.name()
+			}
+			f.Directives = p.directives()
+			f.Selections = p.selections()
+			list = append(list, f)
+			continue
+		}
+		list = append(list, p.field())
+	}
+	if len(list) == 0 {
+		p.fail("empty selection set")
+	}
+
+	return list
+}
+
+func (p *parser) field() *FieldSelection {
+	f := &FieldSelection{Line: p.tok.line, Name: p.name()}
+	if p.skipIf(tokPunct, ":") {
+		f.Alias, f.Name = f.Name, p.name()
+	}
+	f.Args = p.arguments()
+	f.Directives = p.directives()
+	if p.tok.is(tokPunct, "{") {
+		f.Selections = p.selections()
+	}
+
+	return f
+}
+
+func (p *parser) arguments() map[string]interface{} {
+	args := map[string]interface{}{}
+	if !p.skipIf(tokPunct, "(") {
+		return args
+	}
+
+	for !p.skipIf(tokPunct, ")") {
+		name := p.name()
+		p.expect(tokPunct, ":")
+		if _, dup := args[name]; dup {
+			p.fail("argument %s given twice", name)
+		}
+		args[name] = p.value(false)
+	}
+
+	return args
+}
+
+func (p *parser) directives() []*Directive {
+	var list []*Directive
+	for p.skipIf(tokPunct, "@") {
+		list = append(list, &Directive{Name: p.name(), Args: p.arguments()})
+	}
+
+	return list
+}
+
+// value reads a literal, variables are refused in constant defaults
+func (p *parser) value(constant bool) interface{} {
+	t := p.tok
+	switch {
+	case t.is(tokPunct, "$") && !constant:
+		p.next()
+		return Variable(p.name())
+	case t.kind == tokInt:
+		p.next()
+		n, err := strconv.Atoi(t.value)
+		if err != nil {
+			p.fail("invalid Int %s", t.value)
+		}
+		return n
+	case t.kind == tokFloat:
+		p.next()
+		f, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			p.fail("invalid Float %s", t.value)
+		}
+		return f
+	case t.kind == tokString:
+		p.next()
+		return t.value
+	case t.kind == tokName:
+		p.next()
+		switch t.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return EnumValue(t.value)
+	case t.is(tokPunct, "["):
+		p.next()
+		list := []interface{}{}
+		for !p.skipIf(tokPunct, "]") {
+			list = append(list, p.value(constant))
+		}
+		return list
+	case t.is(tokPunct, "{"):
+		p.next()
+		obj := map[string]interface{}{}
+		for !p.skipIf(tokPunct, "}") {
+			name := p.name()
+			p.expect(tokPunct, ":")
+			obj[name] = p.value(constant)
+		}
+		return obj
+	}
+
+	p.fail("unexpected %s", t)
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokPunct
+	tokName
+	tokInt
+	tokFloat
+	tokString
+)
+
+func (k tokenKind) String() string {
+	return [...]string{"end of document", "punctuation", "name", "Int", "Float", "String"}[k]
+}
+
+type token struct {
+	kind  tokenKind
+	value string
+	line  int
+}
+
+func (t token) is(kind tokenKind, value string) bool {
+	return t.kind == kind && t.value == value
+}
+
+func (t token) String() string {
+	if t.kind == tokEOF {
+		return t.kind.String()
+	}
+
+	return fmt.Sprintf("%s %q", t.kind, t.value)
+}
+
+type lexer struct {
+	src  string
+	pos  int
+	line int
+}
+
+func (l *lexer) fail(format string, args ...interface{}) {
+	panic(syntaxError{fmt.Sprintf(format, args...), l.line})
+}
+
+func (l *lexer) lex() token {
+	// white space, commas and comments are insignificant
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		if c == '#' {
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != ',' && c != '\n' && c != '\r' {
+			break
+		}
+		if c == '\n' {
+			l.line++
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.src) {
+		return token{kind: tokEOF, line: l.line}
+	}
+
+	start, c := l.pos, l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		return token{tokPunct, "...", l.line}
+	case strings.IndexByte("!$()[]{}:=@|&", c) >= 0:
+		l.pos++
+		return token{tokPunct, string(c), l.line}
+	case c == '_' || isLetter(c):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		return token{tokName, l.src[start:l.pos], l.line}
+	case c == '-' || isDigit(c):
+		return l.number()
+	case c == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	}
+
+	r, _ := utf8.DecodeRuneInString(l.src[l.pos:])
+	l.fail("unexpected character %q", r)
+	return token{}
+}
+
+func isLetter(c byte) bool { return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' }
+func isDigit(c byte) bool  { return c >= '0' && c <= '9' }
+
+func (l *lexer) number() token {
+	start, kind := l.pos, tokInt
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() {
+		n := l.pos
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+		}
+		if l.pos == n {
+			l.fail("invalid number %s", l.src[start:l.pos])
+		}
+	}
+
+	digits()
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		kind = tokFloat
+		l.pos++
+		digits()
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		kind = tokFloat
+		l.pos++
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		digits()
+	}
+
+	return token{kind, l.src[start:l.pos], l.line}
+}
+
+func (l *lexer) string() token {
+	l.pos++
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.src) || l.src[l.pos] == '\n' {
+			l.fail("unterminated string")
+		}
+
+		c := l.src[l.pos]
+		switch {
+		case c == '"':
+			l.pos++
+			return token{tokString, b.String(), l.line}
+		case c == '\\' && l.pos+1 < len(l.src):
+			e := l.src[l.pos+1]
+			l.pos += 2
+			switch e {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case 'b':
+				b.WriteByte('\b')
+			case 'f':
+				b.WriteByte('\f')
+			case '"', '\\', '/':
+				b.WriteByte(e)
+			case 'u':
+				if l.pos+4 > len(l.src) {
+					l.fail("invalid unicode escape")
+				}
+				n, err := strconv.ParseUint(l.src[l.pos:l.pos+4], 16, 32)
+				if err != nil {
+					l.fail("invalid unicode escape")
+				}
+				b.WriteRune(rune(n))
+				l.pos += 4
+			default:
+				l.fail("invalid escape \\%c", e)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+}
+
+// blockString reads """...""" keeping the text as written, only the
+// common indentation and blank first and last lines are removed
+func (l *lexer) blockString() token {
+	l.pos += 3
+	end := strings.Index(l.src[l.pos:], `"""`)
+	if end < 0 {
+		l.fail("unterminated block string")
+	}
+	raw := strings.ReplaceAll(l.src[l.pos:l.pos+end], `\"""`, `"""`)
+	l.pos += end + 3
+
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, s := range lines[1:] {
+		if t := strings.TrimLeft(s, " \t"); t != "" && (indent < 0 || len(s)-len(t) < indent) {
+			indent = len(s) - len(t)
+		}
+	}
+	for i := 1; i < len(lines) && indent > 0; i++ {
+		if len(lines[i]) >= indent {
+			lines[i] = lines[i][indent:]
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	t := token{tokString, strings.Join(lines, "\n"), l.line}
+	l.line += strings.Count(raw, "\n")
+
+	return t
+}
@@ -0,0 +1,461 @@
+package graphql
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Type is a *Scalar, *Enum, *Object, *InputObject, *List or *NonNull
+type Type interface {
+	String() string
+}
+
+type Scalar struct {
+	Name        string
+	Description string
+	// Serialize turns a resolved value into its JSON form
+	Serialize func(interface{}) (interface{}, error)
+	// Parse turns an argument or variable into the value resolvers get
+	Parse func(interface{}) (interface{}, error)
+}
+
+type Enum struct {
+	Name        string
+	Description string
+	Values      []string
+}
+
+type Object struct {
+	Name        string
+	Description string
+	Fields      Fields
+}
+
+type Fields map[string]*Field
+
+type Field struct {
+	Type        Type
+	Description string
+	Args        Args
+	// Resolve is optional, the default takes the struct field or map key
+	// of the source with the name of the field
+	Resolve ResolveFunc
+	// Size estimates how many objects the field returns for its arguments,
+	// see Schema.MaxComplexity, 1 when nil
+	Size func(args map[string]interface{}) int
+}
+
+type Args map[string]*Arg
+
+// Arg is an argument of a field or a field of an input object
+type Arg struct {
+	Type        Type
+	Description string
+	Default     interface{}
+}
+
+type InputObject struct {
+	Name        string
+	Description string
+	Fields      Args
+}
+
+type List struct {
+	Of Type
+}
+
+type NonNull struct {
+	Of Type
+}
+
+func ListOf(t Type) *List       { return &List{t} }
+func NonNullOf(t Type) *NonNull { return &NonNull{t} }
+
+func (t *Scalar) String() string      { return t.Name }
+func (t *Enum) String() string        { return t.Name }
+func (t *Object) String() string      { return t.Name }
+func (t *InputObject) String() string { return t.Name }
+func (t *List) String() string        { return "[" + t.Of.String() + "]" }
+func (t *NonNull) String() string     { return t.Of.String() + "!" }
+
+// ResolveFunc computes the value of a field. Returned structs, maps and
+// slices are completed by the type of the field.
+type ResolveFunc func(p ResolveParams) (interface{}, error)
+
+type ResolveParams struct {
+	Context context.Context
+	// Source is the value of the object the field belongs to, nil for the
+	// fields of the query and mutation types
+	Source interface{}
+	// Args hold the arguments coerced to their types, arguments without
+	// value or default are missing
+	Args map[string]interface{}
+}
+
+// The built in scalars
+var (
+	String = &Scalar{Name: "String", Serialize: serializeString, Parse: parseString}
+	ID     = &Scalar{Name: "ID", Serialize: serializeString, Parse: parseID}
+	Int    = &Scalar{Name: "Int", Serialize: serializeInt, Parse: serializeInt}
+	Float  = &Scalar{Name: "Float", Serialize: serializeFloat, Parse: serializeFloat}
+	// Boolean doesn't take other values, a string "true" is no boolean
+	Boolean = &Scalar{Name: "Boolean", Serialize: serializeBoolean, Parse: serializeBoolean}
+)
+
+var builtins = []*Scalar{String, ID, Int, Float, Boolean}
+
+func serializeString(v interface{}) (interface{}, error) {
+	switch s := v.(type) {
+	case string:
+		return s, nil
+	case interface{ Hex() string }:
+		return s.Hex(), nil
+	case fmt.Stringer:
+		return s.String(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String:
+		return rv.String(), nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return fmt.Sprint(v), nil
+	}
+
+	return nil, fmt.Errorf("String cannot represent %T", v)
+}
+
+func parseString(v interface{}) (interface{}, error) {
+	if s, ok := v.(string); ok {
+		return s, nil
+	}
+
+	return nil, fmt.Errorf("String cannot represent %s", describe(v))
+}
+
+// parseID takes strings and integers, integers become strings
+func parseID(v interface{}) (interface{}, error) {
+	switch v.(type) {
+	case string:
+		return v, nil
+	case int, float64:
+		if n, err := serializeInt(v); err == nil {
+			return strconv.Itoa(n.(int)), nil
+		}
+	}
+
+	return nil, fmt.Errorf("ID cannot represent %s", describe(v))
+}
+
+func serializeInt(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if n := rv.Int(); n >= math.MinInt32 && n <= math.MaxInt32 {
+			return int(n), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if n := rv.Uint(); n <= math.MaxInt32 {
+			return int(n), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); f == math.Trunc(f) && f >= math.MinInt32 && f <= math.MaxInt32 {
+			return int(f), nil
+		}
+	}
+
+	return nil, fmt.Errorf("Int cannot represent %s", describe(v))
+}
+
+func serializeFloat(v interface{}) (interface{}, error) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), nil
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+	}
+
+	return nil, fmt.Errorf("Float cannot represent %s", describe(v))
+}
+
+func serializeBoolean(v interface{}) (interface{}, error) {
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Bool {
+		return rv.Bool(), nil
+	}
+
+	return nil, fmt.Errorf("Boolean cannot represent %s", describe(v))
+}
+
+func describe(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(v)
+	case EnumValue:
+		return string(v)
+	case []interface{}:
+		return "a list"
+	case map[string]interface{}:
+		return "an object"
+	}
+
+	return fmt.Sprint(v)
+}
+
+// Schema is the set of types reachable from the query and mutation types
+type Schema struct {
+	Query    *Object
+	Mutation *Object
+	// MaxDepth limits how deep selections nest, 0 doesn't limit
+	MaxDepth int
+	// MaxComplexity limits how many fields a query may resolve, a field
+	// counting once for every object the Size of its parents estimates,
+	// 0 doesn't limit
+	MaxComplexity int
+
+	types map[string]Type
+}
+
+// NewSchema collects the types of query and mutation, which may be nil,
+// and fails when two different types share a name.
+func NewSchema(query, mutation *Object) (*Schema, error) {
+	s := &Schema{Query: query, Mutation: mutation, types: map[string]Type{}}
+	for _, t := range builtins {
+		s.types[t.Name] = t
+	}
+
+	for _, t := range []*Object{query, mutation} {
+		if t == nil {
+			continue
+		}
+		if err := s.add(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Schema) add(t Type) error {
+	t = named(t)
+	name := t.String()
+	if have, ok := s.types[name]; ok {
+		if have != t {
+			return fmt.Errorf("graphql: two types are named %s", name)
+		}
+		return nil
+	}
+	s.types[name] = t
+
+	var args []Args
+	switch t := t.(type) {
+	case *Object:
+		for fname, f := range t.Fields {
+			if f.Type == nil {
+				return fmt.Errorf("graphql: %s.%s has no type", name, fname)
+			}
+			if err := s.add(f.Type); err != nil {
+				return err
+			}
+			args = append(args, f.Args)
+		}
+	case *InputObject:
+		args = append(args, t.Fields)
+	}
+
+	for _, a := range args {
+		for aname, arg := range a {
+			switch named(arg.Type).(type) {
+			case *Scalar, *Enum, *InputObject:
+			default:
+				return fmt.Errorf("graphql: %s of %s is no input type", aname, name)
+			}
+			if err := s.add(arg.Type); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// named drops the list and non null wrappers
+func named(t Type) Type {
+	for {
+		switch w := t.(type) {
+		case *List:
+			t = w.Of
+		case *NonNull:
+			t = w.Of
+		default:
+			return t
+		}
+	}
+}
+
+// typeOf finds the input type a variable definition refers to
+func (s *Schema) typeOf(ref TypeRef) (Type, error) {
+	var t Type
+	if ref.Elem != nil {
+		elem, err := s.typeOf(*ref.Elem)
+		if err != nil {
+			return nil, err
+		}
+		t = ListOf(elem)
+	} else {
+		switch n := s.types[ref.Name].(type) {
+		case *Scalar, *Enum, *InputObject:
+			t = n
+		case nil:
+			return nil, fmt.Errorf("unknown type %s", ref.Name)
+		default:
+			return nil, fmt.Errorf("%s is no input type", ref.Name)
+		}
+	}
+
+	if ref.NonNull {
+		t = NonNullOf(t)
+	}
+
+	return t, nil
+}
+
+// SDL prints the schema in the schema definition language, for clients
+// generating code or checking queries.
+func (s *Schema) SDL() string {
+	names := []string{}
+	for name := range s.types {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	b := &strings.Builder{}
+	b.WriteString("schema {\n")
+	if s.Query != nil {
+		fmt.Fprintf(b, "  query: %s\n", s.Query.Name)
+	}
+	if s.Mutation != nil {
+		fmt.Fprintf(b, "  mutation: %s\n", s.Mutation.Name)
+	}
+	b.WriteString("}\n")
+
+	for _, name := range names {
+		switch t := s.types[name].(type) {
+		case *Scalar:
+			if isBuiltin(t) {
+				continue
+			}
+			b.WriteString("\n" + description(t.Description, ""))
+			fmt.Fprintf(b, "scalar %s\n", t.Name)
+		case *Enum:
+			b.WriteString("\n" + description(t.Description, ""))
+			fmt.Fprintf(b, "enum %s {\n", t.Name)
+			for _, v := range t.Values {
+				fmt.Fprintf(b, "  %s\n", v)
+			}
+			b.WriteString("}\n")
+		case *Object:
+			b.WriteString("\n" + description(t.Description, ""))
+			fmt.Fprintf(b, "type %s {\n", t.Name)
+			for _, fname := range sortedKeys(t.Fields) {
+				f := t.Fields[fname]
+				b.WriteString(description(f.Description, "  "))
+				fmt.Fprintf(b, "  %s%s: %s\n", fname, printArgs(f.Args), f.Type)
+			}
+			b.WriteString("}\n")
+		case *InputObject:
+			b.WriteString("\n" + description(t.Description, ""))
+			fmt.Fprintf(b, "input %s {\n", t.Name)
+			for _, fname := range sortedKeys(t.Fields) {
+				f := t.Fields[fname]
+				b.WriteString(description(f.Description, "  "))
+				fmt.Fprintf(b, "  %s: %s%s\n", fname, f.Type, printDefault(f.Default))
+			}
+			b.WriteString("}\n")
+		}
+	}
+
+	return b.String()
+}
+
+func isBuiltin(t *Scalar) bool {
+	for _, b := range builtins {
+		if t == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, k.String())
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func description(text, indent string) string {
+	if text == "" {
+		return ""
+	}
+
+	return indent + strconv.Quote(text) + "\n"
+}
+
+func printArgs(args Args) string {
+	if len(args) == 0 {
+		return ""
+	}
+
+	list := []string{}
+	for _, name := range sortedKeys(args) {
+		list = append(list, name+": "+args[name].Type.String()+printDefault(args[name].Default))
+	}
+
+	return "(" + strings.Join(list, ", ") + ")"
+}
+
+func printDefault(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	return " = " + printValue(v)
+}
+
+func printValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strconv.Quote(v)
+	case []interface{}:
+		list := []string{}
+		for _, e := range v {
+			list = append(list, printValue(e))
+		}
+		return "[" + strings.Join(list, ", ") + "]"
+	case map[string]interface{}:
+		list := []string{}
+		for _, k := range sortedKeys(v) {
+			list = append(list, k+": "+printValue(v[k]))
+		}
+		return "{" + strings.Join(list, ", ") + "}"
+	}
+
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,238 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxMessageSize caps received messages like the gRPC libraries do.
+const DefaultMaxMessageSize = 4 << 20
+
+type callKey struct{}
+
+// Call is one RPC, the handlers of the methods find it in the context of
+// the request with CallFrom.
+type Call struct {
+	// Method is the path of the call, e.g. /phonebook.v1.Contacts/Get
+	Method string
+
+	w       http.ResponseWriter
+	r       *http.Request
+	maxSize int
+
+	lock    sync.Mutex
+	started bool
+	status  *Status
+}
+
+// CallFrom is the call ctx belongs to, nil outside of the gRPC server.
+func CallFrom(ctx context.Context) *Call {
+	c, _ := ctx.Value(callKey{}).(*Call)
+
+	return c
+}
+
+// Receive reads the next request message, io.EOF ends the stream of the client.
+func (c *Call) Receive(m Message) error {
+	var head [5]byte
+	if _, err := io.ReadFull(c.r.Body, head[:]); err != nil {
+		if err == io.EOF {
+			return io.EOF
+		}
+		return c.readError(err)
+	}
+
+	size := binary.BigEndian.Uint32(head[1:])
+	if int64(size) > int64(c.maxSize) {
+		return Errorf(ResourceExhausted, "message of %d bytes is larger than %d", size, c.maxSize)
+	}
+
+	b := make([]byte, size)
+	if _, err := io.ReadFull(c.r.Body, b); err != nil {
+		return c.readError(err)
+	}
+
+	if head[0] == 1 {
+		if c.r.Header.Get("Grpc-Encoding") != "gzip" {
+			return Errorf(Internal, "compressed message without grpc-encoding")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return Errorf(Internal, "decompress: %v", err)
+		}
+		if b, err = io.ReadAll(io.LimitReader(zr, int64(c.maxSize)+1)); err != nil {
+			return Errorf(Internal, "decompress: %v", err)
+		}
+		if len(b) > c.maxSize {
+			return Errorf(ResourceExhausted, "message is larger than %d bytes", c.maxSize)
+		}
+	}
+
+	if err := Unmarshal(b, m); err != nil {
+		return Errorf(InvalidArgument, "%v", err)
+	}
+
+	return nil
+}
+
+func (c *Call) readError(err error) error {
+	if ctxErr := c.r.Context().Err(); ctxErr != nil {
+		return contextStatus(ctxErr)
+	}
+	if err == io.ErrUnexpectedEOF {
+		return Errorf(Internal, "request message is cut off")
+	}
+
+	return Errorf(Canceled, "%v", err)
+}
+
+// ReceiveOne reads the request of a unary call.
+func (c *Call) ReceiveOne(m Message) error {
+	if err := c.Receive(m); err != io.EOF {
+		return err
+	}
+
+	return Errorf(Internal, "request message missing")
+}
+
+// Send writes a response message, flushing it to the client right away.
+func (c *Call) Send(m Message) error {
+	b := Marshal(m)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.status != nil {
+		return c.status
+	}
+
+	if !c.started {
+		c.started = true
+		c.w.Header().Set("Content-Type", "application/grpc")
+		c.w.WriteHeader(http.StatusOK)
+	}
+
+	frame := make([]byte, 5, 5+len(b))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(b)))
+	if _, err := c.w.Write(append(frame, b...)); err != nil {
+		return Errorf(Canceled, "%v", err)
+	}
+	if err := http.NewResponseController(c.w).Flush(); err != nil {
+		return Errorf(Canceled, "%v", err)
+	}
+
+	return nil
+}
+
+// Fail ends the call with err, a *Status or an error answered as Unknown.
+// It returns nil so handlers can return c.Fail(err).
+func (c *Call) Fail(err error) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.status == nil {
+		c.status = StatusOf(err)
+	}
+
+	return nil
+}
+
+// finish answers the status, in the headers when no message was sent.
+func (c *Call) finish(w *callWriter) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s := c.status
+	switch {
+	case s != nil:
+	case w.code != 0:
+		s = fromHTTP(w.code, w.body.String())
+	case w.body.Len() > 0:
+		s = Errorf(Internal, "handler answered no protobuf message")
+	case c.r.Context().Err() != nil:
+		s = contextStatus(c.r.Context().Err())
+	default:
+		s = &Status{Code: OK}
+	}
+
+	h := c.w.Header()
+	prefix := ""
+	if c.started {
+		prefix = http.TrailerPrefix
+	} else {
+		h.Set("Content-Type", "application/grpc")
+	}
+	h.Set(prefix+"Grpc-Status", strconv.Itoa(int(s.Code)))
+	if s.Message != "" {
+		h.Set(prefix+"Grpc-Message", encodeMessage(s.Message))
+	}
+
+	if !c.started {
+		// a trailers-only answer, the headers end the stream
+		c.w.WriteHeader(http.StatusOK)
+	}
+}
+
+func contextStatus(err error) *Status {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return Errorf(DeadlineExceeded, "deadline exceeded")
+	}
+
+	return Errorf(Canceled, "call canceled")
+}
+
+// callWriter is the ResponseWriter of the HTTP handlers, it keeps the
+// error answers of middlewares so finish can turn them into a status.
+type callWriter struct {
+	w    http.ResponseWriter
+	code int
+	body bytes.Buffer
+}
+
+func (w *callWriter) Header() http.Header {
+	return w.w.Header()
+}
+
+func (w *callWriter) WriteHeader(code int) {
+	if code != http.StatusOK && w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *callWriter) Write(b []byte) (int, error) {
+	if w.body.Len() < 1024 {
+		w.body.Write(b)
+	}
+
+	return len(b), nil
+}
+
+// parseTimeout reads the grpc-timeout header, e.g. 100m or 5S.
+func parseTimeout(s string) (time.Duration, bool) {
+	if len(s) < 2 || len(s) > 9 {
+		return 0, false
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, false
+	}
+
+	units := map[byte]time.Duration{'H': time.Hour, 'M': time.Minute, 'S': time.Second,
+		'm': time.Millisecond, 'u': time.Microsecond, 'n': time.Nanosecond}
+	unit, ok := units[s[len(s)-1]]
+	if !ok || n > int64(math.MaxInt64/unit) {
+		return 0, false
+	}
+
+	return time.Duration(n) * unit, true
+}
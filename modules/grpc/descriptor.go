@@ -0,0 +1,215 @@
+package grpc
+
+import (
+	"strings"
+)
+
+// File describes a .proto file, the server reflection hands it out as a
+// FileDescriptorProto so tools like grpcurl can call the service without
+// the source.
+type File struct {
+	// Name is the path of the .proto file, e.g. phonebook/v1/contacts.proto
+	Name        string
+	Package     string
+	GoPackage   string
+	JavaPackage string
+	// JavaMultipleFiles puts every message in a file of its own
+	JavaMultipleFiles bool
+	Messages          []*MessageType
+	Enums             []*EnumType
+	Services          []*Service
+}
+
+type MessageType struct {
+	Name   string
+	Fields []*Field
+	Enums  []*EnumType
+}
+
+// FieldType is the type of a field, the numbers of FieldDescriptorProto.Type
+type FieldType int
+
+const (
+	TypeDouble  FieldType = 1
+	TypeInt64   FieldType = 3
+	TypeInt32   FieldType = 5
+	TypeBool    FieldType = 8
+	TypeString  FieldType = 9
+	TypeMessage FieldType = 11
+	TypeBytes   FieldType = 12
+	TypeEnum    FieldType = 14
+)
+
+type Field struct {
+	Name   string
+	Number int
+	Type   FieldType
+	// TypeName names the message or enum, relative to the package of the
+	// file or fully qualified with a leading dot
+	TypeName string
+	Repeated bool
+}
+
+// EnumType numbers its values in order, starting at 0.
+type EnumType struct {
+	Name   string
+	Values []string
+}
+
+type Service struct {
+	Name    string
+	Methods []*Method
+}
+
+// Method names its input and output like Field.TypeName.
+type Method struct {
+	Name            string
+	Input           string
+	Output          string
+	ClientStreaming bool
+	ServerStreaming bool
+}
+
+// FullName is the qualified name of a type declared in the file.
+func (f *File) FullName(name string) string {
+	if strings.HasPrefix(name, ".") {
+		return name[1:]
+	}
+	if f.Package == "" {
+		return name
+	}
+
+	return f.Package + "." + name
+}
+
+// Path is the HTTP/2 path of a method of the file, e.g. /phonebook.v1.Contacts/Get
+func (f *File) Path(s *Service, m *Method) string {
+	return "/" + f.FullName(s.Name) + "/" + m.Name
+}
+
+// Symbols are the qualified names of the services, methods, messages and
+// enums of the file.
+func (f *File) Symbols() []string {
+	list := []string{}
+	for _, s := range f.Services {
+		list = append(list, f.FullName(s.Name))
+		for _, m := range s.Methods {
+			list = append(list, f.FullName(s.Name+"."+m.Name))
+		}
+	}
+	for _, m := range f.Messages {
+		list = append(list, f.FullName(m.Name))
+		for _, e := range m.Enums {
+			list = append(list, f.FullName(m.Name+"."+e.Name))
+		}
+	}
+	for _, e := range f.Enums {
+		list = append(list, f.FullName(e.Name))
+	}
+
+	return list
+}
+
+// Descriptor is the encoded FileDescriptorProto.
+func (f *File) Descriptor() []byte {
+	e := &Encoder{}
+	e.String(1, f.Name)
+	e.String(2, f.Package)
+	for _, m := range f.Messages {
+		e.Bytes(4, f.marshalMessage(m))
+	}
+	for _, en := range f.Enums {
+		e.Bytes(5, marshalEnum(en))
+	}
+	for _, s := range f.Services {
+		e.Bytes(6, f.marshalService(s))
+	}
+
+	opts := &Encoder{}
+	opts.String(1, f.JavaPackage)
+	opts.Bool(10, f.JavaMultipleFiles)
+	opts.String(11, f.GoPackage)
+	if len(opts.b) > 0 {
+		e.Bytes(8, opts.b)
+	}
+
+	e.String(12, "proto3")
+
+	return e.b
+}
+
+func (f *File) marshalMessage(m *MessageType) []byte {
+	e := &Encoder{}
+	e.String(1, m.Name)
+	for _, fd := range m.Fields {
+		fe := &Encoder{}
+		fe.String(1, fd.Name)
+		fe.Int(3, int64(fd.Number))
+		label := int64(1)
+		if fd.Repeated {
+			label = 3
+		}
+		fe.Int(4, label)
+		fe.Int(5, int64(fd.Type))
+		if fd.TypeName != "" {
+			fe.String(6, "."+f.FullName(fd.TypeName))
+		}
+		fe.String(10, jsonName(fd.Name))
+		e.Bytes(2, fe.b)
+	}
+	for _, en := range m.Enums {
+		e.Bytes(4, marshalEnum(en))
+	}
+
+	return e.b
+}
+
+func marshalEnum(en *EnumType) []byte {
+	e := &Encoder{}
+	e.String(1, en.Name)
+	for i, v := range en.Values {
+		ve := &Encoder{}
+		ve.String(1, v)
+		// descriptors are proto2, the first value needs its number 0 too
+		ve.tag(2, wireVarint)
+		ve.varint(uint64(i))
+		e.Bytes(2, ve.b)
+	}
+
+	return e.b
+}
+
+func (f *File) marshalService(s *Service) []byte {
+	e := &Encoder{}
+	e.String(1, s.Name)
+	for _, m := range s.Methods {
+		me := &Encoder{}
+		me.String(1, m.Name)
+		me.String(2, "."+f.FullName(m.Input))
+		me.String(3, "."+f.FullName(m.Output))
+		me.Bool(5, m.ClientStreaming)
+		me.Bool(6, m.ServerStreaming)
+		e.Bytes(2, me.b)
+	}
+
+	return e.b
+}
+
+// jsonName is the lowerCamelCase name protoc records, first_name is firstName.
+func jsonName(name string) string {
+	b := &strings.Builder{}
+	upper := false
+	for _, c := range name {
+		switch {
+		case c == '_':
+			upper = true
+		case upper:
+			b.WriteString(strings.ToUpper(string(c)))
+			upper = false
+		default:
+			b.WriteRune(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,325 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type note struct {
+	Text  string
+	Count int64
+	Tags  []string
+	Done  bool
+	Reply *note
+}
+
+func (m *note) Marshal(e *Encoder) {
+	e.String(1, m.Text)
+	e.Int(2, m.Count)
+	e.Strings(3, m.Tags)
+	e.Bool(4, m.Done)
+	if m.Reply != nil {
+		e.Message(5, m.Reply)
+	}
+}
+
+func (m *note) Unmarshal(d *Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Text = d.String()
+		case 2:
+			m.Count = d.Int()
+		case 3:
+			m.Tags = append(m.Tags, d.String())
+		case 4:
+			m.Done = d.Bool()
+		case 5:
+			m.Reply = &note{}
+			d.Message(m.Reply)
+		}
+	}
+
+	return d.Err()
+}
+
+func TestWire(t *testing.T) {
+	in := &note{Text: "héllo", Count: -3, Tags: []string{"a", ""}, Done: true, Reply: &note{}}
+	b := Marshal(in)
+
+	// unknown fields are skipped
+	e := &Encoder{b: b}
+	e.Double(9, 1.5)
+	e.String(10, "later")
+
+	out := &note{}
+	if err := Unmarshal(e.b, out); err != nil {
+		t.Fatal(err)
+	}
+	if out.Text != in.Text || out.Count != -3 || len(out.Tags) != 2 || !out.Done || out.Reply == nil {
+		t.Errorf("decoded %+v", out)
+	}
+
+	if len(Marshal(&note{})) != 0 {
+		t.Error("zero values encoded")
+	}
+
+	for _, bad := range [][]byte{{0x0a, 5, 'a'}, {0x08}, {0x0b}, {0x11, 1, 2}} {
+		if err := Unmarshal(bad, &note{}); err == nil {
+			t.Errorf("% x decoded", bad)
+		}
+	}
+	// a string where a number is expected
+	if err := Unmarshal([]byte{0x12, 1, 'x'}, &note{}); err == nil {
+		t.Error("wrong wire type decoded")
+	}
+}
+
+var testFile = &File{
+	Name:    "test/echo.proto",
+	Package: "test",
+	Messages: []*MessageType{{Name: "Note", Fields: []*Field{
+		{Name: "text", Number: 1, Type: TypeString},
+		{Name: "count", Number: 2, Type: TypeInt64},
+		{Name: "reply_to", Number: 5, Type: TypeMessage, TypeName: "Note"},
+	}, Enums: []*EnumType{{Name: "Kind", Values: []string{"KIND_UNSPECIFIED"}}}}},
+	Services: []*Service{{Name: "Echo", Methods: []*Method{
+		{Name: "Say", Input: "Note", Output: "Note"},
+		{Name: "Count", Input: "Note", Output: "Note", ServerStreaming: true},
+		{Name: "Deny", Input: "Note", Output: "Note"},
+	}}},
+}
+
+func startServer(t *testing.T) (*Server, *http.Client, string) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/test.Echo/Say", func(w http.ResponseWriter, r *http.Request) {
+		call := CallFrom(r.Context())
+		req := &note{}
+		if err := call.ReceiveOne(req); err != nil {
+			call.Fail(err)
+			return
+		}
+		if req.Text == "" {
+			call.Fail(Errorf(InvalidArgument, "text is required\nreally"))
+			return
+		}
+		call.Send(&note{Text: req.Text + "!"})
+	})
+	mux.HandleFunc("/test.Echo/Count", func(w http.ResponseWriter, r *http.Request) {
+		call := CallFrom(r.Context())
+		req := &note{}
+		if err := call.ReceiveOne(req); err != nil {
+			call.Fail(err)
+			return
+		}
+		for i := int64(1); i <= req.Count; i++ {
+			call.Send(&note{Count: i})
+		}
+		call.Fail(Errorf(Aborted, "stopped"))
+	})
+	mux.HandleFunc("/test.Echo/Deny", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "Permission denied", http.StatusForbidden)
+	})
+
+	s := &Server{Handler: mux}
+	s.Register(testFile)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Serve(l)
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	client := &http.Client{Transport: &http.Transport{Protocols: protocols}, Timeout: 5 * time.Second}
+
+	return s, client, "http://" + l.Addr().String()
+}
+
+func frame(m Message) []byte {
+	b := Marshal(m)
+	head := make([]byte, 5)
+	binary.BigEndian.PutUint32(head[1:], uint32(len(b)))
+
+	return append(head, b...)
+}
+
+// invoke calls method with the requests and returns the replies and the status.
+func invoke(t *testing.T, client *http.Client, url, method string, reqs ...Message) ([]*note, Code, string) {
+	body := []byte{}
+	for _, m := range reqs {
+		body = append(body, frame(m)...)
+	}
+
+	req, _ := http.NewRequest("POST", url+method, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies := []*note{}
+	for len(data) >= 5 {
+		size := binary.BigEndian.Uint32(data[1:5])
+		m := &note{}
+		if err := Unmarshal(data[5:5+size], m); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, m)
+		data = data[5+size:]
+	}
+
+	status, message := res.Trailer.Get("Grpc-Status"), res.Trailer.Get("Grpc-Message")
+	if status == "" {
+		status, message = res.Header.Get("Grpc-Status"), res.Header.Get("Grpc-Message")
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		t.Fatalf("%s: no grpc-status", method)
+	}
+
+	return replies, Code(code), message
+}
+
+func TestCall(t *testing.T) {
+	_, client, url := startServer(t)
+
+	replies, code, _ := invoke(t, client, url, "/test.Echo/Say", &note{Text: "hi"})
+	if code != OK || len(replies) != 1 || replies[0].Text != "hi!" {
+		t.Errorf("Say = %v %+v", code, replies)
+	}
+
+	if _, code, msg := invoke(t, client, url, "/test.Echo/Say", &note{}); code != InvalidArgument || msg != "text is required%0Areally" {
+		t.Errorf("empty Say = %v %q", code, msg)
+	}
+
+	if _, code, _ := invoke(t, client, url, "/test.Echo/Say"); code != Internal {
+		t.Errorf("Say without request = %v", code)
+	}
+
+	replies, code, msg := invoke(t, client, url, "/test.Echo/Count", &note{Count: 3})
+	if code != Aborted || msg != "stopped" || len(replies) != 3 || replies[2].Count != 3 {
+		t.Errorf("Count = %v %q %+v", code, msg, replies)
+	}
+
+	if _, code, msg := invoke(t, client, url, "/test.Echo/Deny", &note{}); code != PermissionDenied || msg != "Permission denied" {
+		t.Errorf("Deny = %v %q", code, msg)
+	}
+
+	if _, code, _ := invoke(t, client, url, "/test.Echo/Nope", &note{}); code != Unimplemented {
+		t.Errorf("unknown method = %v", code)
+	}
+}
+
+// reflectionReply picks the fields of a ServerReflectionResponse.
+type reflectionReply struct {
+	services []string
+	files    [][]byte
+	errCode  int64
+}
+
+func (m *reflectionReply) Marshal(e *Encoder) {}
+
+func (m *reflectionReply) Unmarshal(d *Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 4, 6, 7:
+			field := d.Field()
+			sub := NewDecoder(d.Bytes())
+			for sub.Next() {
+				switch {
+				case field == 4:
+					m.files = append(m.files, sub.Bytes())
+				case field == 6:
+					name := NewDecoder(sub.Bytes())
+					for name.Next() {
+						m.services = append(m.services, name.String())
+					}
+				case sub.Field() == 1:
+					m.errCode = sub.Int()
+				}
+			}
+		}
+	}
+
+	return d.Err()
+}
+
+func TestReflection(t *testing.T) {
+	_, client, url := startServer(t)
+
+	body := []byte{}
+	for _, req := range []*reflectionRequest{{which: 7, arg: ""}, {which: 4, arg: "test.Echo.Count"}, {which: 4, arg: "test.Note.Kind"}, {which: 3, arg: "nope.proto"}} {
+		body = append(body, frame(req)...)
+	}
+
+	req, _ := http.NewRequest("POST", url+"/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/grpc")
+	res, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	replies := []*reflectionReply{}
+	for len(data) >= 5 {
+		size := binary.BigEndian.Uint32(data[1:5])
+		m := &reflectionReply{}
+		if err := Unmarshal(data[5:5+size], m); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, m)
+		data = data[5+size:]
+	}
+
+	if len(replies) != 4 || res.Trailer.Get("Grpc-Status") != "0" {
+		t.Fatalf("%d replies, status %q", len(replies), res.Trailer.Get("Grpc-Status"))
+	}
+	if s := replies[0].services; len(s) != 3 || s[0] != "test.Echo" {
+		t.Errorf("services = %v", s)
+	}
+	for _, r := range replies[1:3] {
+		if len(r.files) != 1 || !bytes.Equal(r.files[0], testFile.Descriptor()) {
+			t.Errorf("files = %v", r.files)
+		}
+	}
+	if replies[3].errCode != int64(NotFound) {
+		t.Errorf("missing file answered %+v", replies[3])
+	}
+
+	// the descriptor names the file and qualifies the types
+	d := NewDecoder(testFile.Descriptor())
+	names := []string{}
+	for d.Next() {
+		if d.Field() == 1 || d.Field() == 2 || d.Field() == 12 {
+			names = append(names, d.String())
+		}
+	}
+	if len(names) != 3 || names[0] != "test/echo.proto" || names[1] != "test" || names[2] != "proto3" || !bytes.Contains(testFile.Descriptor(), []byte(".test.Note")) {
+		t.Errorf("descriptor names %v", names)
+	}
+}
+
+func TestParseTimeout(t *testing.T) {
+	for s, want := range map[string]time.Duration{"5S": 5 * time.Second, "100m": 100 * time.Millisecond, "1H": time.Hour, "99999999H": 0, "5x": 0, "S": 0} {
+		if d, _ := parseTimeout(s); d != want {
+			t.Errorf("%s = %v", s, d)
+		}
+	}
+}
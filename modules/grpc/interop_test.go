@@ -0,0 +1,145 @@
+// The interop test calls the server with grpc-go the way grpcurl does:
+// it lists the services and fetches their descriptors by reflection, then
+// builds the messages from the descriptors. grpc-go and
+// google.golang.org/protobuf are vendored for it.
+package grpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	grpcgo "google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// describe asks the reflection service of conn for the services and the
+// descriptor of service
+func describe(t *testing.T, ctx context.Context, conn *grpcgo.ClientConn, service string) ([]string, protoreflect.ServiceDescriptor) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stream.CloseSend()
+
+	ask := func(req *rpb.ServerReflectionRequest) *rpb.ServerReflectionResponse {
+		if err := stream.Send(req); err != nil {
+			t.Fatal(err)
+		}
+		res, err := stream.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+
+	services := []string{}
+	list := ask(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_ListServices{}})
+	for _, s := range list.GetListServicesResponse().GetService() {
+		services = append(services, s.GetName())
+	}
+
+	res := ask(&rpb.ServerReflectionRequest{MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service}})
+	files := res.GetFileDescriptorResponse().GetFileDescriptorProto()
+	if len(files) != 1 {
+		t.Fatalf("%s: %d files, %v", service, len(files), res.GetErrorResponse())
+	}
+
+	fdp := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(files[0], fdp); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(fdp, new(protoregistry.Files))
+	if err != nil {
+		t.Fatalf("descriptor refused by protodesc: %v", err)
+	}
+
+	sd := fd.Services().ByName(protoreflect.FullName(service).Name())
+	if sd == nil {
+		t.Fatalf("%s missing from %s", service, fd.Path())
+	}
+
+	return services, sd
+}
+
+func TestInterop(t *testing.T) {
+	_, _, url := startServer(t)
+
+	conn, err := grpcgo.NewClient(strings.TrimPrefix(url, "http://"), grpcgo.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	services, echo := describe(t, ctx, conn, "test.Echo")
+	if strings.Join(services, ",") != "test.Echo,grpc.reflection.v1.ServerReflection,grpc.reflection.v1alpha.ServerReflection" {
+		t.Errorf("services = %v", services)
+	}
+
+	say := echo.Methods().ByName("Say")
+	note := func(fields map[string]interface{}) *dynamicpb.Message {
+		m := dynamicpb.NewMessage(say.Input())
+		for name, v := range fields {
+			m.Set(m.Descriptor().Fields().ByName(protoreflect.Name(name)), protoreflect.ValueOf(v))
+		}
+		return m
+	}
+
+	reply := dynamicpb.NewMessage(say.Output())
+	if err := conn.Invoke(ctx, "/test.Echo/Say", note(map[string]interface{}{"text": "hi"}), reply); err != nil {
+		t.Fatal(err)
+	}
+	if text := reply.Get(reply.Descriptor().Fields().ByName("text")).String(); text != "hi!" {
+		t.Errorf("Say = %q", text)
+	}
+
+	for method, want := range map[string]*status.Status{
+		"/test.Echo/Say":  status.New(codes.InvalidArgument, "text is required\nreally"),
+		"/test.Echo/Deny": status.New(codes.PermissionDenied, "Permission denied"),
+		"/test.Echo/Nope": status.New(codes.Unimplemented, "unknown method test.Echo/Nope"),
+	} {
+		err := conn.Invoke(ctx, method, note(nil), dynamicpb.NewMessage(say.Output()))
+		if got := status.Convert(err); got.Code() != want.Code() || got.Message() != want.Message() {
+			t.Errorf("%s = %v", method, err)
+		}
+	}
+
+	count := echo.Methods().ByName("Count")
+	if !count.IsStreamingServer() {
+		t.Fatal("Count is not a server stream")
+	}
+	stream, err := conn.NewStream(ctx, &grpcgo.StreamDesc{ServerStreams: true}, "/test.Echo/Count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.SendMsg(note(map[string]interface{}{"count": int64(3)})); err != nil {
+		t.Fatal(err)
+	}
+	stream.CloseSend()
+
+	counts := []int64{}
+	for {
+		m := dynamicpb.NewMessage(count.Output())
+		if err = stream.RecvMsg(m); err != nil {
+			break
+		}
+		counts = append(counts, m.Get(m.Descriptor().Fields().ByName("count")).Int())
+	}
+	if len(counts) != 3 || counts[2] != 3 || err == io.EOF || status.Code(err) != codes.Aborted {
+		t.Errorf("Count = %v, %v", counts, err)
+	}
+}
@@ -0,0 +1,168 @@
+package grpc
+
+import (
+	"io"
+	"strings"
+)
+
+// reflectionServices are answered by the server itself, v1alpha is still
+// what many clients ask for first.
+var reflectionServices = []string{"grpc.reflection.v1.ServerReflection", "grpc.reflection.v1alpha.ServerReflection"}
+
+func isReflection(path string) bool {
+	for _, name := range reflectionServices {
+		if path == "/"+name+"/ServerReflectionInfo" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// reflectionRequest is a ServerReflectionRequest, which names the field of
+// the message_request oneof that is set.
+type reflectionRequest struct {
+	host  string
+	which int
+	arg   string
+	raw   []byte
+}
+
+func (m *reflectionRequest) Marshal(e *Encoder) {
+	e.String(1, m.host)
+	e.Bytes(m.which, []byte(m.arg))
+}
+
+func (m *reflectionRequest) Unmarshal(d *Decoder) error {
+	m.raw = d.b
+	for d.Next() {
+		switch f := d.Field(); f {
+		case 1:
+			m.host = d.String()
+		case 3, 4, 6, 7:
+			m.which, m.arg = f, d.String()
+		case 5:
+			// an ExtensionRequest, only the containing type matters
+			m.which = f
+			sub := NewDecoder(d.Bytes())
+			for sub.Next() {
+				if sub.Field() == 1 {
+					m.arg = sub.String()
+				}
+			}
+		}
+	}
+
+	return d.Err()
+}
+
+// rawMessage is an encoded message.
+type rawMessage []byte
+
+func (m rawMessage) Marshal(e *Encoder) {
+	e.b = append(e.b, m...)
+}
+
+func (m rawMessage) Unmarshal(d *Decoder) error {
+	return nil
+}
+
+// reflect serves ServerReflectionInfo, answering every request of the
+// stream until the client closes it.
+func (s *Server) reflect(c *Call) {
+	for {
+		req := &reflectionRequest{}
+		if err := c.Receive(req); err == io.EOF {
+			return
+		} else if err != nil {
+			c.Fail(err)
+			return
+		}
+
+		if err := c.Send(s.reflectionResponse(req)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) reflectionResponse(req *reflectionRequest) rawMessage {
+	e := &Encoder{}
+	e.String(1, req.host)
+	e.Bytes(2, req.raw)
+
+	s.lock.Lock()
+	files := s.files
+	s.lock.Unlock()
+
+	fail := func(code Code, message string) rawMessage {
+		sub := &Encoder{}
+		sub.Int(1, int64(code))
+		sub.String(2, message)
+		e.Bytes(7, sub.b)
+		return e.b
+	}
+
+	switch req.which {
+	case 7:
+		sub := &Encoder{}
+		for _, name := range append(serviceNames(files), reflectionServices...) {
+			service := &Encoder{}
+			service.String(1, name)
+			sub.Bytes(1, service.b)
+		}
+		e.Bytes(6, sub.b)
+	case 3, 4:
+		f := findFile(files, req.which, req.arg)
+		if f == nil {
+			return fail(NotFound, req.arg+" not found")
+		}
+		sub := &Encoder{}
+		sub.Bytes(1, f.Descriptor())
+		e.Bytes(4, sub.b)
+	case 6:
+		if findFile(files, 4, req.arg) == nil {
+			return fail(NotFound, req.arg+" not found")
+		}
+		// proto3 messages have no extensions
+		sub := &Encoder{}
+		sub.String(1, req.arg)
+		e.Bytes(5, sub.b)
+	case 5:
+		return fail(NotFound, "no extensions of "+req.arg)
+	default:
+		return fail(Unimplemented, "unknown reflection request")
+	}
+
+	return e.b
+}
+
+func serviceNames(files []*File) []string {
+	names := []string{}
+	for _, f := range files {
+		for _, s := range f.Services {
+			names = append(names, f.FullName(s.Name))
+		}
+	}
+
+	return names
+}
+
+// findFile looks up a file by name when which is 3 or by one of its
+// symbols when which is 4.
+func findFile(files []*File, which int, arg string) *File {
+	arg = strings.TrimPrefix(arg, ".")
+	for _, f := range files {
+		if which == 3 && f.Name == arg {
+			return f
+		}
+		if which == 4 {
+			for _, sym := range f.Symbols() {
+				if sym == arg {
+					return f
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,175 @@
+// Package grpc serves gRPC services over the HTTP/2 support of net/http,
+// with hand written protobuf messages and server reflection, so internal
+// services get a typed, streaming API without generated code here.
+//
+// The server frames the messages and answers the status, the methods are
+// plain HTTP handlers behind Handler that read and write their messages
+// through the Call of the request:
+//
+//	call := grpc.CallFrom(r.Context())
+//	req := &GetRequest{}
+//	if err := call.ReceiveOne(req); err != nil {
+//		return call.Fail(err)
+//	}
+//	call.Send(reply)
+//
+// HTTP error answers of the handler, such as 401 of an authentication
+// middleware, end the call with the matching status.
+package grpc
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Server struct {
+	Address string
+	// TLS serves HTTP/2 over TLS, without it the server speaks plaintext
+	// HTTP/2 (h2c) like most internal gRPC deployments
+	TLS *tls.Config
+	// Handler serves the methods of the registered files, e.g. a router
+	// with a route per method path
+	Handler http.Handler
+	// MaxMessageSize caps received messages, DefaultMaxMessageSize when 0
+	MaxMessageSize int
+
+	lock    sync.Mutex
+	files   []*File
+	methods map[string]bool
+	http    *http.Server
+	cancel  context.CancelFunc
+	closed  bool
+}
+
+// Register publishes the services of f, calls of other methods fail with
+// Unimplemented before reaching Handler.
+func (s *Server) Register(f *File) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.methods == nil {
+		s.methods = map[string]bool{}
+	}
+	for _, service := range f.Services {
+		for _, m := range service.Methods {
+			s.methods[f.Path(service, m)] = true
+		}
+	}
+	s.files = append(s.files, f)
+}
+
+// ListenAndServe listens on Address and serves until Shutdown is called.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, it returns nil after Shutdown.
+func (s *Server) Serve(l net.Listener) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	protocols := new(http.Protocols)
+	srv := &http.Server{
+		Handler:           s,
+		TLSConfig:         s.TLS,
+		Protocols:         protocols,
+		ReadHeaderTimeout: 10 * time.Second,
+		// streams end with the base context, see Shutdown
+		BaseContext: func(net.Listener) context.Context { return ctx },
+	}
+
+	s.lock.Lock()
+	if s.closed {
+		s.lock.Unlock()
+		cancel()
+		l.Close()
+		return nil
+	}
+	s.http, s.cancel = srv, cancel
+	s.lock.Unlock()
+
+	var err error
+	if s.TLS != nil {
+		protocols.SetHTTP2(true)
+		err = srv.ServeTLS(l, "", "")
+	} else {
+		protocols.SetUnencryptedHTTP2(true)
+		err = srv.Serve(l)
+	}
+
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+
+	return err
+}
+
+// Shutdown stops listening, cancels the running streams such as watches
+// and waits for the calls to finish until ctx is done.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.lock.Lock()
+	s.closed = true
+	srv, cancel := s.http, s.cancel
+	s.lock.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	cancel()
+
+	return srv.Shutdown(ctx)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.ProtoMajor != 2 {
+		http.Error(w, "gRPC requires HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	ct := r.Header.Get("Content-Type")
+	if r.Method != http.MethodPost || (ct != "application/grpc" && ct != "application/grpc+proto") {
+		http.Error(w, "not a gRPC request", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	ctx := r.Context()
+	if d, ok := parseTimeout(r.Header.Get("Grpc-Timeout")); ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d)
+		defer cancel()
+	}
+
+	c := &Call{Method: r.URL.Path, w: w, maxSize: s.MaxMessageSize}
+	if c.maxSize <= 0 {
+		c.maxSize = DefaultMaxMessageSize
+	}
+	r = r.WithContext(context.WithValue(ctx, callKey{}, c))
+	c.r = r
+	w.Header().Set("Grpc-Accept-Encoding", "gzip")
+
+	s.lock.Lock()
+	known := s.methods[r.URL.Path]
+	s.lock.Unlock()
+
+	cw := &callWriter{w: w}
+	switch {
+	case isReflection(r.URL.Path):
+		s.reflect(c)
+	case !known || s.Handler == nil:
+		c.Fail(Errorf(Unimplemented, "unknown method %s", strings.TrimPrefix(r.URL.Path, "/")))
+	default:
+		s.Handler.ServeHTTP(cw, r)
+	}
+
+	c.finish(cw)
+}
@@ -0,0 +1,99 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Code is a gRPC status code.
+type Code int
+
+const (
+	OK                 Code = 0
+	Canceled           Code = 1
+	Unknown            Code = 2
+	InvalidArgument    Code = 3
+	DeadlineExceeded   Code = 4
+	NotFound           Code = 5
+	AlreadyExists      Code = 6
+	PermissionDenied   Code = 7
+	ResourceExhausted  Code = 8
+	FailedPrecondition Code = 9
+	Aborted            Code = 10
+	OutOfRange         Code = 11
+	Unimplemented      Code = 12
+	Internal           Code = 13
+	Unavailable        Code = 14
+	DataLoss           Code = 15
+	Unauthenticated    Code = 16
+)
+
+// Status is the error a call ends with.
+type Status struct {
+	Code    Code
+	Message string
+}
+
+func (s *Status) Error() string {
+	return "grpc: code " + strconv.Itoa(int(s.Code)) + ": " + s.Message
+}
+
+func Errorf(code Code, format string, args ...interface{}) *Status {
+	return &Status{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+// StatusOf turns err into a status, errors that are no *Status are Unknown.
+func StatusOf(err error) *Status {
+	if err == nil {
+		return &Status{Code: OK}
+	}
+
+	var s *Status
+	if errors.As(err, &s) {
+		return s
+	}
+
+	return &Status{Code: Unknown, Message: err.Error()}
+}
+
+// httpCodes map the answers of the HTTP middlewares, like a refused
+// session or a rate limit, to the status of the call.
+var httpCodes = map[int]Code{
+	http.StatusBadRequest:         InvalidArgument,
+	http.StatusUnauthorized:       Unauthenticated,
+	http.StatusForbidden:          PermissionDenied,
+	http.StatusNotFound:           NotFound,
+	http.StatusTooManyRequests:    ResourceExhausted,
+	http.StatusNotImplemented:     Unimplemented,
+	http.StatusServiceUnavailable: Unavailable,
+	http.StatusGatewayTimeout:     DeadlineExceeded,
+}
+
+func fromHTTP(code int, message string) *Status {
+	c, ok := httpCodes[code]
+	if !ok {
+		c = Internal
+		if code < 500 {
+			c = Unknown
+		}
+	}
+
+	return &Status{Code: c, Message: strings.TrimSpace(message)}
+}
+
+// encodeMessage percent-encodes the grpc-message trailer.
+func encodeMessage(s string) string {
+	b := &strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package grpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+// Message is a protocol buffers message the service sends or receives,
+// encoded by hand with Encoder and Decoder.
+type Message interface {
+	Marshal(e *Encoder)
+	Unmarshal(d *Decoder) error
+}
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errMalformed = errors.New("malformed protobuf message")
+
+// Encoder writes the fields of a message. Scalars at their zero value are
+// left out like proto3 does, messages and Bytes are always written.
+type Encoder struct {
+	b []byte
+}
+
+// Marshal encodes m.
+func Marshal(m Message) []byte {
+	e := &Encoder{}
+	m.Marshal(e)
+
+	return e.b
+}
+
+func (e *Encoder) varint(v uint64) {
+	e.b = binary.AppendUvarint(e.b, v)
+}
+
+func (e *Encoder) tag(field, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (e *Encoder) String(field int, s string) {
+	if s != "" {
+		e.Bytes(field, []byte(s))
+	}
+}
+
+// Strings writes a repeated string field, empty elements included.
+func (e *Encoder) Strings(field int, list []string) {
+	for _, s := range list {
+		e.Bytes(field, []byte(s))
+	}
+}
+
+// Bytes writes a length delimited field even when b is empty, for oneofs,
+// repeated elements and messages encoded elsewhere.
+func (e *Encoder) Bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.b = append(e.b, b...)
+}
+
+// Int writes int32, int64 and enum fields.
+func (e *Encoder) Int(field int, v int64) {
+	if v != 0 {
+		e.tag(field, wireVarint)
+		e.varint(uint64(v))
+	}
+}
+
+func (e *Encoder) Bool(field int, v bool) {
+	if v {
+		e.tag(field, wireVarint)
+		e.varint(1)
+	}
+}
+
+func (e *Encoder) Double(field int, v float64) {
+	if v != 0 {
+		e.tag(field, wireFixed64)
+		e.b = binary.LittleEndian.AppendUint64(e.b, math.Float64bits(v))
+	}
+}
+
+// Message writes m as a sub message, nil is left out.
+func (e *Encoder) Message(field int, m Message) {
+	if m != nil {
+		e.Bytes(field, Marshal(m))
+	}
+}
+
+// Decoder walks the fields of an encoded message:
+//
+//	for d.Next() {
+//		switch d.Field() {
+//		case 1:
+//			m.Name = d.String()
+//		}
+//	}
+//	return d.Err()
+//
+// Unknown fields are skipped by not reading them.
+type Decoder struct {
+	b     []byte
+	pos   int
+	field int
+	wire  int
+	value []byte
+	num   uint64
+	err   error
+}
+
+func NewDecoder(b []byte) *Decoder {
+	return &Decoder{b: b}
+}
+
+// Unmarshal decodes b into m.
+func Unmarshal(b []byte, m Message) error {
+	return m.Unmarshal(NewDecoder(b))
+}
+
+// Next moves to the next field, false at the end or on malformed input.
+func (d *Decoder) Next() bool {
+	if d.err != nil || d.pos >= len(d.b) {
+		return false
+	}
+
+	tag, n := binary.Uvarint(d.b[d.pos:])
+	if n <= 0 || tag>>3 == 0 || tag>>3 > math.MaxInt32 {
+		return d.fail()
+	}
+	d.pos += n
+	d.field, d.wire = int(tag>>3), int(tag&7)
+
+	switch d.wire {
+	case wireVarint:
+		v, n := binary.Uvarint(d.b[d.pos:])
+		if n <= 0 {
+			return d.fail()
+		}
+		d.num = v
+		d.pos += n
+	case wireFixed64, wireFixed32:
+		size := 8
+		if d.wire == wireFixed32 {
+			size = 4
+		}
+		if len(d.b)-d.pos < size {
+			return d.fail()
+		}
+		d.value = d.b[d.pos : d.pos+size]
+		d.pos += size
+	case wireBytes:
+		size, n := binary.Uvarint(d.b[d.pos:])
+		if n <= 0 || size > uint64(len(d.b)-d.pos-n) {
+			return d.fail()
+		}
+		d.pos += n
+		d.value = d.b[d.pos : d.pos+int(size)]
+		d.pos += int(size)
+	default:
+		// groups are long deprecated and not used by this service
+		return d.fail()
+	}
+
+	return true
+}
+
+func (d *Decoder) fail() bool {
+	d.err = errMalformed
+
+	return false
+}
+
+func (d *Decoder) Field() int {
+	return d.field
+}
+
+// Err is the decoding error after Next returned false.
+func (d *Decoder) Err() error {
+	return d.err
+}
+
+func (d *Decoder) want(wire int) bool {
+	if d.wire != wire {
+		d.err = errMalformed
+		return false
+	}
+
+	return true
+}
+
+func (d *Decoder) String() string {
+	return string(d.Bytes())
+}
+
+func (d *Decoder) Bytes() []byte {
+	if !d.want(wireBytes) {
+		return nil
+	}
+
+	return d.value
+}
+
+func (d *Decoder) Int() int64 {
+	if !d.want(wireVarint) {
+		return 0
+	}
+
+	return int64(d.num)
+}
+
+func (d *Decoder) Bool() bool {
+	return d.Int() != 0
+}
+
+func (d *Decoder) Double() float64 {
+	if !d.want(wireFixed64) {
+		return 0
+	}
+
+	return math.Float64frombits(binary.LittleEndian.Uint64(d.value))
+}
+
+// Message decodes the field into m.
+func (d *Decoder) Message(m Message) {
+	b := d.Bytes()
+	if d.err != nil {
+		return
+	}
+
+	if err := Unmarshal(b, m); err != nil {
+		d.err = err
+	}
+}
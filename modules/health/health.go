@@ -0,0 +1,161 @@
+// Package health answers the liveness and readiness probes of an
+// orchestrator.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	// StatusDegraded is reported when only non-critical checks fail
+	StatusDegraded = "degraded"
+)
+
+// Check tests a dependency, Run must give up when ctx is done.
+type Check struct {
+	Name string
+	// Critical checks make the service unready when they fail
+	Critical bool
+	Run      func(ctx context.Context) error
+}
+
+// Build describes the running binary.
+type Build struct {
+	Version   string
+	Commit    string
+	BuildDate string
+	GoVersion string
+}
+
+type CheckResult struct {
+	Name     string
+	Status   string
+	Critical bool
+	Latency  string
+	Error    string `json:",omitempty"`
+}
+
+type Report struct {
+	Status string
+	Build  Build
+	Uptime string
+	Checks []CheckResult `json:",omitempty"`
+}
+
+// Probes serves Live and Ready.
+type Probes struct {
+	Build  Build
+	Checks []Check
+	// Timeout bounds every readiness check, 2 seconds when 0
+	Timeout time.Duration
+
+	started time.Time
+}
+
+func NewProbes(build Build, checks ...Check) *Probes {
+	if build.GoVersion == "" {
+		build.GoVersion = runtime.Version()
+	}
+
+	return &Probes{Build: build, Checks: checks, started: time.Now()}
+}
+
+// Live answers 200 as long as the process serves HTTP, it checks nothing
+// so a database outage does not get the service restarted.
+func (p *Probes) Live(w http.ResponseWriter, r *http.Request) {
+	write(w, http.StatusOK, Report{Status: StatusOK, Build: p.Build, Uptime: p.uptime()})
+}
+
+// Ready runs the checks in parallel and answers 503 when a critical one
+// fails, so no traffic is sent until the dependencies are back.
+func (p *Probes) Ready(w http.ResponseWriter, r *http.Request) {
+	report := p.Run(r.Context())
+
+	code := http.StatusOK
+	if report.Status == StatusUnavailable {
+		code = http.StatusServiceUnavailable
+	}
+
+	write(w, code, report)
+}
+
+// Run runs the checks and sums them up.
+func (p *Probes) Run(ctx context.Context) Report {
+	timeout := p.Timeout
+	if timeout <= 0 {
+		timeout = 2 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	results := make([]CheckResult, len(p.Checks))
+
+	var wg sync.WaitGroup
+	for i, c := range p.Checks {
+		wg.Add(1)
+		go func(i int, c Check) {
+			defer wg.Done()
+			results[i] = run(ctx, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	report := Report{Status: StatusOK, Build: p.Build, Uptime: p.uptime(), Checks: results}
+	for _, res := range results {
+		if res.Status == StatusOK {
+			continue
+		}
+
+		if res.Critical {
+			report.Status = StatusUnavailable
+		} else if report.Status == StatusOK {
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// run gives up on a check when ctx is done even if Run ignores it
+func run(ctx context.Context, c Check) CheckResult {
+	start := time.Now()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Run(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := CheckResult{Name: c.Name, Status: StatusOK, Critical: c.Critical, Latency: time.Since(start).Round(time.Microsecond).String()}
+	if err != nil {
+		res.Status = StatusUnavailable
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+func (p *Probes) uptime() string {
+	return time.Since(p.started).Round(time.Second).String()
+}
+
+func write(w http.ResponseWriter, code int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(report)
+}
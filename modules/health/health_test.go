@@ -0,0 +1,61 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, Report) {
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	report := Report{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("invalid report %q", rec.Body.String())
+	}
+
+	return rec.Code, report
+}
+
+func TestReady(t *testing.T) {
+	dbErr := error(nil)
+	slow := false
+
+	p := NewProbes(Build{Version: "1.2.0"},
+		Check{Name: "mongodb", Critical: true, Run: func(ctx context.Context) error { return dbErr }},
+		Check{Name: "photos", Run: func(ctx context.Context) error {
+			if slow {
+				time.Sleep(time.Second)
+			}
+			return nil
+		}},
+	)
+	p.Timeout = 50 * time.Millisecond
+
+	code, report := probe(t, p.Ready)
+	if code != 200 || report.Status != StatusOK || len(report.Checks) != 2 || report.Build.Version != "1.2.0" || report.Build.GoVersion == "" {
+		t.Errorf("healthy: got %d %+v", code, report)
+	}
+
+	slow = true
+	code, report = probe(t, p.Ready)
+	if code != 200 || report.Status != StatusDegraded || report.Checks[1].Error != context.DeadlineExceeded.Error() {
+		t.Errorf("slow optional check: got %d %+v", code, report)
+	}
+
+	dbErr = errors.New("no reachable servers")
+	code, report = probe(t, p.Ready)
+	if code != 503 || report.Status != StatusUnavailable || report.Checks[0].Error != "no reachable servers" {
+		t.Errorf("database down: got %d %+v", code, report)
+	}
+
+	code, report = probe(t, p.Live)
+	if code != 200 || report.Status != StatusOK || len(report.Checks) != 0 {
+		t.Errorf("liveness must not depend on checks: got %d %+v", code, report)
+	}
+}
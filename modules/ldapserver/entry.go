@@ -0,0 +1,132 @@
+package ldapserver
+
+import (
+	"strings"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"github.com/eaciit/ldap"
+)
+
+var objectClasses = []string{"top", "person", "organizationalPerson", "inetOrgPerson"}
+
+// phoneAttributes maps the phone types to the inetOrgPerson attribute,
+// anything else is published as telephoneNumber.
+var phoneAttributes = map[string]string{
+	"mobile":    "mobile",
+	"cell":      "mobile",
+	"home":      "homePhone",
+	"fax":       "facsimileTelephoneNumber",
+	"ip phone":  "ipPhone",
+	"pager":     "pager",
+	"telephone": "telephoneNumber",
+}
+
+// Entry maps a contact to an inetOrgPerson entry named uid=<Id>,<baseDN>.
+func Entry(baseDN string, c model.Phonebook) *ldap.Entry {
+	uid := c.Id.Hex()
+	e := ldap.NewEntry("uid=" + uid + "," + baseDN)
+
+	e.AddAttributeValues("objectClass", objectClasses)
+	e.AddAttributeValue("uid", uid)
+	e.AddAttributeValue("cn", strings.TrimSpace(c.FirstName+" "+c.LastName))
+	e.AddAttributeValue("displayName", strings.TrimSpace(c.FirstName+" "+c.LastName))
+	e.AddAttributeValue("givenName", c.FirstName)
+	// sn is mandatory for person
+	if c.LastName != "" {
+		e.AddAttributeValue("sn", c.LastName)
+	} else {
+		e.AddAttributeValue("sn", c.FirstName)
+	}
+
+	for _, m := range c.Emails {
+		e.AddAttributeValue("mail", m.EmailAddress)
+	}
+	if len(c.Emails) == 0 {
+		e.AddAttributeValue("mail", c.Email)
+	}
+
+	for _, p := range c.PhoneNumber {
+		attr, ok := phoneAttributes[strings.ToLower(p.ProneType)]
+		if !ok {
+			attr = "telephoneNumber"
+		}
+		e.AddAttributeValue(attr, p.PhoneNo)
+	}
+
+	e.AddAttributeValue("title", c.JobTitle)
+	e.AddAttributeValue("o", c.Company)
+	e.AddAttributeValue("ou", c.Department)
+	e.AddAttributeValue("labeledURI", c.Website)
+
+	if len(c.Addresses) > 0 {
+		a := c.Addresses[0]
+		e.AddAttributeValue("street", a.Street)
+		e.AddAttributeValue("l", a.City)
+		e.AddAttributeValue("st", a.Region)
+		e.AddAttributeValue("postalCode", a.PostalCode)
+		e.AddAttributeValue("c", a.Country)
+
+		lines := []string{}
+		for _, s := range []string{a.Street, a.City, a.Region, a.PostalCode, a.Country} {
+			if s != "" {
+				lines = append(lines, s)
+			}
+		}
+		e.AddAttributeValue("postalAddress", strings.Join(lines, "$"))
+	}
+
+	return e
+}
+
+// rootDSE describes the server to clients that look it up before searching.
+func rootDSE(baseDN string) *ldap.Entry {
+	e := ldap.NewEntry("")
+	e.AddAttributeValue("objectClass", "top")
+	e.AddAttributeValue("namingContexts", baseDN)
+	e.AddAttributeValue("supportedLDAPVersion", "3")
+	e.AddAttributeValue("vendorName", "phonebook")
+
+	return e
+}
+
+// base describes the container holding the contacts.
+func base(baseDN string) *ldap.Entry {
+	e := ldap.NewEntry(baseDN)
+	e.AddAttributeValues("objectClass", []string{"top", "organizationalUnit"})
+
+	rdn := strings.SplitN(strings.SplitN(baseDN, ",", 2)[0], "=", 2)
+	if len(rdn) == 2 {
+		e.AddAttributeValue(strings.TrimSpace(rdn[0]), strings.TrimSpace(rdn[1]))
+	}
+
+	return e
+}
+
+// project keeps the requested attributes of e, "*" or no attribute
+// means all of them and "1.1" none.
+func project(e *ldap.Entry, attributes []string, typesOnly bool) *ldap.Entry {
+	all := len(attributes) == 0
+	wanted := map[string]bool{}
+	for _, a := range attributes {
+		if a == "*" {
+			all = true
+		}
+		wanted[strings.ToLower(a)] = true
+	}
+
+	res := ldap.NewEntry(e.DN)
+	for _, a := range e.Attributes {
+		if !all && !wanted[strings.ToLower(a.Name)] {
+			continue
+		}
+
+		if typesOnly {
+			res.Attributes = append(res.Attributes, &ldap.EntryAttribute{Name: a.Name})
+		} else {
+			res.Attributes = append(res.Attributes, a)
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,139 @@
+package ldapserver
+
+import (
+	"errors"
+	"strings"
+
+	ber "github.com/eaciit/asn1-ber"
+	"github.com/eaciit/ldap"
+)
+
+// phoneSyntax are the attributes compared with telephoneNumberMatch, which
+// ignores spaces and hyphens so *5550123* finds "+62 21 555-0123".
+var phoneSyntax = map[string]bool{
+	"telephonenumber":          true,
+	"mobile":                   true,
+	"homephone":                true,
+	"facsimiletelephonenumber": true,
+	"ipphone":                  true,
+	"pager":                    true,
+}
+
+var errFilter = errors.New("Invalid search filter")
+
+// Match evaluates a BER encoded search filter against e, every attribute
+// is compared case-insensitively.
+func Match(f *ber.Packet, e *ldap.Entry) (bool, error) {
+	if f.ClassType != ber.ClassContext {
+		return false, errFilter
+	}
+
+	switch f.Tag {
+	case ldap.FilterAnd:
+		for _, c := range f.Children {
+			ok, err := Match(c, e)
+			if err != nil || !ok {
+				return false, err
+			}
+		}
+		return true, nil
+
+	case ldap.FilterOr:
+		for _, c := range f.Children {
+			ok, err := Match(c, e)
+			if err != nil || ok {
+				return ok, err
+			}
+		}
+		return false, nil
+
+	case ldap.FilterNot:
+		if len(f.Children) != 1 {
+			return false, errFilter
+		}
+		ok, err := Match(f.Children[0], e)
+		return !ok, err
+
+	case ldap.FilterPresent:
+		return len(e.GetAttributeValues(f.Data.String())) > 0, nil
+
+	case ldap.FilterEqualityMatch, ldap.FilterApproxMatch, ldap.FilterGreaterOrEqual, ldap.FilterLessOrEqual:
+		if len(f.Children) != 2 {
+			return false, errFilter
+		}
+
+		attr := octets(f.Children[0])
+		want := normalize(attr, octets(f.Children[1]))
+		for _, v := range e.GetAttributeValues(attr) {
+			v = normalize(attr, v)
+			if (f.Tag == ldap.FilterGreaterOrEqual && v >= want) ||
+				(f.Tag == ldap.FilterLessOrEqual && v <= want) ||
+				(f.Tag != ldap.FilterGreaterOrEqual && f.Tag != ldap.FilterLessOrEqual && v == want) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case ldap.FilterSubstrings:
+		if len(f.Children) != 2 {
+			return false, errFilter
+		}
+
+		attr := octets(f.Children[0])
+		for _, v := range e.GetAttributeValues(attr) {
+			if substrings(normalize(attr, v), attr, f.Children[1].Children) {
+				return true, nil
+			}
+		}
+		return false, nil
+
+	case ldap.FilterExtensibleMatch:
+		// matching rules are not supported, RFC 4511 says evaluate to Undefined
+		return false, nil
+	}
+
+	return false, errFilter
+}
+
+func substrings(v, attr string, parts []*ber.Packet) bool {
+	for i, p := range parts {
+		s := normalize(attr, p.Data.String())
+
+		switch p.Tag {
+		case ldap.FilterSubstringsInitial:
+			if i != 0 || !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case ldap.FilterSubstringsAny:
+			n := strings.Index(v, s)
+			if n < 0 {
+				return false
+			}
+			v = v[n+len(s):]
+		case ldap.FilterSubstringsFinal:
+			if i != len(parts)-1 || !strings.HasSuffix(v, s) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func normalize(attr, v string) string {
+	v = strings.ToLower(strings.TrimSpace(v))
+	if phoneSyntax[strings.ToLower(attr)] {
+		v = strings.NewReplacer(" ", "", "-", "").Replace(v)
+	}
+
+	return v
+}
+
+func octets(p *ber.Packet) string {
+	if s, ok := p.Value.(string); ok {
+		return s
+	}
+
+	return p.Data.String()
+}
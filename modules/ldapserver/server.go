@@ -0,0 +1,360 @@
+// Package ldapserver publishes the phonebook as a read-only LDAP directory
+// for desk phones and mail clients that can't use the REST API.
+package ldapserver
+
+import (
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+
+	ber "github.com/eaciit/asn1-ber"
+	"github.com/eaciit/ldap"
+)
+
+// Directory is the part of the phonebook storage the server searches.
+type Directory interface {
+	Contacts() ([]model.Phonebook, error)
+}
+
+type Server struct {
+	Address string
+	// BaseDN is the container of the contact entries, e.g. ou=phonebook,dc=example,dc=com
+	BaseDN    string
+	Directory Directory
+	// Authenticate checks simple binds, nil refuses every named bind.
+	Authenticate func(dn, password string) bool
+	// AllowAnonymous lets clients search without binding first.
+	AllowAnonymous bool
+	// SizeLimit caps the entries of one search, 0 means no limit.
+	SizeLimit int
+	// IdleTimeout closes connections that send nothing for that long.
+	IdleTimeout time.Duration
+
+	lock     sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	closed   bool
+}
+
+// ListenAndServe listens on Address and serves until Close is called.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(l)
+}
+
+// Serve accepts connections on l, it returns nil after Close.
+func (s *Server) Serve(l net.Listener) error {
+	s.lock.Lock()
+	s.listener = l
+	s.conns = map[net.Conn]bool{}
+	s.lock.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.lock.Lock()
+			closed := s.closed
+			s.lock.Unlock()
+
+			if closed {
+				return nil
+			}
+			return err
+		}
+
+		s.lock.Lock()
+		s.conns[conn] = true
+		s.lock.Unlock()
+
+		go s.serve(conn)
+	}
+}
+
+// Close stops the listener and drops the open connections.
+func (s *Server) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closed = true
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Close()
+}
+
+type session struct {
+	conn  net.Conn
+	bound bool
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() {
+		s.lock.Lock()
+		delete(s.conns, conn)
+		s.lock.Unlock()
+		conn.Close()
+	}()
+
+	ss := &session{conn: conn}
+	for {
+		if s.IdleTimeout > 0 {
+			conn.SetReadDeadline(time.Now().Add(s.IdleTimeout))
+		}
+
+		p, err := ber.ReadPacket(conn)
+		if err != nil {
+			if err != io.EOF && !isClosed(err) {
+				logger.Warn("LDAP connection failed", "remote", conn.RemoteAddr().String(), "error", err)
+			}
+			return
+		}
+
+		if len(p.Children) < 2 {
+			return
+		}
+
+		id, ok := p.Children[0].Value.(int64)
+		if !ok {
+			return
+		}
+
+		op := p.Children[1]
+		if op.ClassType != ber.ClassApplication {
+			return
+		}
+
+		var err2 error
+		switch ldap.ApplicationCode(op.Tag) {
+		case ldap.ApplicationBindRequest:
+			err2 = s.bind(ss, id, op)
+		case ldap.ApplicationUnbindRequest:
+			return
+		case ldap.ApplicationSearchRequest:
+			err2 = s.search(ss, id, op)
+		case ldap.ApplicationAbandonRequest:
+			// searches are answered synchronously, nothing to abandon
+		case ldap.ApplicationModifyRequest:
+			err2 = ss.result(id, ldap.ApplicationModifyResponse, ldap.ResultUnwillingToPerform, "", "The directory is read-only")
+		case ldap.ApplicationAddRequest:
+			err2 = ss.result(id, ldap.ApplicationAddResponse, ldap.ResultUnwillingToPerform, "", "The directory is read-only")
+		case ldap.ApplicationDelRequest:
+			err2 = ss.result(id, ldap.ApplicationDelResponse, ldap.ResultUnwillingToPerform, "", "The directory is read-only")
+		case ldap.ApplicationModifyDNRequest:
+			err2 = ss.result(id, ldap.ApplicationModifyDNResponse, ldap.ResultUnwillingToPerform, "", "The directory is read-only")
+		case ldap.ApplicationCompareRequest:
+			err2 = ss.result(id, ldap.ApplicationCompareResponse, ldap.ResultUnwillingToPerform, "", "Compare is not supported")
+		case ldap.ApplicationExtendedRequest:
+			err2 = ss.result(id, ldap.ApplicationExtendedResponse, ldap.ResultProtocolError, "", "Extended operations are not supported")
+		default:
+			return
+		}
+
+		if err2 != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) bind(ss *session, id int64, op *ber.Packet) error {
+	ss.bound = false
+
+	if len(op.Children) < 3 {
+		return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultProtocolError, "", "Malformed bind request")
+	}
+
+	if v, _ := op.Children[0].Value.(int64); v != 3 {
+		return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultProtocolError, "", "Only LDAPv3 is supported")
+	}
+
+	auth := op.Children[2]
+	if auth.ClassType != ber.ClassContext || auth.Tag != 0 {
+		return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultAuthMethodNotSupported, "", "Only simple bind is supported")
+	}
+
+	dn := octets(op.Children[1])
+	password := auth.Data.String()
+
+	// an empty password is an unauthenticated bind whatever the name
+	if password == "" {
+		if !s.AllowAnonymous {
+			return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultInappropriateAuthentication, "", "Anonymous bind is disabled")
+		}
+		return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultSuccess, "", "")
+	}
+
+	if s.Authenticate == nil || !s.Authenticate(dn, password) {
+		return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultInvalidCredentials, "", "Invalid credentials")
+	}
+
+	ss.bound = true
+	return ss.result(id, ldap.ApplicationBindResponse, ldap.ResultSuccess, "", "")
+}
+
+func (s *Server) search(ss *session, id int64, op *ber.Packet) error {
+	if !ss.bound && !s.AllowAnonymous {
+		return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultInsufficientAccessRights, "", "Bind required")
+	}
+
+	if len(op.Children) < 8 {
+		return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultProtocolError, "", "Malformed search request")
+	}
+
+	baseObject := octets(op.Children[0])
+	scope, _ := op.Children[1].Value.(int64)
+	sizeLimit, _ := op.Children[3].Value.(int64)
+	typesOnly, _ := op.Children[5].Value.(bool)
+	filter := op.Children[6]
+
+	attributes := []string{}
+	for _, a := range op.Children[7].Children {
+		attributes = append(attributes, octets(a))
+	}
+
+	candidates, code, err := s.candidates(baseObject, ldap.Scope(scope))
+	if err != nil {
+		logger.Error("LDAP search failed", "error", err)
+		return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultOperationsError, "", "Unable to read the phonebook")
+	}
+	if code != ldap.ResultSuccess {
+		return ss.result(id, ldap.ApplicationSearchResultDone, code, "", "")
+	}
+
+	limit := s.SizeLimit
+	if sizeLimit > 0 && (limit == 0 || int(sizeLimit) < limit) {
+		limit = int(sizeLimit)
+	}
+
+	sent := 0
+	for _, e := range candidates {
+		ok, err := Match(filter, e)
+		if err != nil {
+			return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultProtocolError, "", err.Error())
+		}
+		if !ok {
+			continue
+		}
+
+		if limit > 0 && sent == limit {
+			return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultSizeLimitExceeded, "", "")
+		}
+
+		if err := ss.entry(id, project(e, attributes, typesOnly)); err != nil {
+			return err
+		}
+		sent++
+	}
+
+	return ss.result(id, ldap.ApplicationSearchResultDone, ldap.ResultSuccess, "", "")
+}
+
+// candidates lists the entries in scope of baseObject before filtering.
+func (s *Server) candidates(baseObject string, scope ldap.Scope) ([]*ldap.Entry, ldap.ResultCode, error) {
+	dn := normalizeDN(baseObject)
+	root := normalizeDN(s.BaseDN)
+
+	if dn == "" && scope == ldap.ScopeBaseObject {
+		return []*ldap.Entry{rootDSE(s.BaseDN)}, ldap.ResultSuccess, nil
+	}
+
+	if dn != "" && dn != root && !strings.HasSuffix(dn, ","+root) {
+		return nil, ldap.ResultNoSuchObject, nil
+	}
+
+	if dn == root && scope == ldap.ScopeBaseObject {
+		return []*ldap.Entry{base(s.BaseDN)}, ldap.ResultSuccess, nil
+	}
+
+	contacts, err := s.Directory.Contacts()
+	if err != nil {
+		return nil, ldap.ResultOperationsError, err
+	}
+
+	entries := []*ldap.Entry{}
+	if dn == "" || dn == root {
+		if scope == ldap.ScopeWholeSubtree {
+			entries = append(entries, base(s.BaseDN))
+		}
+		for _, c := range contacts {
+			entries = append(entries, Entry(s.BaseDN, c))
+		}
+		return entries, ldap.ResultSuccess, nil
+	}
+
+	// a single contact, which has no children
+	for _, c := range contacts {
+		e := Entry(s.BaseDN, c)
+		if normalizeDN(e.DN) == dn {
+			if scope == ldap.ScopeSingleLevel {
+				return entries, ldap.ResultSuccess, nil
+			}
+			return []*ldap.Entry{e}, ldap.ResultSuccess, nil
+		}
+	}
+
+	return nil, ldap.ResultNoSuchObject, nil
+}
+
+func (ss *session) result(id int64, app ldap.ApplicationCode, code ldap.ResultCode, matchedDN, diagnostic string) error {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(app), nil, "Response")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, matchedDN, "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, diagnostic, "Diagnostic Message"))
+
+	return ss.write(id, op)
+}
+
+func (ss *session) entry(id int64, e *ldap.Entry) error {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ldap.ApplicationSearchResultEntry), nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "Object Name"))
+
+	attrs := ber.NewSequence("Attributes")
+	for _, a := range e.Attributes {
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.Name, "Type"))
+
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range a.Values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+
+	return ss.write(id, op)
+}
+
+func (ss *session) write(id int64, op *ber.Packet) error {
+	p := ber.NewSequence("LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+
+	_, err := ss.conn.Write(p.Bytes())
+	return err
+}
+
+func normalizeDN(dn string) string {
+	parts := strings.Split(dn, ",")
+	for i, p := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(p))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+func isClosed(err error) bool {
+	return strings.Contains(err.Error(), "use of closed network connection")
+}
@@ -0,0 +1,140 @@
+package ldapserver
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"github.com/eaciit/ldap"
+	"gopkg.in/mgo.v2/bson"
+)
+
+const baseDN = "ou=phonebook,dc=example,dc=com"
+
+type contacts []model.Phonebook
+
+func (c contacts) Contacts() ([]model.Phonebook, error) {
+	return c, nil
+}
+
+func testServer(t *testing.T, anonymous bool) (*Server, string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &Server{
+		BaseDN: baseDN,
+		Directory: contacts{
+			{Id: bson.NewObjectId(), FirstName: "Tias", LastName: "Faluthi", Company: "Eaciit",
+				Emails:      []model.EmailDetail{{EmailAddress: "tias@example.com", EmailType: model.EmailTypeWork}},
+				PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "+62 21 555-0123", ProneType: "Work"}, {PhoneNo: "0812 999", ProneType: "Mobile"}}},
+			{Id: bson.NewObjectId(), FirstName: "Agil", LastName: "Dwi", Company: "Eaciit",
+				PhoneNumber: []model.PhoneNumberDetail{{PhoneNo: "+62 21 555 0456", ProneType: "Work"}}},
+			{Id: bson.NewObjectId(), FirstName: "Budi", LastName: "Santoso", Company: "Other"},
+		},
+		Authenticate: func(dn, password string) bool {
+			return dn == "cn=phone,dc=example,dc=com" && password == "secret"
+		},
+		AllowAnonymous: anonymous,
+	}
+	go s.Serve(l)
+
+	return s, l.Addr().String()
+}
+
+func dial(t *testing.T, addr string) *ldap.Connection {
+	conn := ldap.NewConnection(addr)
+	conn.NetworkConnectTimeout = 5 * time.Second
+	conn.ReadTimeout = 5 * time.Second
+	if err := conn.Connect(); err != nil {
+		t.Fatal(err)
+	}
+
+	return conn
+}
+
+func names(res *ldap.SearchResult) []string {
+	n := []string{}
+	for _, e := range res.Entries {
+		n = append(n, e.GetAttributeValue("cn"))
+	}
+	return n
+}
+
+func TestSearch(t *testing.T) {
+	s, addr := testServer(t, true)
+	defer s.Close()
+
+	conn := dial(t, addr)
+	defer conn.Close()
+
+	cases := []struct {
+		filter string
+		want   int
+	}{
+		{"(objectClass=inetOrgPerson)", 3},
+		{"(|(cn=*tias*)(telephoneNumber=*0456*))", 2},
+		{"(telephoneNumber=*5550123*)", 1},
+		{"(&(o=eaciit)(!(sn=Dwi)))", 1},
+		{"(mail=*)", 1},
+		{"(cn=Tia*)", 1},
+		{"(cn=*nomatch*)", 0},
+	}
+
+	for _, c := range cases {
+		res, err := conn.Search(ldap.NewSimpleSearchRequest(baseDN, ldap.ScopeWholeSubtree, "(&(objectClass=person)"+c.filter+")", []string{"cn", "mail"}))
+		if err != nil {
+			t.Fatalf("%s: %s", c.filter, err.Error())
+		}
+
+		if len(res.Entries) != c.want {
+			t.Errorf("%s: expected %d entries, got %v", c.filter, c.want, names(res))
+		}
+	}
+
+	res, err := conn.Search(ldap.NewSimpleSearchRequest(baseDN, ldap.ScopeSingleLevel, "(cn=Tias Faluthi)", []string{"cn", "mobile"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Entries) != 1 || res.Entries[0].GetAttributeValue("mobile") != "0812 999" || res.Entries[0].GetAttributeValue("mail") != "" {
+		t.Errorf("unexpected entries %v", res.Entries)
+	}
+
+	_, err = conn.Search(ldap.NewSimpleSearchRequest("dc=other", ldap.ScopeWholeSubtree, "(cn=*)", nil))
+	if err == nil {
+		t.Error("expected no such object outside the base DN")
+	}
+}
+
+func TestBind(t *testing.T) {
+	s, addr := testServer(t, false)
+	defer s.Close()
+
+	conn := dial(t, addr)
+	defer conn.Close()
+
+	if _, err := conn.Search(ldap.NewSimpleSearchRequest(baseDN, ldap.ScopeWholeSubtree, "(cn=*)", nil)); err == nil {
+		t.Error("expected search without bind to fail")
+	}
+
+	if err := conn.Bind("cn=phone,dc=example,dc=com", "wrong"); err == nil {
+		t.Error("expected invalid credentials")
+	}
+
+	if err := conn.Bind("cn=phone,dc=example,dc=com", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := conn.Search(ldap.NewSimpleSearchRequest(baseDN, ldap.ScopeWholeSubtree, "(cn=*)", nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Entries) != 3 {
+		t.Errorf("expected 3 entries, got %v", names(res))
+	}
+}
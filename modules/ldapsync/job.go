@@ -0,0 +1,84 @@
+package ldapsync
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+// Job runs Sync every Interval and keeps the outcome of the last run.
+type Job struct {
+	Config   func() Config
+	Store    Store
+	Interval time.Duration
+
+	lock    sync.Mutex
+	running bool
+	last    *Diff
+	lastErr error
+}
+
+func NewJob(config func() Config, store Store, interval time.Duration) *Job {
+	return &Job{Config: config, Store: store, Interval: interval}
+}
+
+// Run syncs once, it refuses to start while another run is in progress.
+func (j *Job) Run(dryRun bool) (*Diff, error) {
+	j.lock.Lock()
+	if j.running {
+		j.lock.Unlock()
+		return nil, fmt.Errorf("LDAP sync is already running")
+	}
+	j.running = true
+	j.lock.Unlock()
+
+	cfg := j.Config()
+	cfg.DryRun = dryRun
+	diff, err := Sync(cfg, j.Store)
+
+	j.lock.Lock()
+	j.running = false
+	if !dryRun {
+		j.last, j.lastErr = diff, err
+	}
+	j.lock.Unlock()
+
+	return diff, err
+}
+
+// Last returns the outcome of the last run that was not a dry run.
+func (j *Job) Last() (*Diff, error) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	return j.last, j.lastErr
+}
+
+// Start runs the job every Interval until stop is closed. A zero Interval
+// disables the schedule, leaving only manual runs.
+func (j *Job) Start(stop <-chan struct{}) {
+	if j.Interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(j.Interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				diff, err := j.Run(false)
+				if err != nil {
+					logger.Error("LDAP sync failed", "error", err)
+				} else if diff.Warning != "" {
+					logger.Warn("LDAP sync withheld deactivations", "warning", diff.Warning, "contacts", len(diff.Withheld))
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
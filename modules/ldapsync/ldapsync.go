@@ -0,0 +1,402 @@
+package ldapsync
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"github.com/eaciit/ldap"
+)
+
+// Source is the ExternalSource of the contacts maintained by the sync.
+const Source = "ldap"
+
+// Attributes maps contact fields to LDAP attribute names, an empty name
+// leaves the field alone.
+type Attributes struct {
+	FirstName  string
+	LastName   string
+	FullName   string
+	Email      string
+	Phone      string
+	Mobile     string
+	IpPhone    string
+	JobTitle   string
+	Department string
+	Company    string
+}
+
+var DefaultAttributes = Attributes{
+	FirstName:  "givenName",
+	LastName:   "sn",
+	FullName:   "cn",
+	Email:      "mail",
+	Phone:      "telephoneNumber",
+	Mobile:     "mobile",
+	IpPhone:    "ipPhone",
+	JobTitle:   "title",
+	Department: "department",
+	Company:    "company",
+}
+
+func (a Attributes) names() []string {
+	names := []string{}
+	for _, n := range []string{a.FirstName, a.LastName, a.FullName, a.Email, a.Phone, a.Mobile, a.IpPhone, a.JobTitle, a.Department, a.Company} {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+
+	return names
+}
+
+type Config struct {
+	Address      string
+	BindDN       string
+	BindPassword string
+	BaseDN       string
+	Filter       string
+	Attributes   Attributes
+	// PageSize uses the paged results control when above zero, which
+	// Active Directory needs for more than 1000 entries.
+	PageSize int
+	Timeout  time.Duration
+	// DryRun computes the Diff without saving anything.
+	DryRun bool
+	// MaxDeactivate is the largest share of the active synced contacts a run
+	// may deactivate, DefaultMaxDeactivate when zero and no limit from 1.
+	MaxDeactivate float64
+}
+
+// DefaultMaxDeactivate keeps a wrong filter or base DN from emptying the
+// directory, see Config.MaxDeactivate.
+const DefaultMaxDeactivate = 0.2
+
+// Store is the part of the phonebook storage the sync works on.
+type Store interface {
+	FindBySource(source string) ([]model.Phonebook, error)
+	Save(m *model.Phonebook) error
+}
+
+type Change struct {
+	ExternalId string
+	Name       string
+	Fields     []string `json:",omitempty"`
+}
+
+type Diff struct {
+	Created     []Change
+	Updated     []Change
+	Deactivated []Change
+	Skipped     []Change
+	// Withheld are the contacts whose entry disappeared but were left
+	// active, Warning tells why.
+	Withheld  []Change
+	Warning   string `json:",omitempty"`
+	Unchanged int
+	DryRun    bool
+	Started   time.Time
+	Finished  time.Time
+}
+
+func newDiff(dryRun bool) *Diff {
+	return &Diff{
+		Created:     []Change{},
+		Updated:     []Change{},
+		Deactivated: []Change{},
+		Skipped:     []Change{},
+		Withheld:    []Change{},
+		DryRun:      dryRun,
+		Started:     time.Now(),
+	}
+}
+
+// Search reads the entries below BaseDN matching Filter.
+func Search(cfg Config) ([]*ldap.Entry, error) {
+	if cfg.Address == "" || cfg.BaseDN == "" {
+		return nil, errors.New("LDAP address and base DN are required")
+	}
+
+	filter := cfg.Filter
+	if filter == "" {
+		filter = "(objectClass=person)"
+	}
+
+	conn := ldap.NewConnection(cfg.Address)
+	conn.NetworkConnectTimeout = cfg.Timeout
+	conn.ReadTimeout = cfg.Timeout
+	if err := conn.Connect(); err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if cfg.BindDN != "" {
+		if err := conn.Bind(cfg.BindDN, cfg.BindPassword); err != nil {
+			return nil, err
+		}
+	}
+
+	req := ldap.NewSimpleSearchRequest(cfg.BaseDN, ldap.ScopeWholeSubtree, filter, cfg.Attributes.names())
+
+	var res *ldap.SearchResult
+	var err error
+	if cfg.PageSize > 0 {
+		res, err = conn.SearchWithPaging(req, uint32(cfg.PageSize))
+	} else {
+		res, err = conn.Search(req)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Entries, nil
+}
+
+// Sync searches the directory and applies the entries to store, see Apply.
+func Sync(cfg Config, store Store) (*Diff, error) {
+	entries, err := Search(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return Apply(cfg, entries, store)
+}
+
+// Apply creates a contact for every new entry, updates the mapped fields of
+// known ones and deactivates synced contacts whose entry disappeared. It
+// withholds the deactivations when the search found nothing or when they
+// exceed MaxDeactivate, a directory hiccup must not empty the phonebook.
+func Apply(cfg Config, entries []*ldap.Entry, store Store) (*Diff, error) {
+	diff := newDiff(cfg.DryRun)
+
+	existing, err := store.FindBySource(Source)
+	if err != nil {
+		return nil, err
+	}
+
+	byId := map[string]*model.Phonebook{}
+	for i := range existing {
+		byId[strings.ToLower(existing[i].ExternalId)] = &existing[i]
+	}
+
+	seen := map[string]bool{}
+	for _, entry := range entries {
+		key := strings.ToLower(entry.DN)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		mapped := MapEntry(cfg.Attributes, entry)
+		if mapped.FirstName == "" || mapped.LastName == "" {
+			diff.Skipped = append(diff.Skipped, Change{ExternalId: entry.DN, Fields: []string{"FirstName", "LastName"}})
+			continue
+		}
+
+		contact, found := byId[key]
+		if !found {
+			diff.Created = append(diff.Created, change(mapped, nil))
+			if err := save(cfg, store, mapped); err != nil {
+				return diff, err
+			}
+			continue
+		}
+
+		fields := merge(contact, mapped)
+		if len(fields) == 0 {
+			diff.Unchanged++
+			continue
+		}
+
+		diff.Updated = append(diff.Updated, change(contact, fields))
+		if err := save(cfg, store, contact); err != nil {
+			return diff, err
+		}
+	}
+
+	active, gone := 0, []*model.Phonebook{}
+	for i := range existing {
+		contact := &existing[i]
+		if contact.Status == model.StatusInactive {
+			continue
+		}
+		active++
+		if !seen[strings.ToLower(contact.ExternalId)] {
+			gone = append(gone, contact)
+		}
+	}
+
+	maxShare := cfg.MaxDeactivate
+	if maxShare <= 0 {
+		maxShare = DefaultMaxDeactivate
+	}
+	switch {
+	case len(gone) > 0 && len(entries) == 0:
+		diff.Warning = "The search found no entries, no contact was deactivated"
+	case float64(len(gone)) > maxShare*float64(active):
+		diff.Warning = fmt.Sprintf("%d of %d contacts disappeared, more than the %g%% allowed, no contact was deactivated", len(gone), active, maxShare*100)
+	}
+	if diff.Warning != "" {
+		for _, contact := range gone {
+			diff.Withheld = append(diff.Withheld, change(contact, nil))
+		}
+		gone = nil
+	}
+
+	for _, contact := range gone {
+		contact.Status = model.StatusInactive
+		diff.Deactivated = append(diff.Deactivated, change(contact, []string{"Status"}))
+		if err := save(cfg, store, contact); err != nil {
+			return diff, err
+		}
+	}
+
+	diff.Finished = time.Now()
+
+	return diff, nil
+}
+
+func save(cfg Config, store Store, m *model.Phonebook) error {
+	if cfg.DryRun {
+		return nil
+	}
+
+	m.UpdateBy = Source
+	if m.Id == "" {
+		m.CreatedBy = Source
+	}
+
+	return store.Save(m)
+}
+
+func change(m *model.Phonebook, fields []string) Change {
+	return Change{
+		ExternalId: m.ExternalId,
+		Name:       strings.TrimSpace(m.FirstName + " " + m.LastName),
+		Fields:     fields,
+	}
+}
+
+// MapEntry builds a contact from the mapped attributes of entry.
+func MapEntry(attrs Attributes, entry *ldap.Entry) *model.Phonebook {
+	get := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		return strings.TrimSpace(entry.GetAttributeValue(name))
+	}
+
+	m := &model.Phonebook{
+		FirstName:      get(attrs.FirstName),
+		LastName:       get(attrs.LastName),
+		JobTitle:       get(attrs.JobTitle),
+		Department:     get(attrs.Department),
+		Company:        get(attrs.Company),
+		Status:         model.StatusActive,
+		ExternalSource: Source,
+		ExternalId:     entry.DN,
+		PhoneNumber:    []model.PhoneNumberDetail{},
+		Emails:         []model.EmailDetail{},
+	}
+
+	// single word names such as service accounts only have a cn
+	if m.FirstName == "" && m.LastName != "" {
+		m.FirstName = get(attrs.FullName)
+	}
+
+	phones := []struct{ attr, kind string }{
+		{attrs.Phone, "Work"},
+		{attrs.Mobile, "Mobile"},
+		{attrs.IpPhone, "IP Phone"},
+	}
+	for _, p := range phones {
+		if p.attr == "" {
+			continue
+		}
+		for _, no := range entry.GetAttributeValues(p.attr) {
+			if no = strings.TrimSpace(no); no != "" {
+				m.PhoneNumber = append(m.PhoneNumber, model.PhoneNumberDetail{PhoneNo: no, ProneType: p.kind})
+			}
+		}
+	}
+
+	if attrs.Email != "" {
+		for _, mail := range entry.GetAttributeValues(attrs.Email) {
+			if mail = strings.TrimSpace(mail); mail != "" {
+				m.Emails = append(m.Emails, model.EmailDetail{EmailAddress: mail, EmailType: model.EmailTypeWork})
+			}
+		}
+	}
+	m.NormalizeEmail()
+
+	return m
+}
+
+// merge copies the directory owned fields of src into dst and returns the
+// names of the fields that changed.
+func merge(dst, src *model.Phonebook) []string {
+	fields := []string{}
+
+	strs := []struct {
+		name     string
+		dst, src *string
+	}{
+		{"FirstName", &dst.FirstName, &src.FirstName},
+		{"LastName", &dst.LastName, &src.LastName},
+		{"JobTitle", &dst.JobTitle, &src.JobTitle},
+		{"Department", &dst.Department, &src.Department},
+		{"Company", &dst.Company, &src.Company},
+		{"Status", &dst.Status, &src.Status},
+		{"ExternalId", &dst.ExternalId, &src.ExternalId},
+	}
+	for _, f := range strs {
+		if *f.dst != *f.src {
+			*f.dst = *f.src
+			fields = append(fields, f.name)
+		}
+	}
+
+	if !samePhones(dst.PhoneNumber, src.PhoneNumber) {
+		dst.PhoneNumber = src.PhoneNumber
+		fields = append(fields, "PhoneNumber")
+	}
+
+	if !sameEmails(dst.Emails, src.Emails) {
+		dst.Emails = src.Emails
+		dst.Email = src.Email
+		fields = append(fields, "Emails")
+	}
+
+	return fields
+}
+
+func samePhones(a, b []model.PhoneNumberDetail) bool {
+	key := func(l []model.PhoneNumberDetail) string {
+		s := []string{}
+		for _, p := range l {
+			s = append(s, p.ProneType+":"+p.PhoneNo+":"+p.PhoneExt)
+		}
+		sort.Strings(s)
+		return strings.Join(s, "|")
+	}
+
+	return key(a) == key(b)
+}
+
+func sameEmails(a, b []model.EmailDetail) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if !strings.EqualFold(a[i].EmailAddress, b[i].EmailAddress) || a[i].EmailType != b[i].EmailType {
+			return false
+		}
+	}
+
+	return true
+}
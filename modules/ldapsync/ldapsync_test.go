@@ -0,0 +1,255 @@
+package ldapsync
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	ber "github.com/eaciit/asn1-ber"
+	"github.com/eaciit/ldap"
+	"gopkg.in/mgo.v2/bson"
+)
+
+// standIn is a minimal LDAP server answering every bind with success and
+// every search with all of its entries.
+type standIn struct {
+	listener net.Listener
+	entries  []*ldap.Entry
+}
+
+func newStandIn(t *testing.T, entries []*ldap.Entry) *standIn {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &standIn{listener: l, entries: entries}
+	go s.serve()
+
+	return s
+}
+
+func (s *standIn) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *standIn) handle(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		p, err := ber.ReadPacket(conn)
+		if err != nil || len(p.Children) < 2 {
+			return
+		}
+
+		id := p.Children[0].Value.(int64)
+		switch ldap.ApplicationCode(p.Children[1].Tag) {
+		case ldap.ApplicationBindRequest:
+			conn.Write(result(id, ldap.ApplicationBindResponse).Bytes())
+		case ldap.ApplicationSearchRequest:
+			for _, e := range s.entries {
+				conn.Write(entry(id, e).Bytes())
+			}
+			conn.Write(result(id, ldap.ApplicationSearchResultDone).Bytes())
+		default:
+			return
+		}
+	}
+}
+
+func envelope(id int64, op *ber.Packet) *ber.Packet {
+	p := ber.NewSequence("LDAP Response")
+	p.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	p.AppendChild(op)
+	return p
+}
+
+func result(id int64, app ldap.ApplicationCode) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(app), nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, 0, "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic"))
+	return envelope(id, op)
+}
+
+func entry(id int64, e *ldap.Entry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ber.Tag(ldap.ApplicationSearchResultEntry), nil, "Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, e.DN, "DN"))
+
+	attrs := ber.NewSequence("Attributes")
+	for _, a := range e.Attributes {
+		attr := ber.NewSequence("Attribute")
+		attr.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, a.Name, "Name"))
+		vals := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, v := range a.Values {
+			vals.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, v, "Value"))
+		}
+		attr.AppendChild(vals)
+		attrs.AppendChild(attr)
+	}
+	op.AppendChild(attrs)
+
+	return envelope(id, op)
+}
+
+func person(dn, given, sn, mail, phone string) *ldap.Entry {
+	e := ldap.NewEntry(dn)
+	e.AddAttributeValue("givenName", given)
+	e.AddAttributeValue("sn", sn)
+	e.AddAttributeValue("mail", mail)
+	e.AddAttributeValue("telephoneNumber", phone)
+	return e
+}
+
+type memoryStore struct {
+	contacts []model.Phonebook
+}
+
+func (m *memoryStore) FindBySource(source string) ([]model.Phonebook, error) {
+	res := []model.Phonebook{}
+	for _, c := range m.contacts {
+		if c.ExternalSource == source {
+			res = append(res, c)
+		}
+	}
+	return res, nil
+}
+
+func (m *memoryStore) Save(c *model.Phonebook) error {
+	if c.Id == "" {
+		c.Id = bson.NewObjectId()
+	}
+	for i := range m.contacts {
+		if m.contacts[i].Id == c.Id {
+			m.contacts[i] = *c
+			return nil
+		}
+	}
+	m.contacts = append(m.contacts, *c)
+	return nil
+}
+
+func TestSync(t *testing.T) {
+	server := newStandIn(t, []*ldap.Entry{
+		person("cn=tias,ou=people,dc=example,dc=com", "Tias", "Faluthi", "tias@example.com", "+62 21 555 01"),
+		person("cn=agil,ou=people,dc=example,dc=com", "Agil", "D", "agil@example.com", "+62 21 555 02"),
+	})
+	defer server.listener.Close()
+
+	cfg := Config{
+		Address:    server.listener.Addr().String(),
+		BindDN:     "cn=sync,dc=example,dc=com",
+		BaseDN:     "ou=people,dc=example,dc=com",
+		Attributes: DefaultAttributes,
+		Timeout:    5 * time.Second,
+		// agil leaving is half of the directory
+		MaxDeactivate: 0.5,
+	}
+
+	store := &memoryStore{}
+	diff, err := Sync(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Created) != 2 || len(store.contacts) != 2 {
+		t.Fatalf("expected 2 created contacts, got %+v", diff)
+	}
+
+	if c := store.contacts[0]; c.Email != "tias@example.com" || len(c.PhoneNumber) != 1 || c.PhoneNumber[0].ProneType != "Work" {
+		t.Errorf("unexpected mapping %+v", c)
+	}
+
+	// agil leaves, tias changes number
+	server.entries = []*ldap.Entry{
+		person("cn=tias,ou=people,dc=example,dc=com", "Tias", "Faluthi", "tias@example.com", "+62 21 555 09"),
+	}
+
+	diff, err = Sync(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Created) != 0 || len(diff.Updated) != 1 || len(diff.Deactivated) != 1 {
+		t.Fatalf("unexpected diff %+v", diff)
+	}
+
+	if diff.Updated[0].Fields[0] != "PhoneNumber" {
+		t.Errorf("expected PhoneNumber to change, got %v", diff.Updated[0].Fields)
+	}
+
+	if store.contacts[1].Status != model.StatusInactive {
+		t.Errorf("expected agil to be inactive, got %q", store.contacts[1].Status)
+	}
+
+	diff, err = Sync(cfg, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if diff.Unchanged != 1 || len(diff.Updated)+len(diff.Deactivated) != 0 {
+		t.Errorf("expected nothing to change, got %+v", diff)
+	}
+}
+
+func TestSyncDryRun(t *testing.T) {
+	store := &memoryStore{}
+	entries := []*ldap.Entry{person("cn=tias,dc=example,dc=com", "Tias", "Faluthi", "", "1")}
+
+	diff, err := Apply(Config{Attributes: DefaultAttributes, DryRun: true}, entries, store)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(diff.Created) != 1 || len(store.contacts) != 0 {
+		t.Errorf("dry run should report without saving, got %+v", diff)
+	}
+}
+
+func TestSyncWithholdsDeactivation(t *testing.T) {
+	entries := []*ldap.Entry{}
+	for _, name := range []string{"tias", "agil", "budi", "sari", "dewi"} {
+		entries = append(entries, person("cn="+name+",dc=example,dc=com", name, "X", "", "1"))
+	}
+
+	store := &memoryStore{}
+	if _, err := Apply(Config{Attributes: DefaultAttributes}, entries, store); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		entries  []*ldap.Entry
+		max      float64
+		withheld int
+	}{
+		{"empty search", nil, 1, 5},
+		{"two of five", entries[2:], 0, 2},
+		{"two of five allowed", entries[2:], 0.4, 0},
+	}
+	for _, c := range cases {
+		saved := append([]model.Phonebook{}, store.contacts...)
+		diff, err := Apply(Config{Attributes: DefaultAttributes, MaxDeactivate: c.max}, c.entries, &memoryStore{contacts: saved})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(diff.Withheld) != c.withheld || (c.withheld > 0) != (diff.Warning != "") {
+			t.Errorf("%s: withheld %d, warning %q", c.name, len(diff.Withheld), diff.Warning)
+		}
+		if c.withheld > 0 && len(diff.Deactivated) != 0 {
+			t.Errorf("%s: deactivated %v", c.name, diff.Deactivated)
+		}
+		if c.withheld == 0 && len(diff.Deactivated) != 2 {
+			t.Errorf("%s: deactivated %v", c.name, diff.Deactivated)
+		}
+	}
+}
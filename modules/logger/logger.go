@@ -0,0 +1,149 @@
+// Package logger writes leveled log entries as JSON lines, with phone
+// numbers and email addresses redacted.
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return "unknown"
+	}
+
+	return levelNames[l]
+}
+
+// ParseLevel reads debug, info, warn or error, anything else is info.
+func ParseLevel(s string) Level {
+	for i, name := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(s), name) {
+			return Level(i)
+		}
+	}
+
+	return LevelInfo
+}
+
+// Logger writes the entries at or above its level to out, one JSON object
+// per line with time, level, msg and the key value pairs of the entry.
+type Logger struct {
+	out   *output
+	level Level
+	// fields are added to every entry, see With
+	fields []interface{}
+	// Now is the clock, replaced by tests
+	Now func() time.Time
+}
+
+// output is shared by a logger and the loggers derived with With
+type output struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+func New(w io.Writer, level Level) *Logger {
+	return &Logger{out: &output{w: w}, level: level, Now: time.Now}
+}
+
+// Default is the logger of the application, see SetDefault.
+var Default = New(os.Stderr, LevelInfo)
+
+func SetDefault(l *Logger) {
+	Default = l
+}
+
+// With returns a logger adding the key value pairs to every entry.
+func (l *Logger) With(kv ...interface{}) *Logger {
+	c := *l
+	c.fields = append(append([]interface{}{}, l.fields...), kv...)
+
+	return &c
+}
+
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.level
+}
+
+func (l *Logger) Debug(msg string, kv ...interface{}) { l.Log(LevelDebug, msg, kv...) }
+func (l *Logger) Info(msg string, kv ...interface{})  { l.Log(LevelInfo, msg, kv...) }
+func (l *Logger) Warn(msg string, kv ...interface{})  { l.Log(LevelWarn, msg, kv...) }
+func (l *Logger) Error(msg string, kv ...interface{}) { l.Log(LevelError, msg, kv...) }
+
+// Log writes an entry, kv are alternating keys and values. Strings, errors
+// and the strings inside other values are redacted.
+func (l *Logger) Log(level Level, msg string, kv ...interface{}) {
+	if !l.Enabled(level) {
+		return
+	}
+
+	keys := []string{"time", "level", "msg"}
+	entry := map[string]interface{}{
+		"time":  l.Now().UTC().Format(time.RFC3339Nano),
+		"level": level.String(),
+		"msg":   Redact(msg),
+	}
+
+	pairs := append(append([]interface{}{}, l.fields...), kv...)
+	for i := 0; i < len(pairs); i += 2 {
+		key := fmt.Sprint(pairs[i])
+		var value interface{} = "!MISSING"
+		if i+1 < len(pairs) {
+			value = redactValue(pairs[i+1])
+		}
+
+		if _, exists := entry[key]; !exists {
+			keys = append(keys, key)
+		}
+		entry[key] = value
+	}
+
+	line := l.encode(keys, entry)
+
+	l.out.lock.Lock()
+	defer l.out.lock.Unlock()
+	l.out.w.Write(line)
+}
+
+// encode writes the fields in the order logged, encoding/json would sort
+// the keys of a map
+func (l *Logger) encode(keys []string, entry map[string]interface{}) []byte {
+	b := []byte{'{'}
+	for i, key := range keys {
+		if i > 0 {
+			b = append(b, ',')
+		}
+
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(entry[key])
+		if err != nil {
+			v, _ = json.Marshal(err.Error())
+		}
+
+		b = append(append(append(b, k...), ':'), v...)
+	}
+
+	return append(b, '}', '\n')
+}
+
+func Debug(msg string, kv ...interface{}) { Default.Log(LevelDebug, msg, kv...) }
+func Info(msg string, kv ...interface{})  { Default.Log(LevelInfo, msg, kv...) }
+func Warn(msg string, kv ...interface{})  { Default.Log(LevelWarn, msg, kv...) }
+func Error(msg string, kv ...interface{}) { Default.Log(LevelError, msg, kv...) }
@@ -0,0 +1,63 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+	l := New(buf, LevelInfo)
+	l.Now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	l.Debug("hidden")
+	l.With("request_id", "abc").Info("saved", "status", 200, "err", errors.New("call +62 812-3456-7890"), "odd")
+
+	expected := `{"time":"2020-01-02T03:04:05Z","level":"info","msg":"saved","request_id":"abc","status":200,"err":"call ***90","odd":"!MISSING"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("expected %s got %s", expected, buf.String())
+	}
+}
+
+func TestRedact(t *testing.T) {
+	cases := map[string]string{
+		"mail tias@example.co.id now":       "mail ***@example.co.id now",
+		"+62 812-3456-7890":                 "***90",
+		"(021) 555 1234 ext":                "(***34 ext",
+		"port 3030 in 2020":                 "port 3030 in 2020",
+		"from 192.168.100.200":              "from 192.168.100.200",
+		"id 507f1f77bcf86cd799439011":       "id 507f1f77bcf86cd799439011",
+		"id 653678901234567890123456":       "id 653678901234567890123456",
+		"since 2026-10-19 ok":               "since 2026-10-19 ok",
+		"at 2026-10-19 08:30:00":            "at 2026-10-19 08:30:00",
+		"on 2026-10-19 0812 3456 7890":      "on 2026-10-19 ***90",
+		"call 0812-3456-7890 on 2026-10-19": "call ***90 on 2026-10-19",
+	}
+
+	for in, out := range cases {
+		if got := Redact(in); got != out {
+			t.Errorf("%q: expected %q, got %q", in, out, got)
+		}
+	}
+}
+
+func TestRedactValue(t *testing.T) {
+	contact := struct {
+		Name  string
+		Phone []string
+		Age   int
+	}{"Tias", []string{"0812 3456 7890"}, 30}
+
+	got := redactValue(contact).(map[string]interface{})
+	if got["Name"] != "Tias" || got["Phone"].([]interface{})[0] != "***90" || got["Age"] != float64(30) {
+		t.Errorf("unexpected %+v", got)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	if ParseLevel("WARN") != LevelWarn || ParseLevel("") != LevelInfo || ParseLevel("debug") != LevelDebug {
+		t.Error("levels not parsed")
+	}
+}
@@ -0,0 +1,109 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@([A-Za-z0-9-]+\.)+[A-Za-z]{2,}`)
+	// phone numbers are digit runs broken by spaces, hyphens or parentheses,
+	// dots are left alone so addresses and versions survive
+	phonePattern = regexp.MustCompile(`(\+|\b)\d[\d ()-]{5,}\d\b`)
+	// datePattern finds the dates of timestamps inside a phonePattern match
+	datePattern = regexp.MustCompile(`(^|[^\d])(\d{4}-\d{2}-\d{2})([^\d]|$)`)
+)
+
+const (
+	// minPhoneDigits keeps short numbers such as ports, counts and years
+	minPhoneDigits = 7
+	// maxPhoneDigits keeps longer ids, E.164 numbers have at most 15 digits
+	maxPhoneDigits = 15
+)
+
+// Redact masks the email addresses and phone numbers in s, keeping the
+// domain of addresses and the last two digits of numbers. Dates and
+// numbers too short or too long for a phone number are kept.
+func Redact(s string) string {
+	s = emailPattern.ReplaceAllStringFunc(s, func(m string) string {
+		return "***" + m[strings.LastIndex(m, "@"):]
+	})
+
+	return redactPhones(s)
+}
+
+func redactPhones(s string) string {
+	return phonePattern.ReplaceAllStringFunc(s, redactPhone)
+}
+
+// redactPhone masks a phonePattern match, dates in it are kept and the
+// text around them is checked on its own.
+func redactPhone(m string) string {
+	if loc := datePattern.FindStringSubmatchIndex(m); loc != nil {
+		return redactPhones(m[:loc[4]]) + m[loc[4]:loc[5]] + redactPhones(m[loc[5]:])
+	}
+
+	digits := 0
+	for _, c := range m {
+		if c >= '0' && c <= '9' {
+			digits++
+		}
+	}
+
+	if digits < minPhoneDigits || digits > maxPhoneDigits {
+		return m
+	}
+
+	return "***" + m[len(m)-2:]
+}
+
+// redactValue redacts the strings of v, values other than numbers, bools
+// and times go through their JSON form.
+func redactValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case nil, bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return t
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case time.Duration:
+		return t.String()
+	case string:
+		return Redact(t)
+	case error:
+		return Redact(t.Error())
+	case fmt.Stringer:
+		return Redact(t.String())
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return Redact(fmt.Sprintf("%+v", v))
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return Redact(string(b))
+	}
+
+	return redactDecoded(decoded)
+}
+
+func redactDecoded(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return Redact(t)
+	case []interface{}:
+		for i := range t {
+			t[i] = redactDecoded(t[i])
+		}
+	case map[string]interface{}:
+		for k := range t {
+			t[k] = redactDecoded(t[k])
+		}
+	}
+
+	return v
+}
@@ -0,0 +1,312 @@
+// Package metrics keeps counters, histograms and gauges and serves them in
+// the Prometheus text exposition format.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ContentType is the Prometheus text format version 0.0.4.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets suit latencies in seconds, from 5ms to 10s.
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Sample is a value with the values of the labels of its metric.
+type Sample struct {
+	Labels []string
+	Value  float64
+}
+
+type collector interface {
+	write(w *bufio.Writer)
+}
+
+// Registry holds the metrics of the application and writes them in the
+// order they were created.
+type Registry struct {
+	lock       sync.Mutex
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Default is the registry served on /metrics.
+var Default = NewRegistry()
+
+func (r *Registry) add(c collector) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.collectors = append(r.collectors, c)
+}
+
+// Write writes every metric in the text format.
+func (r *Registry) Write(w io.Writer) error {
+	r.lock.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.lock.Unlock()
+
+	b := bufio.NewWriter(w)
+	for _, c := range collectors {
+		c.write(b)
+	}
+
+	return b.Flush()
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+// desc is the name, help and label names shared by every kind of metric
+type desc struct {
+	name   string
+	help   string
+	labels []string
+}
+
+func (d desc) header(w *bufio.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, escapeHelp(d.help), d.name, kind)
+}
+
+// series writes one line, extra is appended to the labels (le of buckets)
+func (d desc) series(w *bufio.Writer, suffix string, values []string, extra string, v float64) {
+	w.WriteString(d.name + suffix)
+
+	pairs := []string{}
+	for i, l := range d.labels {
+		value := ""
+		if i < len(values) {
+			value = values[i]
+		}
+		pairs = append(pairs, l+`="`+escapeLabel(value)+`"`)
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+
+	if len(pairs) > 0 {
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+
+	w.WriteString(" " + formatFloat(v) + "\n")
+}
+
+func (d desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec counts events by the values of its labels.
+type CounterVec struct {
+	desc
+	lock   sync.Mutex
+	values map[string]*Sample
+}
+
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{desc: desc{name, help, labels}, values: map[string]*Sample{}}
+	r.add(c)
+
+	return c
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) Add(v float64, values ...string) {
+	key := c.key(values)
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	s, ok := c.values[key]
+	if !ok {
+		s = &Sample{Labels: append([]string{}, values...)}
+		c.values[key] = s
+	}
+	s.Value += v
+}
+
+// Value is the count for the label values, for tests.
+func (c *CounterVec) Value(values ...string) float64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if s, ok := c.values[c.key(values)]; ok {
+		return s.Value
+	}
+
+	return 0
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		s := c.values[key]
+		c.series(w, "", s.Labels, "", s.Value)
+	}
+}
+
+// HistogramVec counts observations into buckets by the values of its labels.
+type HistogramVec struct {
+	desc
+	buckets []float64
+	lock    sync.Mutex
+	values  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogramVec uses DefaultBuckets when buckets is nil.
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	h := &HistogramVec{desc: desc{name, help, labels}, buckets: buckets, values: map[string]*histogram{}}
+	r.add(h)
+
+	return h
+}
+
+func (h *HistogramVec) Observe(v float64, values ...string) {
+	key := h.key(values)
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	s, ok := h.values[key]
+	if !ok {
+		s = &histogram{labels: append([]string{}, values...), counts: make([]uint64, len(h.buckets))}
+		h.values[key] = s
+	}
+
+	for i, le := range h.buckets {
+		if v <= le {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += v
+}
+
+// Count is the number of observations for the label values, for tests.
+func (h *HistogramVec) Count(values ...string) uint64 {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if s, ok := h.values[h.key(values)]; ok {
+		return s.count
+	}
+
+	return 0
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.values) {
+		s := h.values[key]
+		for i, le := range h.buckets {
+			h.series(w, "_bucket", s.labels, `le="`+formatFloat(le)+`"`, float64(s.counts[i]))
+		}
+		h.series(w, "_bucket", s.labels, `le="+Inf"`, float64(s.count))
+		h.series(w, "_sum", s.labels, "", s.sum)
+		h.series(w, "_count", s.labels, "", float64(s.count))
+	}
+}
+
+// GaugeFunc reads its samples when the metrics are written, for values
+// such as pool sizes and record counts that live elsewhere.
+type GaugeFunc struct {
+	desc
+	read func() []Sample
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, read func() []Sample, labels ...string) *GaugeFunc {
+	g := &GaugeFunc{desc: desc{name, help, labels}, read: read}
+	r.add(g)
+
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	samples := g.read()
+
+	g.header(w, "gauge")
+	for _, s := range samples {
+		g.series(w, "", s.Labels, "", s.Value)
+	}
+}
+
+func sortedKeys(m interface{}) []string {
+	keys := []string{}
+	switch t := m.(type) {
+	case map[string]*Sample:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	case map[string]*histogram:
+		for k := range t {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounterVec("http_requests_total", "Requests answered.", "route", "status")
+	requests.Inc("/phonebook/get", "200")
+	requests.Inc("/phonebook/get", "200")
+	requests.Add(3, "/auth/login", "401")
+
+	latency := r.NewHistogramVec("http_request_duration_seconds", "Request latency.", []float64{0.1, 1}, "route")
+	latency.Observe(0.05, "/phonebook/get")
+	latency.Observe(0.5, "/phonebook/get")
+
+	r.NewGaugeFunc("phonebook_contacts", "Contacts by tenant.", func() []Sample {
+		return []Sample{{Labels: []string{`a"b`}, Value: 7}}
+	}, "tenant")
+
+	buf := &bytes.Buffer{}
+	if err := r.Write(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP http_requests_total Requests answered.
+# TYPE http_requests_total counter
+http_requests_total{route="/auth/login",status="401"} 3
+http_requests_total{route="/phonebook/get",status="200"} 2
+# HELP http_request_duration_seconds Request latency.
+# TYPE http_request_duration_seconds histogram
+http_request_duration_seconds_bucket{route="/phonebook/get",le="0.1"} 1
+http_request_duration_seconds_bucket{route="/phonebook/get",le="1"} 2
+http_request_duration_seconds_bucket{route="/phonebook/get",le="+Inf"} 2
+http_request_duration_seconds_sum{route="/phonebook/get"} 0.55
+http_request_duration_seconds_count{route="/phonebook/get"} 2
+# HELP phonebook_contacts Contacts by tenant.
+# TYPE phonebook_contacts gauge
+phonebook_contacts{tenant="a\"b"} 7
+`
+	if buf.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, buf.String())
+	}
+
+	if requests.Value("/phonebook/get", "200") != 2 || latency.Count("/phonebook/get") != 2 {
+		t.Error("unexpected values")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounterVec("up_total", "Counter without labels.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if rec.Header().Get("Content-Type") != ContentType || rec.Body.String() != "# HELP up_total Counter without labels.\n# TYPE up_total counter\nup_total 1\n" {
+		t.Errorf("unexpected response %q", rec.Body.String())
+	}
+}
+
+func TestLabelCount(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for missing label values")
+		}
+	}()
+
+	NewRegistry().NewCounterVec("x_total", "x", "a", "b").Inc("only")
+}
@@ -0,0 +1,188 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// DocsHandler serves a self-contained page browsing the document at
+// specURL, grouped by tag, with a form to try the operations.
+func DocsHandler(title, specURL string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; style-src 'unsafe-inline'; script-src 'unsafe-inline'")
+
+		docsPage.Execute(w, struct{ Title, SpecURL string }{title, specURL})
+	})
+}
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 0 auto; max-width: 1100px; padding: 1em; color: #222; }
+h2 { border-bottom: 1px solid #ddd; padding-bottom: .2em; }
+details { border: 1px solid #ddd; border-radius: 4px; margin: .4em 0; }
+summary { cursor: pointer; padding: .5em; font-family: monospace; font-size: 1.05em; }
+summary .text { font-family: sans-serif; color: #555; margin-left: 1em; }
+.method { display: inline-block; width: 4.5em; text-align: center; color: #fff; border-radius: 3px; font-weight: bold; }
+.get { background: #2f7fd0; } .post { background: #3a9a4f; } .put { background: #c98a13; } .delete { background: #c8372d; }
+.body { padding: 0 1em 1em; }
+pre { background: #f6f8fa; padding: .6em; overflow: auto; }
+input, textarea { font-family: monospace; width: 100%; box-sizing: border-box; }
+label { display: block; margin: .4em 0 .1em; font-weight: bold; }
+#auth { background: #f6f8fa; padding: .6em; }
+</style>
+</head>
+<body>
+<h1 id="title">{{.Title}}</h1>
+<div id="auth">
+<label for="key">Session id or API key, sent as X-Session-Id or bearer token</label>
+<input id="key" placeholder="optional">
+</div>
+<div id="api">Loading {{.SpecURL}}</div>
+<script>
+(function() {
+  var spec;
+
+  function el(tag, attrs, children) {
+    var e = document.createElement(tag);
+    for (var k in attrs || {}) { if (k === "text") { e.textContent = attrs[k]; } else { e.setAttribute(k, attrs[k]); } }
+    (children || []).forEach(function(c) { e.appendChild(c); });
+    return e;
+  }
+
+  function resolve(schema) {
+    while (schema && schema.$ref) { schema = spec.components.schemas[schema.$ref.split("/").pop()]; }
+    return schema || {};
+  }
+
+  // example builds a sample value, seen guards recursive components
+  function example(schema, seen) {
+    seen = seen || {};
+    if (schema && schema.$ref) {
+      if (seen[schema.$ref]) { return {}; }
+      seen = Object.assign({}, seen);
+      seen[schema.$ref] = true;
+    }
+    var s = resolve(schema);
+    switch (s.type) {
+    case "object":
+      if (s.additionalProperties) { return {"key": example(s.additionalProperties, seen)}; }
+      var o = {};
+      Object.keys(s.properties || {}).sort().forEach(function(k) { o[k] = example(s.properties[k], seen); });
+      return o;
+    case "array": return [example(s.items, seen)];
+    case "integer": case "number": return 0;
+    case "boolean": return false;
+    case "string": return s.format === "date-time" ? "2024-01-01T00:00:00Z" : "";
+    }
+    return null;
+  }
+
+  function schemaOf(content) {
+    var types = Object.keys(content || {});
+    if (!types.length) { return null; }
+    return {type: types[0], schema: content[types[0]].schema};
+  }
+
+  function operation(path, method, op) {
+    var body = el("div", {"class": "body"});
+    if (op.description) { body.appendChild(el("p", {text: op.description})); }
+
+    var inputs = {};
+    (op.parameters || []).forEach(function(p) {
+      body.appendChild(el("label", {text: p.name + " (" + p.in + (p.required ? ", required" : "") + ")"}));
+      inputs[p.name] = body.appendChild(el("input", {}));
+    });
+
+    var request = op.requestBody && schemaOf(op.requestBody.content), textarea;
+    if (request) {
+      body.appendChild(el("label", {text: "Request body, " + request.type}));
+      if (request.type === "application/json") {
+        textarea = body.appendChild(el("textarea", {rows: 8}));
+        textarea.value = JSON.stringify(example(request.schema), null, 2);
+      } else {
+        textarea = body.appendChild(el("input", {type: "file"}));
+      }
+    }
+
+    Object.keys(op.responses).sort().forEach(function(code) {
+      var r = op.responses[code], c = schemaOf(r.content);
+      body.appendChild(el("label", {text: code + " " + r.description + (c ? ", " + c.type : "")}));
+      if (c && c.type === "application/json") {
+        body.appendChild(el("pre", {text: JSON.stringify(example(c.schema), null, 2)}));
+      }
+    });
+
+    var out = el("pre", {text: ""});
+    var send = el("button", {text: "Send"});
+    send.onclick = function() {
+      var url = path, query = [];
+      (op.parameters || []).forEach(function(p) {
+        var v = inputs[p.name].value;
+        if (p.in === "path") { url = url.replace("{" + p.name + "}", encodeURIComponent(v)); }
+        else if (v) { query.push(encodeURIComponent(p.name) + "=" + encodeURIComponent(v)); }
+      });
+      if (query.length) { url += "?" + query.join("&"); }
+
+      var headers = {}, key = document.getElementById("key").value.trim(), payload;
+      if (key) { headers["X-Session-Id"] = key; headers["Authorization"] = "Bearer " + key; }
+      if (textarea && textarea.files) {
+        if (textarea.files.length) { payload = new FormData(); payload.append("photo", textarea.files[0]); }
+      } else if (textarea) {
+        headers["Content-Type"] = "application/json";
+        payload = textarea.value;
+      }
+
+      // browsers refuse GET bodies, the paging fields of list routes keep their defaults
+      if (method === "get") { payload = undefined; delete headers["Content-Type"]; }
+
+      out.textContent = "...";
+      fetch(url, {method: method.toUpperCase(), headers: headers, body: payload}).then(function(res) {
+        return res.text().then(function(text) {
+          try { text = JSON.stringify(JSON.parse(text), null, 2); } catch (e) {}
+          out.textContent = res.status + " " + res.statusText + "\n\n" + text;
+        });
+      }).catch(function(err) { out.textContent = String(err); });
+    };
+    body.appendChild(send);
+    body.appendChild(out);
+
+    return el("details", {}, [
+      el("summary", {}, [el("span", {"class": "method " + method, text: method.toUpperCase()}), document.createTextNode(" " + path), el("span", {"class": "text", text: op.summary || ""})]),
+      body
+    ]);
+  }
+
+  fetch({{.SpecURL}}).then(function(res) { return res.json(); }).then(function(s) {
+    spec = s;
+    document.title = s.info.title;
+    document.getElementById("title").textContent = s.info.title + " " + s.info.version;
+
+    var api = document.getElementById("api"), groups = {};
+    api.textContent = "";
+    if (s.info.description) { api.appendChild(el("p", {text: s.info.description})); }
+
+    Object.keys(s.paths).sort().forEach(function(path) {
+      ["get", "post", "put", "delete"].forEach(function(method) {
+        var op = s.paths[path][method];
+        if (!op) { return; }
+        var tag = (op.tags || ["Other"])[0];
+        groups[tag] = groups[tag] || [];
+        groups[tag].push(operation(path, method, op));
+      });
+    });
+
+    Object.keys(groups).sort().forEach(function(tag) {
+      api.appendChild(el("h2", {text: tag}));
+      groups[tag].forEach(function(e) { api.appendChild(e); });
+    });
+  }).catch(function(err) { document.getElementById("api").textContent = "Failed to load the API: " + err; });
+})();
+</script>
+</body>
+</html>
+`))
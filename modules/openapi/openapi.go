@@ -0,0 +1,248 @@
+// Package openapi generates an OpenAPI 3 document from the routes of the
+// router and the types their controllers document, see routing.Doc.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+)
+
+const Version = "3.0.3"
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Document struct {
+	OpenAPI    string                `json:"openapi"`
+	Info       Info                  `json:"info"`
+	Tags       []Tag                 `json:"tags,omitempty"`
+	Paths      map[string]PathItem   `json:"paths"`
+	Components Components            `json:"components"`
+	Security   []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem maps lower case methods to their operations.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []SecurityRequirement `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Content map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema,omitempty"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme,omitempty"`
+	In     string `json:"in,omitempty"`
+	Name   string `json:"name,omitempty"`
+}
+
+// SecurityRequirement names the schemes of which one must be satisfied,
+// scopes are not used.
+type SecurityRequirement map[string][]string
+
+// securitySchemes match routing.SessionAuth
+var securitySchemes = map[string]SecurityScheme{
+	"session":       {Type: "apiKey", In: "header", Name: routing.SessionHeader},
+	"sessionCookie": {Type: "apiKey", In: "cookie", Name: routing.SessionCookie},
+	"apiKey":        {Type: "http", Scheme: "bearer"},
+	"basic":         {Type: "http", Scheme: "basic"},
+}
+
+var (
+	// pathVar matches gorilla variables, patterns such as {id:[0-9]+} are dropped
+	pathVar = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+	// nonWord turns paths into operation ids
+	nonWord = regexp.MustCompile(`[^A-Za-z0-9]+`)
+)
+
+// Generate documents the routes, envelope is the type wrapping the
+// responses of routes whose Doc sets Result, its Data field takes the
+// response. Mounted routes are left out as they serve protocols of their
+// own, such as CardDAV.
+func Generate(info Info, routes []*routing.Route, envelope interface{}) *Document {
+	s := newSchemas()
+	doc := &Document{
+		OpenAPI: Version,
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Security: []SecurityRequirement{
+			{"session": {}}, {"sessionCookie": {}}, {"apiKey": {}},
+		},
+	}
+
+	tags := map[string]bool{}
+	for _, route := range routes {
+		method := strings.ToLower(string(route.Method))
+		if method == "*" {
+			continue
+		}
+
+		op := operation(s, route, envelope)
+		path := pathVar.ReplaceAllString(route.Path, "{$1}")
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = PathItem{}
+		}
+		doc.Paths[path][method] = op
+
+		for _, t := range op.Tags {
+			tags[t] = true
+		}
+	}
+
+	for t := range tags {
+		doc.Tags = append(doc.Tags, Tag{Name: t})
+	}
+	sort.Slice(doc.Tags, func(i, j int) bool { return doc.Tags[i].Name < doc.Tags[j].Name })
+
+	doc.Components = Components{Schemas: s.components, SecuritySchemes: securitySchemes}
+
+	return doc
+}
+
+func operation(s *schemas, route *routing.Route, envelope interface{}) *Operation {
+	d := routing.Doc{}
+	if route.Doc != nil {
+		d = *route.Doc
+	}
+
+	op := &Operation{
+		OperationID: strings.Trim(nonWord.ReplaceAllString(strings.ToLower(string(route.Method))+route.Path, "_"), "_"),
+		Summary:     d.Summary,
+		Description: d.Description,
+		Responses:   map[string]Response{},
+	}
+	if op.Summary == "" {
+		op.Summary = route.Class
+	}
+	if ar := strings.Split(route.Class, "."); len(ar) == 2 {
+		op.Tags = []string{ar[0]}
+	}
+
+	for _, m := range pathVar.FindAllStringSubmatch(route.Path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{Name: m[1], In: "path", Required: true, Schema: &Schema{Type: "string"}})
+	}
+	for _, q := range d.Query {
+		op.Parameters = append(op.Parameters, Parameter{Name: q, In: "query", Schema: &Schema{Type: "string"}})
+	}
+
+	if d.Request != nil || d.RequestType != "" {
+		op.RequestBody = &RequestBody{Content: content(d.RequestType, s.of(d.Request))}
+	}
+
+	response := s.of(d.Response)
+	if d.View != nil && strings.Contains(route.Path, "{id}") {
+		response = s.of(d.View)
+	}
+	if d.Result {
+		response = wrap(s, envelope, response)
+	}
+	ok := Response{Description: "OK"}
+	if response != nil || d.ResponseType != "" {
+		ok.Content = content(d.ResponseType, response)
+	}
+	op.Responses["200"] = ok
+
+	errorText := map[string]MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	op.Responses["400"] = Response{Description: "Invalid request", Content: errorText}
+	op.Responses["429"] = Response{Description: "Rate limit exceeded, see Retry-After", Content: errorText}
+	op.Responses["500"] = Response{Description: "Server error", Content: errorText}
+
+	switch {
+	case route.IsPublic:
+		op.Security = []SecurityRequirement{{}}
+	case route.Realm != "":
+		op.Security = []SecurityRequirement{{"session": {}}, {"sessionCookie": {}}, {"apiKey": {}}, {"basic": {}}}
+	}
+	if !route.IsPublic {
+		op.Responses["401"] = Response{Description: "Login required", Content: errorText}
+	}
+	if route.Permission != "" {
+		op.Responses["403"] = Response{Description: "Missing permission " + route.Permission, Content: errorText}
+		op.Description = strings.TrimSpace(op.Description + "\n\nRequires the " + route.Permission + " permission.")
+	}
+	if strings.Contains(route.Path, "{") {
+		op.Responses["404"] = Response{Description: "Not found", Content: errorText}
+	}
+
+	return op
+}
+
+func content(contentType string, schema *Schema) map[string]MediaType {
+	if contentType == "" {
+		contentType = "application/json"
+	}
+
+	return map[string]MediaType{contentType: {Schema: schema}}
+}
+
+// wrap inlines the envelope with data as its Data field
+func wrap(s *schemas, envelope interface{}, data *Schema) *Schema {
+	if envelope == nil {
+		return data
+	}
+
+	t := reflect.TypeOf(envelope)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	o := s.object(t)
+	if _, ok := o.Properties["Data"]; ok && data != nil {
+		o.Properties["Data"] = data
+	}
+
+	return o
+}
+
+// ServeHTTP serves the document as JSON.
+func (d *Document) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+
+	json.NewEncoder(w).Encode(d)
+}
@@ -0,0 +1,169 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	routing "github.com/tmluthfiana/phonebook/modules/routing"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type envelope struct {
+	Data    interface{}
+	Message string
+	Total   int
+}
+
+type contact struct {
+	Base     `json:"-"`
+	Id       bson.ObjectId `json:"_id"`
+	Name     string
+	Manager  *contact `json:"Manager,omitempty"`
+	Phones   []string
+	Birthday *time.Time
+	hidden   string
+}
+
+type Base struct {
+	Secret string
+}
+
+type source struct {
+	Type string
+}
+
+type contactInfo struct {
+	contact
+	Source source
+}
+
+type Contacts struct {
+	*routing.BaseController
+}
+
+func (c *Contacts) Get(r *routing.WeContent) interface{}   { return nil }
+func (c *Contacts) Save(r *routing.WeContent) interface{}  { return nil }
+func (c *Contacts) Login(r *routing.WeContent) interface{} { return nil }
+func (c *Contacts) Dav(r *routing.WeContent) interface{}   { return nil }
+
+func (c *Contacts) Docs() map[string]routing.Doc {
+	return map[string]routing.Doc{
+		"Get":  {Summary: "List contacts", Query: []string{"q"}, Response: []contactInfo{}, View: contactInfo{}, Result: true},
+		"Save": {Summary: "Save a contact", Request: contact{}, Response: contact{}},
+	}
+}
+
+func generate() *Document {
+	rt := routing.NewRouting("", []interface{}{&Contacts{}})
+	rt.Get("/contact/get", "Contacts.Get").Require("phonebook.read")
+	rt.Get("/contact/view/{id}", "Contacts.Get").Require("phonebook.read")
+	rt.Put("/contact/edit/{id:[0-9a-f]+}", "Contacts.Save")
+	rt.Post("/login", "Contacts.Login").Public()
+	rt.Mount("/dav", "Contacts.Dav").BasicAuth("dav")
+
+	return Generate(Info{Title: "Test", Version: "1"}, rt.Routes(), envelope{})
+}
+
+func TestGenerate(t *testing.T) {
+	doc := generate()
+
+	if doc.OpenAPI != Version || doc.Info.Title != "Test" {
+		t.Errorf("header = %s %+v", doc.OpenAPI, doc.Info)
+	}
+	if _, ok := doc.Paths["/dav"]; ok {
+		t.Error("mounted route documented")
+	}
+
+	list := doc.Paths["/contact/get"]["get"]
+	if list == nil || list.Summary != "List contacts" || list.Tags[0] != "Contacts" {
+		t.Fatalf("list = %+v", list)
+	}
+	if !strings.Contains(list.Description, "phonebook.read") || list.Responses["403"].Description == "" {
+		t.Errorf("permission not documented: %+v", list)
+	}
+	if len(list.Parameters) != 1 || list.Parameters[0].In != "query" {
+		t.Errorf("parameters = %+v", list.Parameters)
+	}
+
+	data := list.Responses["200"].Content["application/json"].Schema.Properties["Data"]
+	if data.Type != "array" || data.Items.Ref != componentsPrefix+"ContactInfo" {
+		t.Errorf("list data = %+v", data)
+	}
+
+	view := doc.Paths["/contact/view/{id}"]["get"]
+	if view.Parameters[0].Name != "id" || !view.Parameters[0].Required {
+		t.Errorf("path parameter = %+v", view.Parameters)
+	}
+	if data := view.Responses["200"].Content["application/json"].Schema.Properties["Data"]; data.Ref != componentsPrefix+"ContactInfo" {
+		t.Errorf("view data = %+v", data)
+	}
+
+	edit := doc.Paths["/contact/edit/{id}"]["put"]
+	if edit == nil || edit.RequestBody == nil {
+		t.Fatalf("edit = %+v", edit)
+	}
+	if ref := edit.Responses["200"].Content["application/json"].Schema.Ref; ref != componentsPrefix+"Contact" {
+		t.Errorf("unwrapped response = %s", ref)
+	}
+
+	login := doc.Paths["/login"]["post"]
+	if login.Summary != "Contacts.Login" || len(login.Security) != 1 || len(login.Security[0]) != 0 {
+		t.Errorf("public route = %+v", login)
+	}
+	if _, ok := login.Responses["401"]; ok {
+		t.Error("public route answers 401")
+	}
+}
+
+func TestSchemas(t *testing.T) {
+	c := generate().Components.Schemas["Contact"]
+	if c == nil {
+		t.Fatal("no Contact component")
+	}
+
+	for _, name := range []string{"Secret", "hidden", "Id"} {
+		if _, ok := c.Properties[name]; ok {
+			t.Errorf("%s documented", name)
+		}
+	}
+	if id := c.Properties["_id"]; id.Type != "string" || id.Pattern == "" {
+		t.Errorf("_id = %+v", id)
+	}
+	if m := c.Properties["Manager"]; m.Ref != componentsPrefix+"Contact" {
+		t.Errorf("recursive field = %+v", m)
+	}
+	if b := c.Properties["Birthday"]; b.Format != "date-time" || !b.Nullable {
+		t.Errorf("Birthday = %+v", b)
+	}
+
+	info := generate().Components.Schemas["ContactInfo"]
+	if _, ok := info.Properties["Name"]; !ok {
+		t.Error("embedded fields not flattened")
+	}
+	if s := info.Properties["Source"]; s.Ref != componentsPrefix+"Source" {
+		t.Errorf("Source = %+v", s)
+	}
+}
+
+func TestServe(t *testing.T) {
+	w := httptest.NewRecorder()
+	generate().ServeHTTP(w, httptest.NewRequest("GET", "/openapi.json", nil))
+
+	doc := map[string]interface{}{}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc["openapi"] != Version {
+		t.Errorf("openapi = %v", doc["openapi"])
+	}
+
+	w = httptest.NewRecorder()
+	DocsHandler("Test <API>", "/openapi.json").ServeHTTP(w, httptest.NewRequest("GET", "/docs", nil))
+	if body := w.Body.String(); !strings.Contains(body, "Test &lt;API&gt;") || !strings.Contains(body, `fetch("/openapi.json")`) {
+		t.Errorf("docs page = %s", body)
+	}
+}
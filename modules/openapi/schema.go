@@ -0,0 +1,182 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Schema is the subset of the OpenAPI schema object the generator emits.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+const componentsPrefix = "#/components/schemas/"
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	objectIdType  = reflect.TypeOf(bson.ObjectId(""))
+	marshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemas turns Go types into schemas, named structs become components
+// referenced by $ref so recursive types such as the org chart terminate.
+type schemas struct {
+	components map[string]*Schema
+	// names keeps the type of every component to tell same named types of
+	// different packages apart
+	names map[string]reflect.Type
+	types map[reflect.Type]string
+}
+
+func newSchemas() *schemas {
+	return &schemas{components: map[string]*Schema{}, names: map[string]reflect.Type{}, types: map[reflect.Type]string{}}
+}
+
+// of returns the schema of the type of v, nil for a nil value
+func (s *schemas) of(v interface{}) *Schema {
+	if v == nil {
+		return nil
+	}
+
+	return s.schema(reflect.TypeOf(v))
+}
+
+func (s *schemas) schema(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case t == objectIdType:
+		return &Schema{Type: "string", Pattern: "^[0-9a-f]{24}$", Description: "Object id in hex"}
+	case t.Kind() != reflect.Struct && t.Implements(marshalerType):
+		// encoded by its own rules
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &Schema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &Schema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		return s.ref(t)
+	}
+
+	// interfaces and anything else take any value
+	return &Schema{}
+}
+
+// ref registers t as a component on first use
+func (s *schemas) ref(t reflect.Type) *Schema {
+	if name, ok := s.types[t]; ok {
+		return &Schema{Ref: componentsPrefix + name}
+	}
+
+	name := componentName(t)
+	if other, taken := s.names[name]; taken && other != t {
+		name = componentName(t) + "_" + strings.ReplaceAll(t.PkgPath(), "/", "_")
+	}
+	// registered before the fields so recursive types find it
+	s.names[name] = t
+	s.types[t] = name
+	s.components[name] = s.object(t)
+
+	return &Schema{Ref: componentsPrefix + name}
+}
+
+// componentName capitalises unexported type names such as contactInfo
+func componentName(t reflect.Type) string {
+	r := []rune(t.Name())
+	r[0] = unicode.ToUpper(r[0])
+
+	return string(r)
+}
+
+// object lists the fields as encoding/json writes them, embedded structs
+// without a json name are flattened
+func (s *schemas) object(t reflect.Type) *Schema {
+	o := &Schema{Type: "object", Properties: map[string]*Schema{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+
+		name, skip := jsonName(f)
+		if skip {
+			continue
+		}
+
+		ft := f.Type
+		for ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			for k, v := range s.object(ft).Properties {
+				if _, exists := o.Properties[k]; !exists {
+					o.Properties[k] = v
+				}
+			}
+			continue
+		}
+
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		prop := s.schema(f.Type)
+		// siblings of $ref are ignored, so only inline schemas say nullable
+		if f.Type.Kind() == reflect.Ptr && prop.Ref == "" {
+			prop.Nullable = true
+		}
+		o.Properties[name] = prop
+	}
+
+	return o
+}
+
+// jsonName reads the json tag, skip is true for "-"
+func jsonName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+
+	return strings.Split(tag, ",")[0], false
+}
@@ -0,0 +1,74 @@
+package photo
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DiskStore keeps photos as files below Dir, with the content type in a
+// sidecar file next to each photo.
+type DiskStore struct {
+	Dir string
+}
+
+func NewDiskStore(dir string) *DiskStore {
+	return &DiskStore{Dir: dir}
+}
+
+func (s *DiskStore) path(name string) string {
+	return filepath.Join(s.Dir, filepath.FromSlash(filepath.Clean("/"+name)))
+}
+
+func (s *DiskStore) Put(f *File) error {
+	p := s.path(f.Name)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+
+	if err := ioutil.WriteFile(p+".type", []byte(f.ContentType), 0644); err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(p, f.Data, 0644)
+}
+
+func (s *DiskStore) Get(name string) (*File, error) {
+	p := s.path(name)
+
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	data, err := ioutil.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, err := ioutil.ReadFile(p + ".type")
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Name:        name,
+		ContentType: strings.TrimSpace(string(contentType)),
+		Data:        data,
+		UploadDate:  info.ModTime(),
+	}, nil
+}
+
+func (s *DiskStore) Delete(name string) error {
+	p := s.path(name)
+	for _, f := range []string{p, p + ".type"} {
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package photo
+
+import (
+	"io/ioutil"
+
+	mgo "gopkg.in/mgo.v2"
+)
+
+// GridFSStore keeps photos in a Mongo GridFS bucket. Like the rest of the
+// Mongo access it opens a session per operation through Dial.
+type GridFSStore struct {
+	Dial     func() (*mgo.Session, error)
+	Database string
+	Prefix   string
+}
+
+func NewGridFSStore(dial func() (*mgo.Session, error), database string) *GridFSStore {
+	return &GridFSStore{Dial: dial, Database: database, Prefix: "photos"}
+}
+
+func (s *GridFSStore) Put(f *File) error {
+	sess, err := s.Dial()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	gfs := sess.DB(s.Database).GridFS(s.Prefix)
+	if err := gfs.Remove(f.Name); err != nil {
+		return err
+	}
+
+	gf, err := gfs.Create(f.Name)
+	if err != nil {
+		return err
+	}
+
+	gf.SetContentType(f.ContentType)
+	gf.SetUploadDate(f.UploadDate)
+	if _, err := gf.Write(f.Data); err != nil {
+		gf.Abort()
+		gf.Close()
+		return err
+	}
+
+	return gf.Close()
+}
+
+func (s *GridFSStore) Get(name string) (*File, error) {
+	sess, err := s.Dial()
+	if err != nil {
+		return nil, err
+	}
+	defer sess.Close()
+
+	gf, err := sess.DB(s.Database).GridFS(s.Prefix).Open(name)
+	if err == mgo.ErrNotFound {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	defer gf.Close()
+
+	data, err := ioutil.ReadAll(gf)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		Name:        name,
+		ContentType: gf.ContentType(),
+		Data:        data,
+		UploadDate:  gf.UploadDate(),
+	}, nil
+}
+
+func (s *GridFSStore) Delete(name string) error {
+	sess, err := s.Dial()
+	if err != nil {
+		return err
+	}
+	defer sess.Close()
+
+	return sess.DB(s.Database).GridFS(s.Prefix).Remove(name)
+}
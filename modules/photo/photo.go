@@ -0,0 +1,191 @@
+package photo
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	MaxSize      = 5 << 20
+	MaxDimension = 4096
+
+	Original = "original"
+)
+
+// Sizes are the edge lengths in pixels of the square thumbnails generated for every upload.
+var Sizes = []int{64, 128, 256}
+
+var (
+	ErrNotFound    = errors.New("Photo not found")
+	ErrTooLarge    = fmt.Errorf("Photo must be smaller than %d MB", MaxSize>>20)
+	ErrInvalidType = errors.New("Photo must be a JPEG, PNG or GIF image")
+)
+
+var allowedTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+}
+
+type File struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	UploadDate  time.Time
+}
+
+type Store interface {
+	Put(f *File) error
+	Get(name string) (*File, error)
+	Delete(name string) error
+}
+
+// Name is the store name of one size variant of the photo of a contact.
+func Name(contactId, size string) string {
+	return contactId + "/" + size
+}
+
+// Names lists the store names of every size variant of the photo of a contact.
+func Names(contactId string) []string {
+	names := []string{Name(contactId, Original)}
+	for _, size := range Sizes {
+		names = append(names, Name(contactId, strconv.Itoa(size)))
+	}
+
+	return names
+}
+
+// Validate checks the uploaded bytes and returns their sniffed content type.
+func Validate(data []byte) (string, error) {
+	if len(data) > MaxSize {
+		return "", ErrTooLarge
+	}
+
+	contentType := http.DetectContentType(data)
+	if !allowedTypes[contentType] {
+		return "", ErrInvalidType
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", ErrInvalidType
+	}
+
+	if cfg.Width > MaxDimension || cfg.Height > MaxDimension {
+		return "", fmt.Errorf("Photo must be at most %dx%d pixels", MaxDimension, MaxDimension)
+	}
+
+	return contentType, nil
+}
+
+// Version is a short content hash used as ETag and cache buster.
+func Version(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Process validates an upload for a contact and returns the original
+// together with a JPEG thumbnail for every entry of Sizes.
+func Process(contactId string, data []byte) ([]*File, error) {
+	contentType, err := Validate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidType
+	}
+
+	now := time.Now()
+	files := []*File{
+		{Name: Name(contactId, Original), ContentType: contentType, Data: data, UploadDate: now},
+	}
+
+	for _, size := range Sizes {
+		buf := new(bytes.Buffer)
+		if err := jpeg.Encode(buf, Thumbnail(img, size), &jpeg.Options{Quality: 85}); err != nil {
+			return nil, err
+		}
+
+		files = append(files, &File{
+			Name:        Name(contactId, strconv.Itoa(size)),
+			ContentType: "image/jpeg",
+			Data:        buf.Bytes(),
+			UploadDate:  now,
+		})
+	}
+
+	return files, nil
+}
+
+// Thumbnail crops the centre square of src and scales it to size x size,
+// averaging every source pixel that falls into a target pixel.
+func Thumbnail(src image.Image, size int) *image.RGBA {
+	b := src.Bounds()
+	edge := b.Dx()
+	if b.Dy() < edge {
+		edge = b.Dy()
+	}
+
+	crop := image.Rect(0, 0, edge, edge)
+	sq := image.NewRGBA(crop)
+	origin := image.Pt(b.Min.X+(b.Dx()-edge)/2, b.Min.Y+(b.Dy()-edge)/2)
+	draw.Draw(sq, crop, src, origin, draw.Src)
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	if edge == 0 {
+		return dst
+	}
+
+	for y := 0; y < size; y++ {
+		y0, y1 := span(y, size, edge)
+		for x := 0; x < size; x++ {
+			x0, x1 := span(x, size, edge)
+
+			var r, g, bl, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := sq.PixOffset(x0, sy)
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(sq.Pix[i])
+					g += uint32(sq.Pix[i+1])
+					bl += uint32(sq.Pix[i+2])
+					a += uint32(sq.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+
+	return dst
+}
+
+// span maps target pixel i of a size wide row to the source pixels [lo, hi)
+// of an edge wide row, always covering at least one pixel.
+func span(i, size, edge int) (int, int) {
+	lo := i * edge / size
+	hi := (i + 1) * edge / size
+	if hi <= lo {
+		hi = lo + 1
+	}
+
+	return lo, hi
+}
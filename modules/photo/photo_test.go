@@ -0,0 +1,86 @@
+package photo
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func samplePNG(t *testing.T, w, h int) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{uint8(x), uint8(y), 200, 255})
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func TestValidate(t *testing.T) {
+	if ct, err := Validate(samplePNG(t, 10, 10)); err != nil || ct != "image/png" {
+		t.Errorf("expected valid png, got %q %v", ct, err)
+	}
+
+	if _, err := Validate([]byte("not an image")); err != ErrInvalidType {
+		t.Errorf("expected ErrInvalidType, got %v", err)
+	}
+}
+
+func TestProcess(t *testing.T) {
+	files, err := Process("abc", samplePNG(t, 300, 200))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(files) != len(Sizes)+1 {
+		t.Fatalf("expected %d files, got %d", len(Sizes)+1, len(files))
+	}
+
+	for i, size := range Sizes {
+		f := files[i+1]
+		img, _, err := image.Decode(bytes.NewReader(f.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Errorf("%s: expected %dx%d, got %v", f.Name, size, size, b)
+		}
+	}
+}
+
+func TestDiskStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "photo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := NewDiskStore(dir)
+	if err := s.Put(&File{Name: Name("abc", Original), ContentType: "image/png", Data: []byte("x")}); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := s.Get(Name("abc", Original))
+	if err != nil || f.ContentType != "image/png" || string(f.Data) != "x" {
+		t.Errorf("unexpected file %+v %v", f, err)
+	}
+
+	if err := s.Delete(Name("abc", Original)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Get(Name("abc", Original)); err != ErrNotFound {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
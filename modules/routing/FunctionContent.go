@@ -8,12 +8,31 @@ import (
 	"io/ioutil"
 	"net/http"
 	"reflect"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+
+	acl "github.com/eaciit/acl/v1.0"
 )
 
 type WeContent struct {
 	Writer http.ResponseWriter
 	Req    *http.Request
 	vars   map[string]string
+
+	Route     *Route
+	SessionID string
+	User      *acl.User
+	// Token is the API key the request authenticated with, its scopes
+	// narrow what User may do
+	Token *acl.Token
+	// TenantID is the tenant the request works in, see Tenants
+	TenantID string
+	// RequestID identifies the request in logs, see AccessLog
+	RequestID string
+
+	log *logger.Logger
+	// failure is the message of the error answered, for the access log
+	failure string
 }
 
 func (f *WeContent) Parse(d interface{}) error {
@@ -31,6 +50,10 @@ func (f *WeContent) Parse(d interface{}) error {
 		return err
 	}
 
+	if len(body) == 0 {
+		return nil
+	}
+
 	if err := json.Unmarshal(body, d); err != nil {
 		if err := json.NewEncoder(f.Writer).Encode(err); err != nil {
 			return err
@@ -40,6 +63,24 @@ func (f *WeContent) Parse(d interface{}) error {
 	return nil
 }
 
+// UserName is the login id of the authenticated user, empty for anonymous requests.
+func (f *WeContent) UserName() string {
+	if f.User == nil {
+		return ""
+	}
+
+	return f.User.LoginID
+}
+
+// Log is the logger of the request, its entries carry the request id.
+func (f *WeContent) Log() *logger.Logger {
+	if f.log == nil {
+		return logger.Default
+	}
+
+	return f.log
+}
+
 func (f *WeContent) VarsGet(k string) (string, error) {
 	if v, isexist := f.vars[k]; isexist {
 		return v, nil
@@ -59,10 +100,32 @@ func (f *WeContent) JSON(d interface{}) []byte {
 	return js
 }
 
+func (f *WeContent) Data(contentType string, d []byte) []byte {
+	f.Writer.Header().Set("Content-Type", contentType)
+
+	return d
+}
+
+func (f *WeContent) BadRequest(er error) interface{} {
+	return f.error(400, er.Error())
+}
+
+func (f *WeContent) Unauthorized(er error) interface{} {
+	return f.error(401, er.Error())
+}
+
+func (f *WeContent) Forbidden(er error) interface{} {
+	return f.error(403, er.Error())
+}
+
 func (f *WeContent) NotFound(er error) interface{} {
 	return f.error(404, er.Error())
 }
 
+func (f *WeContent) TooManyRequests(er error) interface{} {
+	return f.error(429, er.Error())
+}
+
 func (f *WeContent) ServerError(er error) interface{} {
 	return f.error(500, er.Error())
 }
@@ -70,6 +133,7 @@ func (f *WeContent) ServerError(er error) interface{} {
 func (f *WeContent) error(Code int, Message string) []byte {
 	f.Writer.Header().Set("Content-Type", "application/json; charset=UTF-8")
 	f.Writer.WriteHeader(Code) // unprocessable entity
+	f.failure = Message
 
 	return []byte(Message)
 }
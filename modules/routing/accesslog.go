@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"time"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+// RequestIDHeader carries the id of a request in both directions, ids sent
+// by clients or proxies are kept.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestID bounds the ids taken from clients
+const maxRequestID = 128
+
+// statusWriter remembers the status and size of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
+
+// Flush lets streaming handlers flush through the wrapper.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the connection, e.g. to lift
+// the write deadline of a stream.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status is the status answered, 200 when nothing was written yet.
+func (w *statusWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
+
+// serve calls next and writes its answer itself, so middlewares know the
+// status and size afterwards. Nested calls share one statusWriter.
+func serve(wc *WeContent, next HandlerFunc) (interface{}, *statusWriter) {
+	w, ok := wc.Writer.(*statusWriter)
+	if !ok {
+		w = &statusWriter{ResponseWriter: wc.Writer}
+		wc.Writer = w
+	}
+
+	data := next(wc)
+	if b, ok := data.([]byte); ok {
+		wc.Return(b)
+		data = nil
+	}
+
+	return data, w
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func requestID(r *http.Request) string {
+	id := r.Header.Get(RequestIDHeader)
+	if id == "" || len(id) > maxRequestID {
+		return newRequestID()
+	}
+
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return newRequestID()
+		}
+	}
+
+	return id
+}
+
+// AccessLog is a middleware giving every request an id and logging it
+// once answered with method, path, status, size, latency, user and tenant.
+// 5xx answers are logged as errors and 4xx as warnings. It goes first so it
+// sees the answers of the other middlewares.
+func AccessLog(log *logger.Logger) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(wc *WeContent) interface{} {
+			start := time.Now()
+
+			wc.RequestID = requestID(wc.Req)
+			wc.Writer.Header().Set(RequestIDHeader, wc.RequestID)
+			wc.log = log.With("request_id", wc.RequestID)
+
+			data, w := serve(wc, next)
+			status := w.Status()
+
+			kv := []interface{}{
+				"method", wc.Req.Method,
+				"path", wc.Req.URL.Path,
+				"status", status,
+				"bytes", w.bytes,
+				"latency_ms", float64(time.Since(start).Microseconds()) / 1000,
+				"remote", wc.Req.RemoteAddr,
+				"user", wc.UserName(),
+				"tenant", wc.TenantID,
+			}
+			if wc.failure != "" {
+				kv = append(kv, "error", wc.failure)
+			}
+
+			level := logger.LevelInfo
+			if status >= 500 {
+				level = logger.LevelError
+			} else if status >= 400 {
+				level = logger.LevelWarn
+			}
+			wc.log.Log(level, "request", kv...)
+
+			return data
+		}
+	}
+}
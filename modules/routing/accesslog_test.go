@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+func TestAccessLog(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		if sessionId != "good" {
+			return nil, errors.New("Session is not active")
+		}
+		return &acl.User{LoginID: "tias", Enable: true}, nil
+	}
+
+	buf := &bytes.Buffer{}
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(AccessLog(logger.New(buf, logger.LevelInfo)), rt.SessionAuth)
+	rt.Get("/private", "Greeter.Hello")
+
+	cases := []struct {
+		session   string
+		requestId string
+		status    float64
+		level     string
+	}{
+		{"good", "", 200, "info"},
+		{"", "from-proxy-1", 401, "warn"},
+		{"", "not valid", 401, "warn"},
+	}
+
+	for _, c := range cases {
+		buf.Reset()
+
+		req := httptest.NewRequest(http.MethodGet, "/private?q=1", nil)
+		if c.session != "" {
+			req.Header.Set(SessionHeader, c.session)
+		}
+		if c.requestId != "" {
+			req.Header.Set(RequestIDHeader, c.requestId)
+		}
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		id := rec.Header().Get(RequestIDHeader)
+		if id == "" || (c.requestId != "" && !strings.Contains(c.requestId, " ") && id != c.requestId) {
+			t.Errorf("%+v: unexpected request id %q", c, id)
+		}
+
+		entry := map[string]interface{}{}
+		if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+			t.Fatalf("%+v: invalid log line %q", c, buf.String())
+		}
+
+		if entry["request_id"] != id || entry["status"] != c.status || entry["level"] != c.level ||
+			entry["path"] != "/private" || entry["method"] != "GET" || entry["bytes"] != float64(rec.Body.Len()) {
+			t.Errorf("%+v: unexpected entry %s", c, buf.String())
+		}
+
+		if c.status == 401 && entry["error"] != "Login required" {
+			t.Errorf("%+v: expected the error to be logged, got %s", c, buf.String())
+		}
+	}
+}
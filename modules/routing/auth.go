@@ -0,0 +1,146 @@
+package routing
+
+import (
+	"crypto/md5"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+const (
+	SessionHeader = "X-Session-Id"
+	SessionCookie = "sessionid"
+)
+
+// FindSessionUser resolves an active acl session to its user. It is a
+// variable so the lookup can be replaced when acl is not backed by Mongo.
+var FindSessionUser = func(sessionId string) (*acl.User, error) {
+	userId, err := acl.FindUserBySessionID(sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	user := new(acl.User)
+	if err := acl.FindByID(user, userId); err != nil {
+		return nil, err
+	}
+
+	if !user.Enable {
+		return nil, errors.New("User is not active")
+	}
+
+	return user, nil
+}
+
+// FindPasswordUser checks the password of a basic acl user for HTTP basic
+// authentication, LDAP users have to log in through /auth/login.
+var FindPasswordUser = func(loginId, password string) (*acl.User, error) {
+	user := new(acl.User)
+	if err := acl.FindUserByLoginID(user, loginId); err != nil || user.ID == "" || user.LoginType == acl.LogTypeLdap {
+		return nil, errors.New("Username and password is incorrect")
+	}
+
+	// acl keeps the md5 hex of the password
+	sum := md5.Sum([]byte(password))
+	if subtle.ConstantTimeCompare([]byte(hex.EncodeToString(sum[:])), []byte(user.Password)) != 1 {
+		return nil, errors.New("Username and password is incorrect")
+	}
+
+	if !user.Enable {
+		return nil, errors.New("User is not active")
+	}
+
+	return user, nil
+}
+
+// FindCertificateUser maps a verified client certificate to the acl user
+// whose login id is the common name of its subject.
+var FindCertificateUser = func(cert *x509.Certificate) (*acl.User, error) {
+	user := new(acl.User)
+	if cert.Subject.CommonName == "" {
+		return nil, errors.New("Client certificate has no common name")
+	}
+	if err := acl.FindUserByLoginID(user, cert.Subject.CommonName); err != nil || user.ID == "" {
+		return nil, errors.New("No user for the client certificate")
+	}
+
+	if !user.Enable {
+		return nil, errors.New("User is not active")
+	}
+
+	return user, nil
+}
+
+// BasicAuth also accepts HTTP basic credentials of acl users on the route,
+// for clients like CardDAV that cannot hold a session.
+func (r *Route) BasicAuth(realm string) *Route {
+	r.Realm = realm
+
+	return r
+}
+
+// SessionID reads the acl session id from the X-Session-Id header, falling
+// back to the session cookie.
+func SessionID(r *http.Request) string {
+	if v := strings.TrimSpace(r.Header.Get(SessionHeader)); v != "" {
+		return v
+	}
+
+	if c, err := r.Cookie(SessionCookie); err == nil {
+		return c.Value
+	}
+
+	return ""
+}
+
+// SessionAuth is a middleware rejecting requests without an active acl
+// session, API key (Authorization: Bearer) or verified client certificate
+// on every route not marked Public, and attaching the user to the WeContent
+// otherwise.
+func (rt *Router) SessionAuth(next HandlerFunc) HandlerFunc {
+	return func(wc *WeContent) interface{} {
+		sessionId := SessionID(wc.Req)
+		if sessionId != "" {
+			if user, err := FindSessionUser(sessionId); err == nil {
+				wc.SessionID = sessionId
+				wc.User = user
+			}
+		}
+
+		if key := BearerToken(wc.Req); wc.User == nil && key != "" {
+			if token, user, err := FindTokenUser(key); err == nil {
+				wc.Token = token
+				wc.User = user
+			}
+		}
+
+		// only chains the TLS listener verified against tlsclientca count
+		if state := wc.Req.TLS; wc.User == nil && state != nil && len(state.VerifiedChains) > 0 {
+			if user, err := FindCertificateUser(state.VerifiedChains[0][0]); err == nil {
+				wc.User = user
+			}
+		}
+
+		if wc.User == nil && wc.Route != nil && wc.Route.Realm != "" {
+			if loginId, password, ok := wc.Req.BasicAuth(); ok {
+				if user, err := FindPasswordUser(loginId, password); err == nil {
+					wc.User = user
+				}
+			}
+		}
+
+		if wc.User == nil && (wc.Route == nil || !wc.Route.IsPublic) {
+			if wc.Route != nil && wc.Route.Realm != "" {
+				wc.Writer.Header().Set("WWW-Authenticate", `Basic realm="`+wc.Route.Realm+`", charset="UTF-8"`)
+			}
+			return wc.Unauthorized(errors.New("Login required"))
+		}
+
+		return next(wc)
+	}
+}
@@ -0,0 +1,155 @@
+package routing
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+type Greeter struct {
+	*BaseController
+}
+
+func (g *Greeter) Hello(r *WeContent) interface{} {
+	return []byte("hello " + r.UserName())
+}
+
+func newTestRouter() *Router {
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(rt.SessionAuth)
+
+	rt.Get("/public", "Greeter.Hello").Public()
+	rt.Get("/private", "Greeter.Hello")
+
+	return rt
+}
+
+func TestSessionAuth(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		if sessionId != "good" {
+			return nil, errors.New("Session is not active")
+		}
+		return &acl.User{LoginID: "tias", Enable: true}, nil
+	}
+
+	rt := newTestRouter()
+
+	cases := []struct {
+		path    string
+		session string
+		code    int
+		body    string
+	}{
+		{"/public", "", 200, "hello "},
+		{"/private", "", 401, "Login required"},
+		{"/private", "bad", 401, "Login required"},
+		{"/private", "good", 200, "hello tias"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.session != "" {
+			req.AddCookie(&http.Cookie{Name: SessionCookie, Value: c.session})
+		}
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code || rec.Body.String() != c.body {
+			t.Errorf("%s with session %q: got %d %q", c.path, c.session, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestBasicAuth(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		return nil, errors.New("Session is not active")
+	}
+	FindPasswordUser = func(loginId, password string) (*acl.User, error) {
+		if loginId != "tias" || password != "secret" {
+			return nil, errors.New("Username and password is incorrect")
+		}
+		return &acl.User{LoginID: loginId, Enable: true}, nil
+	}
+
+	rt := newTestRouter()
+	rt.Mount("/dav", "Greeter.Hello").BasicAuth("phonebook")
+
+	cases := []struct {
+		method   string
+		path     string
+		password string
+		code     int
+	}{
+		{"PROPFIND", "/dav/book/", "", 401},
+		{"PROPFIND", "/dav/book/", "wrong", 401},
+		{"PROPFIND", "/dav/book/", "secret", 200},
+		{"GET", "/private", "secret", 401},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, c.path, nil)
+		if c.password != "" {
+			req.SetBasicAuth("tias", c.password)
+		}
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code {
+			t.Errorf("%s %s with password %q: got %d %q", c.method, c.path, c.password, rec.Code, rec.Body.String())
+		}
+
+		if c.code == 401 && c.path == "/dav/book/" && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s %s: missing basic auth challenge", c.method, c.path)
+		}
+	}
+}
+
+func TestCertificateAuth(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		return nil, errors.New("Session is not active")
+	}
+	FindCertificateUser = func(cert *x509.Certificate) (*acl.User, error) {
+		if cert.Subject.CommonName != "tias" {
+			return nil, errors.New("No user for the client certificate")
+		}
+		return &acl.User{LoginID: "tias", Enable: true}, nil
+	}
+
+	rt := newTestRouter()
+
+	cert := func(cn string) *x509.Certificate {
+		return &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+	}
+
+	cases := []struct {
+		name  string
+		state *tls.ConnectionState
+		code  int
+		body  string
+	}{
+		{"no tls", nil, 401, "Login required"},
+		{"unverified", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert("tias")}}, 401, "Login required"},
+		{"unknown", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert("eve")}}}, 401, "Login required"},
+		{"verified", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert("tias")}}}, 200, "hello tias"},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/private", nil)
+		req.TLS = c.state
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code || rec.Body.String() != c.body {
+			t.Errorf("%s: got %d %q", c.name, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Doc describes a route for the API reference, see the openapi module.
+type Doc struct {
+	Summary     string
+	Description string
+	// Query names the query string parameters
+	Query []string
+	// Request and Response are values of the JSON body types, nil when the
+	// route takes or answers none
+	Request  interface{}
+	Response interface{}
+	// View is the response of the routes with an id, such as
+	// /phonebook/view/{id}, when it differs from Response
+	View interface{}
+	// Result wraps Response in the helper.Result envelope
+	Result bool
+	// RequestType and ResponseType replace application/json, e.g. for photos
+	RequestType  string
+	ResponseType string
+}
+
+// Documented controllers describe their methods, keyed by method name.
+type Documented interface {
+	Docs() map[string]Doc
+}
+
+// Describe sets the documentation of the route, replacing the one of the
+// controller.
+func (r *Route) Describe(d Doc) *Route {
+	r.Doc = &d
+
+	return r
+}
+
+// Routes returns the registered routes in the order registered.
+func (rt *Router) Routes() []*Route {
+	return append([]*Route{}, rt.routes...)
+}
+
+// controllerDoc looks up the Doc of a Class such as Phonebook.Get
+func (rt *Router) controllerDoc(c string) *Doc {
+	ar := strings.Split(c, ".")
+	if len(ar) != 2 {
+		return nil
+	}
+
+	for _, class := range rt.ClassList {
+		if reflect.Indirect(reflect.ValueOf(class)).Type().Name() != ar[0] {
+			continue
+		}
+
+		if d, ok := class.(Documented); ok {
+			if doc, ok := d.Docs()[ar[1]]; ok {
+				return &doc
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package routing
+
+import (
+	metrics "github.com/tmluthfiana/phonebook/modules/metrics"
+	"strconv"
+	"time"
+)
+
+// RouteMetrics are the request metrics kept by the Metrics middleware.
+type RouteMetrics struct {
+	Requests *metrics.CounterVec
+	Errors   *metrics.CounterVec
+	Duration *metrics.HistogramVec
+}
+
+func NewRouteMetrics(reg *metrics.Registry) *RouteMetrics {
+	return &RouteMetrics{
+		Requests: reg.NewCounterVec("http_requests_total", "HTTP requests answered, by route, method and status.", "route", "method", "status"),
+		Errors:   reg.NewCounterVec("http_errors_total", "HTTP requests answered with a 4xx or 5xx status, by status.", "status"),
+		Duration: reg.NewHistogramVec("http_request_duration_seconds", "Time taken to answer HTTP requests, by route and method.", nil, "route", "method"),
+	}
+}
+
+// metricMethods are the methods labelled by name, the others count as
+// "other" so clients cannot add series with made up methods.
+var metricMethods = map[string]bool{
+	"GET": true, "HEAD": true, "POST": true, "PUT": true, "PATCH": true, "DELETE": true,
+	"OPTIONS": true, "PROPFIND": true, "REPORT": true,
+}
+
+func methodLabel(method string) string {
+	if metricMethods[method] {
+		return method
+	}
+
+	return "other"
+}
+
+// Metrics is a middleware counting requests and timing them by route
+// template, so /phonebook/view/{id} is one series whatever the id.
+func Metrics(m *RouteMetrics) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(wc *WeContent) interface{} {
+			start := time.Now()
+
+			data, w := serve(wc, next)
+
+			route := ""
+			if wc.Route != nil {
+				route = wc.Route.Path
+			}
+			method := methodLabel(wc.Req.Method)
+			status := strconv.Itoa(w.Status())
+
+			m.Requests.Inc(route, method, status)
+			m.Duration.Observe(time.Since(start).Seconds(), route, method)
+			if w.Status() >= 400 {
+				m.Errors.Inc(status)
+			}
+
+			return data
+		}
+	}
+}
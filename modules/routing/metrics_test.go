@@ -0,0 +1,66 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metrics "github.com/tmluthfiana/phonebook/modules/metrics"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+func TestMetrics(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		if sessionId != "good" {
+			return nil, errors.New("Session is not active")
+		}
+		return &acl.User{LoginID: "tias", Enable: true}, nil
+	}
+
+	m := NewRouteMetrics(metrics.NewRegistry())
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(Metrics(m), rt.SessionAuth)
+	rt.Get("/hello/{name}", "Greeter.Hello")
+
+	for _, session := range []string{"good", "good", "bad"} {
+		req := httptest.NewRequest(http.MethodGet, "/hello/"+session, nil)
+		req.Header.Set(SessionHeader, session)
+		rt.Routing().ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	if v := m.Requests.Value("/hello/{name}", "GET", "200"); v != 2 {
+		t.Errorf("expected 2 answered requests, got %v", v)
+	}
+	if v := m.Requests.Value("/hello/{name}", "GET", "401"); v != 1 {
+		t.Errorf("expected 1 rejected request, got %v", v)
+	}
+	if v := m.Errors.Value("401"); v != 1 {
+		t.Errorf("expected 1 error, got %v", v)
+	}
+	if n := m.Duration.Count("/hello/{name}", "GET"); n != 3 {
+		t.Errorf("expected 3 timings, got %d", n)
+	}
+}
+
+func TestMetricsMethods(t *testing.T) {
+	m := NewRouteMetrics(metrics.NewRegistry())
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(Metrics(m))
+	rt.Mount("/dav", "Greeter.Hello").Public()
+
+	for _, method := range []string{"PROPFIND", "BREW", "XYZZY1", "get"} {
+		rt.Routing().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, "/dav", nil))
+	}
+
+	if v := m.Requests.Value("/dav", "PROPFIND", "200"); v != 1 {
+		t.Errorf("expected 1 PROPFIND, got %v", v)
+	}
+	if v := m.Requests.Value("/dav", "other", "200"); v != 3 {
+		t.Errorf("expected 3 other methods, got %v", v)
+	}
+	if n := m.Duration.Count("/dav", "BREW"); n != 0 {
+		t.Errorf("made up method got a series, %d timings", n)
+	}
+}
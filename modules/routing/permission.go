@@ -0,0 +1,94 @@
+package routing
+
+import (
+	"errors"
+	"strings"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+// actions maps the verb of a permission such as phonebook.write to acl
+// access bits, verbs not listed here use the acl names (create, update, ...).
+var actions = map[string]acl.AccessTypeEnum{
+	"read":   acl.AccessRead,
+	"write":  acl.AccessCreate | acl.AccessUpdate,
+	"delete": acl.AccessDelete,
+}
+
+// HasAccess checks a user against the grants of the user and its groups.
+var HasAccess = func(user *acl.User, accessId string, access acl.AccessTypeEnum) bool {
+	return acl.HasAccess(user.LoginID, acl.IDTypeUser, accessId, access)
+}
+
+// ParsePermission splits a permission like phonebook.read into the acl
+// access id and access bits.
+func ParsePermission(p string) (string, acl.AccessTypeEnum, error) {
+	i := strings.LastIndex(p, ".")
+	if i <= 0 || i == len(p)-1 {
+		return "", 0, errors.New("Invalid permission " + p)
+	}
+
+	accessId, verb := p[:i], p[i+1:]
+
+	access, ok := actions[verb]
+	if !ok {
+		access = acl.GetAccessEnum(verb)
+	}
+
+	if access == 0 {
+		return "", 0, errors.New("Invalid permission " + p)
+	}
+
+	return accessId, access, nil
+}
+
+// Require declares the permission a user needs to call the route, it is
+// checked by Router.Authorize.
+func (r *Route) Require(permission string) *Route {
+	if _, _, err := ParsePermission(permission); err != nil {
+		panic(err)
+	}
+
+	r.Permission = permission
+
+	return r
+}
+
+// Authorize is a middleware answering 403 when the session user lacks the
+// permission the route requires. It runs after SessionAuth.
+func (rt *Router) Authorize(next HandlerFunc) HandlerFunc {
+	return func(wc *WeContent) interface{} {
+		if wc.Route == nil || wc.Route.Permission == "" {
+			return next(wc)
+		}
+
+		if wc.User == nil {
+			return wc.Unauthorized(errors.New("Login required"))
+		}
+
+		if !wc.Can(wc.Route.Permission) {
+			return wc.Forbidden(errors.New("Permission " + wc.Route.Permission + " required"))
+		}
+
+		return next(wc)
+	}
+}
+
+// Can reports whether the session user holds permission, for controllers
+// needing more than the permission of their route.
+func (wc *WeContent) Can(permission string) bool {
+	if wc.User == nil {
+		return false
+	}
+
+	accessId, access, err := ParsePermission(permission)
+	if err != nil {
+		return false
+	}
+
+	if wc.Token != nil && !inScope(TokenScopes(wc.Token), accessId, access) {
+		return false
+	}
+
+	return HasAccess(wc.User, accessId, access)
+}
@@ -0,0 +1,56 @@
+package routing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+func TestParsePermission(t *testing.T) {
+	id, access, err := ParsePermission("phonebook.write")
+	if err != nil || id != "phonebook" || access != acl.AccessCreate|acl.AccessUpdate {
+		t.Errorf("unexpected %q %d %v", id, access, err)
+	}
+
+	if _, _, err := ParsePermission("phonebook.fly"); err == nil {
+		t.Error("expected error for unknown verb")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		return &acl.User{LoginID: sessionId, Enable: true}, nil
+	}
+	HasAccess = func(user *acl.User, accessId string, access acl.AccessTypeEnum) bool {
+		return user.LoginID == "admin" || access == acl.AccessRead
+	}
+
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(rt.SessionAuth, rt.Authorize)
+	rt.Get("/read", "Greeter.Hello").Require("phonebook.read")
+	rt.Delete("/delete", "Greeter.Hello").Require("phonebook.delete")
+
+	cases := []struct {
+		path string
+		user string
+		code int
+	}{
+		{"/read", "tias", 200},
+		{"/delete", "tias", 403},
+		{"/delete", "admin", 200},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set(SessionHeader, c.user)
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code {
+			t.Errorf("%s as %s: expected %d, got %d", c.path, c.user, c.code, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package routing
+
+import (
+	"errors"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Limit is a token bucket holding Burst requests, refilled with Requests
+// every Per. A zero Requests means unlimited.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+	// Burst is the bucket size, Requests when 0
+	Burst int
+}
+
+func (l Limit) unlimited() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+func (l Limit) size() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+
+	return float64(l.Requests)
+}
+
+// rate is the refill in requests per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// RateLimit gives the route its own limit instead of the default of the
+// RateLimiter, RateLimit(0, 0) lifts the limit.
+func (r *Route) RateLimit(requests int, per time.Duration) *Route {
+	r.Limit = &Limit{Requests: requests, Per: per}
+
+	return r
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	// full is when the bucket is refilled, it can be forgotten after
+	full time.Time
+}
+
+// RateLimiter keeps a token bucket per route and client, a client being
+// the API key, the user or the IP address of the request, and one per IP
+// address for all routes together.
+type RateLimiter struct {
+	// Default applies to the routes without a limit of their own
+	Default Limit
+	// Address applies to all the requests of an IP address, see ThrottleAddress
+	Address Limit
+	// TrustProxy takes the client address from X-Forwarded-For, as set
+	// by the proxy in front of the server
+	TrustProxy bool
+	// Now is the clock, replaced by tests
+	Now func() time.Time
+
+	lock      sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewRateLimiter(def Limit) *RateLimiter {
+	return &RateLimiter{Default: def, Now: time.Now, buckets: map[string]*bucket{}}
+}
+
+// ThrottleAddress is a middleware answering 429 with Retry-After once an IP
+// address used up the Address bucket. It runs before SessionAuth, so it
+// also counts the requests failing authentication and spares the session
+// and key lookups to the requests it refuses.
+func (l *RateLimiter) ThrottleAddress(next HandlerFunc) HandlerFunc {
+	return func(wc *WeContent) interface{} {
+		if l.Address.unlimited() {
+			return next(wc)
+		}
+
+		if ok, _, _, retry := l.take("addr:"+l.clientIP(wc.Req), l.Address); !ok {
+			wc.Writer.Header().Set("Retry-After", strconv.Itoa(retry))
+			return wc.TooManyRequests(errors.New("Rate limit exceeded, retry in " + strconv.Itoa(retry) + " seconds"))
+		}
+
+		return next(wc)
+	}
+}
+
+// Throttle is a middleware answering 429 with Retry-After once a client used
+// up the bucket of a route. Every limited response carries the
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers. It runs
+// after SessionAuth so users and API keys are known.
+func (l *RateLimiter) Throttle(next HandlerFunc) HandlerFunc {
+	return func(wc *WeContent) interface{} {
+		limit := l.Default
+		path := ""
+		if wc.Route != nil {
+			path = wc.Route.Path
+			if wc.Route.Limit != nil {
+				limit = *wc.Route.Limit
+			}
+		}
+
+		if limit.unlimited() {
+			return next(wc)
+		}
+
+		ok, remaining, reset, retry := l.take(path+" "+l.ClientKey(wc), limit)
+
+		h := wc.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(int(limit.size())))
+		h.Set("RateLimit-Remaining", strconv.Itoa(remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !ok {
+			h.Set("Retry-After", strconv.Itoa(retry))
+			return wc.TooManyRequests(errors.New("Rate limit exceeded, retry in " + strconv.Itoa(retry) + " seconds"))
+		}
+
+		return next(wc)
+	}
+}
+
+// ClientKey names who a request is counted against: the API key, else the
+// user, else the client address.
+func (l *RateLimiter) ClientKey(wc *WeContent) string {
+	if wc.Token != nil {
+		return "key:" + wc.Token.ID
+	}
+
+	if wc.User != nil {
+		return "user:" + wc.User.ID
+	}
+
+	return "ip:" + l.clientIP(wc.Req)
+}
+
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.TrustProxy {
+		// the last address is the one our proxy saw, earlier ones are
+		// whatever the client sent
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); ip != "" {
+			return ip
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+
+	return r.RemoteAddr
+}
+
+// take removes a request from the bucket of key and returns whether there
+// was one, the requests left, the seconds until the bucket is full again
+// and, when refused, the seconds until the next request is allowed.
+func (l *RateLimiter) take(key string, limit Limit) (bool, int, int, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.Now()
+	if l.buckets == nil {
+		l.buckets = map[string]*bucket{}
+	}
+	l.sweep(now)
+
+	size, rate := limit.size(), limit.rate()
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: size, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(size, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	ok := b.tokens >= 1
+	if ok {
+		b.tokens--
+	}
+
+	refill := (size - b.tokens) / rate
+	b.full = now.Add(time.Duration(refill * float64(time.Second)))
+
+	reset := int(math.Ceil(refill))
+	retry := 0
+	if !ok {
+		retry = int(math.Ceil((1 - b.tokens) / rate))
+	}
+
+	return ok, int(b.tokens), reset, retry
+}
+
+// sweep forgets the buckets that are full again, a new one is the same,
+// at most once a minute.
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if !now.Before(b.full) {
+			delete(l.buckets, key)
+		}
+	}
+}
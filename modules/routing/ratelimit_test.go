@@ -0,0 +1,138 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+func TestRateLimiter(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		return &acl.User{ID: sessionId, LoginID: sessionId, Enable: true}, nil
+	}
+
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewRateLimiter(Limit{Requests: 2, Per: time.Minute})
+	limiter.Now = func() time.Time { return now }
+
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(rt.SessionAuth, limiter.Throttle)
+	rt.Get("/hello", "Greeter.Hello").Public()
+	rt.Get("/login", "Greeter.Hello").Public().RateLimit(1, time.Hour)
+	rt.Get("/free", "Greeter.Hello").Public().RateLimit(0, 0)
+
+	call := func(path, user, addr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = addr
+		if user != "" {
+			req.Header.Set(SessionHeader, user)
+		}
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i, remaining := range []string{"1", "0"} {
+		rec := call("/hello", "", "10.0.0.1:1234")
+		if rec.Code != 200 || rec.Header().Get("RateLimit-Remaining") != remaining || rec.Header().Get("RateLimit-Limit") != "2" {
+			t.Fatalf("request %d: got %d with %v", i, rec.Code, rec.Header())
+		}
+	}
+
+	rec := call("/hello", "", "10.0.0.1:1234")
+	if rec.Code != 429 || rec.Header().Get("Retry-After") != "30" || rec.Header().Get("RateLimit-Reset") != "60" {
+		t.Fatalf("expected 429 retrying in 30s, got %d with %v", rec.Code, rec.Header())
+	}
+
+	// other clients and routes have buckets of their own
+	if rec := call("/hello", "", "10.0.0.2:1234"); rec.Code != 200 {
+		t.Errorf("another address: got %d", rec.Code)
+	}
+	if rec := call("/hello", "tias", "10.0.0.1:1234"); rec.Code != 200 {
+		t.Errorf("a user on the same address: got %d", rec.Code)
+	}
+	if rec := call("/login", "", "10.0.0.1:1234"); rec.Code != 200 || rec.Header().Get("RateLimit-Limit") != "1" {
+		t.Errorf("route limit: got %d with %v", rec.Code, rec.Header())
+	}
+	if rec := call("/login", "", "10.0.0.1:1234"); rec.Code != 429 || rec.Header().Get("Retry-After") != "3600" {
+		t.Errorf("route limit exceeded: got %d with %v", rec.Code, rec.Header())
+	}
+	for i := 0; i < 5; i++ {
+		if rec := call("/free", "", "10.0.0.1:1234"); rec.Code != 200 || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatalf("unlimited route: got %d with %v", rec.Code, rec.Header())
+		}
+	}
+
+	now = now.Add(30 * time.Second)
+	if rec := call("/hello", "", "10.0.0.1:1234"); rec.Code != 200 {
+		t.Errorf("after 30s: got %d", rec.Code)
+	}
+
+	now = now.Add(2 * time.Hour)
+	limiter.take("sweep", Limit{Requests: 1, Per: time.Second})
+	if len(limiter.buckets) != 1 {
+		t.Errorf("expected the full buckets to be swept, %d left", len(limiter.buckets))
+	}
+}
+
+func TestRateLimiterTrustProxy(t *testing.T) {
+	limiter := NewRateLimiter(Limit{})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4, 192.0.2.7")
+	wc := &WeContent{Req: req}
+
+	if key := limiter.ClientKey(wc); key != "ip:10.0.0.1" {
+		t.Errorf("expected the remote address, got %s", key)
+	}
+
+	limiter.TrustProxy = true
+	if key := limiter.ClientKey(wc); key != "ip:192.0.2.7" {
+		t.Errorf("expected the address the proxy saw, got %s", key)
+	}
+}
+
+func TestRateLimiterAddress(t *testing.T) {
+	lookups := 0
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		lookups++
+		return nil, errors.New("session expired")
+	}
+
+	limiter := NewRateLimiter(Limit{})
+	limiter.Address = Limit{Requests: 3, Per: time.Minute}
+
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(limiter.ThrottleAddress, rt.SessionAuth, limiter.Throttle)
+	rt.Get("/hello", "Greeter.Hello")
+
+	call := func(addr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/hello", nil)
+		req.RemoteAddr = addr
+		req.Header.Set(SessionHeader, "guess")
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := call("10.0.0.1:1234"); code != http.StatusUnauthorized {
+			t.Fatalf("request %d: got %d", i, code)
+		}
+	}
+
+	// failed authentications count, and refused requests look nothing up
+	if code := call("10.0.0.1:1234"); code != http.StatusTooManyRequests || lookups != 3 {
+		t.Errorf("expected 429 without a lookup, got %d after %d lookups", code, lookups)
+	}
+	if code := call("10.0.0.2:1234"); code != http.StatusUnauthorized {
+		t.Errorf("another address: got %d", code)
+	}
+}
@@ -2,7 +2,7 @@ package routing
 
 import (
 	"errors"
-	"fmt"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
 	"net/http"
 	"reflect"
 	"strings"
@@ -13,16 +13,39 @@ import (
 type HttpMethod string
 
 var (
-	get    HttpMethod = "GET"
-	post   HttpMethod = "POST"
-	put    HttpMethod = "PUT"
-	delete HttpMethod = "DELETE"
+	get        HttpMethod = "GET"
+	post       HttpMethod = "POST"
+	put        HttpMethod = "PUT"
+	httpDelete HttpMethod = "DELETE"
+	// every method, see Mount
+	anyMethod HttpMethod = "*"
 )
 
-type webContext struct {
-	Class  string
-	Func   func(*WeContent) interface{}
-	Method HttpMethod
+type HandlerFunc func(*WeContent) interface{}
+
+// Middleware wraps the handler of every registered route, see Router.Use.
+type Middleware func(HandlerFunc) HandlerFunc
+
+type Route struct {
+	Path       string
+	Class      string
+	Func       HandlerFunc
+	Method     HttpMethod
+	IsPublic   bool
+	Permission string
+	// Realm enables HTTP basic authentication, see BasicAuth
+	Realm string
+	// Limit overrides the default of the RateLimiter, see RateLimit
+	Limit *Limit
+	// Doc describes the route for the API reference, see Describe
+	Doc *Doc
+}
+
+// Public lets the route through without an authenticated session.
+func (r *Route) Public() *Route {
+	r.IsPublic = true
+
+	return r
 }
 
 type Router struct {
@@ -30,7 +53,10 @@ type Router struct {
 	ClassList      []interface{}
 	GorillaMux     *mux.Router
 
-	UrlPath map[string]webContext
+	UrlPath     map[string]*Route
+	middlewares []Middleware
+	// routes keeps the registration order for Routes
+	routes []*Route
 }
 
 func NewRouting(ControllerPath string, ClassList []interface{}) *Router {
@@ -45,7 +71,7 @@ func NewRouting(ControllerPath string, ClassList []interface{}) *Router {
 	return r
 }
 
-func (rt *Router) ScanningClass(c string) (func(*WeContent) interface{}, error) {
+func (rt *Router) ScanningClass(c string) (HandlerFunc, error) {
 	ar := strings.Split(c, ".")
 	if len(ar) != 2 {
 		return nil, errors.New("Invalid class name")
@@ -74,8 +100,6 @@ func (rt *Router) ScanningClass(c string) (func(*WeContent) interface{}, error)
 
 					return fnc, nil
 				}
-
-				fmt.Println("method", method.Name)
 			}
 		}
 	}
@@ -85,56 +109,95 @@ func (rt *Router) ScanningClass(c string) (func(*WeContent) interface{}, error)
 
 func (rt *Router) url() *Router {
 	if rt.UrlPath == nil {
-		rt.UrlPath = map[string]webContext{}
+		rt.UrlPath = map[string]*Route{}
 	}
 
 	return rt
 }
 
-func (rt *Router) registerController(path string, c string, m HttpMethod) {
-	if v, err := rt.ScanningClass(c); err == nil {
-		fmt.Println("Path >>", path, "class", c)
+// Use appends middlewares, they run in the order added for every route,
+// including the routes registered before the call.
+func (rt *Router) Use(m ...Middleware) {
+	rt.middlewares = append(rt.middlewares, m...)
+}
 
-		rt.GorillaMux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			fmt.Println(fmt.Sprintf("%+v", v), path)
+func (rt *Router) registerController(path string, c string, m HttpMethod) *Route {
+	route := &Route{Path: path, Class: c, Method: m}
 
-			wc := new(WeContent)
-			wc.Writer = w
-			wc.Req = r
-			wc.vars = mux.Vars(r)
+	if v, err := rt.ScanningClass(c); err == nil {
+		logger.Debug("route", "path", path, "class", c)
 
-			data := v(wc)
+		route.Func = v
+		route.Doc = rt.controllerDoc(c)
+		rt.url().UrlPath[path] = route
+		rt.routes = append(rt.routes, route)
 
-			wc.Return(data.([]byte))
-		})
+		if route.Method == anyMethod {
+			rt.GorillaMux.PathPrefix(path).HandlerFunc(rt.handler(route))
+		} else {
+			rt.GorillaMux.HandleFunc(path, rt.handler(route))
+		}
+	} else {
+		logger.Error("route not registered", "path", path, "class", c, "error", err)
 	}
+
+	return route
 }
 
-func (rt *Router) Get(path string, c string) {
-	rt.registerController(path, c, get)
+func (rt *Router) handler(route *Route) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		wc := new(WeContent)
+		wc.Writer = w
+		wc.Req = r
+		wc.vars = mux.Vars(r)
+		wc.Route = route
+
+		h := route.Func
+		for i := len(rt.middlewares) - 1; i >= 0; i-- {
+			h = rt.middlewares[i](h)
+		}
+
+		data := h(wc)
+
+		if b, ok := data.([]byte); ok {
+			wc.Return(b)
+		}
+	}
 }
 
-func (rt *Router) Post(path string, c string) {
-	rt.registerController(path, c, post)
+func (rt *Router) Get(path string, c string) *Route {
+	return rt.registerController(path, c, get)
 }
 
-func (rt *Router) Put(path string, c string) {
-	rt.registerController(path, c, put)
+func (rt *Router) Post(path string, c string) *Route {
+	return rt.registerController(path, c, post)
 }
 
-func (rt *Router) Delete(path string, c string) {
-	rt.registerController(path, c, delete)
+func (rt *Router) Put(path string, c string) *Route {
+	return rt.registerController(path, c, put)
 }
 
-func (rt *Router) Dispatch() *Router {
-	// for k, v := range rt.url().UrlPath {
-	// 	fmt.Println("Registering", k)
+func (rt *Router) Delete(path string, c string) *Route {
+	return rt.registerController(path, c, httpDelete)
+}
 
-	// }
+// Mount registers the controller for every method and every path starting
+// with prefix, for protocols such as WebDAV that own a whole tree.
+func (rt *Router) Mount(prefix string, c string) *Route {
+	return rt.registerController(prefix, c, anyMethod)
+}
 
+func (rt *Router) Dispatch() *Router {
 	return rt
 }
 
 func (rt *Router) Routing() *mux.Router {
 	return rt.GorillaMux
 }
+
+// Handle registers a plain handler that bypasses the middlewares, for
+// endpoints such as health probes that must answer whatever the state of
+// sessions and the database.
+func (rt *Router) Handle(path string, h http.HandlerFunc) {
+	rt.GorillaMux.HandleFunc(path, h)
+}
@@ -0,0 +1,113 @@
+package routing
+
+import (
+	"errors"
+	"net"
+	"strings"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+const (
+	TenantHeader = "X-Tenant-Id"
+	// tenantLoginConf is the acl.User LoginConf key naming the user's tenant
+	tenantLoginConf = "tenant"
+	// TenantSwitch lets users act in tenants other than their own
+	TenantSwitch = "tenant.read"
+)
+
+type TenantConfig struct {
+	// Domain enables tenants as subdomains, acme.phonebook.example.com
+	// resolves to acme when Domain is phonebook.example.com
+	Domain string
+	// Default is the tenant of users and public requests naming none
+	Default string
+	// Find fails for unknown or disabled tenants
+	Find func(id string) error
+}
+
+// UserTenant is the tenant a user belongs to, empty when not set.
+func UserTenant(u *acl.User) string {
+	if u == nil || u.LoginConf == nil {
+		return ""
+	}
+
+	return u.LoginConf.GetString(tenantLoginConf)
+}
+
+// SetUserTenant assigns a user to a tenant.
+func SetUserTenant(u *acl.User, tenant string) {
+	if u.LoginConf == nil {
+		u.LoginConf = map[string]interface{}{}
+	}
+
+	u.LoginConf.Set(tenantLoginConf, tenant)
+}
+
+// RequestedTenant reads the tenant a request names in the X-Tenant-Id
+// header or, when domain is set, as subdomain.
+func RequestedTenant(wc *WeContent, domain string) string {
+	if v := strings.TrimSpace(wc.Req.Header.Get(TenantHeader)); v != "" {
+		return strings.ToLower(v)
+	}
+
+	if domain == "" {
+		return ""
+	}
+
+	host := wc.Req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	host = strings.ToLower(host)
+	suffix := "." + strings.ToLower(domain)
+	if !strings.HasSuffix(host, suffix) {
+		return ""
+	}
+
+	sub := strings.TrimSuffix(host, suffix)
+	if strings.Contains(sub, ".") {
+		return ""
+	}
+
+	return sub
+}
+
+// Tenants is a middleware resolving the tenant of each request into
+// WeContent.TenantID. Users work in their own tenant, only holders of
+// TenantSwitch may name another one. It runs after SessionAuth.
+func Tenants(cfg TenantConfig) Middleware {
+	return func(next HandlerFunc) HandlerFunc {
+		return func(wc *WeContent) interface{} {
+			tenant := RequestedTenant(wc, cfg.Domain)
+
+			if wc.User != nil {
+				own := UserTenant(wc.User)
+				if own == "" {
+					own = cfg.Default
+				}
+
+				if tenant == "" {
+					tenant = own
+				} else if tenant != own && !wc.Can(TenantSwitch) {
+					return wc.Forbidden(errors.New("Tenant " + tenant + " is not yours"))
+				}
+			}
+
+			if tenant == "" {
+				tenant = cfg.Default
+			}
+
+			if cfg.Find != nil {
+				if err := cfg.Find(tenant); err != nil {
+					return wc.NotFound(err)
+				}
+			}
+
+			wc.TenantID = tenant
+
+			return next(wc)
+		}
+	}
+}
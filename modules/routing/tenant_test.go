@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+type TenantEcho struct {
+	*BaseController
+}
+
+func (e *TenantEcho) Hello(r *WeContent) interface{} {
+	return []byte(r.TenantID)
+}
+
+func TestTenants(t *testing.T) {
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		u := &acl.User{LoginID: sessionId, Enable: true}
+		if sessionId == "tias" {
+			SetUserTenant(u, "acme")
+		}
+		return u, nil
+	}
+	HasAccess = func(user *acl.User, accessId string, access acl.AccessTypeEnum) bool {
+		return user.LoginID == "admin"
+	}
+
+	rt := NewRouting("", []interface{}{&TenantEcho{}})
+	rt.Use(rt.SessionAuth, Tenants(TenantConfig{
+		Domain:  "phonebook.example.com",
+		Default: "default",
+		Find: func(id string) error {
+			if id == "gone" {
+				return errors.New("Tenant gone not found")
+			}
+			return nil
+		},
+	}))
+	rt.Get("/public", "TenantEcho.Hello").Public()
+	rt.Get("/private", "TenantEcho.Hello")
+
+	cases := []struct {
+		path   string
+		user   string
+		host   string
+		header string
+		code   int
+		body   string
+	}{
+		{"/public", "", "", "", 200, "default"},
+		{"/public", "", "acme.phonebook.example.com", "", 200, "acme"},
+		{"/public", "", "a.b.phonebook.example.com", "", 200, "default"},
+		{"/private", "tias", "", "", 200, "acme"},
+		{"/private", "tias", "acme.phonebook.example.com:3030", "", 200, "acme"},
+		{"/private", "tias", "", "Acme", 200, "acme"},
+		{"/private", "tias", "", "globex", 403, ""},
+		{"/private", "bob", "", "", 200, "default"},
+		{"/private", "admin", "", "globex", 200, "globex"},
+		{"/private", "admin", "", "gone", 404, ""},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		if c.user != "" {
+			req.Header.Set(SessionHeader, c.user)
+		}
+		if c.host != "" {
+			req.Host = c.host
+		}
+		if c.header != "" {
+			req.Header.Set(TenantHeader, c.header)
+		}
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code || (c.code == 200 && rec.Body.String() != c.body) {
+			t.Errorf("%s as %q on %q with %q: got %d %q", c.path, c.user, c.host, c.header, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package routing
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+const (
+	// TokenPurpose marks the acl tokens that are API keys, acl also keeps
+	// password reset tokens in the same collection.
+	TokenPurpose = "apikey"
+	// TokenPrefix starts every API key so leaked keys are easy to grep for.
+	TokenPrefix = "pb_"
+
+	tokenLength   = 40
+	tokenAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+)
+
+// NewToken creates an API key for userId limited to scopes. Only the
+// sha256 of the key is stored as the acl token ID, the key itself is
+// returned once. A zero validity never expires.
+//
+// The acl token fields are used as: Data1 the name, Data2 the comma
+// separated scopes, Data3 the visible start of the key, Claimed the time
+// the key was revoked.
+func NewToken(userId, name string, scopes []string, validity time.Duration) (string, *acl.Token, error) {
+	if len(scopes) == 0 {
+		return "", nil, errors.New("At least one scope is required")
+	}
+
+	for _, s := range scopes {
+		if _, _, err := ParsePermission(s); err != nil {
+			return "", nil, err
+		}
+	}
+
+	key := TokenPrefix
+	for i := 0; i < tokenLength; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(tokenAlphabet))))
+		if err != nil {
+			return "", nil, err
+		}
+		key += string(tokenAlphabet[n.Int64()])
+	}
+
+	t := &acl.Token{
+		ID:      HashToken(key),
+		UserID:  userId,
+		Created: time.Now().UTC(),
+		Purpose: TokenPurpose,
+		Data1:   name,
+		Data2:   strings.Join(scopes, ","),
+		Data3:   key[:len(TokenPrefix)+4],
+	}
+	if validity > 0 {
+		t.Expired = t.Created.Add(validity)
+	}
+
+	return key, t, nil
+}
+
+func HashToken(key string) string {
+	sum := sha256.Sum256([]byte(key))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// TokenScopes lists the permissions an API key was limited to.
+func TokenScopes(t *acl.Token) []string {
+	if t.Data2 == "" {
+		return []string{}
+	}
+
+	return strings.Split(t.Data2, ",")
+}
+
+// TokenActive reports whether an API key is neither revoked nor expired.
+func TokenActive(t *acl.Token) bool {
+	return t.Purpose == TokenPurpose && t.Claimed.IsZero() && (t.Expired.IsZero() || time.Now().Before(t.Expired))
+}
+
+// FindTokenUser resolves an API key to its acl token and user.
+var FindTokenUser = func(key string) (*acl.Token, *acl.User, error) {
+	t := new(acl.Token)
+	if err := acl.FindByID(t, HashToken(key)); err != nil || t.ID == "" {
+		return nil, nil, errors.New("Invalid token")
+	}
+
+	if !TokenActive(t) {
+		return nil, nil, errors.New("Token is revoked or expired")
+	}
+
+	user := new(acl.User)
+	if err := acl.FindByID(user, t.UserID); err != nil {
+		return nil, nil, err
+	}
+
+	if !user.Enable {
+		return nil, nil, errors.New("User is not active")
+	}
+
+	return t, user, nil
+}
+
+// BearerToken reads the API key of an Authorization: Bearer header.
+func BearerToken(r *http.Request) string {
+	v := r.Header.Get("Authorization")
+	if len(v) > 7 && strings.EqualFold(v[:7], "Bearer ") {
+		return strings.TrimSpace(v[7:])
+	}
+
+	return ""
+}
+
+// inScope checks a permission against the scopes of an API key, a scope
+// grants the access bits it names on its access id.
+func inScope(scopes []string, accessId string, access acl.AccessTypeEnum) bool {
+	for _, s := range scopes {
+		id, bits, err := ParsePermission(s)
+		if err == nil && id == accessId && bits&access == access {
+			return true
+		}
+	}
+
+	return false
+}
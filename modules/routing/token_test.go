@@ -0,0 +1,85 @@
+package routing
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	acl "github.com/eaciit/acl/v1.0"
+)
+
+func TestNewToken(t *testing.T) {
+	key, token, err := NewToken("u1", "crm", []string{"phonebook.read"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(key, TokenPrefix) || token.ID != HashToken(key) || strings.Contains(token.ID, key) {
+		t.Errorf("unexpected key %q for token %+v", key, token)
+	}
+
+	if !TokenActive(token) {
+		t.Error("expected a new token to be active")
+	}
+
+	token.Claimed = time.Now()
+	if TokenActive(token) {
+		t.Error("expected a revoked token to be inactive")
+	}
+
+	if _, _, err := NewToken("u1", "crm", nil, 0); err == nil {
+		t.Error("expected an error without scopes")
+	}
+
+	if _, _, err := NewToken("u1", "crm", []string{"phonebook"}, 0); err == nil {
+		t.Error("expected an error for an invalid scope")
+	}
+}
+
+func TestBearerToken(t *testing.T) {
+	key, token, _ := NewToken("u1", "crm", []string{"phonebook.read"}, 0)
+
+	FindSessionUser = func(sessionId string) (*acl.User, error) {
+		return nil, errors.New("Session is not active")
+	}
+	FindTokenUser = func(k string) (*acl.Token, *acl.User, error) {
+		if HashToken(k) != token.ID {
+			return nil, nil, errors.New("Invalid token")
+		}
+		return token, &acl.User{ID: "u1", LoginID: "crm", Enable: true}, nil
+	}
+	// the user could delete, the key is limited to reading
+	HasAccess = func(user *acl.User, accessId string, access acl.AccessTypeEnum) bool {
+		return true
+	}
+
+	rt := NewRouting("", []interface{}{&Greeter{}})
+	rt.Use(rt.SessionAuth, rt.Authorize)
+	rt.Get("/read", "Greeter.Hello").Require("phonebook.read")
+	rt.Delete("/delete", "Greeter.Hello").Require("phonebook.delete")
+
+	cases := []struct {
+		path string
+		key  string
+		code int
+	}{
+		{"/read", key, 200},
+		{"/read", "pb_wrong", 401},
+		{"/delete", key, 403},
+	}
+
+	for _, c := range cases {
+		req := httptest.NewRequest(http.MethodGet, c.path, nil)
+		req.Header.Set("Authorization", "Bearer "+c.key)
+
+		rec := httptest.NewRecorder()
+		rt.Routing().ServeHTTP(rec, req)
+
+		if rec.Code != c.code {
+			t.Errorf("%s with %s: expected %d, got %d", c.path, c.key, c.code, rec.Code)
+		}
+	}
+}
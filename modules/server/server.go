@@ -0,0 +1,266 @@
+// Package server runs the HTTP server with timeouts and shuts it down on
+// SIGINT or SIGTERM, draining in-flight requests before releasing the
+// resources of the application.
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+// Config holds the limits of the HTTP server, zero values keep the
+// defaults below.
+type Config struct {
+	Addr              string
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ShutdownTimeout bounds the drain of in-flight requests and the closers
+	ShutdownTimeout time.Duration
+	// TLS serves HTTPS on Addr when set, see NewTLSConfig
+	TLS *tls.Config
+	// RedirectAddr listens for plain HTTP and redirects to HTTPS, TLS only
+	RedirectAddr string
+}
+
+const (
+	DefaultReadTimeout       = 30 * time.Second
+	DefaultReadHeaderTimeout = 10 * time.Second
+	DefaultWriteTimeout      = 60 * time.Second
+	DefaultIdleTimeout       = 120 * time.Second
+	DefaultMaxHeaderBytes    = 1 << 20
+	DefaultShutdownTimeout   = 30 * time.Second
+)
+
+// closer releases a resource on shutdown, such as a database pool.
+type closer struct {
+	name  string
+	close func(ctx context.Context) error
+}
+
+type Server struct {
+	HTTP *http.Server
+	// Redirect is the HTTP to HTTPS redirect server, nil without one
+	Redirect        *http.Server
+	ShutdownTimeout time.Duration
+	// Signals trigger the shutdown, SIGINT and SIGTERM by default
+	Signals []os.Signal
+	Log     *logger.Logger
+
+	lock     sync.Mutex
+	closers  []closer
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func New(cfg Config, h http.Handler) *Server {
+	s := &http.Server{
+		Addr:              cfg.Addr,
+		Handler:           h,
+		ReadTimeout:       orDefault(cfg.ReadTimeout, DefaultReadTimeout),
+		ReadHeaderTimeout: orDefault(cfg.ReadHeaderTimeout, DefaultReadHeaderTimeout),
+		WriteTimeout:      orDefault(cfg.WriteTimeout, DefaultWriteTimeout),
+		IdleTimeout:       orDefault(cfg.IdleTimeout, DefaultIdleTimeout),
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
+		TLSConfig:         cfg.TLS,
+	}
+	if s.MaxHeaderBytes <= 0 {
+		s.MaxHeaderBytes = DefaultMaxHeaderBytes
+	}
+
+	var redirect *http.Server
+	if cfg.TLS != nil && cfg.RedirectAddr != "" {
+		redirect = &http.Server{
+			Addr:              cfg.RedirectAddr,
+			Handler:           RedirectHandler(cfg.Addr),
+			ReadTimeout:       s.ReadTimeout,
+			ReadHeaderTimeout: s.ReadHeaderTimeout,
+			WriteTimeout:      s.WriteTimeout,
+			IdleTimeout:       s.IdleTimeout,
+			MaxHeaderBytes:    s.MaxHeaderBytes,
+		}
+	}
+
+	return &Server{
+		HTTP:            s,
+		Redirect:        redirect,
+		ShutdownTimeout: orDefault(cfg.ShutdownTimeout, DefaultShutdownTimeout),
+		Signals:         []os.Signal{os.Interrupt, syscall.SIGTERM},
+		Log:             logger.Default,
+		stop:            make(chan struct{}),
+	}
+}
+
+func orDefault(d, def time.Duration) time.Duration {
+	if d <= 0 {
+		return def
+	}
+
+	return d
+}
+
+// OnShutdown registers close to run once the requests are drained. Closers
+// run in the reverse order of registration and share the shutdown deadline.
+func (s *Server) OnShutdown(name string, close func(ctx context.Context) error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.closers = append(s.closers, closer{name, close})
+}
+
+// Stop starts the shutdown as a signal would.
+func (s *Server) Stop() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// ListenAndServe listens on the addresses of the config and serves until a
+// signal or Stop, see Serve.
+func (s *Server) ListenAndServe() error {
+	l, err := net.Listen("tcp", s.HTTP.Addr)
+	if err != nil {
+		return err
+	}
+
+	var redirect net.Listener
+	if s.Redirect != nil {
+		if redirect, err = net.Listen("tcp", s.Redirect.Addr); err != nil {
+			l.Close()
+			return err
+		}
+	}
+
+	return s.serve(l, redirect)
+}
+
+// Serve serves on l until a signal or Stop, then shuts down. It returns nil
+// after a clean shutdown, the error of the server if it failed first, or the
+// first error of the shutdown. The redirect server, if any, is not started.
+func (s *Server) Serve(l net.Listener) error {
+	return s.serve(l, nil)
+}
+
+func (s *Server) serve(l, redirect net.Listener) error {
+	signals := make(chan os.Signal, 1)
+	if len(s.Signals) > 0 {
+		signal.Notify(signals, s.Signals...)
+		defer signal.Stop(signals)
+	}
+
+	// ServeTLS sets up TLSConfig, read it before the server goroutine does
+	useTLS := s.HTTP.TLSConfig != nil
+	served := make(chan error, 2)
+	go func() {
+		if useTLS {
+			served <- s.HTTP.ServeTLS(l, "", "")
+			return
+		}
+		served <- s.HTTP.Serve(l)
+	}()
+	s.Log.Info("HTTP server started", "address", l.Addr().String(), "tls", useTLS)
+
+	if redirect != nil {
+		go func() {
+			served <- s.Redirect.Serve(redirect)
+		}()
+		s.Log.Info("HTTPS redirect started", "address", redirect.Addr().String())
+	}
+
+	select {
+	case err := <-served:
+		if !errors.Is(err, http.ErrServerClosed) {
+			s.shutdown()
+			return err
+		}
+	case sig := <-signals:
+		s.Log.Info("Shutting down", "signal", sig.String())
+	case <-s.stop:
+		s.Log.Info("Shutting down", "signal", "stop")
+	}
+
+	return s.shutdown()
+}
+
+// shutdown drains the requests and runs the closers within ShutdownTimeout,
+// requests still running at the deadline are cut.
+func (s *Server) shutdown() error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), s.ShutdownTimeout)
+	defer cancel()
+
+	var first error
+	fail := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+
+	if s.Redirect != nil {
+		s.Redirect.Shutdown(ctx)
+	}
+
+	if err := s.HTTP.Shutdown(ctx); err != nil {
+		s.Log.Warn("Requests not drained, closing connections", "error", err)
+		s.HTTP.Close()
+		fail(err)
+	}
+
+	s.lock.Lock()
+	closers := append([]closer{}, s.closers...)
+	s.lock.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		c := closers[i]
+		if err := c.close(ctx); err != nil {
+			s.Log.Error("Close failed", "resource", c.name, "error", err)
+			fail(err)
+		}
+	}
+
+	s.Log.Info("Shut down", "duration_ms", float64(time.Since(start).Microseconds())/1000)
+
+	return first
+}
+
+// RedirectHandler sends plain HTTP requests to the same host and path over
+// HTTPS on the port of addr. GET and HEAD are moved permanently, other
+// methods get 308 so clients resend the body.
+func RedirectHandler(addr string) http.Handler {
+	_, port, _ := net.SplitHostPort(addr)
+	if port == "443" {
+		port = ""
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" {
+			host += ":" + port
+		}
+
+		target := "https://" + host + r.URL.RequestURI()
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, target, code)
+	})
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+func newTestServer(t *testing.T, h http.Handler, cfg Config) (*Server, net.Listener) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := New(cfg, h)
+	s.Signals = nil
+	s.Log = logger.New(io.Discard, logger.LevelError)
+
+	return s, l
+}
+
+func TestDefaults(t *testing.T) {
+	s := New(Config{Addr: ":3030", WriteTimeout: time.Minute * 5}, http.NotFoundHandler())
+
+	if s.HTTP.ReadTimeout != DefaultReadTimeout || s.HTTP.IdleTimeout != DefaultIdleTimeout {
+		t.Errorf("timeouts = %v, %v", s.HTTP.ReadTimeout, s.HTTP.IdleTimeout)
+	}
+	if s.HTTP.WriteTimeout != 5*time.Minute {
+		t.Errorf("write timeout = %v", s.HTTP.WriteTimeout)
+	}
+	if s.HTTP.MaxHeaderBytes != DefaultMaxHeaderBytes || s.ShutdownTimeout != DefaultShutdownTimeout {
+		t.Errorf("max header = %d, shutdown = %v", s.HTTP.MaxHeaderBytes, s.ShutdownTimeout)
+	}
+}
+
+func TestShutdownDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("saved"))
+	})
+
+	s, l := newTestServer(t, h, Config{ShutdownTimeout: 5 * time.Second})
+
+	closed := []string{}
+	s.OnShutdown("db", func(ctx context.Context) error {
+		closed = append(closed, "db")
+		return nil
+	})
+	s.OnShutdown("ldap", func(ctx context.Context) error {
+		closed = append(closed, "ldap")
+		return nil
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+
+	<-started
+	s.Stop()
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	if got := <-body; got != "saved" {
+		t.Errorf("body = %q", got)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Serve = %v", err)
+	}
+
+	if len(closed) != 2 || closed[0] != "ldap" || closed[1] != "db" {
+		t.Errorf("closed = %v, want reverse order", closed)
+	}
+}
+
+func TestShutdownDeadline(t *testing.T) {
+	started := make(chan struct{})
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	})
+
+	s, l := newTestServer(t, h, Config{ShutdownTimeout: 50 * time.Millisecond})
+
+	closerErr := errors.New("pool busy")
+	s.OnShutdown("db", func(ctx context.Context) error {
+		<-ctx.Done()
+		return closerErr
+	})
+
+	done := make(chan error, 1)
+	go func() { done <- s.Serve(l) }()
+	go http.Get("http://" + l.Addr().String())
+
+	<-started
+	s.Stop()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Serve = %v, want the drain deadline", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("shutdown did not honour its deadline")
+	}
+}
+
+func TestServeFailure(t *testing.T) {
+	s, l := newTestServer(t, http.NotFoundHandler(), Config{})
+	l.Close()
+
+	closed := false
+	s.OnShutdown("db", func(ctx context.Context) error {
+		closed = true
+		return nil
+	})
+
+	if err := s.Serve(l); err == nil {
+		t.Error("Serve on a closed listener succeeded")
+	}
+	if !closed {
+		t.Error("closers did not run after the failure")
+	}
+}
+
+func TestMaxHeaderBytes(t *testing.T) {
+	s, l := newTestServer(t, http.NotFoundHandler(), Config{MaxHeaderBytes: 1024})
+	go s.Serve(l)
+	defer s.Stop()
+
+	req, _ := http.NewRequest("GET", "http://"+l.Addr().String(), nil)
+	req.Header.Set("X-Big", string(bytes.Repeat([]byte("a"), 8192)))
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("status = %d", resp.StatusCode)
+	}
+}
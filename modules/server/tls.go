@@ -0,0 +1,294 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+)
+
+// TLSConfig describes the HTTPS listener, files are reloaded when they
+// change on disk.
+type TLSConfig struct {
+	CertFile string
+	KeyFile  string
+	// MinVersion is "1.2" or "1.3", "1.2" when empty
+	MinVersion string
+	// CipherSuites are names from crypto/tls such as
+	// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, empty keeps the Go defaults.
+	// They only apply to TLS 1.2, the TLS 1.3 suites are fixed.
+	CipherSuites []string
+	// ClientCAFile verifies client certificates, ClientAuth is "request" to
+	// verify those sent or "require" to refuse connections without one.
+	// "require" applies to the whole listener, browsers and /auth/login
+	// included, so it is for deployments only internal callers reach. A
+	// verified certificate logs in the user named by its common name, see
+	// routing.SessionAuth
+	ClientCAFile string
+	ClientAuth   string
+	// ReloadInterval is how often the files are checked, DefaultReloadInterval when zero
+	ReloadInterval time.Duration
+}
+
+const DefaultReloadInterval = 10 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// NewTLSConfig loads the certificate and client CAs and returns a config
+// that serves the current files.
+func NewTLSConfig(cfg TLSConfig) (*tls.Config, error) {
+	if cfg.CertFile == "" || cfg.KeyFile == "" {
+		return nil, errors.New("TLS needs both a certificate and a key file")
+	}
+
+	version := strings.TrimSpace(cfg.MinVersion)
+	if version == "" {
+		version = "1.2"
+	}
+	minVersion, ok := tlsVersions[version]
+	if !ok {
+		return nil, fmt.Errorf("unsupported minimum TLS version %q, use 1.2 or 1.3", cfg.MinVersion)
+	}
+
+	ciphers, err := cipherSuites(cfg.CipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := cfg.ReloadInterval
+	if interval <= 0 {
+		interval = DefaultReloadInterval
+	}
+
+	certs, err := NewCertReloader(cfg.CertFile, cfg.KeyFile, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// NextProtos is set here, http.Server only adds h2 to the config it is
+	// given, not to those of GetConfigForClient, and the gRPC listener needs it
+	config := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   ciphers,
+		GetCertificate: certs.GetCertificate,
+		NextProtos:     []string{"h2", "http/1.1"},
+	}
+
+	switch cfg.ClientAuth {
+	case "", "none":
+		return config, nil
+	case "request":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		return nil, fmt.Errorf("unsupported client auth %q, use none, request or require", cfg.ClientAuth)
+	}
+
+	if cfg.ClientCAFile == "" {
+		return nil, errors.New("client authentication needs a client CA file")
+	}
+	cas, err := NewCAReloader(cfg.ClientCAFile, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	// the client CAs are part of the config, so a fresh one is handed to
+	// every handshake
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		c := config.Clone()
+		c.GetConfigForClient = nil
+		c.ClientCAs = cas.Pool()
+		return c, nil
+	}
+
+	return config, nil
+}
+
+// cipherSuites maps names to ids, insecure suites are refused
+func cipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	known := map[string]uint16{}
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+
+	ids := []uint16{}
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, nil
+}
+
+// watch reloads a value when the modification time or size of its files
+// change, checking at most once per interval.
+type watch struct {
+	files    []string
+	interval time.Duration
+	load     func() error
+	// now is the clock, replaced by tests
+	now func() time.Time
+
+	lock    sync.Mutex
+	checked time.Time
+	stamp   string
+}
+
+func newWatch(interval time.Duration, load func() error, files ...string) (*watch, error) {
+	w := &watch{files: files, interval: interval, load: load, now: time.Now}
+
+	stamp, err := w.fileStamp()
+	if err != nil {
+		return nil, err
+	}
+	if err := load(); err != nil {
+		return nil, err
+	}
+	w.stamp, w.checked = stamp, w.now()
+
+	return w, nil
+}
+
+func (w *watch) fileStamp() (string, error) {
+	parts := []string{}
+	for _, f := range w.files {
+		info, err := os.Stat(f)
+		if err != nil {
+			return "", err
+		}
+		parts = append(parts, fmt.Sprintf("%d:%d", info.ModTime().UnixNano(), info.Size()))
+	}
+
+	return strings.Join(parts, ","), nil
+}
+
+// check reloads if the files changed, the last good value is kept when
+// they can't be read, e.g. while a deploy is half way through writing them
+func (w *watch) check() {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	now := w.now()
+	if now.Sub(w.checked) < w.interval {
+		return
+	}
+	w.checked = now
+
+	stamp, err := w.fileStamp()
+	if err != nil || stamp == w.stamp {
+		return
+	}
+
+	if err := w.load(); err != nil {
+		logger.Error("Reload TLS files failed", "files", strings.Join(w.files, ","), "error", err)
+		return
+	}
+	w.stamp = stamp
+	logger.Info("Reloaded TLS files", "files", strings.Join(w.files, ","))
+}
+
+// CertReloader serves a key pair, reloading it when the files change.
+type CertReloader struct {
+	*watch
+
+	lock sync.RWMutex
+	cert *tls.Certificate
+}
+
+func NewCertReloader(certFile, keyFile string, interval time.Duration) (*CertReloader, error) {
+	r := &CertReloader{}
+
+	w, err := newWatch(interval, func() error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return err
+		}
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.cert = &cert
+		return nil
+	}, certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	r.watch = w
+
+	return r, nil
+}
+
+// GetCertificate is the tls.Config hook.
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.check()
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.cert, nil
+}
+
+// CAReloader keeps a pool of PEM certificates, reloading it when the file
+// changes.
+type CAReloader struct {
+	*watch
+
+	lock sync.RWMutex
+	pool *x509.CertPool
+}
+
+func NewCAReloader(file string, interval time.Duration) (*CAReloader, error) {
+	r := &CAReloader{}
+
+	w, err := newWatch(interval, func() error {
+		pem, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in %s", file)
+		}
+
+		r.lock.Lock()
+		defer r.lock.Unlock()
+		r.pool = pool
+		return nil
+	}, file)
+	if err != nil {
+		return nil, err
+	}
+	r.watch = w
+
+	return r, nil
+}
+
+func (r *CAReloader) Pool() *x509.CertPool {
+	r.check()
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.pool
+}
@@ -0,0 +1,262 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	kpem []byte
+}
+
+// newCert issues a certificate for name, self-signed when parent is nil
+func newCert(t *testing.T, name string, parent *testCert, ca bool) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  ca,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+
+	signer, signerKey := tmpl, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	kder, _ := x509.MarshalECPrivateKey(key)
+
+	return &testCert{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		kpem: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}),
+	}
+}
+
+func (c *testCert) write(t *testing.T, dir string, mtime time.Time) (string, string) {
+	certFile, keyFile := filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	for file, data := range map[string][]byte{certFile: c.pem, keyFile: c.kpem} {
+		if err := os.WriteFile(file, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file, mtime, mtime)
+	}
+
+	return certFile, keyFile
+}
+
+func (c *testCert) tls() tls.Certificate {
+	cert, _ := tls.X509KeyPair(c.pem, c.kpem)
+	return cert
+}
+
+func TestNewTLSConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := newCert(t, "localhost", nil, false).write(t, dir, time.Now())
+
+	for name, cfg := range map[string]TLSConfig{
+		"no key":        {CertFile: certFile},
+		"version":       {CertFile: certFile, KeyFile: keyFile, MinVersion: "1.0"},
+		"cipher":        {CertFile: certFile, KeyFile: keyFile, CipherSuites: []string{"TLS_RSA_WITH_RC4_128_SHA"}},
+		"client auth":   {CertFile: certFile, KeyFile: keyFile, ClientAuth: "maybe"},
+		"no client ca":  {CertFile: certFile, KeyFile: keyFile, ClientAuth: "require"},
+		"missing files": {CertFile: filepath.Join(dir, "none.pem"), KeyFile: keyFile},
+	} {
+		if _, err := NewTLSConfig(cfg); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+
+	config, err := NewTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3",
+		CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.MinVersion != tls.VersionTLS13 || len(config.CipherSuites) != 1 {
+		t.Errorf("config = %x, %v", config.MinVersion, config.CipherSuites)
+	}
+}
+
+func TestCertReload(t *testing.T) {
+	dir := t.TempDir()
+	first := newCert(t, "first", nil, false)
+	certFile, keyFile := first.write(t, dir, time.Now().Add(-time.Minute))
+
+	r, err := NewCertReloader(certFile, keyFile, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	r.now = func() time.Time { return now }
+
+	second := newCert(t, "second", nil, false)
+	second.write(t, dir, time.Now())
+
+	cert, _ := r.GetCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "first" {
+		t.Errorf("reloaded before the interval: %s", leaf.Subject.CommonName)
+	}
+
+	now = now.Add(2 * time.Second)
+	cert, _ = r.GetCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "second" {
+		t.Errorf("not reloaded: %s", leaf.Subject.CommonName)
+	}
+
+	// a broken file keeps the last good certificate
+	os.WriteFile(keyFile, []byte("garbage"), 0600)
+	os.Chtimes(keyFile, now.Add(time.Hour), now.Add(time.Hour))
+	now = now.Add(2 * time.Second)
+	cert, _ = r.GetCertificate(nil)
+	if leaf, _ := x509.ParseCertificate(cert.Certificate[0]); leaf.Subject.CommonName != "second" {
+		t.Errorf("broken files replaced the certificate: %s", leaf.Subject.CommonName)
+	}
+}
+
+func TestMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "ca", nil, true)
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, ca.pem, 0600)
+
+	serverCert := newCert(t, "127.0.0.1", ca, false)
+	certFile, keyFile := serverCert.write(t, dir, time.Now())
+
+	config, err := NewTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: "require"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, l := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}), Config{TLS: config})
+	go s.Serve(l)
+	defer s.Stop()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	get := func(certs ...tls.Certificate) (string, error) {
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, Certificates: certs}}}
+		resp, err := client.Get("https://" + l.Addr().String())
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b), nil
+	}
+
+	if _, err := get(); err == nil {
+		t.Error("connection without a client certificate accepted")
+	}
+	if _, err := get(newCert(t, "stranger", nil, false).tls()); err == nil {
+		t.Error("client certificate from another CA accepted")
+	}
+	if got, err := get(newCert(t, "billing", ca, false).tls()); err != nil || got != "billing" {
+		t.Errorf("get = %q, %v", got, err)
+	}
+}
+
+func TestTLSNegotiatesHTTP2(t *testing.T) {
+	dir := t.TempDir()
+	ca := newCert(t, "ca", nil, true)
+	caFile := filepath.Join(dir, "ca.pem")
+	os.WriteFile(caFile, ca.pem, 0600)
+	certFile, keyFile := newCert(t, "127.0.0.1", ca, false).write(t, dir, time.Now())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+	client := &tls.Config{RootCAs: roots, Certificates: []tls.Certificate{newCert(t, "billing", ca, false).tls()}, NextProtos: []string{"h2", "http/1.1"}}
+
+	for _, mode := range []string{"none", "request", "require"} {
+		config, err := NewTLSConfig(TLSConfig{CertFile: certFile, KeyFile: keyFile, ClientCAFile: caFile, ClientAuth: mode})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		l, err := tls.Listen("tcp", "127.0.0.1:0", config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		go func() {
+			if conn, err := l.Accept(); err == nil {
+				conn.(*tls.Conn).Handshake()
+				conn.Close()
+			}
+		}()
+
+		conn, err := tls.Dial("tcp", l.Addr().String(), client)
+		if err != nil {
+			t.Fatalf("%s: %v", mode, err)
+		}
+		if proto := conn.ConnectionState().NegotiatedProtocol; proto != "h2" {
+			t.Errorf("%s: negotiated %q", mode, proto)
+		}
+		conn.Close()
+		l.Close()
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	for _, tt := range []struct {
+		addr, method, url, location string
+		code                        int
+	}{
+		{":443", "GET", "http://example.com/phonebook/get?q=a", "https://example.com/phonebook/get?q=a", http.StatusMovedPermanently},
+		{":3443", "GET", "http://example.com:8080/auth/me", "https://example.com:3443/auth/me", http.StatusMovedPermanently},
+		{":443", "POST", "http://example.com/auth/login", "https://example.com/auth/login", http.StatusPermanentRedirect},
+		{":443", "GET", "http://[::1]:80/", "https://[::1]/", http.StatusMovedPermanently},
+	} {
+		w := httptest.NewRecorder()
+		RedirectHandler(tt.addr).ServeHTTP(w, httptest.NewRequest(tt.method, tt.url, nil))
+
+		if w.Code != tt.code || w.Header().Get("Location") != tt.location {
+			t.Errorf("%s %s = %d %s", tt.method, tt.url, w.Code, w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRedirectServer(t *testing.T) {
+	s := New(Config{Addr: "127.0.0.1:0", TLS: &tls.Config{}, RedirectAddr: "127.0.0.1:0"}, http.NotFoundHandler())
+	if s.Redirect == nil {
+		t.Fatal("no redirect server")
+	}
+
+	plain := New(Config{Addr: "127.0.0.1:0", RedirectAddr: "127.0.0.1:0"}, http.NotFoundHandler())
+	if plain.Redirect != nil {
+		t.Error("redirect server without TLS")
+	}
+}
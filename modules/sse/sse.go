@@ -0,0 +1,104 @@
+// Package sse writes server-sent events, the text/event-stream format the
+// browser EventSource reads.
+package sse
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Event is one message of a stream.
+type Event struct {
+	// Id is sent back by the client as Last-Event-ID when it reconnects
+	Id string
+	// Event names the type, EventSource listeners pick events by it
+	Event string
+	Data  []byte
+	// Retry tells the client how long to wait before reconnecting
+	Retry time.Duration
+}
+
+var ErrNotStreaming = errors.New("response writer cannot flush")
+
+// Stream writes events to a response.
+type Stream struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+// Start answers the request with an event stream. The write timeout of the
+// server is lifted, the stream lasts until the handler returns.
+func Start(w http.ResponseWriter) (*Stream, error) {
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return nil, err
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// proxies such as nginx would buffer the stream otherwise
+	h.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	s := &Stream{w: w, rc: rc}
+	if err := rc.Flush(); err != nil {
+		return nil, ErrNotStreaming
+	}
+
+	return s, nil
+}
+
+// Send writes e and flushes it to the client.
+func (s *Stream) Send(e Event) error {
+	b := bytes.Buffer{}
+	if e.Id != "" {
+		b.WriteString("id: " + clean(e.Id) + "\n")
+	}
+	if e.Event != "" {
+		b.WriteString("event: " + clean(e.Event) + "\n")
+	}
+	if e.Retry > 0 {
+		b.WriteString("retry: " + strconv.FormatInt(e.Retry.Milliseconds(), 10) + "\n")
+	}
+	for _, line := range strings.Split(strings.ReplaceAll(string(e.Data), "\r\n", "\n"), "\n") {
+		b.WriteString("data: " + strings.ReplaceAll(line, "\r", "") + "\n")
+	}
+	b.WriteString("\n")
+
+	return s.write(b.Bytes())
+}
+
+// Comment writes a line clients ignore, it keeps idle connections open
+// through proxies.
+func (s *Stream) Comment(text string) error {
+	return s.write([]byte(": " + clean(text) + "\n\n"))
+}
+
+func (s *Stream) write(b []byte) error {
+	if _, err := s.w.Write(b); err != nil {
+		return err
+	}
+
+	return s.rc.Flush()
+}
+
+// clean keeps single line fields on one line.
+func clean(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// LastEventID is the id of the last event the client saw: the Last-Event-ID
+// header of a reconnecting EventSource, or the lastEventId query parameter
+// for clients that cannot set headers on their first request.
+func LastEventID(r *http.Request) string {
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		return id
+	}
+
+	return r.URL.Query().Get("lastEventId")
+}
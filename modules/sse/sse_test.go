@@ -0,0 +1,42 @@
+package sse
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	w := httptest.NewRecorder()
+	s, err := Start(w)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Send(Event{Id: "1", Event: "contact.created", Data: []byte("{\"a\":1}\nsecond\r\nthird"), Retry: 2 * time.Second})
+	s.Send(Event{Id: "2\nevent: forged", Data: nil})
+	s.Comment("ping")
+
+	want := "id: 1\nevent: contact.created\nretry: 2000\ndata: {\"a\":1}\ndata: second\ndata: third\n\n" +
+		"id: 2event: forged\ndata: \n\n" +
+		": ping\n\n"
+	if got := w.Body.String(); got != want {
+		t.Errorf("stream = %q", got)
+	}
+	if w.Header().Get("Content-Type") != "text/event-stream" || !w.Flushed {
+		t.Errorf("headers %v, flushed %v", w.Header(), w.Flushed)
+	}
+}
+
+func TestLastEventID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/events?lastEventId=b", nil)
+	if id := LastEventID(r); id != "b" {
+		t.Errorf("query id = %q", id)
+	}
+
+	r.Header.Set("Last-Event-ID", "a")
+	if id := LastEventID(r); id != "a" {
+		t.Errorf("header id = %q", id)
+	}
+}
@@ -0,0 +1,377 @@
+// Package vcard converts contacts to and from vCard 3.0 (RFC 2426), the
+// format CardDAV clients exchange.
+package vcard
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	model "github.com/tmluthfiana/phonebook/model"
+)
+
+const ContentType = "text/vcard; charset=utf-8"
+
+// phoneTypes maps phone types to the TEL TYPE parameter and back, the
+// first matching TYPE of a TEL wins when decoding.
+var phoneTypes = [][2]string{
+	{"Mobile", "CELL"},
+	{"Home", "HOME"},
+	{"Work", "WORK"},
+	{"Fax", "FAX"},
+	{"Pager", "PAGER"},
+}
+
+// placeTypes maps the email and address types to their TYPE parameter.
+var placeTypes = [][2]string{
+	{model.EmailTypeWork, "WORK"},
+	{model.EmailTypeHome, "HOME"},
+}
+
+// Encode writes c as a vCard with uid as its UID.
+func Encode(c *model.Phonebook, uid string) []byte {
+	w := &writer{}
+
+	w.line("BEGIN", nil, "VCARD")
+	w.line("VERSION", nil, "3.0")
+	w.line("PRODID", nil, "-//phonebook//EN")
+	w.line("UID", nil, escape(uid))
+	w.line("N", nil, join(c.LastName, c.FirstName, "", "", ""))
+	w.line("FN", nil, escape(strings.TrimSpace(c.FirstName+" "+c.LastName)))
+
+	for _, p := range c.PhoneNumber {
+		no := p.PhoneNo
+		if p.PhoneExt != "" {
+			// a comma is a dial pause, so phones dial the extension too
+			no += "," + p.PhoneExt
+		}
+		w.line("TEL", []string{"VOICE", lookup(phoneTypes, p.ProneType, 0, "")}, no)
+	}
+
+	for i, m := range c.Emails {
+		types := []string{"INTERNET", lookup(placeTypes, m.EmailType, 0, "")}
+		if i == 0 {
+			types = append(types, "PREF")
+		}
+		w.line("EMAIL", types, escape(m.EmailAddress))
+	}
+
+	for _, a := range c.Addresses {
+		w.line("ADR", []string{lookup(placeTypes, a.AddressType, 0, "")}, join("", "", a.Street, a.City, a.Region, a.PostalCode, a.Country))
+	}
+
+	if c.Company != "" || c.Department != "" {
+		w.line("ORG", nil, join(c.Company, c.Department))
+	}
+	if c.JobTitle != "" {
+		w.line("TITLE", nil, escape(c.JobTitle))
+	}
+	if c.Website != "" {
+		w.line("URL", nil, c.Website)
+	}
+	if c.Birthday != "" {
+		w.line("BDAY", nil, c.Birthday)
+	}
+	if c.Notes != "" {
+		w.line("NOTE", nil, escape(c.Notes))
+	}
+
+	w.line("REV", nil, c.Modified().UTC().Format("20060102T150405Z"))
+	w.line("END", nil, "VCARD")
+
+	return w.Bytes()
+}
+
+// Decode reads a single vCard into a new contact, the UID is returned
+// apart. Properties without a contact field are dropped.
+func Decode(data []byte) (*model.Phonebook, string, error) {
+	c := &model.Phonebook{}
+	uid := ""
+	begin, end := false, false
+
+	for _, l := range unfold(data) {
+		name, types, value, ok := parse(l)
+		if !ok {
+			continue
+		}
+
+		switch name {
+		case "BEGIN":
+			begin = strings.EqualFold(value, "VCARD")
+		case "END":
+			end = strings.EqualFold(value, "VCARD")
+		case "UID":
+			uid = unescape(value)
+		case "N":
+			n := split(value)
+			c.LastName, c.FirstName = n[0], n[1]
+		case "FN":
+			if c.FirstName == "" && c.LastName == "" {
+				fn := unescape(value)
+				if i := strings.LastIndex(fn, " "); i > 0 {
+					c.FirstName, c.LastName = fn[:i], fn[i+1:]
+				} else {
+					c.FirstName = fn
+				}
+			}
+		case "TEL":
+			p := model.PhoneNumberDetail{PhoneNo: strings.TrimSpace(value), ProneType: "Work"}
+			if i := strings.Index(p.PhoneNo, ","); i > 0 {
+				p.PhoneNo, p.PhoneExt = strings.TrimSpace(p.PhoneNo[:i]), strings.TrimLeft(p.PhoneNo[i+1:], ",")
+			}
+			if t := match(phoneTypes, types); t != "" {
+				p.ProneType = t
+			}
+			c.PhoneNumber = append(c.PhoneNumber, p)
+		case "EMAIL":
+			m := model.EmailDetail{EmailAddress: unescape(value), EmailType: model.EmailTypeOther}
+			if t := match(placeTypes, types); t != "" {
+				m.EmailType = t
+			}
+			if hasType(types, "PREF") {
+				c.Emails = append([]model.EmailDetail{m}, c.Emails...)
+			} else {
+				c.Emails = append(c.Emails, m)
+			}
+		case "ADR":
+			a := split(value)
+			c.Addresses = append(c.Addresses, model.AddressDetail{
+				AddressType: match(placeTypes, types),
+				Street:      a[2],
+				City:        a[3],
+				Region:      a[4],
+				PostalCode:  a[5],
+				Country:     a[6],
+			})
+		case "ORG":
+			o := split(value)
+			c.Company, c.Department = o[0], o[1]
+		case "TITLE":
+			c.JobTitle = unescape(value)
+		case "URL":
+			c.Website = value
+		case "BDAY":
+			c.Birthday = birthday(value)
+		case "NOTE":
+			c.Notes = unescape(value)
+		}
+	}
+
+	if !begin || !end {
+		return nil, "", errors.New("Invalid vCard")
+	}
+
+	c.NormalizeEmail()
+
+	return c, uid, nil
+}
+
+// Split cuts a file of several vCards, as address book exports write
+// them, into single cards for Decode. Lines outside of cards are dropped.
+func Split(data []byte) [][]byte {
+	cards := [][]byte{}
+	var card *bytes.Buffer
+	for _, l := range strings.SplitAfter(string(data), "\n") {
+		name, _, value, ok := parse(strings.TrimRight(l, "\r\n"))
+		if ok && name == "BEGIN" && strings.EqualFold(value, "VCARD") {
+			card = &bytes.Buffer{}
+		}
+		if card == nil {
+			continue
+		}
+
+		card.WriteString(l)
+		if ok && name == "END" && strings.EqualFold(value, "VCARD") {
+			cards = append(cards, card.Bytes())
+			card = nil
+		}
+	}
+
+	return cards
+}
+
+// Properties lists the unescaped values of every property of a card by
+// upper case name, for matching CardDAV query filters.
+func Properties(data []byte) map[string][]string {
+	props := map[string][]string{}
+	for _, l := range unfold(data) {
+		if name, _, value, ok := parse(l); ok {
+			props[name] = append(props[name], unescape(value))
+		}
+	}
+
+	return props
+}
+
+// birthday accepts the basic and extended ISO 8601 dates clients send and
+// keeps the YYYY-MM-DD form the phonebook uses.
+func birthday(v string) string {
+	v = strings.TrimSpace(v)
+	if i := strings.Index(v, "T"); i > 0 {
+		v = v[:i]
+	}
+
+	for _, layout := range []string{"2006-01-02", "20060102"} {
+		if t, err := time.Parse(layout, v); err == nil {
+			return t.Format("2006-01-02")
+		}
+	}
+
+	return ""
+}
+
+type writer struct {
+	bytes.Buffer
+}
+
+// line writes a content line folded at 75 octets.
+func (w *writer) line(name string, types []string, value string) {
+	l := name
+	t := []string{}
+	for _, s := range types {
+		if s != "" {
+			t = append(t, s)
+		}
+	}
+	if len(t) > 0 {
+		l += ";TYPE=" + strings.Join(t, ",")
+	}
+	l += ":" + value
+
+	for len(l) > 75 {
+		n := 75
+		for !utf8.RuneStart(l[n]) {
+			n--
+		}
+		w.WriteString(l[:n] + "\r\n ")
+		l = l[n:]
+	}
+	w.WriteString(l + "\r\n")
+}
+
+func unfold(data []byte) []string {
+	lines := []string{}
+	for _, l := range strings.Split(strings.Replace(string(data), "\r\n", "\n", -1), "\n") {
+		if (strings.HasPrefix(l, " ") || strings.HasPrefix(l, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += l[1:]
+			continue
+		}
+		lines = append(lines, l)
+	}
+
+	return lines
+}
+
+// parse splits a content line into its upper case name without group,
+// the TYPE parameters and the raw value.
+func parse(l string) (string, []string, string, bool) {
+	quoted := false
+	i := -1
+	for n, r := range l {
+		if r == '"' {
+			quoted = !quoted
+		} else if r == ':' && !quoted {
+			i = n
+			break
+		}
+	}
+	if i <= 0 {
+		return "", nil, "", false
+	}
+
+	params := strings.Split(l[:i], ";")
+	name := strings.ToUpper(params[0])
+	if n := strings.LastIndex(name, "."); n >= 0 {
+		name = name[n+1:]
+	}
+
+	types := []string{}
+	for _, p := range params[1:] {
+		kv := strings.SplitN(p, "=", 2)
+		switch {
+		case len(kv) == 1:
+			// vCard 2.1 bare types, TEL;CELL:
+			types = append(types, strings.ToUpper(kv[0]))
+		case strings.EqualFold(kv[0], "TYPE"):
+			for _, t := range strings.Split(strings.Trim(kv[1], `"`), ",") {
+				types = append(types, strings.ToUpper(t))
+			}
+		}
+	}
+
+	return name, types, l[i+1:], true
+}
+
+func hasType(types []string, t string) bool {
+	for _, s := range types {
+		if s == t {
+			return true
+		}
+	}
+
+	return false
+}
+
+func match(table [][2]string, types []string) string {
+	for _, t := range types {
+		if v := lookup(table, t, 1, ""); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+func lookup(table [][2]string, v string, from int, def string) string {
+	for _, row := range table {
+		if strings.EqualFold(row[from], v) {
+			return row[1-from]
+		}
+	}
+
+	return def
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ",", `\,`, ";", `\;`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+func unescape(s string) string {
+	return strings.NewReplacer(`\\`, `\`, `\,`, ",", `\;`, ";", `\n`, "\n", `\N`, "\n").Replace(s)
+}
+
+func join(parts ...string) string {
+	for i, p := range parts {
+		parts[i] = escape(p)
+	}
+
+	return strings.Join(parts, ";")
+}
+
+// split divides a structured value on unescaped semicolons, padded to
+// seven components so callers can index the ADR parts.
+func split(v string) []string {
+	parts := []string{}
+	cur := ""
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v):
+			cur += v[i : i+2]
+			i++
+		case v[i] == ';':
+			parts = append(parts, unescape(cur))
+			cur = ""
+		default:
+			cur += v[i : i+1]
+		}
+	}
+	parts = append(parts, unescape(cur))
+
+	for len(parts) < 7 {
+		parts = append(parts, "")
+	}
+
+	return parts
+}
@@ -0,0 +1,108 @@
+package vcard
+
+import (
+	"strings"
+	"testing"
+
+	model "github.com/tmluthfiana/phonebook/model"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+func TestRoundTrip(t *testing.T) {
+	c := &model.Phonebook{
+		Id:        bson.NewObjectId(),
+		FirstName: "Tias",
+		LastName:  "Faluthi",
+		PhoneNumber: []model.PhoneNumberDetail{
+			{PhoneNo: "+62 21 555 0123", ProneType: "Work", PhoneExt: "12"},
+			{PhoneNo: "0812 999", ProneType: "Mobile"},
+		},
+		Emails: []model.EmailDetail{
+			{EmailAddress: "tias@example.com", EmailType: model.EmailTypeWork},
+			{EmailAddress: "tias@home.example", EmailType: model.EmailTypeHome},
+		},
+		Addresses:  []model.AddressDetail{{AddressType: "Work", Street: "Jl. Sudirman 1; Tower A", City: "Jakarta", Country: "Indonesia"}},
+		Company:    "Eaciit",
+		Department: "R&D",
+		JobTitle:   "Engineer",
+		Birthday:   "1990-02-03",
+		Notes:      "first line\nsecond, with comma and a rather long text that needs folding at seventy five octets",
+	}
+
+	data := Encode(c, "abc")
+	for _, l := range strings.Split(string(data), "\r\n") {
+		if len(l) > 75 {
+			t.Errorf("line not folded: %q", l)
+		}
+	}
+
+	d, uid, err := Decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uid != "abc" || d.FirstName != c.FirstName || d.LastName != c.LastName || d.Notes != c.Notes ||
+		d.Company != c.Company || d.Department != c.Department || d.JobTitle != c.JobTitle || d.Birthday != c.Birthday {
+		t.Errorf("unexpected contact %+v", d)
+	}
+
+	if len(d.PhoneNumber) != 2 || d.PhoneNumber[0] != c.PhoneNumber[0] || d.PhoneNumber[1] != c.PhoneNumber[1] {
+		t.Errorf("unexpected phones %+v", d.PhoneNumber)
+	}
+
+	if len(d.Emails) != 2 || d.Emails[0] != c.Emails[0] || d.Email != "tias@example.com" {
+		t.Errorf("unexpected emails %+v", d.Emails)
+	}
+
+	if len(d.Addresses) != 1 || d.Addresses[0] != c.Addresses[0] {
+		t.Errorf("unexpected addresses %+v", d.Addresses)
+	}
+}
+
+func TestDecodeClientCard(t *testing.T) {
+	card := "BEGIN:VCARD\r\nVERSION:3.0\r\nN:Dwi;Agil;;;\r\nFN:Agil Dwi\r\n" +
+		"item1.TEL;type=CELL;type=VOICE;type=pref:+62 812\r\n" +
+		"TEL;HOME:021 555\r\n" +
+		"EMAIL;type=INTERNET:agil@exa\r\n mple.com\r\n" +
+		"BDAY:19900203\r\nUID:1234-ABCD\r\nEND:VCARD\r\n"
+
+	d, uid, err := Decode([]byte(card))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uid != "1234-ABCD" || d.FirstName != "Agil" || d.LastName != "Dwi" || d.Birthday != "1990-02-03" {
+		t.Errorf("unexpected contact %+v", d)
+	}
+
+	if len(d.PhoneNumber) != 2 || d.PhoneNumber[0].ProneType != "Mobile" || d.PhoneNumber[1].ProneType != "Home" {
+		t.Errorf("unexpected phones %+v", d.PhoneNumber)
+	}
+
+	if d.Email != "agil@example.com" || d.Emails[0].EmailType != model.EmailTypeOther {
+		t.Errorf("unexpected emails %+v", d.Emails)
+	}
+
+	if _, _, err := Decode([]byte("BEGIN:VCARD\r\nFN:x\r\n")); err == nil {
+		t.Error("expected an error for a truncated card")
+	}
+}
+
+func TestSplit(t *testing.T) {
+	a := Encode(&model.Phonebook{FirstName: "Agil"}, "a")
+	b := Encode(&model.Phonebook{FirstName: "Budi"}, "b")
+	data := append(append([]byte("junk\r\n"), a...), b...)
+
+	cards := Split(data)
+	if len(cards) != 2 || string(cards[0]) != string(a) || string(cards[1]) != string(b) {
+		t.Fatalf("cards = %q", cards)
+	}
+
+	if _, uid, err := Decode(cards[1]); err != nil || uid != "b" {
+		t.Errorf("decode = %s, %v", uid, err)
+	}
+	if len(Split([]byte("BEGIN:VCARD\r\nFN:x\r\n"))) != 0 {
+		t.Error("truncated card returned")
+	}
+}
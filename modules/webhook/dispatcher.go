@@ -0,0 +1,307 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+	logger "github.com/tmluthfiana/phonebook/modules/logger"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+// Store is the part of the phonebook storage the dispatcher needs.
+type Store interface {
+	// Hooks are the webhooks of a tenant
+	Hooks(tenant string) ([]model.Webhook, error)
+	Hook(tenant string, id bson.ObjectId) (*model.Webhook, error)
+	// Due are the pending deliveries of all tenants whose next attempt has come
+	Due(now time.Time, limit int) ([]model.WebhookDelivery, error)
+	SaveDelivery(d *model.WebhookDelivery) error
+	// Prune deletes the delivered deliveries created before
+	Prune(before time.Time) error
+	// Events are the logged events of all tenants after the event id after
+	// and logged before, oldest first
+	Events(after bson.ObjectId, before time.Time, limit int) ([]events.Event, error)
+	// Cursor is the id of the last queued event, the newest logged event
+	// when none was queued yet; SaveCursor moves it
+	Cursor() (bson.ObjectId, error)
+	SaveCursor(id bson.ObjectId) error
+}
+
+const (
+	DefaultMaxAttempts = 8
+	DefaultBackoff     = 30 * time.Second
+	DefaultMaxBackoff  = 6 * time.Hour
+	DefaultTimeout     = 10 * time.Second
+	// DefaultLogLag keeps the queue that far behind the event log, so events
+	// logged just before the cursor but stored just after are not skipped
+	DefaultLogLag = 5 * time.Second
+	// logPage is how many logged events are read at once
+	logPage = 500
+)
+
+// Dispatcher queues a delivery per matching webhook for every logged event
+// and sends the due deliveries.
+type Dispatcher struct {
+	Store  Store
+	Client *http.Client
+	// MaxAttempts fails a delivery after that many tries
+	MaxAttempts int
+	// Backoff waits before the second try, doubling up to MaxBackoff
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	// Interval is how often logged events and due deliveries are looked for
+	Interval time.Duration
+	// LogLag keeps the queue behind the event log, see DefaultLogLag
+	LogLag time.Duration
+	// Retention keeps delivered deliveries that long, 0 keeps them forever
+	Retention time.Duration
+	// Workers is how many deliveries are sent at once
+	Workers   int
+	UserAgent string
+
+	wake chan struct{}
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	return &Dispatcher{
+		Store:       store,
+		Client:      NewClient(DefaultTimeout),
+		MaxAttempts: DefaultMaxAttempts,
+		Backoff:     DefaultBackoff,
+		MaxBackoff:  DefaultMaxBackoff,
+		Interval:    5 * time.Second,
+		LogLag:      DefaultLogLag,
+		Workers:     8,
+		UserAgent:   "phonebook-webhook",
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake looks for due deliveries right away, e.g. after a replay.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Enqueue stores a pending delivery of e for every webhook that wants it.
+func (d *Dispatcher) Enqueue(e events.Event) error {
+	hooks, err := d.Store.Hooks(e.TenantId)
+	if err != nil {
+		return err
+	}
+
+	var body []byte
+	p := Payload{Id: e.Id, Type: e.Type, TenantId: e.TenantId, Time: e.Time, Contact: e.Contact}
+	if p.Id == "" {
+		p.Id = bson.NewObjectId().Hex()
+	}
+	for i := range hooks {
+		if !Match(&hooks[i], e) {
+			continue
+		}
+
+		if body == nil {
+			if body, err = json.Marshal(p); err != nil {
+				return err
+			}
+		}
+
+		del := &model.WebhookDelivery{TenantId: e.TenantId, WebhookId: hooks[i].Id, EventId: p.Id, Event: e.Type,
+			ContactId: e.Contact.Id, Payload: string(body), Status: model.DeliveryPending, NextAttempt: time.Now()}
+		if err := d.Store.SaveDelivery(del); err != nil {
+			return err
+		}
+	}
+
+	if body != nil {
+		d.Wake()
+	}
+
+	return nil
+}
+
+// Deliver makes one attempt to send del and records the outcome.
+func (d *Dispatcher) Deliver(del *model.WebhookDelivery) error {
+	attempt := model.WebhookAttempt{Date: time.Now()}
+	// deliveries to missing or disabled webhooks fail until replayed
+	permanent := true
+
+	hook, err := d.Store.Hook(del.TenantId, del.WebhookId)
+	switch {
+	case err != nil:
+		attempt.Error = "Webhook not found"
+	case !hook.Enable:
+		attempt.Error = "Webhook is disabled"
+	default:
+		attempt, permanent = d.post(hook, del), false
+	}
+
+	del.Tries++
+	del.Attempts = append(del.Attempts, attempt)
+	switch {
+	case attempt.StatusCode >= 200 && attempt.StatusCode < 300:
+		del.Status = model.DeliveryDelivered
+	case permanent || del.Tries >= d.MaxAttempts:
+		del.Status = model.DeliveryFailed
+	default:
+		del.NextAttempt = time.Now().Add(d.backoff(del.Tries))
+	}
+
+	return d.Store.SaveDelivery(del)
+}
+
+func (d *Dispatcher) post(hook *model.Webhook, del *model.WebhookDelivery) model.WebhookAttempt {
+	start := time.Now()
+	attempt := model.WebhookAttempt{Date: start}
+
+	body := []byte(del.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", d.UserAgent)
+	req.Header.Set(EventHeader, del.Event)
+	req.Header.Set(DeliveryHeader, del.Id.Hex())
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, start, body))
+
+	res, err := d.Client.Do(req)
+	attempt.Duration = time.Since(start).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer res.Body.Close()
+
+	b, _ := io.ReadAll(io.LimitReader(res.Body, 512))
+	attempt.StatusCode = res.StatusCode
+	attempt.Response = string(b)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		attempt.Error = res.Status
+	}
+
+	return attempt
+}
+
+// backoff is the wait after the given number of tries, doubling from
+// Backoff with up to a tenth more so failed receivers are not hit at once.
+func (d *Dispatcher) backoff(tries int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < tries && wait < d.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > d.MaxBackoff {
+		wait = d.MaxBackoff
+	}
+
+	return wait + time.Duration(rand.Int63n(int64(wait)/10+1))
+}
+
+// DeliverDue sends the deliveries whose next attempt has come.
+func (d *Dispatcher) DeliverDue() error {
+	due, err := d.Store.Due(time.Now(), 100)
+	if err != nil {
+		return err
+	}
+
+	workers := make(chan struct{}, max(d.Workers, 1))
+	wg := sync.WaitGroup{}
+	for i := range due {
+		workers <- struct{}{}
+		wg.Add(1)
+		go func(del *model.WebhookDelivery) {
+			defer func() { <-workers; wg.Done() }()
+			if err := d.Deliver(del); err != nil {
+				logger.Error("Webhook delivery not saved", "delivery", del.Id.Hex(), "error", err)
+			}
+		}(&due[i])
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// QueueLogged enqueues the logged events after the cursor and moves the
+// cursor past them. Events that fail to queue stop the round with the cursor
+// before them, they are queued by the next round.
+func (d *Dispatcher) QueueLogged() error {
+	cursor, err := d.Store.Cursor()
+	if err != nil {
+		return err
+	}
+
+	for {
+		logged, err := d.Store.Events(cursor, time.Now().Add(-d.LogLag), logPage)
+		if err != nil {
+			return err
+		}
+
+		queued := cursor
+		for _, e := range logged {
+			if err = d.Enqueue(e); err != nil {
+				break
+			}
+			queued = bson.ObjectIdHex(e.Id)
+		}
+
+		if queued != cursor {
+			if err := d.Store.SaveCursor(queued); err != nil {
+				return err
+			}
+			cursor = queued
+		}
+		if err != nil {
+			return err
+		}
+
+		if len(logged) < logPage {
+			return nil
+		}
+	}
+}
+
+// Start queues the logged events and sends the deliveries until stop is
+// closed. Reading the event log from a stored cursor, rather than following
+// the live events, keeps events a slow database or a restart would lose.
+func (d *Dispatcher) Start(stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(d.Interval)
+		defer ticker.Stop()
+		pruned := time.Time{}
+
+		for {
+			if err := d.QueueLogged(); err != nil {
+				logger.Error("Webhook deliveries not queued", "error", err)
+			}
+
+			if err := d.DeliverDue(); err != nil {
+				logger.Error("Webhook deliveries failed", "error", err)
+			}
+
+			if d.Retention > 0 && time.Since(pruned) > time.Hour {
+				pruned = time.Now()
+				if err := d.Store.Prune(pruned.Add(-d.Retention)); err != nil {
+					logger.Error("Webhook delivery log not pruned", "error", err)
+				}
+			}
+
+			select {
+			case <-ticker.C:
+			case <-d.wake:
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// ErrPrivateAddress refuses receivers on loopback, private or link-local
+// addresses, webhooks must not reach into the network of the phonebook.
+var ErrPrivateAddress = errors.New("Webhook receivers must not be on a loopback, private or link-local address")
+
+// resolveTimeout bounds the lookup of CheckURL.
+const resolveTimeout = 5 * time.Second
+
+// PublicIP reports whether ip may receive webhooks.
+func PublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast() && !ip.IsUnspecified()
+}
+
+// CheckURL resolves the host of rawurl and refuses it when one of its
+// addresses is not public. The delivery client checks again when it
+// connects, the host may resolve differently by then.
+func CheckURL(rawurl string) error {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return errors.New("Webhook host " + u.Hostname() + " cannot be resolved")
+	}
+
+	for _, a := range addrs {
+		if !PublicIP(a.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+// NewClient is the http.Client of deliveries, it only connects to public
+// addresses, redirects included, and ignores the proxy settings so no proxy
+// connects for it.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, Control: dialPublic}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// dialPublic is the net.Dialer Control refusing addresses that are not
+// public, it sees the address after resolution.
+func dialPublic(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !PublicIP(ip) {
+		return ErrPrivateAddress
+	}
+
+	return nil
+}
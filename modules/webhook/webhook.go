@@ -0,0 +1,132 @@
+// Package webhook posts the changes of contacts to subscribed URLs, signed
+// with the secret of the subscription and retried with exponential backoff
+// until the receiver answers 2xx.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+)
+
+// The headers of a delivery
+const (
+	SignatureHeader = "X-Phonebook-Signature"
+	EventHeader     = "X-Phonebook-Event"
+	DeliveryHeader  = "X-Phonebook-Delivery"
+)
+
+// EventTypes are the events a webhook can subscribe to.
+var EventTypes = []string{events.Created, events.Updated, events.Deleted}
+
+// Payload is the JSON body of a delivery.
+type Payload struct {
+	// Id identifies the event, receivers drop repeated deliveries by it
+	Id       string
+	Type     string
+	TenantId string
+	Time     time.Time
+	Contact  model.Phonebook
+}
+
+// NewSecret creates a random signing secret.
+func NewSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+// Sign is the X-Phonebook-Signature of body sent at t, the HMAC-SHA256 of
+// "<unix time>.<body>" with the secret:
+//
+//	t=1700000000,v1=5257a869...
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+
+	return "t=" + ts + ",v1=" + mac(secret, ts, body)
+}
+
+func mac(secret, ts string, body []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(ts + "."))
+	h.Write(body)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+var ErrSignature = errors.New("webhook signature mismatch")
+
+// Verify checks the signature header of a received body, refusing
+// signatures older than tolerance so captured requests can't be replayed.
+func Verify(secret, header string, body []byte, tolerance time.Duration, now time.Time) error {
+	ts, sigs := "", []string{}
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sigs = append(sigs, v)
+		}
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrSignature
+	}
+	if age := now.Sub(time.Unix(sec, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("webhook signature is %v old", age.Round(time.Second))
+	}
+
+	want := mac(secret, ts, body)
+	for _, sig := range sigs {
+		if hmac.Equal([]byte(sig), []byte(want)) {
+			return nil
+		}
+	}
+
+	return ErrSignature
+}
+
+// Match reports whether the webhook wants e, webhooks only see the
+// contacts of the shared directory.
+func Match(h *model.Webhook, e events.Event) bool {
+	c := &e.Contact
+	if !h.Enable || h.TenantId != e.TenantId || c.AddressBookId != "" {
+		return false
+	}
+
+	if len(h.Events) > 0 && !contains(h.Events, e.Type) {
+		return false
+	}
+
+	if h.Company != "" && !strings.EqualFold(h.Company, c.Company) {
+		return false
+	}
+
+	return (h.OrganizationId == "" || h.OrganizationId == c.OrganizationId) &&
+		(h.DepartmentId == "" || h.DepartmentId == c.DepartmentId) &&
+		(h.LocationId == "" || h.LocationId == c.LocationId)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,296 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	model "github.com/tmluthfiana/phonebook/model"
+	events "github.com/tmluthfiana/phonebook/modules/events"
+
+	"gopkg.in/mgo.v2/bson"
+)
+
+type memoryStore struct {
+	lock       sync.Mutex
+	hooks      []model.Webhook
+	deliveries map[bson.ObjectId]model.WebhookDelivery
+	logged     []events.Event
+	cursor     bson.ObjectId
+	// down fails Hooks like a database that went away
+	down bool
+}
+
+func (m *memoryStore) Hooks(tenant string) ([]model.Webhook, error) {
+	if m.down {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	return m.hooks, nil
+}
+
+func (m *memoryStore) Hook(tenant string, id bson.ObjectId) (*model.Webhook, error) {
+	for i := range m.hooks {
+		if m.hooks[i].Id == id {
+			return &m.hooks[i], nil
+		}
+	}
+
+	return nil, io.EOF
+}
+
+func (m *memoryStore) Due(now time.Time, limit int) ([]model.WebhookDelivery, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	due := []model.WebhookDelivery{}
+	for _, d := range m.deliveries {
+		if d.Status == model.DeliveryPending && !d.NextAttempt.After(now) {
+			due = append(due, d)
+		}
+	}
+
+	return due, nil
+}
+
+func (m *memoryStore) SaveDelivery(d *model.WebhookDelivery) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if d.Id == "" {
+		d.Id = bson.NewObjectId()
+	}
+	m.deliveries[d.Id] = *d
+
+	return nil
+}
+
+func (m *memoryStore) Prune(before time.Time) error {
+	return nil
+}
+
+func (m *memoryStore) Events(after bson.ObjectId, before time.Time, limit int) ([]events.Event, error) {
+	res := []events.Event{}
+	for _, e := range m.logged {
+		id := bson.ObjectIdHex(e.Id)
+		if id > after && id.Time().Before(before) && len(res) < limit {
+			res = append(res, e)
+		}
+	}
+
+	return res, nil
+}
+
+func (m *memoryStore) Cursor() (bson.ObjectId, error) {
+	return m.cursor, nil
+}
+
+func (m *memoryStore) SaveCursor(id bson.ObjectId) error {
+	m.cursor = id
+	return nil
+}
+
+func TestSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	body := []byte(`{"Type":"contact.created"}`)
+	sig := Sign("whsec_x", now, body)
+
+	if err := Verify("whsec_x", sig, body, time.Minute, now.Add(30*time.Second)); err != nil {
+		t.Error(err)
+	}
+	for name, err := range map[string]error{
+		"other secret": Verify("whsec_y", sig, body, time.Minute, now),
+		"other body":   Verify("whsec_x", sig, []byte(`{}`), time.Minute, now),
+		"too old":      Verify("whsec_x", sig, body, time.Minute, now.Add(2*time.Minute)),
+		"no timestamp": Verify("whsec_x", "v1=abc", body, time.Minute, now),
+	} {
+		if err == nil {
+			t.Errorf("%s verified", name)
+		}
+	}
+
+	if s, err := NewSecret(); err != nil || len(s) != 54 {
+		t.Errorf("secret %q, %v", s, err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	dept := bson.NewObjectId()
+	h := &model.Webhook{TenantId: "acme", Enable: true, Events: []string{events.Deleted}, DepartmentId: dept, Company: "Eaciit"}
+	e := events.Event{Type: events.Deleted, TenantId: "acme", Contact: model.Phonebook{DepartmentId: dept, Company: "eaciit"}}
+
+	if !Match(h, e) {
+		t.Error("matching event refused")
+	}
+
+	for name, change := range map[string]func(e *events.Event){
+		"other type":   func(e *events.Event) { e.Type = events.Created },
+		"other tenant": func(e *events.Event) { e.TenantId = "other" },
+		"address book": func(e *events.Event) { e.Contact.AddressBookId = bson.NewObjectId() },
+		"department":   func(e *events.Event) { e.Contact.DepartmentId = "" },
+		"company":      func(e *events.Event) { e.Contact.Company = "Acme" },
+	} {
+		other := e
+		change(&other)
+		if Match(h, other) {
+			t.Errorf("%s matched", name)
+		}
+	}
+}
+
+func TestDispatcher(t *testing.T) {
+	var lock sync.Mutex
+	answers := []int{500, 200}
+	received := []*http.Request{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		if err := Verify("whsec_x", r.Header.Get(SignatureHeader), body, time.Minute, time.Now()); err != nil {
+			t.Error(err)
+		}
+		p := Payload{}
+		if err := json.Unmarshal(body, &p); err != nil || p.Contact.FirstName != "Tias" {
+			t.Errorf("payload %s", body)
+		}
+		received = append(received, r)
+
+		w.WriteHeader(answers[0])
+		answers = answers[1:]
+	}))
+	defer srv.Close()
+
+	hook := model.Webhook{Id: bson.NewObjectId(), TenantId: "acme", Url: srv.URL, Secret: "whsec_x", Enable: true}
+	off := model.Webhook{Id: bson.NewObjectId(), TenantId: "acme", Url: srv.URL, Secret: "whsec_x", Enable: true, Events: []string{events.Updated}}
+	store := &memoryStore{hooks: []model.Webhook{hook, off}, deliveries: map[bson.ObjectId]model.WebhookDelivery{}}
+
+	d := NewDispatcher(store)
+	d.Backoff = time.Hour
+	// the test receiver listens on loopback, which the delivery client refuses
+	d.Client = srv.Client()
+
+	if err := d.Enqueue(events.Event{Type: events.Created, TenantId: "acme", Contact: model.Phonebook{FirstName: "Tias"}}); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.deliveries) != 1 {
+		t.Fatalf("%d deliveries queued", len(store.deliveries))
+	}
+
+	// the first try fails and waits an hour
+	d.DeliverDue()
+	del := onlyDelivery(store)
+	if del.Status != model.DeliveryPending || del.Tries != 1 || del.Attempts[0].StatusCode != 500 || time.Until(del.NextAttempt) < 50*time.Minute {
+		t.Errorf("after a failure %+v", del)
+	}
+	d.DeliverDue()
+	if len(received) != 1 {
+		t.Errorf("retried before the backoff, %d requests", len(received))
+	}
+
+	del.NextAttempt = time.Now()
+	store.SaveDelivery(&del)
+	d.DeliverDue()
+	if del = onlyDelivery(store); del.Status != model.DeliveryDelivered || len(del.Attempts) != 2 || received[1].Header.Get(EventHeader) != events.Created {
+		t.Errorf("after a success %+v", del)
+	}
+
+	// the last try fails the delivery for good
+	d.MaxAttempts = 1
+	store.hooks[0].Enable = false
+	store.SaveDelivery(&model.WebhookDelivery{TenantId: "acme", WebhookId: hook.Id, Payload: "{}", Status: model.DeliveryPending})
+	d.DeliverDue()
+	failed := 0
+	for _, del := range store.deliveries {
+		if del.Status == model.DeliveryFailed && del.Attempts[0].Error == "Webhook is disabled" {
+			failed++
+		}
+	}
+	if failed != 1 || len(received) != 2 {
+		t.Errorf("%d failed, %d requests", failed, len(received))
+	}
+}
+
+func onlyDelivery(m *memoryStore) model.WebhookDelivery {
+	for _, d := range m.deliveries {
+		return d
+	}
+
+	return model.WebhookDelivery{}
+}
+
+func TestBackoff(t *testing.T) {
+	d := NewDispatcher(nil)
+	for tries, want := range map[int]time.Duration{1: 30 * time.Second, 2: time.Minute, 4: 4 * time.Minute, 30: 6 * time.Hour} {
+		if got := d.backoff(tries); got < want || got > want+want/10 {
+			t.Errorf("backoff(%d) = %v", tries, got)
+		}
+	}
+}
+
+func TestPrivateAddress(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("loopback receiver reached")
+	}))
+	defer srv.Close()
+
+	if _, err := NewClient(time.Second).Post(srv.URL, "application/json", nil); !errors.Is(err, ErrPrivateAddress) {
+		t.Errorf("loopback dialed, %v", err)
+	}
+
+	for _, u := range []string{"http://127.0.0.1/", "http://localhost:8080/", "http://10.0.0.1/", "http://192.168.1.1/",
+		"http://169.254.169.254/", "http://[::1]/", "http://[fe80::1]/", "http://0.0.0.0/"} {
+		if err := CheckURL(u); err == nil {
+			t.Errorf("%s accepted", u)
+		}
+	}
+	if err := CheckURL("https://203.0.113.10/hooks"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestQueueLogged(t *testing.T) {
+	hook := model.Webhook{Id: bson.NewObjectId(), TenantId: "acme", Url: "https://203.0.113.10/", Enable: true}
+	store := &memoryStore{hooks: []model.Webhook{hook}, deliveries: map[bson.ObjectId]model.WebhookDelivery{}}
+
+	now := time.Now()
+	logged := func(ago time.Duration) events.Event {
+		e := events.Event{Id: bson.NewObjectIdWithTime(now.Add(-ago)).Hex(), Type: events.Created, TenantId: "acme"}
+		store.logged = append(store.logged, e)
+		return e
+	}
+	before := logged(time.Hour)
+	store.cursor = bson.ObjectIdHex(before.Id)
+	first, second := logged(time.Minute), logged(30*time.Second)
+	recent := logged(0)
+
+	d := NewDispatcher(store)
+	if err := d.QueueLogged(); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.deliveries) != 2 || store.cursor.Hex() != second.Id {
+		t.Errorf("%d queued up to %s, want %s and %s", len(store.deliveries), store.cursor.Hex(), first.Id, second.Id)
+	}
+
+	// the event within the lag waited, a failure keeps the cursor before it
+	d.LogLag = -time.Minute
+	store.down = true
+	if err := d.QueueLogged(); err == nil || store.cursor.Hex() != second.Id {
+		t.Errorf("cursor moved to %s, %v", store.cursor.Hex(), err)
+	}
+
+	store.down = false
+	if err := d.QueueLogged(); err != nil || len(store.deliveries) != 3 || store.cursor.Hex() != recent.Id {
+		t.Errorf("%d queued up to %s, %v", len(store.deliveries), store.cursor.Hex(), err)
+	}
+	for _, del := range store.deliveries {
+		if del.EventId == before.Id {
+			t.Error("event before the cursor queued")
+		}
+	}
+}
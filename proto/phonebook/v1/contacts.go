@@ -0,0 +1,371 @@
+// Package phonebookv1 holds the messages of contacts.proto, encoded by
+// hand for the gRPC server. Keep them, File and the .proto source in step,
+// the test compares the fields.
+package phonebookv1
+
+import (
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+)
+
+type Contact struct {
+	Id             string
+	FirstName      string
+	LastName       string
+	PhoneNumbers   []*PhoneNumber
+	Emails         []*Email
+	Addresses      []*Address
+	JobTitle       string
+	Company        string
+	Department     string
+	Website        string
+	Birthday       string
+	Notes          string
+	OrganizationId string
+	DepartmentId   string
+	LocationId     string
+	ManagerId      string
+	AddressBookId  string
+	Status         string
+	CreatedDate    string
+	CreatedBy      string
+	UpdateDate     string
+	UpdateBy       string
+	PhotoUrl       string
+}
+
+func (m *Contact) Marshal(e *grpc.Encoder) {
+	e.String(1, m.Id)
+	e.String(2, m.FirstName)
+	e.String(3, m.LastName)
+	for _, p := range m.PhoneNumbers {
+		e.Message(4, p)
+	}
+	for _, p := range m.Emails {
+		e.Message(5, p)
+	}
+	for _, p := range m.Addresses {
+		e.Message(6, p)
+	}
+	e.String(7, m.JobTitle)
+	e.String(8, m.Company)
+	e.String(9, m.Department)
+	e.String(10, m.Website)
+	e.String(11, m.Birthday)
+	e.String(12, m.Notes)
+	e.String(13, m.OrganizationId)
+	e.String(14, m.DepartmentId)
+	e.String(15, m.LocationId)
+	e.String(16, m.ManagerId)
+	e.String(17, m.AddressBookId)
+	e.String(18, m.Status)
+	e.String(19, m.CreatedDate)
+	e.String(20, m.CreatedBy)
+	e.String(21, m.UpdateDate)
+	e.String(22, m.UpdateBy)
+	e.String(23, m.PhotoUrl)
+}
+
+func (m *Contact) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Id = d.String()
+		case 2:
+			m.FirstName = d.String()
+		case 3:
+			m.LastName = d.String()
+		case 4:
+			p := &PhoneNumber{}
+			d.Message(p)
+			m.PhoneNumbers = append(m.PhoneNumbers, p)
+		case 5:
+			p := &Email{}
+			d.Message(p)
+			m.Emails = append(m.Emails, p)
+		case 6:
+			p := &Address{}
+			d.Message(p)
+			m.Addresses = append(m.Addresses, p)
+		case 7:
+			m.JobTitle = d.String()
+		case 8:
+			m.Company = d.String()
+		case 9:
+			m.Department = d.String()
+		case 10:
+			m.Website = d.String()
+		case 11:
+			m.Birthday = d.String()
+		case 12:
+			m.Notes = d.String()
+		case 13:
+			m.OrganizationId = d.String()
+		case 14:
+			m.DepartmentId = d.String()
+		case 15:
+			m.LocationId = d.String()
+		case 16:
+			m.ManagerId = d.String()
+		case 17:
+			m.AddressBookId = d.String()
+		case 18:
+			m.Status = d.String()
+		case 19:
+			m.CreatedDate = d.String()
+		case 20:
+			m.CreatedBy = d.String()
+		case 21:
+			m.UpdateDate = d.String()
+		case 22:
+			m.UpdateBy = d.String()
+		case 23:
+			m.PhotoUrl = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+type PhoneNumber struct {
+	Number string
+	Type   string
+	Ext    string
+}
+
+func (m *PhoneNumber) Marshal(e *grpc.Encoder) {
+	e.String(1, m.Number)
+	e.String(2, m.Type)
+	e.String(3, m.Ext)
+}
+
+func (m *PhoneNumber) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Number = d.String()
+		case 2:
+			m.Type = d.String()
+		case 3:
+			m.Ext = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+type Email struct {
+	Address string
+	Type    string
+}
+
+func (m *Email) Marshal(e *grpc.Encoder) {
+	e.String(1, m.Address)
+	e.String(2, m.Type)
+}
+
+func (m *Email) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Address = d.String()
+		case 2:
+			m.Type = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+type Address struct {
+	Type       string
+	Street     string
+	City       string
+	Region     string
+	PostalCode string
+	Country    string
+}
+
+func (m *Address) Marshal(e *grpc.Encoder) {
+	e.String(1, m.Type)
+	e.String(2, m.Street)
+	e.String(3, m.City)
+	e.String(4, m.Region)
+	e.String(5, m.PostalCode)
+	e.String(6, m.Country)
+}
+
+func (m *Address) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Type = d.String()
+		case 2:
+			m.Street = d.String()
+		case 3:
+			m.City = d.String()
+		case 4:
+			m.Region = d.String()
+		case 5:
+			m.PostalCode = d.String()
+		case 6:
+			m.Country = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+// IdRequest is GetContactRequest and DeleteContactRequest.
+type IdRequest struct {
+	Id string
+}
+
+func (m *IdRequest) Marshal(e *grpc.Encoder) {
+	e.String(1, m.Id)
+}
+
+func (m *IdRequest) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		if d.Field() == 1 {
+			m.Id = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+// ContactRequest is CreateContactRequest and UpdateContactRequest.
+type ContactRequest struct {
+	Contact *Contact
+}
+
+func (m *ContactRequest) Marshal(e *grpc.Encoder) {
+	if m.Contact != nil {
+		e.Message(1, m.Contact)
+	}
+}
+
+func (m *ContactRequest) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		if d.Field() == 1 {
+			m.Contact = &Contact{}
+			d.Message(m.Contact)
+		}
+	}
+
+	return d.Err()
+}
+
+// ListContactsRequest is also WatchContactsRequest, which has no limit.
+type ListContactsRequest struct {
+	AddressBookId string
+	Limit         int32
+}
+
+func (m *ListContactsRequest) Marshal(e *grpc.Encoder) {
+	e.String(1, m.AddressBookId)
+	e.Int(2, int64(m.Limit))
+}
+
+func (m *ListContactsRequest) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.AddressBookId = d.String()
+		case 2:
+			m.Limit = int32(d.Int())
+		}
+	}
+
+	return d.Err()
+}
+
+// EventType is ContactEvent.Type
+type EventType int32
+
+const (
+	EventUnspecified EventType = 0
+	EventCreated     EventType = 1
+	EventUpdated     EventType = 2
+	EventDeleted     EventType = 3
+)
+
+type ContactEvent struct {
+	Type    EventType
+	Contact *Contact
+	Time    string
+}
+
+func (m *ContactEvent) Marshal(e *grpc.Encoder) {
+	e.Int(1, int64(m.Type))
+	if m.Contact != nil {
+		e.Message(2, m.Contact)
+	}
+	e.String(3, m.Time)
+}
+
+func (m *ContactEvent) Unmarshal(d *grpc.Decoder) error {
+	for d.Next() {
+		switch d.Field() {
+		case 1:
+			m.Type = EventType(d.Int())
+		case 2:
+			m.Contact = &Contact{}
+			d.Message(m.Contact)
+		case 3:
+			m.Time = d.String()
+		}
+	}
+
+	return d.Err()
+}
+
+func str(name string, number int) *grpc.Field {
+	return &grpc.Field{Name: name, Number: number, Type: grpc.TypeString}
+}
+
+// File is contacts.proto for the server reflection.
+var File = &grpc.File{
+	Name:              "phonebook/v1/contacts.proto",
+	Package:           "phonebook.v1",
+	GoPackage:         "github.com/tmluthfiana/phonebook/proto/phonebook/v1;phonebookv1",
+	JavaPackage:       "com.github.tmluthfiana.phonebook.v1",
+	JavaMultipleFiles: true,
+	Messages: []*grpc.MessageType{
+		{Name: "Contact", Fields: []*grpc.Field{
+			str("id", 1), str("first_name", 2), str("last_name", 3),
+			{Name: "phone_numbers", Number: 4, Type: grpc.TypeMessage, TypeName: "PhoneNumber", Repeated: true},
+			{Name: "emails", Number: 5, Type: grpc.TypeMessage, TypeName: "Email", Repeated: true},
+			{Name: "addresses", Number: 6, Type: grpc.TypeMessage, TypeName: "Address", Repeated: true},
+			str("job_title", 7), str("company", 8), str("department", 9), str("website", 10),
+			str("birthday", 11), str("notes", 12), str("organization_id", 13), str("department_id", 14),
+			str("location_id", 15), str("manager_id", 16), str("address_book_id", 17), str("status", 18),
+			str("created_date", 19), str("created_by", 20), str("update_date", 21), str("update_by", 22),
+			str("photo_url", 23),
+		}},
+		{Name: "PhoneNumber", Fields: []*grpc.Field{str("number", 1), str("type", 2), str("ext", 3)}},
+		{Name: "Email", Fields: []*grpc.Field{str("address", 1), str("type", 2)}},
+		{Name: "Address", Fields: []*grpc.Field{str("type", 1), str("street", 2), str("city", 3),
+			str("region", 4), str("postal_code", 5), str("country", 6)}},
+		{Name: "GetContactRequest", Fields: []*grpc.Field{str("id", 1)}},
+		{Name: "ListContactsRequest", Fields: []*grpc.Field{str("address_book_id", 1),
+			{Name: "limit", Number: 2, Type: grpc.TypeInt32}}},
+		{Name: "CreateContactRequest", Fields: []*grpc.Field{{Name: "contact", Number: 1, Type: grpc.TypeMessage, TypeName: "Contact"}}},
+		{Name: "UpdateContactRequest", Fields: []*grpc.Field{{Name: "contact", Number: 1, Type: grpc.TypeMessage, TypeName: "Contact"}}},
+		{Name: "DeleteContactRequest", Fields: []*grpc.Field{str("id", 1)}},
+		{Name: "WatchContactsRequest", Fields: []*grpc.Field{str("address_book_id", 1)}},
+		{Name: "ContactEvent", Fields: []*grpc.Field{
+			{Name: "type", Number: 1, Type: grpc.TypeEnum, TypeName: "ContactEvent.Type"},
+			{Name: "contact", Number: 2, Type: grpc.TypeMessage, TypeName: "Contact"},
+			str("time", 3),
+		}, Enums: []*grpc.EnumType{{Name: "Type", Values: []string{"TYPE_UNSPECIFIED", "CREATED", "UPDATED", "DELETED"}}}},
+	},
+	Services: []*grpc.Service{{Name: "Contacts", Methods: []*grpc.Method{
+		{Name: "Get", Input: "GetContactRequest", Output: "Contact"},
+		{Name: "List", Input: "ListContactsRequest", Output: "Contact", ServerStreaming: true},
+		{Name: "Create", Input: "CreateContactRequest", Output: "Contact"},
+		{Name: "Update", Input: "UpdateContactRequest", Output: "Contact"},
+		{Name: "Delete", Input: "DeleteContactRequest", Output: "Contact"},
+		{Name: "Watch", Input: "WatchContactsRequest", Output: "ContactEvent", ServerStreaming: true},
+	}}},
+}
@@ -0,0 +1,79 @@
+package phonebookv1
+
+import (
+	"os"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+)
+
+// TestFileMatchesProto keeps File in step with contacts.proto.
+func TestFileMatchesProto(t *testing.T) {
+	src, err := os.ReadFile("contacts.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := regexp.MustCompile(`^message (\w+) \{`)
+	field := regexp.MustCompile(`^(repeated )?(\w+) (\w+) = (\d+);`)
+	rpc := regexp.MustCompile(`^rpc (\w+)\((\w+)\) returns \((stream )?(\w+)\);`)
+
+	fromProto, fromFile := map[string][]string{}, map[string][]string{}
+	current := ""
+	for _, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if m := message.FindStringSubmatch(line); m != nil {
+			current = m[1]
+		} else if m := field.FindStringSubmatch(line); m != nil {
+			fromProto[current] = append(fromProto[current], m[1]+m[2]+" "+m[3]+" = "+m[4])
+		} else if m := rpc.FindStringSubmatch(line); m != nil {
+			fromProto["rpc"] = append(fromProto["rpc"], m[1]+" "+m[2]+" "+m[3]+m[4])
+		}
+	}
+
+	types := map[grpc.FieldType]string{grpc.TypeString: "string", grpc.TypeInt32: "int32"}
+	for _, m := range File.Messages {
+		for _, f := range m.Fields {
+			typ := types[f.Type]
+			if typ == "" {
+				typ = f.TypeName[strings.LastIndex(f.TypeName, ".")+1:]
+			}
+			if f.Repeated {
+				typ = "repeated " + typ
+			}
+			fromFile[m.Name] = append(fromFile[m.Name], typ+" "+f.Name+" = "+strconv.Itoa(f.Number))
+		}
+	}
+	for _, m := range File.Services[0].Methods {
+		stream := ""
+		if m.ServerStreaming {
+			stream = "stream "
+		}
+		fromFile["rpc"] = append(fromFile["rpc"], m.Name+" "+m.Input+" "+stream+m.Output)
+	}
+
+	if !reflect.DeepEqual(fromProto, fromFile) {
+		t.Errorf("contacts.proto\n%v\nFile\n%v", fromProto, fromFile)
+	}
+}
+
+func TestContactRoundTrip(t *testing.T) {
+	in := &ContactEvent{Type: EventUpdated, Time: "2024-01-02T03:04:05Z", Contact: &Contact{
+		Id: "abc", FirstName: "Tias", PhotoUrl: "/p",
+		PhoneNumbers: []*PhoneNumber{{Number: "0812", Type: "Mobile"}},
+		Emails:       []*Email{{Address: "t@example.com"}},
+		Addresses:    []*Address{{City: "Jakarta", PostalCode: "10110"}},
+	}}
+
+	out := &ContactEvent{}
+	if err := grpc.Unmarshal(grpc.Marshal(in), out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v", out.Contact)
+	}
+}
@@ -0,0 +1,70 @@
+// The interop test checks File and the hand encoded messages against
+// the vendored google.golang.org/protobuf.
+package phonebookv1
+
+import (
+	"reflect"
+	"testing"
+
+	grpc "github.com/tmluthfiana/phonebook/modules/grpc"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestInterop(t *testing.T) {
+	fdp := &descriptorpb.FileDescriptorProto{}
+	if err := proto.Unmarshal(File.Descriptor(), fdp); err != nil {
+		t.Fatal(err)
+	}
+	fd, err := protodesc.NewFile(fdp, new(protoregistry.Files))
+	if err != nil {
+		t.Fatalf("descriptor refused by protodesc: %v", err)
+	}
+
+	in := &ContactEvent{Type: EventUpdated, Time: "2024-01-02T03:04:05Z", Contact: &Contact{
+		Id: "abc", FirstName: "Tias", PhotoUrl: "/p",
+		PhoneNumbers: []*PhoneNumber{{Number: "0812", Type: "Mobile"}},
+		Emails:       []*Email{{Address: "t@example.com"}},
+		Addresses:    []*Address{{City: "Jakarta", PostalCode: "10110"}},
+	}}
+
+	// protobuf reads what we write, and we read what it writes back
+	m := dynamicpb.NewMessage(fd.Messages().ByName("ContactEvent"))
+	if err := proto.Unmarshal(grpc.Marshal(in), m); err != nil {
+		t.Fatal(err)
+	}
+	contact := m.Get(m.Descriptor().Fields().ByName("contact")).Message()
+	if name := contact.Get(contact.Descriptor().Fields().ByName("first_name")).String(); name != "Tias" {
+		t.Errorf("first_name = %q", name)
+	}
+	if phones := contact.Get(contact.Descriptor().Fields().ByName("phone_numbers")).List(); phones.Len() != 1 {
+		t.Errorf("%d phone numbers", phones.Len())
+	}
+	typ := m.Get(m.Descriptor().Fields().ByName("type")).Enum()
+	if value := m.Descriptor().Enums().ByName("Type").Values().ByNumber(typ); value == nil || value.Name() != "UPDATED" {
+		t.Errorf("type = %d", typ)
+	}
+
+	b, err := proto.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := &ContactEvent{}
+	if err := grpc.Unmarshal(b, out); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("got %+v", out.Contact)
+	}
+
+	for _, name := range []protoreflect.Name{"List", "Watch"} {
+		if method := fd.Services().ByName("Contacts").Methods().ByName(name); method == nil || !method.IsStreamingServer() {
+			t.Errorf("%s is not a server stream", name)
+		}
+	}
+}
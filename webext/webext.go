@@ -10,6 +10,22 @@ func RegisterClass() []interface{} {
 
 	ret := []interface{}{}
 	ret = append(ret, &Phonebook{base})
+	ret = append(ret, &Organization{base})
+	ret = append(ret, &Department{base})
+	ret = append(ret, &Location{base})
+	ret = append(ret, &Directory{base})
+	ret = append(ret, &Auth{base})
+	ret = append(ret, &Group{base})
+	ret = append(ret, &User{base})
+	ret = append(ret, &LdapSync{base})
+	ret = append(ret, &CardDav{base})
+	ret = append(ret, &Token{base})
+	ret = append(ret, &Tenant{base})
+	ret = append(ret, &AddressBook{base})
+	ret = append(ret, &Metrics{base})
+	ret = append(ret, &GraphQL{base})
+	ret = append(ret, &ContactService{base})
+	ret = append(ret, &Webhook{base})
 
 	return ret
 }